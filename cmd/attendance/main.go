@@ -0,0 +1,45 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+	_ "github.com/lib/pq"
+)
+
+// attendance marks the past bookings nobody checked in or out. It runs
+// nightly and leaves the current Europe/Rome day to the admins.
+func main() {
+	databaseUrl := os.Getenv("DATABASE_URL")
+	if databaseUrl == "" {
+		log.Fatal("DATABASE_URL is missing")
+	}
+	db, err := sql.Open("postgres", databaseUrl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		log.Fatal(err)
+	}
+
+	db.SetMaxIdleConns(0)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		log.Fatal(err)
+	}
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	marked, err := models.NewAttendanceRepository(db).MarkUnmarked(today)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("attendance marked %d bookings on %s", marked, time.Now())
+}
@@ -29,6 +29,7 @@ func main() {
 	cleanupQueries := []string{
 		"DELETE FROM sessions WHERE expires_at < now()",
 		"DELETE FROM events WHERE starts_at < now() - interval '1 months'",
+		"DELETE FROM waitlist_entries WHERE starts_at <= now()",
 	}
 
 	for _, query := range cleanupQueries {
@@ -0,0 +1,28 @@
+-- Migration: Per-instructor weekly availability
+-- Each row is a time range on a weekday (0 = Sunday ... 6 = Saturday, Europe/Rome local time)
+-- during which the instructor accepts bookings. An instructor without rows has no bookable slots.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.tables
+        WHERE table_schema = 'public' AND table_name = 'instructor_availability'
+    ) THEN
+        CREATE TABLE instructor_availability (
+            id SERIAL PRIMARY KEY,
+            instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+            weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+            start_time TIME NOT NULL,
+            end_time TIME NOT NULL,
+            CHECK (end_time > start_time)
+        );
+
+        -- Seed the previous hardcoded schedule (Monday-Saturday, last slot at 21:00)
+        -- only when the table is first created, so admin edits are never overwritten.
+        INSERT INTO instructor_availability (instructor_id, weekday, start_time, end_time)
+        SELECT i.id, d.weekday, TIME '07:00', TIME '22:00'
+        FROM instructors i
+        CROSS JOIN generate_series(1, 6) AS d(weekday);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_instructor_availability_instructor_id ON instructor_availability(instructor_id);
@@ -0,0 +1,16 @@
+-- Migration: Studio and instructor closures
+-- A closure blocks every slot between starts_on and ends_on (inclusive, Europe/Rome dates).
+-- instructor_id NULL means the whole studio is closed.
+-- Italian national holidays are built in and do not need rows here.
+CREATE TABLE IF NOT EXISTS closures (
+    id SERIAL PRIMARY KEY,
+    instructor_id INTEGER REFERENCES instructors(id) ON DELETE CASCADE,
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_closures_dates ON closures(starts_on, ends_on);
+CREATE INDEX IF NOT EXISTS idx_closures_instructor_id ON closures(instructor_id);
@@ -0,0 +1,25 @@
+-- Migration: Service catalog
+-- A service has its own duration and capacity weight. A booking without service
+-- is a standard one hour session with weight 1.
+-- A service without rows in service_instructors can be booked with every instructor.
+CREATE TABLE IF NOT EXISTS services (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0 AND duration_minutes <= 1440),
+    capacity_weight INTEGER NOT NULL DEFAULT 1 CHECK (capacity_weight > 0),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS service_instructors (
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+    PRIMARY KEY (service_id, instructor_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_instructors_instructor_id ON service_instructors(instructor_id);
+
+-- Bookings keep their own duration so that editing a service does not change past bookings
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS service_id INTEGER REFERENCES services(id) ON DELETE SET NULL;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS duration_minutes INTEGER NOT NULL DEFAULT 60;
@@ -0,0 +1,17 @@
+-- Migration: Waitlist for full slots
+-- Users waiting for capacity on an instructor's slot, served in creation order.
+-- Entries expire when the slot starts and are removed by the cleanup job.
+CREATE TABLE IF NOT EXISTS waitlist_entries (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+    service_id INTEGER REFERENCES services(id) ON DELETE CASCADE,
+    duration_minutes INTEGER NOT NULL DEFAULT 60,
+    starts_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_waitlist_user_instructor_time
+        UNIQUE (user_id, instructor_id, starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_instructor_starts_at ON waitlist_entries(instructor_id, starts_at);
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_user_id ON waitlist_entries(user_id);
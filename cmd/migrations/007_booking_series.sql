@@ -0,0 +1,19 @@
+-- Migration: Recurring weekly bookings
+-- A series books the same weekday and time with an instructor every week.
+-- Each occurrence is a regular SIMPLE booking linked back to its series.
+CREATE TABLE IF NOT EXISTS booking_series (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+    service_id INTEGER REFERENCES services(id) ON DELETE SET NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (until >= starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_series_user_id ON booking_series(user_id);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS series_id BIGINT REFERENCES booking_series(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_bookings_series_id ON bookings(series_id);
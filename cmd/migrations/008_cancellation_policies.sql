@@ -0,0 +1,30 @@
+-- Migration: Cancellation policies
+-- A policy decides whether a cancelled booking gives its access back.
+-- Cancelling at least cutoff_hours before the start is always refunded; later
+-- cancellations follow late_refund: NONE never refunds, PARTIAL refunds up to
+-- free_late_cancels late cancellations per calendar month, FULL always refunds.
+-- A policy with a sub_type applies to the users of that subscription type.
+CREATE TABLE IF NOT EXISTS cancellation_policies (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    sub_type VARCHAR(50) UNIQUE,
+    cutoff_hours INTEGER NOT NULL DEFAULT 3 CHECK (cutoff_hours >= 0 AND cutoff_hours <= 720),
+    late_refund VARCHAR(10) NOT NULL DEFAULT 'NONE' CHECK (late_refund IN ('NONE', 'PARTIAL', 'FULL')),
+    free_late_cancels INTEGER NOT NULL DEFAULT 0 CHECK (free_late_cancels >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Keep the previous behaviour: refund only when cancelling 3+ hours before
+INSERT INTO cancellation_policies (name, sub_type)
+VALUES ('Condiviso', 'SHARED'), ('Singolo', 'SINGLE')
+ON CONFLICT (sub_type) DO NOTHING;
+
+-- Cancellation events record the outcome and the policy that produced it.
+-- policy_reason is a snapshot, so editing a policy does not rewrite history.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS refunded BOOLEAN;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS late_cancel BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS policy_id INTEGER REFERENCES cancellation_policies(id) ON DELETE SET NULL;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS policy_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_events_user_id_occurred_at ON events(user_id, occurred_at);
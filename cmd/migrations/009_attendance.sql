@@ -0,0 +1,18 @@
+-- Migration: Attendance tracking
+-- SIMPLE bookings record whether the member came. NULL means not marked yet;
+-- the nightly attendance job marks past bookings following the policy of the
+-- member's subscription type.
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS attendance VARCHAR(20)
+    CHECK (attendance IN ('ATTENDED', 'NO_SHOW', 'LATE_CANCEL'));
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS attendance_marked_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_bookings_user_id_attendance ON bookings(user_id, attendance);
+
+-- unmarked_attendance is what the nightly job assumes for unmarked bookings.
+-- With max_no_shows > 0 members reaching that many no-shows within
+-- no_show_window_days cannot book until older no-shows fall out of the window.
+ALTER TABLE cancellation_policies ADD COLUMN IF NOT EXISTS unmarked_attendance VARCHAR(20) NOT NULL DEFAULT 'ATTENDED'
+    CHECK (unmarked_attendance IN ('ATTENDED', 'NO_SHOW'));
+ALTER TABLE cancellation_policies ADD COLUMN IF NOT EXISTS max_no_shows INTEGER NOT NULL DEFAULT 0 CHECK (max_no_shows >= 0);
+ALTER TABLE cancellation_policies ADD COLUMN IF NOT EXISTS no_show_window_days INTEGER NOT NULL DEFAULT 30
+    CHECK (no_show_window_days > 0 AND no_show_window_days <= 365);
@@ -0,0 +1,39 @@
+-- Migration: Group classes
+-- A class template describes a recurring group class (pilates, functional...)
+-- and a class session is one scheduled occurrence with its own capacity.
+-- While a session runs its instructor takes no regular bookings.
+CREATE TABLE IF NOT EXISTS class_templates (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    room VARCHAR(255) NOT NULL DEFAULT '',
+    instructor_id INTEGER REFERENCES instructors(id) ON DELETE SET NULL,
+    capacity INTEGER NOT NULL CHECK (capacity > 0),
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0 AND duration_minutes <= 1440),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS class_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    template_id INTEGER NOT NULL REFERENCES class_templates(id) ON DELETE CASCADE,
+    instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+    room VARCHAR(255) NOT NULL DEFAULT '',
+    capacity INTEGER NOT NULL CHECK (capacity > 0),
+    starts_at TIMESTAMPTZ NOT NULL,
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0 AND duration_minutes <= 1440),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_class_sessions_starts_at ON class_sessions(starts_at);
+CREATE INDEX IF NOT EXISTS idx_class_sessions_instructor_id_starts_at ON class_sessions(instructor_id, starts_at);
+
+CREATE TABLE IF NOT EXISTS class_enrollments (
+    id BIGSERIAL PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES class_sessions(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_class_enrollment UNIQUE (session_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_class_enrollments_user_id ON class_enrollments(user_id);
@@ -0,0 +1,30 @@
+-- Migration: Rooms and equipment
+-- A resource (a room, the reformer machine...) can be used by at most capacity
+-- bookings at the same time, whichever instructor they are with.
+-- Services declare the resources they need and every booking keeps its own copy,
+-- so editing a service does not change existing bookings.
+CREATE TABLE IF NOT EXISTS resources (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'ROOM' CHECK (kind IN ('ROOM', 'EQUIPMENT')),
+    capacity INTEGER NOT NULL DEFAULT 1 CHECK (capacity > 0),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS service_resources (
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    resource_id INTEGER NOT NULL REFERENCES resources(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    PRIMARY KEY (service_id, resource_id)
+);
+
+CREATE TABLE IF NOT EXISTS booking_resources (
+    booking_id BIGINT NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    resource_id INTEGER NOT NULL REFERENCES resources(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    PRIMARY KEY (booking_id, resource_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_resources_resource_id ON booking_resources(resource_id);
@@ -0,0 +1,33 @@
+-- Migration: Studio locations
+-- Every instructor works at one location and the weekly opening hours of an
+-- instructor are those of their location's time zone. Bookings keep the location
+-- they were made at, so moving an instructor does not move past bookings.
+-- A closure with a location applies to all its instructors, one without
+-- instructor nor location to every location.
+CREATE TABLE IF NOT EXISTS locations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    address VARCHAR(255) NOT NULL DEFAULT '',
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'Europe/Rome',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO locations (name)
+SELECT 'Sede principale'
+WHERE NOT EXISTS (SELECT 1 FROM locations);
+
+ALTER TABLE instructors ADD COLUMN IF NOT EXISTS location_id INTEGER REFERENCES locations(id);
+UPDATE instructors SET location_id = (SELECT MIN(id) FROM locations) WHERE location_id IS NULL;
+ALTER TABLE instructors ALTER COLUMN location_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_instructors_location_id ON instructors(location_id);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS location_id INTEGER REFERENCES locations(id);
+UPDATE bookings b SET location_id = i.location_id
+FROM instructors i
+WHERE i.id = b.instructor_id AND b.location_id IS NULL;
+ALTER TABLE bookings ALTER COLUMN location_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_bookings_location_starts_at ON bookings(location_id, starts_at);
+
+ALTER TABLE closures ADD COLUMN IF NOT EXISTS location_id INTEGER REFERENCES locations(id) ON DELETE CASCADE;
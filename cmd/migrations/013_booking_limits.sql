@@ -0,0 +1,13 @@
+-- Migration: Per-user booking limits
+-- Members of a subscription type can hold at most max_per_day bookings on one
+-- day, max_per_week in one ISO week and max_open bookings not yet started.
+-- Zero disables a limit; subscription types without a row are unlimited.
+CREATE TABLE IF NOT EXISTS booking_limits (
+    sub_type VARCHAR(50) PRIMARY KEY,
+    max_per_day INTEGER NOT NULL DEFAULT 0 CHECK (max_per_day >= 0),
+    max_per_week INTEGER NOT NULL DEFAULT 0 CHECK (max_per_week >= 0),
+    max_open INTEGER NOT NULL DEFAULT 0 CHECK (max_open >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookings_user_id_starts_at ON bookings(user_id, starts_at);
@@ -0,0 +1,13 @@
+-- Migration: Booking horizon, lead time and release schedule
+-- A single row: members book between lead_time_minutes and horizon_days ahead.
+-- With release_enabled, the slots of each ISO week open on release_weekday
+-- (0 = Sunday) at release_time (minutes since midnight) of the week before.
+CREATE TABLE IF NOT EXISTS booking_settings (
+    id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    horizon_days INTEGER NOT NULL DEFAULT 30 CHECK (horizon_days BETWEEN 1 AND 366),
+    lead_time_minutes INTEGER NOT NULL DEFAULT 240 CHECK (lead_time_minutes >= 0),
+    release_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    release_weekday SMALLINT NOT NULL DEFAULT 0 CHECK (release_weekday BETWEEN 0 AND 6),
+    release_time INTEGER NOT NULL DEFAULT 1080 CHECK (release_time >= 0 AND release_time < 1440),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
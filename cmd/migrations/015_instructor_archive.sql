@@ -0,0 +1,13 @@
+-- Migration: Archive instructors instead of deleting them
+-- An archived instructor takes no more bookings but keeps every booking and
+-- class session they held, so attendance history survives them. Deleting an
+-- instructor with bookings or sessions is refused instead of cascading.
+ALTER TABLE instructors ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_instructor_id_fkey;
+ALTER TABLE bookings ADD CONSTRAINT bookings_instructor_id_fkey
+    FOREIGN KEY (instructor_id) REFERENCES instructors(id) ON DELETE RESTRICT;
+
+ALTER TABLE class_sessions DROP CONSTRAINT IF EXISTS class_sessions_instructor_id_fkey;
+ALTER TABLE class_sessions ADD CONSTRAINT class_sessions_instructor_id_fkey
+    FOREIGN KEY (instructor_id) REFERENCES instructors(id) ON DELETE RESTRICT;
@@ -0,0 +1,23 @@
+-- Migration: Booking lifecycle
+-- Bookings are no longer deleted when cancelled: they keep who cancelled them
+-- (NULL when the system did), when, and whether the access was refunded.
+-- Only bookings without cancelled_at hold capacity and count for limits.
+-- attended and no_show follow the attendance marked on past bookings.
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'confirmed'
+    CHECK (status IN ('confirmed', 'cancelled_by_user', 'cancelled_by_admin', 'attended', 'no_show'));
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS cancelled_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS refunded BOOLEAN;
+
+UPDATE bookings SET status = CASE attendance WHEN 'ATTENDED' THEN 'attended' ELSE 'no_show' END
+WHERE status = 'confirmed' AND attendance IN ('ATTENDED', 'NO_SHOW');
+
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_cancelled_status;
+ALTER TABLE bookings ADD CONSTRAINT bookings_cancelled_status
+    CHECK ((cancelled_at IS NOT NULL) = (status IN ('cancelled_by_user', 'cancelled_by_admin')));
+
+-- A cancelled booking no longer holds its member's place in the slot
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS unique_user_instructor_time;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookings_active_user_instructor_time
+    ON bookings(user_id, instructor_id, starts_at) WHERE cancelled_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_bookings_user_id_status ON bookings(user_id, status);
@@ -0,0 +1,47 @@
+-- Migration: Plan catalog and subscription history
+-- A plan is what the studio sells: a validity in days, a number of accesses,
+-- the subscription type and a price. Its cancellation policy, when set, takes
+-- precedence over the policy of the subscription type.
+CREATE TABLE IF NOT EXISTS plans (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    duration_days INTEGER NOT NULL CHECK (duration_days > 0 AND duration_days <= 1095),
+    accesses INTEGER NOT NULL CHECK (accesses >= 0),
+    sub_type VARCHAR(50) NOT NULL DEFAULT 'SHARED' CHECK (sub_type IN ('SHARED', 'SINGLE')),
+    price_cents INTEGER NOT NULL DEFAULT 0 CHECK (price_cents >= 0),
+    policy_id INTEGER REFERENCES cancellation_policies(id) ON DELETE SET NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every purchase and renewal of a member, valid from starts_on to ends_on
+-- included. Name, type and price are copied from the plan so history survives
+-- edits of the catalog; plan_id is NULL for subscriptions predating it.
+-- users.sub_type, expires_at and remaining_accesses stay as the cached state
+-- of the member's subscriptions.
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plan_id INTEGER REFERENCES plans(id) ON DELETE RESTRICT,
+    plan_name VARCHAR(255) NOT NULL,
+    sub_type VARCHAR(50) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('PURCHASE', 'RENEWAL')),
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL,
+    accesses INTEGER NOT NULL CHECK (accesses >= 0),
+    price_cents INTEGER NOT NULL DEFAULT 0,
+    created_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id_ends_on ON subscriptions(user_id, ends_on);
+
+-- Existing members keep their current period as a subscription without a plan
+INSERT INTO subscriptions (user_id, plan_name, sub_type, kind, starts_on, ends_on, accesses)
+SELECT u.id, 'Abbonamento precedente', u.sub_type, 'PURCHASE',
+       LEAST(u.created_at::date, u.expires_at), u.expires_at, GREATEST(u.remaining_accesses, 0)
+FROM users u
+WHERE u.role = 'USER'
+  AND NOT EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = u.id);
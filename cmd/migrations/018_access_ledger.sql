@@ -0,0 +1,24 @@
+-- Migration: Access ledger
+-- Every change to users.remaining_accesses appends a row here in the same
+-- transaction, so the cached balance always equals the sum of its deltas.
+-- Rows are never updated nor deleted by the application; booking_id and
+-- actor_id are only cleared when the row they point to is removed.
+CREATE TABLE IF NOT EXISTS access_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    delta INTEGER NOT NULL CHECK (delta <> 0),
+    reason VARCHAR(20) NOT NULL
+        CHECK (reason IN ('OPENING', 'PLAN', 'EXPIRY', 'BOOKING', 'REFUND', 'CLASS', 'CLASS_REFUND', 'ADJUSTMENT')),
+    booking_id BIGINT REFERENCES bookings(id) ON DELETE SET NULL,
+    actor_id VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_ledger_user_id_created_at ON access_ledger(user_id, created_at);
+
+-- The balances found when the ledger starts become its opening entries
+INSERT INTO access_ledger (user_id, delta, reason)
+SELECT u.id, u.remaining_accesses, 'OPENING'
+FROM users u
+WHERE u.remaining_accesses <> 0
+  AND NOT EXISTS (SELECT 1 FROM access_ledger l WHERE l.user_id = u.id);
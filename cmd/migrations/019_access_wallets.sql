@@ -0,0 +1,23 @@
+-- Migration: Per-service access wallets
+-- A wallet holds accesses reserved to one service with its own expiry.
+-- Bookings of a service the member has a valid, unspent wallet for consume
+-- that wallet; every other booking keeps consuming users.remaining_accesses,
+-- the general balance. The ledger records which wallet each change applies
+-- to, NULL for the general balance, and a service with wallets cannot be
+-- deleted.
+CREATE TABLE IF NOT EXISTS access_wallets (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    service_id INTEGER NOT NULL REFERENCES services(id),
+    remaining INTEGER NOT NULL DEFAULT 0 CHECK (remaining >= 0),
+    expires_on DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, service_id)
+);
+
+ALTER TABLE access_ledger ADD COLUMN IF NOT EXISTS service_id INTEGER REFERENCES services(id);
+
+ALTER TABLE access_ledger DROP CONSTRAINT IF EXISTS access_ledger_reason_check;
+ALTER TABLE access_ledger ADD CONSTRAINT access_ledger_reason_check
+    CHECK (reason IN ('OPENING', 'PLAN', 'EXPIRY', 'BOOKING', 'REFUND', 'CLASS', 'CLASS_REFUND', 'ADJUSTMENT', 'TOP_UP'));
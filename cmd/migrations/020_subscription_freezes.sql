@@ -0,0 +1,25 @@
+-- Migration: Subscription freezes
+-- A plan allows up to max_freeze_days days of pause per subscription; the
+-- value is copied to the subscription when it is sold, so existing
+-- subscriptions cannot be frozen. A freeze pauses a member from starts_on to
+-- ends_on included: nothing can be booked in between and its days are added
+-- to the end of the subscription it pauses and of the renewals queued after
+-- it. The row is the record of who froze the subscription and why.
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS max_freeze_days INTEGER NOT NULL DEFAULT 0
+    CHECK (max_freeze_days >= 0 AND max_freeze_days <= 365);
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS max_freeze_days INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS subscription_freezes (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL,
+    reason TEXT NOT NULL,
+    created_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_freezes_user_id_starts_on ON subscription_freezes(user_id, starts_on);
@@ -0,0 +1,22 @@
+-- Migration: Online payments
+-- A payment is opened as PENDING when a member starts a checkout for a plan
+-- and moves to PAID or FAILED when the provider's webhook reports the
+-- outcome. A PAID payment points to the subscription it bought; amount_cents
+-- is what the checkout charged, whatever the plan costs later.
+CREATE TABLE IF NOT EXISTS payments (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plan_id INTEGER NOT NULL REFERENCES plans(id) ON DELETE RESTRICT,
+    amount_cents INTEGER NOT NULL CHECK (amount_cents > 0),
+    currency VARCHAR(3) NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    checkout_id VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'PAID', 'FAILED')),
+    subscription_id INTEGER REFERENCES subscriptions(id) ON DELETE SET NULL,
+    paid_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, checkout_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_user_id_created_at ON payments(user_id, created_at);
@@ -0,0 +1,25 @@
+-- Migration: Late cancellations on bookings
+-- Cancelled bookings record whether they were cancelled inside the cutoff of
+-- their member's policy, so late cancellations outlive the events cmd/cleanup
+-- deletes after a month.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = 'public' AND table_name = 'bookings' AND column_name = 'late_cancel'
+    ) THEN
+        ALTER TABLE bookings ADD COLUMN late_cancel BOOLEAN NOT NULL DEFAULT FALSE;
+
+        -- Events do not keep the booking id: backfill only when the column
+        -- is first added, so bookings made later are never matched to the
+        -- events of an earlier cancellation of the same slot
+        UPDATE bookings b SET late_cancel = TRUE
+        FROM events e
+        WHERE e.type = 'DELETED' AND e.late_cancel = TRUE
+            AND e.user_id = b.user_id AND e.starts_at = b.starts_at
+            AND b.cancelled_at IS NOT NULL AND b.late_cancel = FALSE;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_bookings_user_id_cancelled_at ON bookings(user_id, cancelled_at)
+    WHERE late_cancel = TRUE;
@@ -0,0 +1,20 @@
+-- Migration: Capacity weight on bookings
+-- Bookings keep the capacity weight of their service when they were made, as
+-- they keep its duration, so editing a service does not change how much of
+-- an instructor existing bookings take.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = 'public' AND table_name = 'bookings' AND column_name = 'capacity_weight'
+    ) THEN
+        ALTER TABLE bookings ADD COLUMN capacity_weight INTEGER NOT NULL DEFAULT 1
+            CHECK (capacity_weight > 0);
+
+        -- Backfill only when the column is first added, so later service
+        -- edits never overwrite the snapshots
+        UPDATE bookings b SET capacity_weight = s.capacity_weight
+        FROM services s
+        WHERE s.id = b.service_id AND b.capacity_weight <> s.capacity_weight;
+    END IF;
+END $$;
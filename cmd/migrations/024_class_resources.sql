@@ -0,0 +1,8 @@
+-- Migration: Class rooms as resources
+-- A class template or session can name the resource it is held in. A session
+-- holds one unit of it while it runs, so bookings needing the same room and
+-- other sessions in it are checked against the session like any booking.
+ALTER TABLE class_templates ADD COLUMN IF NOT EXISTS resource_id INTEGER REFERENCES resources(id) ON DELETE SET NULL;
+ALTER TABLE class_sessions ADD COLUMN IF NOT EXISTS resource_id INTEGER REFERENCES resources(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_class_sessions_resource_id_starts_at ON class_sessions(resource_id, starts_at) WHERE resource_id IS NOT NULL;
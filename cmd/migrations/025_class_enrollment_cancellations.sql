@@ -0,0 +1,14 @@
+-- Migration: Cancelled class enrolments
+-- Members leaving a class keep their enrolment as cancelled, like cancelled
+-- bookings, so late cancellations of classes count toward the monthly
+-- allowance of their policy. A member holds at most one active enrolment
+-- per session.
+ALTER TABLE class_enrollments ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+ALTER TABLE class_enrollments ADD COLUMN IF NOT EXISTS refunded BOOLEAN;
+ALTER TABLE class_enrollments ADD COLUMN IF NOT EXISTS late_cancel BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE class_enrollments DROP CONSTRAINT IF EXISTS unique_class_enrollment;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_class_enrollments_active ON class_enrollments(session_id, user_id)
+    WHERE cancelled_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_class_enrollments_user_id_cancelled_at ON class_enrollments(user_id, cancelled_at)
+    WHERE late_cancel = TRUE;
@@ -273,6 +273,15 @@ func insertDefaultInstructor(tx *sql.Tx) (int64, error) {
 	if err != nil {
 		return 0, fmt.Errorf("failed to create default instructor: %w", err)
 	}
+
+	// Keep the old Next.js opening hours: Monday-Saturday, 07:00-22:00
+	_, err = tx.Exec(`
+		INSERT INTO public.instructor_availability (instructor_id, weekday, start_time, end_time)
+		SELECT $1, d.weekday, TIME '07:00', TIME '22:00'
+		FROM generate_series(1, 6) AS d(weekday)`, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create default instructor availability: %w", err)
+	}
 	return id, nil
 }
 
@@ -351,8 +360,30 @@ func copyUsers(oldDB *sql.DB, tx *sql.Tx) (int64, error) {
 		}
 		inserted++
 	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
 
-	return inserted, rows.Err()
+	// The old app had no plans: each member keeps their period as a subscription
+	if _, err := tx.Exec(`
+		INSERT INTO public.subscriptions (user_id, plan_name, sub_type, kind, starts_on, ends_on, accesses)
+		SELECT id, 'Abbonamento precedente', sub_type, 'PURCHASE',
+			LEAST(created_at::date, expires_at), expires_at, GREATEST(remaining_accesses, 0)
+		FROM public.users
+		WHERE role = 'USER'`); err != nil {
+		return 0, fmt.Errorf("failed to create subscriptions: %w", err)
+	}
+
+	// and their balance as the opening entry of the access ledger
+	if _, err := tx.Exec(`
+		INSERT INTO public.access_ledger (user_id, delta, reason)
+		SELECT id, remaining_accesses, 'OPENING'
+		FROM public.users
+		WHERE remaining_accesses <> 0`); err != nil {
+		return 0, fmt.Errorf("failed to open access ledger: %w", err)
+	}
+
+	return inserted, nil
 }
 
 func copyBookings(oldDB *sql.DB, tx *sql.Tx, instructorID int64) (int64, int64, int64, error) {
@@ -386,7 +417,7 @@ func copyBookings(oldDB *sql.DB, tx *sql.Tx, instructorID int64) (int64, int64,
 	stmt, err := tx.Prepare(`
 		INSERT INTO public.bookings (id, user_id, instructor_id, starts_at, created_at, type)
 		VALUES ($1, $2, $3, $4, $5, 'SIMPLE')
-		ON CONFLICT (user_id, instructor_id, starts_at) DO NOTHING`)
+		ON CONFLICT (user_id, instructor_id, starts_at) WHERE cancelled_at IS NULL DO NOTHING`)
 	if err != nil {
 		return 0, 0, 0, fmt.Errorf("failed to prepare target booking insert: %w", err)
 	}
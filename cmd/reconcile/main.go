@@ -0,0 +1,51 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+	_ "github.com/lib/pq"
+)
+
+// reconcile checks the general balance and the service wallets of every
+// member against the sum of their access ledger. It logs each mismatch and
+// exits with status 1 when it finds any, without changing either side.
+func main() {
+	databaseUrl := os.Getenv("DATABASE_URL")
+	if databaseUrl == "" {
+		log.Fatal("DATABASE_URL is missing")
+	}
+	db, err := sql.Open("postgres", databaseUrl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		log.Fatal(err)
+	}
+
+	db.SetMaxIdleConns(0)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	mismatches, err := models.NewAccessLedgerRepository(db).Reconcile()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, m := range mismatches {
+		balance := "general balance"
+		if m.ServiceID != 0 {
+			balance = fmt.Sprintf("wallet of service %d", m.ServiceID)
+		}
+		log.Printf("user %s (%s), %s: cached %d accesses, ledger sums to %d", m.UserID, m.Email, balance, m.Cached, m.LedgerSum)
+	}
+	if len(mismatches) > 0 {
+		log.Printf("reconcile found %d mismatched balances", len(mismatches))
+		os.Exit(1)
+	}
+
+	log.Print("reconcile found every balance matching its ledger")
+}
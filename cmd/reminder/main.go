@@ -50,15 +50,18 @@ func main() {
 		FirstName string
 		Email     string
 		StartsAt  time.Time
+		TimeZone  string
 	}
 
 	query := `
-	SELECT u.first_name, u.email, b.starts_at
+	SELECT u.first_name, u.email, b.starts_at, l.time_zone
 	FROM bookings b
 	LEFT JOIN users u ON b.user_id =  u.id
+	JOIN locations l ON l.id = b.location_id
 	WHERE b.starts_at >= CURRENT_DATE
 	AND b.starts_at < CURRENT_DATE + INTERVAL '1 day'
 	AND b.type = 'SIMPLE'
+	AND b.cancelled_at IS NULL
 	`
 
 	rows, err := db.Query(query)
@@ -74,6 +77,7 @@ func main() {
 			&booking.FirstName,
 			&booking.Email,
 			&booking.StartsAt,
+			&booking.TimeZone,
 		)
 		if err != nil {
 			log.Fatal(err)
@@ -83,7 +87,7 @@ func main() {
 
 	for _, booking := range bookings {
 		log.Printf("Sending notification to %s", booking.Email)
-		if err := mailer.SendReminderEmail(booking.Email, booking.FirstName, booking.StartsAt); err != nil {
+		if err := mailer.SendReminderEmail(booking.Email, booking.FirstName, booking.StartsAt, booking.TimeZone); err != nil {
 			log.Print(err)
 		}
 	}
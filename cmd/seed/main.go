@@ -50,6 +50,30 @@ func seedTest(db *sql.DB) {
 		log.Println("Created admin user (email: admin@wellness.local, password: admin123, role: ADMIN)")
 	}
 
+	// Every balance starts in the access ledger
+	_, err = db.Exec(`
+		INSERT INTO access_ledger (user_id, delta, reason)
+		SELECT id, remaining_accesses, 'OPENING'
+		FROM users u
+		WHERE email = 'admin@wellness.local' AND remaining_accesses <> 0
+		  AND NOT EXISTS (SELECT 1 FROM access_ledger l WHERE l.user_id = u.id)
+	`)
+	if err != nil {
+		log.Printf("Warning: Could not create admin access ledger: %v", err)
+	}
+
+	// Create the plan catalog
+	_, err = db.Exec(`
+		INSERT INTO plans (name, duration_days, accesses, sub_type, price_cents)
+		SELECT name, duration_days, accesses, sub_type, price_cents
+		FROM (VALUES ('Mensile condiviso', 30, 8, 'SHARED', 8000),
+					 ('Mensile singolo', 30, 8, 'SINGLE', 12000)) AS p(name, duration_days, accesses, sub_type, price_cents)
+		WHERE NOT EXISTS (SELECT 1 FROM plans)
+	`)
+	if err != nil {
+		log.Printf("Warning: Could not create plans: %v", err)
+	}
+
 	// Create test users
 	users := []struct {
 		firstName string
@@ -84,6 +108,27 @@ func seedTest(db *sql.DB) {
 			continue
 		}
 
+		_, err = db.Exec(`
+			INSERT INTO subscriptions (user_id, plan_name, sub_type, kind, starts_on, ends_on, accesses)
+			SELECT id, 'Abbonamento di prova', sub_type, 'PURCHASE', CURRENT_DATE, expires_at, remaining_accesses
+			FROM users u
+			WHERE email = $1 AND NOT EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = u.id)
+		`, u.email)
+		if err != nil {
+			log.Printf("Warning: Could not create subscription for %s: %v", u.email, err)
+		}
+
+		_, err = db.Exec(`
+			INSERT INTO access_ledger (user_id, delta, reason)
+			SELECT id, remaining_accesses, 'PLAN'
+			FROM users u
+			WHERE email = $1 AND remaining_accesses <> 0
+			  AND NOT EXISTS (SELECT 1 FROM access_ledger l WHERE l.user_id = u.id)
+		`, u.email)
+		if err != nil {
+			log.Printf("Warning: Could not create access ledger for %s: %v", u.email, err)
+		}
+
 		userIDs[i] = userID
 	}
 
@@ -103,8 +148,8 @@ func seedTest(db *sql.DB) {
 	for i, instr := range instructors {
 		row := db.QueryRow(`
 			INSERT INTO instructors
-			(id, first_name, last_name)
-			VALUES ($1, $2, $3)
+			(id, first_name, last_name, location_id)
+			VALUES ($1, $2, $3, (SELECT MIN(id) FROM locations))
 			ON CONFLICT DO NOTHING
 		`, i, instr.firstName, instr.lastName)
 		if row.Err() != nil {
@@ -113,6 +158,17 @@ func seedTest(db *sql.DB) {
 			log.Printf("Created instructor %s %s", instr.firstName, instr.lastName)
 		}
 		instructorIDs[i] = id
+
+		// Default weekly availability: Monday-Saturday, 07:00-22:00
+		_, err = db.Exec(`
+			INSERT INTO instructor_availability (instructor_id, weekday, start_time, end_time)
+			SELECT $1, d.weekday, TIME '07:00', TIME '22:00'
+			FROM generate_series(1, 6) AS d(weekday)
+			WHERE NOT EXISTS (SELECT 1 FROM instructor_availability WHERE instructor_id = $1)
+		`, i)
+		if err != nil {
+			log.Printf("Warning: Could not create availability for %s %s: %v", instr.firstName, instr.lastName, err)
+		}
 	}
 
 	// Create time slots for the next 30 days
@@ -154,8 +210,9 @@ func seedTest(db *sql.DB) {
 			instructorID := instructorIDs[(i+j)%len(instructorIDs)]
 
 			_, err = db.Exec(`
-				INSERT INTO bookings (user_id, instructor_id, created_at, starts_at, type)
-				VALUES ($1, $2, $3, $4, $5) ON CONFLICT (user_id, instructor_id, starts_at) DO NOTHING
+				INSERT INTO bookings (user_id, instructor_id, location_id, created_at, starts_at, type)
+				SELECT $1, $2, location_id, $3, $4, $5 FROM instructors WHERE id = $2
+				ON CONFLICT (user_id, instructor_id, starts_at) DO NOTHING
 			`, userID, instructorID, time.Now().Add(-time.Duration(j)*24*time.Hour), bookingTime, "SIMPLE")
 			if err != nil {
 				log.Printf("Warning: Could not create booking: %v", err)
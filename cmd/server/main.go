@@ -22,6 +22,7 @@ import (
 	"github.com/alarmfox/wellness-nutrition/app/mail"
 	"github.com/alarmfox/wellness-nutrition/app/middleware"
 	"github.com/alarmfox/wellness-nutrition/app/models"
+	"github.com/alarmfox/wellness-nutrition/app/payments"
 	"github.com/alarmfox/wellness-nutrition/app/websocket"
 	_ "github.com/joho/godotenv/autoload"
 	_ "github.com/lib/pq"
@@ -64,6 +65,15 @@ func main() {
 		log.Fatalf("failed to initialize secret key: %v", err)
 	}
 
+	paymentProvider, err := newPaymentProvider(os.Getenv("PAYMENT_PROVIDER"), os.Getenv("STRIPE_SECRET_KEY"), os.Getenv("STRIPE_WEBHOOK_SECRET"), secretKey, os.Getenv("ENVIRONMENT"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	paymentCurrency := strings.ToLower(os.Getenv("PAYMENT_CURRENCY"))
+	if paymentCurrency == "" {
+		paymentCurrency = "eur"
+	}
+
 	ctx := context.Background()
 
 	content, err := fs.Sub(files, "static")
@@ -87,18 +97,35 @@ func main() {
 	ctx, cancel := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
 	defer cancel()
 
-	if err := run(ctx, db, listenAddr, content); err != nil {
+	if err := run(ctx, db, listenAddr, content, paymentProvider, paymentCurrency); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func run(ctx context.Context, db *sql.DB, listenAddr string, staticContent fs.FS) error {
+func run(ctx context.Context, db *sql.DB, listenAddr string, staticContent fs.FS, paymentProvider payments.Provider, paymentCurrency string) error {
 	// Initialize repositories
 	userRepo := models.NewUserRepository(db)
 	bookingRepo := models.NewBookingRepository(db)
 	eventRepo := models.NewEventRepository(db)
 	questionRepo := models.NewQuestionRepository(db)
 	instructorRepo := models.NewInstructorRepository(db)
+	availabilityRepo := models.NewAvailabilityRepository(db)
+	closureRepo := models.NewClosureRepository(db)
+	serviceRepo := models.NewServiceRepository(db)
+	waitlistRepo := models.NewWaitlistRepository(db)
+	seriesRepo := models.NewBookingSeriesRepository(db)
+	policyRepo := models.NewCancellationPolicyRepository(db)
+	attendanceRepo := models.NewAttendanceRepository(db)
+	classRepo := models.NewClassRepository(db)
+	resourceRepo := models.NewResourceRepository(db)
+	locationRepo := models.NewLocationRepository(db)
+	limitRepo := models.NewBookingLimitRepository(db)
+	settingsRepo := models.NewBookingSettingsRepository(db)
+	planRepo := models.NewPlanRepository(db)
+	subscriptionRepo := models.NewSubscriptionRepository(db)
+	ledgerRepo := models.NewAccessLedgerRepository(db)
+	walletRepo := models.NewWalletRepository(db)
+	paymentRepo := models.NewPaymentRepository(db)
 
 	// Initialize session store
 	sessionStore := models.NewSessionStore(db)
@@ -123,11 +150,22 @@ func run(ctx context.Context, db *sql.DB, listenAddr string, staticContent fs.FS
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(userRepo, sessionStore)
-	userHandler := handlers.NewUserHandler(userRepo, mailer)
-	bookingHandler := handlers.NewBookingHandler(bookingRepo, eventRepo, userRepo, instructorRepo, mailer, hub)
-	instructorHandler := handlers.NewInstructorHandler(instructorRepo)
+	userHandler := handlers.NewUserHandler(userRepo, subscriptionRepo, ledgerRepo, walletRepo, mailer)
+	bookingHandler := handlers.NewBookingHandler(bookingRepo, eventRepo, userRepo, instructorRepo, availabilityRepo, closureRepo, serviceRepo, waitlistRepo, seriesRepo, policyRepo, attendanceRepo, classRepo, resourceRepo, settingsRepo, subscriptionRepo, walletRepo, mailer, hub)
+	instructorHandler := handlers.NewInstructorHandler(instructorRepo, availabilityRepo, locationRepo)
+	closureHandler := handlers.NewClosureHandler(closureRepo, instructorRepo, locationRepo)
+	serviceHandler := handlers.NewServiceHandler(serviceRepo, instructorRepo, resourceRepo)
+	resourceHandler := handlers.NewResourceHandler(resourceRepo)
+	locationHandler := handlers.NewLocationHandler(locationRepo)
+	policyHandler := handlers.NewPolicyHandler(policyRepo)
+	planHandler := handlers.NewPlanHandler(planRepo, policyRepo)
+	paymentHandler := handlers.NewPaymentHandler(paymentRepo, planRepo, userRepo, paymentProvider, paymentCurrency)
+	limitHandler := handlers.NewBookingLimitHandler(limitRepo)
+	settingsHandler := handlers.NewBookingSettingsHandler(settingsRepo)
+	classHandler := handlers.NewClassHandler(classRepo, instructorRepo, eventRepo, hub)
+	attendanceHandler := handlers.NewAttendanceHandler(attendanceRepo, bookingRepo, policyRepo, userRepo, locationRepo, hub)
 	surveyHandler := handlers.NewSurveyHandler(questionRepo)
-	pageHandler := handlers.NewPageHandler(userRepo, bookingRepo, eventRepo, instructorRepo, questionRepo, tpl)
+	pageHandler := handlers.NewPageHandler(userRepo, bookingRepo, eventRepo, instructorRepo, questionRepo, walletRepo, tpl)
 
 	mux := http.NewServeMux()
 
@@ -162,6 +200,9 @@ func run(ctx context.Context, db *sql.DB, listenAddr string, staticContent fs.FS
 	// Public survey API routes
 	mux.Handle("POST /survey/submit", surveyLimit(formLimit(csrfMiddleware(http.HandlerFunc(surveyHandler.SubmitSurvey)))))
 
+	// Payment webhooks - signed by the provider, so no CSRF
+	mux.Handle("POST /api/payments/webhook", mediumJSONLimit(http.HandlerFunc(paymentHandler.Webhook)))
+
 	// User dashboard - apply CSRF
 	mux.Handle("GET /user", authMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeUserDashboard))))
 	mux.Handle("GET /user/", authMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeUserDashboard))))
@@ -169,8 +210,26 @@ func run(ctx context.Context, db *sql.DB, listenAddr string, staticContent fs.FS
 	// User API - apply CSRF
 	mux.Handle("GET /api/user/bookings", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.GetCurrent))))
 	mux.Handle("POST /api/user/bookings", authMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(bookingHandler.Create)))))
+	mux.Handle("PUT /api/user/bookings/{id}", authMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(bookingHandler.Reschedule)))))
 	mux.Handle("DELETE /api/user/bookings/{id}", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.Delete))))
 	mux.Handle("GET /api/user/bookings/slots", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.GetAvailableSlots))))
+	mux.Handle("GET /api/user/bookings/series", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.GetSeries))))
+	mux.Handle("POST /api/user/bookings/series", authMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(bookingHandler.CreateSeries)))))
+	mux.Handle("DELETE /api/user/bookings/series/{id}", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.DeleteSeries))))
+	mux.Handle("GET /api/user/cancellations", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.GetCancellations))))
+	mux.Handle("GET /api/user/attendance", authMiddleware(csrfMiddleware(http.HandlerFunc(attendanceHandler.GetMine))))
+	mux.Handle("GET /api/user/bookings/{id}/checkin-qr", authMiddleware(csrfMiddleware(http.HandlerFunc(attendanceHandler.GetCheckInCode))))
+	mux.Handle("GET /api/user/waitlist", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.GetWaitlist))))
+	mux.Handle("POST /api/user/waitlist", authMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(bookingHandler.JoinWaitlist)))))
+	mux.Handle("DELETE /api/user/waitlist/{id}", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.LeaveWaitlist))))
+	mux.Handle("GET /api/user/classes", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.GetClasses))))
+	mux.Handle("POST /api/user/classes/{id}/enrollment", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.EnrollClass))))
+	mux.Handle("DELETE /api/user/classes/{id}/enrollment", authMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.CancelClassEnrollment))))
+	mux.Handle("GET /api/user/plans", authMiddleware(csrfMiddleware(http.HandlerFunc(paymentHandler.GetPlans))))
+	mux.Handle("POST /api/user/payments", authMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(paymentHandler.CreateCheckout)))))
+	if _, ok := paymentProvider.(*payments.FakeProvider); ok {
+		mux.Handle("POST /api/user/payments/fake-checkout", authMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(paymentHandler.CompleteFakeCheckout)))))
+	}
 
 	// Admin dashboard - apply CSRF
 	mux.Handle("GET /admin", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeAdminHome))))
@@ -178,6 +237,14 @@ func run(ctx context.Context, db *sql.DB, listenAddr string, staticContent fs.FS
 	mux.Handle("GET /admin/calendar", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeCalendar))))
 	mux.Handle("GET /admin/users", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeUsers))))
 	mux.Handle("GET /admin/instructors", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeInstructors))))
+	mux.Handle("GET /admin/locations", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeLocations))))
+	mux.Handle("GET /admin/closures", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeClosures))))
+	mux.Handle("GET /admin/services", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeServices))))
+	mux.Handle("GET /admin/plans", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServePlans))))
+	mux.Handle("GET /admin/classes", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeClasses))))
+	mux.Handle("GET /admin/policies", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServePolicies))))
+	mux.Handle("GET /admin/attendance", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeAttendance))))
+	mux.Handle("GET /admin/kiosk", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeKiosk))))
 	mux.Handle("GET /admin/events", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeEvents))))
 	mux.Handle("GET /admin/survey/questions", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeSurveyQuestions))))
 	mux.Handle("GET /admin/survey/results", adminMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeSurveyResults))))
@@ -189,6 +256,14 @@ func run(ctx context.Context, db *sql.DB, listenAddr string, staticContent fs.FS
 	mux.Handle("PUT /api/admin/users", adminMiddleware(mediumJSONLimit(csrfMiddleware(http.HandlerFunc(userHandler.Update)))))
 	mux.Handle("DELETE /api/admin/users", adminMiddleware(mediumJSONLimit(csrfMiddleware(http.HandlerFunc(userHandler.Delete)))))
 	mux.Handle("POST /api/admin/users/resend-verification", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(userHandler.ResendVerification)))))
+	mux.Handle("GET /api/admin/users/{id}/bookings", adminMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.GetUserHistory))))
+	mux.Handle("GET /api/admin/users/{id}/subscriptions", adminMiddleware(csrfMiddleware(http.HandlerFunc(userHandler.GetSubscriptions))))
+	mux.Handle("GET /api/admin/users/{id}/accesses", adminMiddleware(csrfMiddleware(http.HandlerFunc(userHandler.GetAccessLedger))))
+	mux.Handle("GET /api/admin/users/{id}/wallets", adminMiddleware(csrfMiddleware(http.HandlerFunc(userHandler.GetWallets))))
+	mux.Handle("POST /api/admin/users/{id}/wallets", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(userHandler.TopUpWallet)))))
+	mux.Handle("GET /api/admin/users/{id}/freezes", adminMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.GetFreezes))))
+	mux.Handle("POST /api/admin/users/{id}/freezes", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(bookingHandler.FreezeSubscription)))))
+	mux.Handle("GET /api/admin/users/{id}/payments", adminMiddleware(csrfMiddleware(http.HandlerFunc(paymentHandler.GetUserPayments))))
 
 	// Instructors API - apply CSRF
 	mux.Handle("GET /api/user/instructors", authMiddleware(csrfMiddleware(http.HandlerFunc(instructorHandler.GetAll))))
@@ -196,10 +271,74 @@ func run(ctx context.Context, db *sql.DB, listenAddr string, staticContent fs.FS
 	mux.Handle("POST /api/admin/instructors", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(instructorHandler.Create)))))
 	mux.Handle("PUT /api/admin/instructors/{id}", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(instructorHandler.Update)))))
 	mux.Handle("DELETE /api/admin/instructors/{id}", adminMiddleware(csrfMiddleware(http.HandlerFunc(instructorHandler.Delete))))
+	mux.Handle("GET /api/admin/instructors/{id}/availability", adminMiddleware(csrfMiddleware(http.HandlerFunc(instructorHandler.GetAvailability))))
+	mux.Handle("PUT /api/admin/instructors/{id}/availability", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(instructorHandler.UpdateAvailability)))))
+
+	// Locations API - apply CSRF
+	mux.Handle("GET /api/user/locations", authMiddleware(csrfMiddleware(http.HandlerFunc(locationHandler.GetEnabled))))
+	mux.Handle("GET /api/admin/locations", adminMiddleware(csrfMiddleware(http.HandlerFunc(locationHandler.GetAll))))
+	mux.Handle("POST /api/admin/locations", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(locationHandler.Create)))))
+	mux.Handle("PUT /api/admin/locations/{id}", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(locationHandler.Update)))))
+	mux.Handle("DELETE /api/admin/locations/{id}", adminMiddleware(csrfMiddleware(http.HandlerFunc(locationHandler.Delete))))
+
+	// Closures API - apply CSRF
+	mux.Handle("GET /api/admin/closures", adminMiddleware(csrfMiddleware(http.HandlerFunc(closureHandler.GetAll))))
+	mux.Handle("POST /api/admin/closures", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(closureHandler.Create)))))
+	mux.Handle("DELETE /api/admin/closures/{id}", adminMiddleware(csrfMiddleware(http.HandlerFunc(closureHandler.Delete))))
+
+	// Services API - apply CSRF
+	mux.Handle("GET /api/user/services", authMiddleware(csrfMiddleware(http.HandlerFunc(serviceHandler.GetEnabled))))
+	mux.Handle("GET /api/admin/services", adminMiddleware(csrfMiddleware(http.HandlerFunc(serviceHandler.GetAll))))
+	mux.Handle("POST /api/admin/services", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(serviceHandler.Create)))))
+	mux.Handle("PUT /api/admin/services/{id}", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(serviceHandler.Update)))))
+	mux.Handle("DELETE /api/admin/services/{id}", adminMiddleware(csrfMiddleware(http.HandlerFunc(serviceHandler.Delete))))
+
+	// Plan catalog API - apply CSRF
+	mux.Handle("GET /api/admin/plans", adminMiddleware(csrfMiddleware(http.HandlerFunc(planHandler.GetAll))))
+	mux.Handle("POST /api/admin/plans", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(planHandler.Create)))))
+	mux.Handle("PUT /api/admin/plans/{id}", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(planHandler.Update)))))
+	mux.Handle("DELETE /api/admin/plans/{id}", adminMiddleware(csrfMiddleware(http.HandlerFunc(planHandler.Delete))))
+
+	// Group classes API - apply CSRF
+	mux.Handle("GET /api/admin/classes/templates", adminMiddleware(csrfMiddleware(http.HandlerFunc(classHandler.GetTemplates))))
+	mux.Handle("POST /api/admin/classes/templates", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(classHandler.CreateTemplate)))))
+	mux.Handle("PUT /api/admin/classes/templates/{id}", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(classHandler.UpdateTemplate)))))
+	mux.Handle("DELETE /api/admin/classes/templates/{id}", adminMiddleware(csrfMiddleware(http.HandlerFunc(classHandler.DeleteTemplate))))
+	mux.Handle("GET /api/admin/classes/sessions", adminMiddleware(csrfMiddleware(http.HandlerFunc(classHandler.GetSessions))))
+	mux.Handle("POST /api/admin/classes/sessions", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(classHandler.ScheduleSession)))))
+	mux.Handle("DELETE /api/admin/classes/sessions/{id}", adminMiddleware(csrfMiddleware(http.HandlerFunc(classHandler.DeleteSession))))
+	mux.Handle("GET /api/admin/classes/sessions/{id}/participants", adminMiddleware(csrfMiddleware(http.HandlerFunc(classHandler.GetParticipants))))
+
+	// Resources API - apply CSRF
+	mux.Handle("GET /api/admin/resources", adminMiddleware(csrfMiddleware(http.HandlerFunc(resourceHandler.GetAll))))
+	mux.Handle("POST /api/admin/resources", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(resourceHandler.Create)))))
+	mux.Handle("PUT /api/admin/resources/{id}", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(resourceHandler.Update)))))
+	mux.Handle("DELETE /api/admin/resources/{id}", adminMiddleware(csrfMiddleware(http.HandlerFunc(resourceHandler.Delete))))
+
+	// Cancellation policies API - apply CSRF
+	mux.Handle("GET /api/admin/policies", adminMiddleware(csrfMiddleware(http.HandlerFunc(policyHandler.GetAll))))
+	mux.Handle("POST /api/admin/policies", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(policyHandler.Create)))))
+	mux.Handle("PUT /api/admin/policies/{id}", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(policyHandler.Update)))))
+	mux.Handle("DELETE /api/admin/policies/{id}", adminMiddleware(csrfMiddleware(http.HandlerFunc(policyHandler.Delete))))
+
+	// Booking limits API - apply CSRF
+	mux.Handle("GET /api/admin/booking-limits", adminMiddleware(csrfMiddleware(http.HandlerFunc(limitHandler.GetAll))))
+	mux.Handle("PUT /api/admin/booking-limits/{subType}", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(limitHandler.Update)))))
+	mux.Handle("GET /api/admin/booking-settings", adminMiddleware(csrfMiddleware(http.HandlerFunc(settingsHandler.Get))))
+	mux.Handle("PUT /api/admin/booking-settings", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(settingsHandler.Update)))))
+
+	// Attendance API - apply CSRF
+	mux.Handle("GET /api/admin/attendance/roster", adminMiddleware(csrfMiddleware(http.HandlerFunc(attendanceHandler.GetRoster))))
+	mux.Handle("GET /api/admin/attendance/stats", adminMiddleware(csrfMiddleware(http.HandlerFunc(attendanceHandler.GetStats))))
+	mux.Handle("PUT /api/admin/bookings/{id}/attendance", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(attendanceHandler.Mark)))))
+	mux.Handle("POST /api/admin/checkin", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(attendanceHandler.CheckIn)))))
 
 	// Bookings API - apply CSRF
 	mux.Handle("GET /api/admin/bookings", adminMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.GetAllBookings))))
 	mux.Handle("POST /api/admin/bookings", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(bookingHandler.CreateBookingForUser)))))
+	mux.Handle("POST /api/admin/bookings/block", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(bookingHandler.BlockRange)))))
+	mux.Handle("POST /api/admin/bookings/reassign", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(bookingHandler.ReassignInstructor)))))
+	mux.Handle("PUT /api/admin/bookings/{id}", adminMiddleware(smallJSONLimit(csrfMiddleware(http.HandlerFunc(bookingHandler.RescheduleAdmin)))))
 	mux.Handle("DELETE /api/admin/bookings/{id}", adminMiddleware(csrfMiddleware(http.HandlerFunc(bookingHandler.DeleteAdmin))))
 
 	// Survey API - apply CSRF
@@ -213,6 +352,9 @@ func run(ctx context.Context, db *sql.DB, listenAddr string, staticContent fs.FS
 	mux.Handle("/ws", adminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		websocket.ServeWs(hub, w, r)
 	})))
+	mux.Handle("/ws/user", authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		websocket.ServeUserWs(hub, middleware.GetUserFromContext(r.Context()).ID, w, r)
+	})))
 
 	// Root redirect based on role - apply CSRF
 	mux.Handle("/", authMiddleware(csrfMiddleware(http.HandlerFunc(pageHandler.ServeRoot))))
@@ -261,6 +403,28 @@ func validateStartupConfig(secretKey, authURL, environment string) error {
 	return nil
 }
 
+// newPaymentProvider returns the provider named by PAYMENT_PROVIDER, or nil
+// when online payments are disabled. The fake provider signs its webhooks
+// with the secret key and cannot run in production.
+func newPaymentProvider(name, stripeSecretKey, stripeWebhookSecret, secretKey, environment string) (payments.Provider, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "stripe":
+		if stripeSecretKey == "" || stripeWebhookSecret == "" {
+			return nil, fmt.Errorf("STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET are required with the stripe payment provider")
+		}
+		return payments.NewStripeProvider(stripeSecretKey, stripeWebhookSecret), nil
+	case "fake":
+		if environment == "production" {
+			return nil, fmt.Errorf("the fake payment provider cannot be used in production")
+		}
+		return payments.NewFakeProvider(secretKey), nil
+	default:
+		return nil, fmt.Errorf("unknown PAYMENT_PROVIDER %q", name)
+	}
+}
+
 func staticCache(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "public, max-age=3600")
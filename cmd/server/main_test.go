@@ -22,3 +22,28 @@ func TestValidateStartupConfigAllowsDevelopmentWithoutAuthURL(t *testing.T) {
 		t.Fatalf("expected development config to be accepted: %v", err)
 	}
 }
+
+func TestNewPaymentProviderDisabledByDefault(t *testing.T) {
+	provider, err := newPaymentProvider("", "", "", "", "production")
+	if err != nil || provider != nil {
+		t.Fatalf("expected payments to be disabled, got %v, %v", provider, err)
+	}
+}
+
+func TestNewPaymentProviderRequiresStripeSecrets(t *testing.T) {
+	if _, err := newPaymentProvider("stripe", "sk_test", "", "", "development"); err == nil {
+		t.Fatal("expected a missing STRIPE_WEBHOOK_SECRET to be rejected")
+	}
+	if _, err := newPaymentProvider("stripe", "sk_test", "whsec_test", "", "production"); err != nil {
+		t.Fatalf("expected stripe config to be accepted: %v", err)
+	}
+}
+
+func TestNewPaymentProviderRejectsFakeInProduction(t *testing.T) {
+	if _, err := newPaymentProvider("fake", "", "", "01234567890123456789012345678901", "production"); err == nil {
+		t.Fatal("expected the fake provider to be rejected in production")
+	}
+	if _, err := newPaymentProvider("paypal", "", "", "", "development"); err == nil {
+		t.Fatal("expected an unknown provider to be rejected")
+	}
+}
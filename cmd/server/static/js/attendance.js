@@ -0,0 +1,211 @@
+(function () {
+    const BUSINESS_TIME_ZONE = 'Europe/Rome';
+    let locations = [];
+    const labels = {
+        ATTENDED: 'Presente',
+        NO_SHOW: 'Assente',
+        LATE_CANCEL: 'Cancellazione tardiva',
+    };
+    const badgeClasses = {
+        ATTENDED: 'badge badge-success',
+        NO_SHOW: 'badge badge-deleted',
+        LATE_CANCEL: 'badge badge-warning',
+    };
+    const actions = [
+        { attendance: 'ATTENDED', icon: 'check_circle', title: 'Presente' },
+        { attendance: 'NO_SHOW', icon: 'person_off', title: 'Assente' },
+        { attendance: '', icon: 'undo', title: 'Annulla' },
+    ];
+
+    function icon(name) {
+        const elem = document.createElement('span');
+        elem.className = 'material-icons';
+        elem.textContent = name;
+        return elem;
+    }
+
+    function emptyRow(body, colSpan, text) {
+        const row = document.createElement('tr');
+        const cell = document.createElement('td');
+        cell.colSpan = colSpan;
+        cell.className = 'empty-cell';
+        cell.textContent = text;
+        row.appendChild(cell);
+        body.appendChild(row);
+    }
+
+    function todayInRome() {
+        // en-CA formats dates as YYYY-MM-DD
+        return new Date().toLocaleDateString('en-CA', { timeZone: BUSINESS_TIME_ZONE });
+    }
+
+    // locationQuery returns the query parameter of the selected location, if any.
+    function locationQuery() {
+        const locationId = document.getElementById('roster-location').value;
+        return locationId ? `locationId=${encodeURIComponent(locationId)}` : '';
+    }
+
+    // rosterTimeZone is the time zone of the selected location, the default one
+    // when showing every location.
+    function rosterTimeZone() {
+        const locationId = document.getElementById('roster-location').value;
+        const location = locations.find(l => String(l.id) === locationId);
+        return location ? location.timeZone : BUSINESS_TIME_ZONE;
+    }
+
+    async function loadLocations() {
+        try {
+            const response = await fetch('/api/admin/locations');
+            if (!response.ok) throw new Error('Failed to load locations');
+            locations = await response.json();
+            const select = document.getElementById('roster-location');
+            locations.forEach(l => {
+                const option = document.createElement('option');
+                option.value = l.id;
+                option.textContent = l.name;
+                select.appendChild(option);
+            });
+        } catch (error) {
+            console.error('Error loading locations:', error);
+        }
+    }
+
+    async function loadRoster() {
+        const date = document.getElementById('roster-date').value;
+        const location = locationQuery();
+        try {
+            const response = await fetch(`/api/admin/attendance/roster?date=${encodeURIComponent(date)}${location ? '&' + location : ''}`);
+            if (!response.ok) throw new Error('Failed to load roster');
+            renderRoster(await response.json());
+        } catch (error) {
+            console.error('Error loading roster:', error);
+            UI.showToast('Errore nel caricamento delle presenze');
+        }
+    }
+
+    function renderRoster(entries) {
+        const body = document.getElementById('roster-table-body');
+        body.textContent = '';
+
+        if (entries.length === 0) {
+            emptyRow(body, 6, 'Nessuna prenotazione per questa data');
+            return;
+        }
+
+        entries.forEach(e => {
+            const row = document.createElement('tr');
+
+            const time = document.createElement('td');
+            time.textContent = new Date(e.startsAt).toLocaleTimeString('it-IT', {
+                hour: '2-digit',
+                minute: '2-digit',
+                timeZone: rosterTimeZone(),
+            });
+            const user = document.createElement('td');
+            user.textContent = `${e.firstName} ${e.lastName}`.trim();
+            const instructor = document.createElement('td');
+            instructor.textContent = e.instructorName || '-';
+            const service = document.createElement('td');
+            service.textContent = e.serviceName || '-';
+
+            const status = document.createElement('td');
+            if (e.attendance) {
+                const badge = document.createElement('span');
+                badge.className = badgeClasses[e.attendance];
+                badge.textContent = labels[e.attendance];
+                status.appendChild(badge);
+            } else {
+                status.textContent = '-';
+            }
+
+            const buttons = document.createElement('td');
+            actions.forEach(a => {
+                if (a.attendance === e.attendance || (a.attendance === '' && !e.attendance)) return;
+                const button = document.createElement('button');
+                button.className = 'btn-icon';
+                button.type = 'button';
+                button.title = a.title;
+                button.appendChild(icon(a.icon));
+                button.addEventListener('click', () => mark(e.bookingId, a.attendance));
+                buttons.appendChild(button);
+            });
+
+            row.append(time, user, instructor, service, status, buttons);
+            body.appendChild(row);
+        });
+    }
+
+    async function mark(bookingId, attendance) {
+        try {
+            const response = await fetch(`/api/admin/bookings/${bookingId}/attendance`, {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCookie('csrf_token'),
+                },
+                body: JSON.stringify({ attendance }),
+            });
+
+            if (response.ok) {
+                UI.showToast('Presenza aggiornata', true);
+                loadRoster();
+                loadStats();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante l\'aggiornamento');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function loadStats() {
+        try {
+            const location = locationQuery();
+            const response = await fetch(`/api/admin/attendance/stats${location ? '?' + location : ''}`);
+            if (!response.ok) throw new Error('Failed to load stats');
+            renderStats(await response.json());
+        } catch (error) {
+            console.error('Error loading attendance stats:', error);
+        }
+    }
+
+    function renderStats(stats) {
+        const body = document.getElementById('stats-table-body');
+        body.textContent = '';
+
+        if (stats.length === 0) {
+            emptyRow(body, 4, 'Nessun dato');
+            return;
+        }
+
+        stats.forEach(s => {
+            const row = document.createElement('tr');
+            const user = document.createElement('td');
+            user.textContent = `${s.firstName} ${s.lastName}`.trim();
+            const attended = document.createElement('td');
+            attended.textContent = s.attended;
+            const noShows = document.createElement('td');
+            noShows.textContent = s.noShows;
+            const lateCancels = document.createElement('td');
+            lateCancels.textContent = s.lateCancels;
+            row.append(user, attended, noShows, lateCancels);
+            body.appendChild(row);
+        });
+    }
+
+    document.addEventListener('DOMContentLoaded', async () => {
+        const dateInput = document.getElementById('roster-date');
+        dateInput.value = todayInRome();
+        dateInput.addEventListener('change', loadRoster);
+        document.getElementById('roster-location').addEventListener('change', () => {
+            loadRoster();
+            loadStats();
+        });
+
+        await loadLocations();
+        loadRoster();
+        loadStats();
+    });
+})();
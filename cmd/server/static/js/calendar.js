@@ -10,7 +10,10 @@ const BookingType = {
     DISABLE: 'DISABLE'
 };
 
-var BUSINESS_TIME_ZONE = 'Europe/Rome';
+// BUSINESS_TIME_ZONE is the time zone the week is drawn in: the default
+// location's, or the selected location's when filtering by location.
+const DEFAULT_TIME_ZONE = 'Europe/Rome';
+var BUSINESS_TIME_ZONE = DEFAULT_TIME_ZONE;
 
 function getRomeParts(date) {
     const parts = new Intl.DateTimeFormat('en-CA', {
@@ -103,27 +106,78 @@ function escapeHTML(value) {
     })[char]);
 }
 
+// Bookings can start at any minute since services have their own length:
+// they are shown in the hourly cell they start in.
+function getBookingSlotTime(booking) {
+    const hour = 60 * 60 * 1000;
+    return Math.floor(new Date(booking.startsAt).getTime() / hour) * hour;
+}
+
+// Returns the start time and service of a booking that is not a standard hourly session.
+function getBookingServiceLabel(booking) {
+    const startsAt = new Date(booking.startsAt);
+    const parts = [];
+    if (startsAt.getTime() !== getBookingSlotTime(booking) || booking.serviceName) {
+        parts.push(startsAt.toLocaleTimeString('it-IT', {
+            hour: '2-digit',
+            minute: '2-digit',
+            timeZone: BUSINESS_TIME_ZONE
+        }));
+    }
+    if (booking.serviceName) {
+        parts.push(booking.serviceName);
+    }
+    return parts.join(' ');
+}
+
+// selectedLocationId returns the location filter as a number, zero for all.
+function selectedLocationId() {
+    return parseInt(CalendarState.selectedLocationId, 10) || 0;
+}
+
 function getInstructorName(instructorId) {
     const instructor = CalendarState.instructors.find(i => i.ID === Number(instructorId));
     if (!instructor) return '';
     return `${instructor.FirstName || ''} ${instructor.LastName || ''}`.trim();
 }
 
+// describeAffectedBookings lists the members and times of the bookings a block
+// would cancel, one per line, for a confirmation prompt.
+function describeAffectedBookings(bookings) {
+    const lines = (bookings || []).slice(0, 10).map(b => {
+        const time = new Date(b.startsAt).toLocaleTimeString('it-IT', {
+            hour: '2-digit',
+            minute: '2-digit',
+            timeZone: BUSINESS_TIME_ZONE
+        });
+        return `${b.firstName} ${b.lastName} (${time})`;
+    });
+    if (bookings && bookings.length > lines.length) {
+        lines.push(`... e altre ${bookings.length - lines.length}`);
+    }
+    return lines.join('\n');
+}
+
 // ============================================================================
 // STATE MANAGEMENT
 // ============================================================================
 const CalendarState = {
     currentDate: new Date(),
     bookings: [],
+    classSessions: [],
     users: [],
     instructors: [],
+    services: [],
+    locations: [],
     selectedInstructorId: '',
+    selectedLocationId: '',
 
     modal: {
         slotTime: null,
         slotData: null,
         bookingId: null,
-        refund: false
+        refund: false,
+        usePolicy: true
     },
 
     dayDisable: {
@@ -137,13 +191,14 @@ const CalendarState = {
         this.modal.slotData = null;
         this.modal.bookingId = null;
         this.modal.refund = false;
+        this.modal.usePolicy = true;
     },
 
     getSlots() {
         const slotMap = new Map();
 
         this.bookings.forEach(booking => {
-            const startTime = new Date(booking.startsAt).getTime();
+            const startTime = getBookingSlotTime(booking);
 
             if (!slotMap.has(startTime)) {
                 slotMap.set(startTime, {
@@ -223,10 +278,25 @@ function getInstructorName(instructor) {
 // API SERVICE
 // ============================================================================
 const API = {
-    async fetchBookings(from, to, instructorId = null) {
+    async fetchBookings(from, to, instructorId = null, locationId = null) {
         let url = `/api/admin/bookings?from=${from.toISOString()}&to=${to.toISOString()}`;
         if (instructorId) {
             url += `&instructorId=${instructorId}`;
+        } else if (locationId) {
+            url += `&locationId=${locationId}`;
+        }
+        const response = await fetch(url);
+        const data = await response.json();
+        return Array.isArray(data) ? data : [];
+    },
+
+    async fetchClassSessions(from, to, instructorId = null, locationId = null) {
+        let url = `/api/admin/classes/sessions?from=${from.toISOString()}&to=${to.toISOString()}`;
+        if (instructorId) {
+            url += `&instructorId=${instructorId}`;
+        }
+        if (locationId) {
+            url += `&locationId=${locationId}`;
         }
         const response = await fetch(url);
         const data = await response.json();
@@ -238,8 +308,22 @@ const API = {
         return response.json();
     },
 
-    async fetchInstructors() {
-        const response = await fetch('/api/admin/instructors');
+    async fetchInstructors(locationId = null) {
+        let url = '/api/admin/instructors';
+        if (locationId) {
+            url += `?locationId=${locationId}`;
+        }
+        const response = await fetch(url);
+        return response.json();
+    },
+
+    async fetchLocations() {
+        const response = await fetch('/api/admin/locations');
+        return response.json();
+    },
+
+    async fetchServices() {
+        const response = await fetch('/api/admin/services');
         return response.json();
     },
 
@@ -256,9 +340,24 @@ const API = {
         return response.json();
     },
 
-    async deleteBooking(bookingId, refund = false) {
+    async blockRange(payload) {
         const csrfToken = getCookie('csrf_token');
-        const response = await fetch(`/api/admin/bookings/${bookingId}?refund=${encodeURIComponent(refund)}`, {
+        const response = await fetch('/api/admin/bookings/block', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'X-CSRF-Token': csrfToken
+            },
+            body: JSON.stringify(payload)
+        });
+        return response.json();
+    },
+
+    // Without refund the cancellation policy of the booking owner decides
+    async deleteBooking(bookingId, refund) {
+        const csrfToken = getCookie('csrf_token');
+        const query = refund === undefined ? '' : `?refund=${encodeURIComponent(refund)}`;
+        const response = await fetch(`/api/admin/bookings/${bookingId}${query}`, {
             method: 'DELETE',
             headers: {
                 'X-CSRF-Token': csrfToken
@@ -311,17 +410,23 @@ const Modal = {
         CalendarState.modal.bookingId = bookingId;
         CalendarState.modal.slotTime = slotTime;
         CalendarState.modal.refund = false;
+        CalendarState.modal.usePolicy = true;
 
         const modal = document.getElementById('deleteBookingModal');
         const userName = document.getElementById('deleteBookingUserName');
         const slotInfo = document.getElementById('deleteBookingSlotInfo');
         const refundCheckbox = document.getElementById('refund-checkbox');
+        const policyCheckbox = document.getElementById('policy-checkbox');
 
         if (modal && userName && slotInfo) {
             userName.textContent = `${firstName} ${lastName}`;
             slotInfo.textContent = UI.formatSlotDateTime(slotTime);
             if (refundCheckbox) {
                 refundCheckbox.checked = false;
+                refundCheckbox.disabled = true;
+            }
+            if (policyCheckbox) {
+                policyCheckbox.checked = true;
             }
             modal.style.display = 'block';
         }
@@ -355,9 +460,37 @@ const OperationForm = {
             html += this.buildUserSelect();
         }
 
+        if (operation !== BookingType.DISABLE) {
+            html += this.buildServiceSelect();
+        }
+
+        return html;
+    },
+
+    buildServiceSelect() {
+        const services = CalendarState.services.filter(service => service.enabled);
+        if (services.length === 0) return '';
+
+        let html = `<div class="operation-field form-group">
+            <label>
+                Servizio
+            </label>
+            <select id="operationServiceId">
+                <option value="">Sessione standard (60 min)</option>`;
+
+        services.forEach(service => {
+            html += `<option value="${Number(service.id)}">${escapeHTML(service.name)} (${Number(service.durationMinutes)} min)</option>`;
+        });
+
+        html += `</select></div>`;
         return html;
     },
 
+    getSelectedServiceId() {
+        const serviceIdEl = document.getElementById('operationServiceId');
+        return serviceIdEl && serviceIdEl.value ? parseInt(serviceIdEl.value) : 0;
+    },
+
     buildInstructorSelect(operation, instructorStates) {
         const availableInstructors = this.getAvailableInstructors(operation, instructorStates);
         const canSelectAll = operation === BookingType.DISABLE &&
@@ -480,7 +613,8 @@ const OperationForm = {
             try {
                 const payload = {
                     startsAt: CalendarState.modal.slotTime,
-                    instructorId: instructor.ID
+                    instructorId: instructor.ID,
+                    serviceId: this.getSelectedServiceId()
                 };
 
                 if (operation === BookingType.SIMPLE && userId) {
@@ -491,9 +625,9 @@ const OperationForm = {
 
                 if (data.error) {
                     errorCount++;
-                } else if (data.hasBookings && operation === BookingType.DISABLE) {
+                } else if (data.hasBookings) {
                     const confirmed = confirm(
-                        `${instructor.FirstName} ha ${data.bookingCount} prenotazioni. Eliminarle?`
+                        `${instructor.FirstName} ha ${data.bookingCount} prenotazioni:\n${describeAffectedBookings(data.bookings)}\n\nCancellarle e rimborsare gli accessi?`
                     );
                     if (confirmed) {
                         const confirmData = await API.createBooking(operation, {
@@ -540,24 +674,34 @@ const OperationForm = {
         try {
             const payload = {
                 startsAt: CalendarState.modal.slotTime,
-                instructorId: parseInt(instructorId)
+                instructorId: parseInt(instructorId),
+                serviceId: this.getSelectedServiceId()
             };
 
             if (operation === BookingType.SIMPLE && userId) {
                 payload.userId = userId;
             }
 
-            const data = await API.createBooking(operation, payload);
+            let data = await API.createBooking(operation, payload);
             UI.hideLoading();
 
+            if (data.code === 'USER_OVERLAP') {
+                if (!confirm('Il cliente ha già una prenotazione in questo orario. Prenotare comunque?')) {
+                    return false;
+                }
+                UI.showLoading('Elaborazione in corso...');
+                data = await API.createBooking(operation, { ...payload, allowOverlap: true });
+                UI.hideLoading();
+            }
+
             if (data.error) {
                 UI.showToast(data.error, false);
                 return false;
             }
 
-            if (data.hasBookings && operation === BookingType.DISABLE) {
+            if (data.hasBookings) {
                 const confirmed = confirm(
-                    `Questo slot ha ${data.bookingCount} prenotazioni. Eliminarle tutte?`
+                    `Questo slot ha ${data.bookingCount} prenotazioni:\n${describeAffectedBookings(data.bookings)}\n\nCancellarle, rimborsare gli accessi e avvisare i clienti?`
                 );
                 if (!confirmed) return false;
 
@@ -575,16 +719,17 @@ const OperationForm = {
     },
 
     async confirmOperation(operation, instructorId, userId) {
-        UI.showLoading('Eliminazione prenotazioni...');
+        UI.showLoading('Cancellazione prenotazioni...');
         try {
             const payload = {
                 startsAt: CalendarState.modal.slotTime,
                 instructorId: parseInt(instructorId),
+                serviceId: this.getSelectedServiceId(),
                 confirmed: true
             };
 
             if (operation === BookingType.SIMPLE && userId) {
-                payload.userId = parseInt(userId);
+                payload.userId = userId;
             }
 
             const data = await API.createBooking(operation, payload);
@@ -595,7 +740,7 @@ const OperationForm = {
                 return false;
             }
 
-            UI.showToast(`Slot disabilitato. ${data.deletedCount || 0} prenotazioni eliminate`, true);
+            UI.showToast(`Slot bloccato. ${data.cancelledCount || 0} prenotazioni cancellate e rimborsate`, true);
             return true;
         } catch (error) {
             UI.hideLoading();
@@ -755,7 +900,7 @@ const SlotActions = {
         const targetTime = new Date(slotTime).getTime();
 
         return CalendarState.bookings.find(b => {
-            const bookingTime = new Date(b.startsAt).getTime();
+            const bookingTime = getBookingSlotTime(b);
             return bookingTime === targetTime &&
                    b.instructorId === instructorId &&
                    b.type === bookingType;
@@ -950,41 +1095,35 @@ const DayDisableActions = {
         return slots;
     },
 
-    getTargetInstructors() {
+    getTargetInstructorId() {
         const select = document.getElementById('disableDayInstructorId');
-        if (!select || !select.value) return [];
+        if (!select || !select.value) return null;
 
-        if (select.value === 'all') {
-            return CalendarState.instructors;
-        }
-
-        const instructorId = parseInt(select.value);
-        return CalendarState.instructors.filter(instructor => instructor.ID === instructorId);
+        return select.value === 'all' ? 0 : parseInt(select.value);
     },
 
-    async fetchDayBookings(slots) {
-        if (slots.length === 0) return [];
-
-        const from = new Date(slots[0]);
-        const to = new Date(slots[slots.length - 1]);
-        to.setTime(to.getTime() + 59 * 60 * 1000 + 59 * 1000 + 999);
-
-        return await API.fetchBookings(from, to);
-    },
+    buildBlockRequest(slots) {
+        const dayParts = CalendarState.dayDisable.dayParts;
+        const request = {
+            type: BookingType.DISABLE,
+            from: `${dayParts.year}-${String(dayParts.month).padStart(2, '0')}-${String(dayParts.day).padStart(2, '0')}`
+        };
+        request.to = request.from;
 
-    hasDisabledBooking(bookings, slotDate, instructorId) {
-        const targetTime = slotDate.getTime();
+        // A selection blocks only its hours, a whole day follows each instructor's schedule
+        if (CalendarState.dayDisable.selectedSlots) {
+            const firstHour = Number(getRomeDateHourParts(slots[0]).hour);
+            const lastHour = Number(getRomeDateHourParts(slots[slots.length - 1]).hour);
+            request.startTime = `${String(firstHour).padStart(2, '0')}:00`;
+            request.endTime = `${String(lastHour + 1).padStart(2, '0')}:00`;
+        }
 
-        return bookings.some(booking => {
-            return booking.type === BookingType.DISABLE &&
-                booking.instructorId === instructorId &&
-                new Date(booking.startsAt).getTime() === targetTime;
-        });
+        return request;
     },
 
     async submit() {
-        const instructors = this.getTargetInstructors();
-        if (instructors.length === 0) {
+        const instructorId = this.getTargetInstructorId();
+        if (instructorId === null) {
             UI.showToast('Seleziona un istruttore', false);
             return;
         }
@@ -996,71 +1135,136 @@ const DayDisableActions = {
         }
 
         const isSelection = Boolean(CalendarState.dayDisable.selectedSlots);
+        const request = { ...this.buildBlockRequest(slots), instructorId, locationId: selectedLocationId() };
+
         UI.showLoading(isSelection ? 'Disabilitazione slot...' : 'Disabilitazione giornata...');
+        const data = await BlockActions.send(request);
+        UI.hideLoading();
+        if (!data) return;
 
-        let successCount = 0;
-        let skippedCount = 0;
-        let errorCount = 0;
+        this.closeModal();
+        await Calendar.load();
 
-        let existingBookings = [];
-        try {
-            existingBookings = await this.fetchDayBookings(slots);
-        } catch (error) {
-            UI.hideLoading();
-            UI.showToast('Errore nel controllo degli slot esistenti', false);
-            console.error(error);
+        if (data.created === 0) {
+            UI.showToast(isSelection ? 'Slot gia disabilitati' : 'Giornata gia disabilitata', true);
             return;
         }
 
-        for (const slotDate of slots) {
-            for (const instructor of instructors) {
-                if (this.hasDisabledBooking(existingBookings, slotDate, instructor.ID)) {
-                    skippedCount++;
-                    continue;
-                }
+        const details = data.skipped > 0 ? `, ${data.skipped} gia presenti` : '';
+        const subject = isSelection ? 'Slot disabilitati' : 'Giornata disabilitata';
+        UI.showToast(`${subject}: ${data.created} slot creati${details}`, true);
+    }
+};
 
-                try {
-                    const data = await API.createBooking(BookingType.DISABLE, {
-                        startsAt: slotDate.toISOString(),
-                        instructorId: instructor.ID
+// ============================================================================
+// BULK BLOCK ACTIONS
+// ============================================================================
+const BlockActions = {
+    // send posts a bulk block; when existing bookings collide the admin is asked
+    // whether to block anyway. It returns the response or null on failure.
+    async send(request) {
+        try {
+            let data = await API.blockRange(request);
+            if (data.collisions && data.collisions.length > 0 && data.error) {
+                const names = data.collisions.slice(0, 5).map(c => {
+                    const when = new Date(c.startsAt).toLocaleString('it-IT', {
+                        day: '2-digit',
+                        month: '2-digit',
+                        hour: '2-digit',
+                        minute: '2-digit',
+                        timeZone: BUSINESS_TIME_ZONE
                     });
-
-                    if (data.error) {
-                        errorCount++;
-                    } else {
-                        successCount++;
-                        existingBookings.push({
-                            startsAt: slotDate.toISOString(),
-                            instructorId: instructor.ID,
-                            type: BookingType.DISABLE
-                        });
-                    }
-                } catch (error) {
-                    errorCount++;
-                    console.error(error);
+                    return `${c.firstName} ${c.lastName} (${when})`;
+                });
+                const more = data.collisions.length > names.length ? `\n... e altre ${data.collisions.length - names.length}` : '';
+                if (!confirm(`Ci sono ${data.collisions.length} prenotazioni in conflitto:\n${names.join('\n')}${more}\n\nBloccare comunque?`)) {
+                    return null;
                 }
+                data = await API.blockRange({ ...request, allowCollisions: true });
             }
+
+            if (data.error) {
+                UI.showToast(data.error, false);
+                return null;
+            }
+            return data;
+        } catch (error) {
+            UI.showToast('Errore durante il blocco degli slot', false);
+            console.error(error);
+            return null;
         }
+    },
 
-        UI.hideLoading();
+    openModal() {
+        const select = document.getElementById('blockInstructorId');
+        if (!select) return;
 
-        if (successCount > 0) {
-            this.closeModal();
-            await Calendar.load();
+        select.textContent = '';
+        const all = document.createElement('option');
+        all.value = '0';
+        all.textContent = 'Tutti';
+        select.appendChild(all);
+        CalendarState.instructors.forEach(instructor => {
+            const option = document.createElement('option');
+            option.value = instructor.ID;
+            option.textContent = getInstructorName(instructor);
+            select.appendChild(option);
+        });
 
-            const details = skippedCount > 0 ? `, ${skippedCount} gia presenti` : '';
-            const subject = isSelection ? 'Slot disabilitati' : 'Giornata disabilitata';
-            UI.showToast(`${subject}: ${successCount} slot creati${details}`, errorCount === 0);
-            return;
+        document.getElementById('blockRangeModal').style.display = 'block';
+    },
+
+    closeModal() {
+        const modal = document.getElementById('blockRangeModal');
+        if (modal) {
+            modal.style.display = 'none';
+        }
+        const form = document.getElementById('blockRangeForm');
+        if (form) {
+            form.reset();
         }
+    },
 
-        if (skippedCount > 0 && errorCount === 0) {
-            this.closeModal();
-            UI.showToast(isSelection ? 'Slot gia disabilitati' : 'Giornata gia disabilitata', true);
+    async submit() {
+        const from = document.getElementById('blockFrom').value;
+        const to = document.getElementById('blockTo').value;
+        if (!from || !to) {
+            UI.showToast('Seleziona il periodo', false);
             return;
         }
 
-        UI.showToast('Errore durante la disabilitazione degli slot', false);
+        const request = {
+            instructorId: parseInt(document.getElementById('blockInstructorId').value) || 0,
+            locationId: selectedLocationId(),
+            type: document.getElementById('blockType').value,
+            from,
+            to
+        };
+
+        const startTime = document.getElementById('blockStartTime').value;
+        const endTime = document.getElementById('blockEndTime').value;
+        if (startTime || endTime) {
+            request.startTime = startTime;
+            request.endTime = endTime;
+        }
+
+        const weekdays = Array.from(document.querySelectorAll('#blockWeekdays input[type="checkbox"]'))
+            .filter(cb => cb.checked)
+            .map(cb => cb.value);
+        if (weekdays.length > 0) {
+            request.rrule = `FREQ=WEEKLY;BYDAY=${weekdays.join(',')}`;
+        }
+
+        UI.showLoading('Blocco slot...');
+        const data = await this.send(request);
+        UI.hideLoading();
+        if (!data) return;
+
+        this.closeModal();
+        await Calendar.load();
+
+        const details = data.skipped > 0 ? `, ${data.skipped} gia presenti` : '';
+        UI.showToast(`${data.created} slot bloccati${details}`, true);
     }
 };
 
@@ -1173,7 +1377,12 @@ const DataLoader = {
         to.setTime(to.getTime() + 59 * 60 * 1000 + 59 * 1000 + 999);
 
         try {
-            CalendarState.bookings = await API.fetchBookings(from, to, CalendarState.selectedInstructorId);
+            const [bookings, classSessions] = await Promise.all([
+                API.fetchBookings(from, to, CalendarState.selectedInstructorId, CalendarState.selectedLocationId),
+                API.fetchClassSessions(from, to, CalendarState.selectedInstructorId, CalendarState.selectedLocationId)
+            ]);
+            CalendarState.bookings = bookings;
+            CalendarState.classSessions = classSessions;
             Calendar.render();
             UI.hideLoading();
         } catch (error) {
@@ -1192,16 +1401,53 @@ const DataLoader = {
         }
     },
 
+    async loadServices() {
+        try {
+            const data = await API.fetchServices();
+            CalendarState.services = Array.isArray(data) ? data : [];
+        } catch (error) {
+            console.error('Error loading services:', error);
+        }
+    },
+
+    async loadLocations() {
+        try {
+            const data = await API.fetchLocations();
+            CalendarState.locations = Array.isArray(data) ? data : [];
+            this.populateLocationFilter();
+        } catch (error) {
+            console.error('Error loading locations:', error);
+        }
+    },
+
+    // loadInstructors loads the instructors of the selected location, or all of them
     async loadInstructors() {
         try {
-            const data = await API.fetchInstructors();
-            CalendarState.instructors = data || [];
+            const data = await API.fetchInstructors(CalendarState.selectedLocationId);
+            CalendarState.instructors = Array.isArray(data) ? data : [];
             this.populateInstructorFilter();
         } catch (error) {
             console.error('Error loading instructors:', error);
         }
     },
 
+    populateLocationFilter() {
+        const select = document.getElementById('locationFilter');
+        if (!select) return;
+
+        select.textContent = '';
+        const all = document.createElement('option');
+        all.value = '';
+        all.textContent = 'Tutte';
+        select.appendChild(all);
+        CalendarState.locations.forEach(location => {
+            const option = document.createElement('option');
+            option.value = location.id;
+            option.textContent = location.name;
+            select.appendChild(option);
+        });
+    },
+
     populateInstructorFilter() {
         const select = document.getElementById('instructorFilter');
         if (!select) return;
@@ -1295,7 +1541,7 @@ const Calendar = {
 
         // Get all bookings for this time slot
         const allBookings = CalendarState.bookings.filter(b => {
-            const bookingTime = new Date(b.startsAt).getTime();
+            const bookingTime = getBookingSlotTime(b);
             return bookingTime === targetTime;
         });
 
@@ -1318,13 +1564,30 @@ const Calendar = {
             : `class="${slotClasses.join(' ')}" onclick="Calendar.handleSlotClick(event, '${isoTime}')" data-slot="${isoTime}" ${dayAttributes}`;
         let html = `<div ${slotAttributes}>`;
 
+        // Render group classes starting in this slot
+        CalendarState.classSessions
+            .filter(s => getBookingSlotTime(s) === targetTime)
+            .forEach(session => {
+                const time = new Date(session.startsAt).toLocaleTimeString('it-IT', {
+                    hour: '2-digit',
+                    minute: '2-digit',
+                    timeZone: BUSINESS_TIME_ZONE
+                });
+                const room = session.room ? `, ${session.room}` : '';
+                const label = `${session.title} ${time} (${session.enrolled}/${session.capacity}) - ${session.instructorName}`;
+                html += `<div class="group-class" title="${escapeHTML(label + room)}" onclick="if (CalendarDragSelection.consumeSuppressedClick(event)) return; event.stopPropagation(); window.location.href = '/admin/classes'">
+                    <span class="material-icons slot-icon">groups</span> ${escapeHTML(label)}
+                </div>`;
+            });
+
         // Render each booking
         filteredBookings.forEach(booking => {
             const instructor = CalendarState.instructors.find(i => i.ID === booking.instructorId);
             if (!instructor) return;
 
+            const serviceLabel = getBookingServiceLabel(booking);
             const instructorName = `${instructor.FirstName} ${instructor.LastName}`.trim();
-            const safeInstructorName = escapeHTML(instructorName);
+            const safeInstructorName = escapeHTML(serviceLabel ? `${instructorName} (${serviceLabel})` : instructorName);
 
             if (booking.type === BookingType.DISABLE) {
                 html += `<div class="disabled" title="Non disponibile - ${safeInstructorName}">
@@ -1340,8 +1603,9 @@ const Calendar = {
                 </div>`;
             } else if (booking.type === BookingType.SIMPLE && booking.user) {
                 const cssClass = booking.user.subType === 'SHARED' ? 'booking shared' : 'booking';
-                const displayName = `${instructorName} - ${booking.user.lastName} ${booking.user.firstName.substring(0, 3)}.`;
-                const title = `${instructorName} - ${booking.user.firstName} ${booking.user.lastName}`;
+                const prefix = serviceLabel ? `${serviceLabel} ${instructorName}` : instructorName;
+                const displayName = `${prefix} - ${booking.user.lastName} ${booking.user.firstName.substring(0, 3)}.`;
+                const title = `${prefix} - ${booking.user.firstName} ${booking.user.lastName}`;
 
                 html += `<div class="${cssClass}" title="${escapeHTML(title)}" onclick="if (CalendarDragSelection.consumeSuppressedClick(event)) return; event.stopPropagation(); Calendar.handleBookingClick('${booking.id}', '${isoTime}')">
                     ${escapeHTML(displayName)}
@@ -1396,8 +1660,10 @@ const BookingActions = {
         UI.showLoading('Eliminazione prenotazione...');
         try {
             const refundCheckbox = document.getElementById('refund-checkbox');
+            const policyCheckbox = document.getElementById('policy-checkbox');
             const shouldRefund = refundCheckbox ? refundCheckbox.checked : CalendarState.modal.refund;
-            const data = await API.deleteBooking(CalendarState.modal.bookingId, shouldRefund);
+            const usePolicy = policyCheckbox ? policyCheckbox.checked : CalendarState.modal.usePolicy;
+            const data = await API.deleteBooking(CalendarState.modal.bookingId, usePolicy ? undefined : shouldRefund);
             UI.hideLoading();
 
             if (data.error) {
@@ -1418,6 +1684,19 @@ const BookingActions = {
 // ============================================================================
 // GLOBAL EVENT HANDLERS
 // ============================================================================
+async function onLocationFilterChange() {
+    const select = document.getElementById('locationFilter');
+    if (!select) return;
+
+    CalendarState.selectedLocationId = select.value;
+    const location = CalendarState.locations.find(l => String(l.id) === select.value);
+    BUSINESS_TIME_ZONE = location ? location.timeZone : DEFAULT_TIME_ZONE;
+
+    CalendarState.selectedInstructorId = '';
+    await DataLoader.loadInstructors();
+    Calendar.load();
+}
+
 function onInstructorFilterChange() {
     const select = document.getElementById('instructorFilter');
     if (select) {
@@ -1446,6 +1725,18 @@ function confirmDisableDay() {
     DayDisableActions.submit();
 }
 
+function openBlockRangeModal() {
+    BlockActions.openModal();
+}
+
+function closeBlockRangeModal() {
+    BlockActions.closeModal();
+}
+
+function confirmBlockRange() {
+    BlockActions.submit();
+}
+
 function previousPeriod() {
     Calendar.previousWeek();
 }
@@ -1462,6 +1753,15 @@ function toggleRefund(elem) {
     CalendarState.modal.refund = elem.checked;
 }
 
+// togglePolicy lets the admin override the cancellation policy with the refund checkbox
+function togglePolicy(elem) {
+    CalendarState.modal.usePolicy = elem.checked;
+    const refundCheckbox = document.getElementById('refund-checkbox');
+    if (refundCheckbox) {
+        refundCheckbox.disabled = elem.checked;
+    }
+}
+
 window.onclick = function(event) {
     DayDisableActions.hideMenu();
 
@@ -1474,6 +1774,11 @@ window.onclick = function(event) {
     if (event.target === disableDayModal) {
         closeDisableDayModal();
     }
+
+    const blockRangeModal = document.getElementById('blockRangeModal');
+    if (event.target === blockRangeModal) {
+        closeBlockRangeModal();
+    }
 };
 
 // ============================================================================
@@ -1485,7 +1790,9 @@ document.addEventListener('DOMContentLoaded', function() {
 
     Promise.all([
         DataLoader.loadUsers(),
-        DataLoader.loadInstructors()
+        DataLoader.loadLocations(),
+        DataLoader.loadInstructors(),
+        DataLoader.loadServices()
     ]).then(() => {
         Calendar.load();
     }).catch(error => {
@@ -0,0 +1,382 @@
+(function () {
+    const BUSINESS_TIME_ZONE = 'Europe/Rome';
+    const templatesEndpoint = '/api/admin/classes/templates';
+    const sessionsEndpoint = '/api/admin/classes/sessions';
+    let instructors = {};
+    let templates = [];
+    let sessions = [];
+    let editingId = null;
+    let schedulingTemplate = null;
+
+    function icon(name) {
+        const elem = document.createElement('span');
+        elem.className = 'material-icons';
+        elem.textContent = name;
+        return elem;
+    }
+
+    function iconButton(name, title, onClick) {
+        const button = document.createElement('button');
+        button.className = 'btn-icon';
+        button.type = 'button';
+        button.title = title;
+        button.appendChild(icon(name));
+        button.addEventListener('click', onClick);
+        return button;
+    }
+
+    function emptyRow(body, text) {
+        const row = document.createElement('tr');
+        const cell = document.createElement('td');
+        cell.colSpan = 6;
+        cell.className = 'empty-cell';
+        cell.textContent = text;
+        row.appendChild(cell);
+        body.appendChild(row);
+    }
+
+    function formatDateTime(value) {
+        return new Date(value).toLocaleString('it-IT', {
+            weekday: 'short',
+            day: '2-digit',
+            month: '2-digit',
+            hour: '2-digit',
+            minute: '2-digit',
+            timeZone: BUSINESS_TIME_ZONE,
+        });
+    }
+
+    async function sendJSON(url, method, body) {
+        const options = {
+            method,
+            headers: { 'X-CSRF-Token': getCookie('csrf_token') },
+        };
+        if (body) {
+            options.headers['Content-Type'] = 'application/json';
+            options.body = JSON.stringify(body);
+        }
+        return fetch(url, options);
+    }
+
+    async function loadInstructors() {
+        const response = await fetch('/api/admin/instructors');
+        if (!response.ok) throw new Error('Failed to load instructors');
+        const list = await response.json();
+        document.querySelectorAll('.instructor-select').forEach(select => {
+            list.forEach(i => {
+                instructors[i.ID] = `${i.FirstName} ${i.LastName}`.trim();
+                const option = document.createElement('option');
+                option.value = i.ID;
+                option.textContent = instructors[i.ID];
+                select.appendChild(option);
+            });
+        });
+    }
+
+    // Only rooms can host a class; the other sessions and the bookings
+    // needing the same room are checked against it
+    async function loadResources() {
+        const response = await fetch('/api/admin/resources');
+        if (!response.ok) throw new Error('Failed to load resources');
+        const list = await response.json();
+        document.querySelectorAll('.resource-select').forEach(select => {
+            list.filter(r => r.kind === 'ROOM').forEach(r => {
+                const option = document.createElement('option');
+                option.value = r.id;
+                option.textContent = r.enabled ? r.name : `${r.name} (disattivata)`;
+                select.appendChild(option);
+            });
+        });
+    }
+
+    async function loadTemplates() {
+        try {
+            const response = await fetch(templatesEndpoint);
+            if (!response.ok) throw new Error('Failed to load classes');
+            templates = await response.json();
+            renderTemplates();
+        } catch (error) {
+            console.error('Error loading classes:', error);
+            UI.showToast('Errore nel caricamento delle classi');
+        }
+    }
+
+    async function loadSessions() {
+        const from = new Date();
+        const to = new Date(from.getTime() + 60 * 24 * 60 * 60 * 1000);
+        try {
+            const response = await fetch(`${sessionsEndpoint}?from=${from.toISOString()}&to=${to.toISOString()}`);
+            if (!response.ok) throw new Error('Failed to load sessions');
+            sessions = await response.json();
+            renderSessions();
+        } catch (error) {
+            console.error('Error loading sessions:', error);
+            UI.showToast('Errore nel caricamento delle lezioni');
+        }
+    }
+
+    function renderTemplates() {
+        const body = document.getElementById('templates-table-body');
+        body.textContent = '';
+
+        if (templates.length === 0) {
+            emptyRow(body, 'Nessuna classe');
+            return;
+        }
+
+        templates.forEach(t => {
+            const row = document.createElement('tr');
+
+            const title = document.createElement('td');
+            title.textContent = t.title;
+            const instructor = document.createElement('td');
+            instructor.textContent = (t.instructorId && instructors[t.instructorId]) || '-';
+            const room = document.createElement('td');
+            room.textContent = t.room || '-';
+            const capacity = document.createElement('td');
+            capacity.textContent = t.capacity;
+            const duration = document.createElement('td');
+            duration.textContent = `${t.durationMinutes} min`;
+
+            const actions = document.createElement('td');
+            actions.append(
+                iconButton('event', 'Programma lezione', () => openSessionModal(t)),
+                iconButton('edit', 'Modifica', () => openTemplateModal(t)),
+                iconButton('delete', 'Elimina', () => deleteTemplate(t.id)),
+            );
+
+            row.append(title, instructor, room, capacity, duration, actions);
+            body.appendChild(row);
+        });
+    }
+
+    function renderSessions() {
+        const body = document.getElementById('sessions-table-body');
+        body.textContent = '';
+
+        if (sessions.length === 0) {
+            emptyRow(body, 'Nessuna lezione programmata');
+            return;
+        }
+
+        sessions.forEach(s => {
+            const row = document.createElement('tr');
+
+            const date = document.createElement('td');
+            date.textContent = formatDateTime(s.startsAt);
+            const title = document.createElement('td');
+            title.textContent = s.title;
+            const instructor = document.createElement('td');
+            instructor.textContent = s.instructorName || '-';
+            const room = document.createElement('td');
+            room.textContent = s.room || '-';
+            const enrolled = document.createElement('td');
+            enrolled.textContent = `${s.enrolled}/${s.capacity}`;
+
+            const actions = document.createElement('td');
+            actions.append(
+                iconButton('group', 'Iscritti', () => showParticipants(s)),
+                iconButton('event_busy', 'Annulla lezione', () => deleteSession(s)),
+            );
+
+            row.append(date, title, instructor, room, enrolled, actions);
+            body.appendChild(row);
+        });
+    }
+
+    function openTemplateModal(template) {
+        editingId = template ? template.id : null;
+        document.getElementById('templateModalTitle').textContent = template ? 'Modifica Classe' : 'Nuova Classe';
+        document.getElementById('template-title').value = template ? template.title : '';
+        document.getElementById('template-description').value = template ? template.description : '';
+        document.getElementById('template-instructor').value = template && template.instructorId ? template.instructorId : '';
+        document.getElementById('template-room').value = template ? template.room : '';
+        document.getElementById('template-resource').value = template && template.resourceId ? template.resourceId : '';
+        document.getElementById('template-capacity').value = template ? template.capacity : 10;
+        document.getElementById('template-duration').value = template ? template.durationMinutes : 60;
+        document.getElementById('templateModal').style.display = 'block';
+    }
+
+    function closeTemplateModal() {
+        document.getElementById('templateModal').style.display = 'none';
+        document.getElementById('templateForm').reset();
+        editingId = null;
+    }
+
+    async function saveTemplate() {
+        const title = document.getElementById('template-title').value.trim();
+        const capacity = parseInt(document.getElementById('template-capacity').value, 10);
+        const durationMinutes = parseInt(document.getElementById('template-duration').value, 10);
+
+        if (!title || isNaN(capacity) || isNaN(durationMinutes)) {
+            UI.showToast('Titolo, posti e durata sono obbligatori');
+            return;
+        }
+
+        const url = editingId ? `${templatesEndpoint}/${editingId}` : templatesEndpoint;
+        try {
+            const response = await sendJSON(url, editingId ? 'PUT' : 'POST', {
+                title,
+                description: document.getElementById('template-description').value.trim(),
+                instructorId: parseInt(document.getElementById('template-instructor').value, 10) || 0,
+                room: document.getElementById('template-room').value.trim(),
+                resourceId: parseInt(document.getElementById('template-resource').value, 10) || 0,
+                capacity,
+                durationMinutes,
+            });
+
+            if (response.ok) {
+                UI.showToast(editingId ? 'Classe aggiornata con successo' : 'Classe creata con successo', true);
+                closeTemplateModal();
+                loadTemplates();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante il salvataggio');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function deleteTemplate(id) {
+        if (!confirm('Sei sicuro di voler eliminare questa classe?')) {
+            return;
+        }
+
+        try {
+            const response = await sendJSON(`${templatesEndpoint}/${id}`, 'DELETE');
+            if (response.ok) {
+                UI.showToast('Classe eliminata con successo', true);
+                loadTemplates();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante l\'eliminazione');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    function openSessionModal(template) {
+        schedulingTemplate = template;
+        document.getElementById('sessionModalTitle').textContent = `Programma ${template.title}`;
+        document.getElementById('sessionModal').style.display = 'block';
+    }
+
+    function closeSessionModal() {
+        document.getElementById('sessionModal').style.display = 'none';
+        document.getElementById('sessionForm').reset();
+        schedulingTemplate = null;
+    }
+
+    async function saveSession() {
+        const startsAt = document.getElementById('session-startsAt').value;
+        if (!startsAt) {
+            UI.showToast('Seleziona data e ora');
+            return;
+        }
+
+        try {
+            const response = await sendJSON(sessionsEndpoint, 'POST', {
+                templateId: schedulingTemplate.id,
+                startsAt,
+                instructorId: parseInt(document.getElementById('session-instructor').value, 10) || 0,
+                room: document.getElementById('session-room').value.trim(),
+                resourceId: parseInt(document.getElementById('session-resource').value, 10) || 0,
+                capacity: parseInt(document.getElementById('session-capacity').value, 10) || 0,
+            });
+
+            if (response.ok) {
+                UI.showToast('Lezione programmata con successo', true);
+                closeSessionModal();
+                loadSessions();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante la programmazione');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function deleteSession(session) {
+        if (!confirm(`Annullare ${session.title} del ${formatDateTime(session.startsAt)}? Gli accessi degli iscritti verranno rimborsati.`)) {
+            return;
+        }
+
+        try {
+            const response = await sendJSON(`${sessionsEndpoint}/${session.id}`, 'DELETE');
+            const data = await response.json();
+            if (response.ok) {
+                UI.showToast(`Lezione annullata, ${data.refunded} accessi rimborsati`, true);
+                loadSessions();
+            } else {
+                UI.showToast(data.error || 'Errore durante l\'annullamento');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function showParticipants(session) {
+        try {
+            const response = await fetch(`${sessionsEndpoint}/${session.id}/participants`);
+            if (!response.ok) throw new Error('Failed to load participants');
+            const participants = await response.json();
+
+            document.getElementById('participantsModalTitle').textContent =
+                `${session.title} - ${formatDateTime(session.startsAt)}`;
+            const list = document.getElementById('participants-list');
+            list.textContent = '';
+            if (participants.length === 0) {
+                const item = document.createElement('li');
+                item.textContent = 'Nessun iscritto';
+                list.appendChild(item);
+            }
+            participants.forEach(p => {
+                const item = document.createElement('li');
+                item.textContent = `${p.firstName} ${p.lastName} (${p.email})`;
+                list.appendChild(item);
+            });
+            document.getElementById('participantsModal').style.display = 'block';
+        } catch (error) {
+            console.error('Error loading participants:', error);
+            UI.showToast('Errore nel caricamento degli iscritti');
+        }
+    }
+
+    function closeParticipantsModal() {
+        document.getElementById('participantsModal').style.display = 'none';
+    }
+
+    document.addEventListener('DOMContentLoaded', async () => {
+        document.getElementById('createTemplateBtn').addEventListener('click', () => openTemplateModal(null));
+        document.getElementById('closeTemplateModalBtn').addEventListener('click', closeTemplateModal);
+        document.getElementById('closeTemplateModalIcon').addEventListener('click', closeTemplateModal);
+        document.getElementById('saveTemplateBtn').addEventListener('click', saveTemplate);
+        document.getElementById('closeSessionModalBtn').addEventListener('click', closeSessionModal);
+        document.getElementById('closeSessionModalIcon').addEventListener('click', closeSessionModal);
+        document.getElementById('saveSessionBtn').addEventListener('click', saveSession);
+        document.getElementById('closeParticipantsModalBtn').addEventListener('click', closeParticipantsModal);
+        document.getElementById('closeParticipantsModalIcon').addEventListener('click', closeParticipantsModal);
+
+        try {
+            await loadInstructors();
+        } catch (error) {
+            console.error('Error loading instructors:', error);
+            UI.showToast('Errore nel caricamento degli istruttori');
+        }
+        try {
+            await loadResources();
+        } catch (error) {
+            console.error('Error loading resources:', error);
+            UI.showToast('Errore nel caricamento delle sale');
+        }
+        loadTemplates();
+        loadSessions();
+    });
+})();
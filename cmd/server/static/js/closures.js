@@ -0,0 +1,209 @@
+(function () {
+    const endpoint = '/api/admin/closures';
+    let instructors = {};
+    let locations = {};
+
+    function formatDate(value) {
+        const [year, month, day] = value.split('-');
+        return `${day}/${month}/${year}`;
+    }
+
+    function icon(name) {
+        const elem = document.createElement('span');
+        elem.className = 'material-icons';
+        elem.textContent = name;
+        return elem;
+    }
+
+    // The target select holds locations as "location:<id>" and instructors
+    // as "instructor:<id>"; the empty value closes every location.
+    async function loadTargets() {
+        const [locationResponse, instructorResponse] = await Promise.all([
+            fetch('/api/admin/locations'),
+            fetch('/api/admin/instructors'),
+        ]);
+        if (!locationResponse.ok) throw new Error('Failed to load locations');
+        if (!instructorResponse.ok) throw new Error('Failed to load instructors');
+
+        const locationGroup = document.getElementById('closure-target-locations');
+        (await locationResponse.json()).forEach(l => {
+            locations[l.id] = l.name;
+            const option = document.createElement('option');
+            option.value = `location:${l.id}`;
+            option.textContent = l.name;
+            locationGroup.appendChild(option);
+        });
+
+        const instructorGroup = document.getElementById('closure-target-instructors');
+        (await instructorResponse.json()).forEach(i => {
+            instructors[i.ID] = `${i.FirstName} ${i.LastName}`.trim();
+            const option = document.createElement('option');
+            option.value = `instructor:${i.ID}`;
+            option.textContent = instructors[i.ID];
+            instructorGroup.appendChild(option);
+        });
+    }
+
+    function closureTarget(c) {
+        if (c.instructorId) return instructors[c.instructorId] || '-';
+        if (c.locationId) return locations[c.locationId] || '-';
+        return 'Tutte le sedi';
+    }
+
+    async function loadClosures() {
+        const year = document.getElementById('closures-year').value;
+        try {
+            const response = await fetch(`${endpoint}?year=${encodeURIComponent(year)}`);
+            if (!response.ok) throw new Error('Failed to load closures');
+            const closures = await response.json();
+            closures.sort((a, b) => a.startsOn.localeCompare(b.startsOn));
+            renderClosures(closures);
+        } catch (error) {
+            console.error('Error loading closures:', error);
+            UI.showToast('Errore nel caricamento delle chiusure');
+        }
+    }
+
+    function renderClosures(closures) {
+        const body = document.getElementById('closures-table-body');
+        body.textContent = '';
+
+        if (closures.length === 0) {
+            const row = document.createElement('tr');
+            const cell = document.createElement('td');
+            cell.colSpan = 5;
+            cell.className = 'empty-cell';
+            cell.textContent = 'Nessuna chiusura';
+            row.appendChild(cell);
+            body.appendChild(row);
+            return;
+        }
+
+        closures.forEach(c => {
+            const row = document.createElement('tr');
+
+            const starts = document.createElement('td');
+            starts.textContent = formatDate(c.startsOn);
+            const ends = document.createElement('td');
+            ends.textContent = formatDate(c.endsOn);
+
+            const instructor = document.createElement('td');
+            instructor.textContent = closureTarget(c);
+
+            const reason = document.createElement('td');
+            reason.textContent = c.reason;
+            if (c.holiday) {
+                const badge = document.createElement('span');
+                badge.className = 'badge badge-warning';
+                badge.textContent = 'Festività';
+                reason.append(' ', badge);
+            }
+
+            const actions = document.createElement('td');
+            if (!c.holiday) {
+                const deleteButton = document.createElement('button');
+                deleteButton.className = 'btn-icon';
+                deleteButton.type = 'button';
+                deleteButton.title = 'Elimina';
+                deleteButton.appendChild(icon('delete'));
+                deleteButton.addEventListener('click', () => deleteClosure(c.id));
+                actions.appendChild(deleteButton);
+            }
+
+            row.append(starts, ends, instructor, reason, actions);
+            body.appendChild(row);
+        });
+    }
+
+    function openModal() {
+        document.getElementById('closureModal').style.display = 'block';
+    }
+
+    function closeModal() {
+        document.getElementById('closureModal').style.display = 'none';
+        document.getElementById('closureForm').reset();
+    }
+
+    async function createClosure() {
+        const startsOn = document.getElementById('closure-startsOn').value;
+        const endsOn = document.getElementById('closure-endsOn').value;
+        const [kind, targetId] = document.getElementById('closure-target').value.split(':');
+        const reason = document.getElementById('closure-reason').value;
+
+        if (!startsOn) {
+            UI.showToast('La data di inizio è obbligatoria');
+            return;
+        }
+
+        try {
+            const response = await fetch(endpoint, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCookie('csrf_token'),
+                },
+                body: JSON.stringify({
+                    startsOn,
+                    endsOn: endsOn || startsOn,
+                    instructorId: kind === 'instructor' ? parseInt(targetId, 10) : null,
+                    locationId: kind === 'location' ? parseInt(targetId, 10) : null,
+                    reason,
+                }),
+            });
+
+            if (response.ok) {
+                UI.showToast('Chiusura creata con successo', true);
+                closeModal();
+                loadClosures();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante la creazione');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function deleteClosure(id) {
+        if (!confirm('Sei sicuro di voler eliminare questa chiusura?')) {
+            return;
+        }
+
+        try {
+            const response = await fetch(`${endpoint}/${id}`, {
+                method: 'DELETE',
+                headers: { 'X-CSRF-Token': getCookie('csrf_token') },
+            });
+
+            if (response.ok) {
+                UI.showToast('Chiusura eliminata con successo', true);
+                loadClosures();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante l\'eliminazione');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    document.addEventListener('DOMContentLoaded', async () => {
+        const yearInput = document.getElementById('closures-year');
+        yearInput.value = new Date().getFullYear();
+        yearInput.addEventListener('change', loadClosures);
+
+        document.getElementById('createClosureBtn').addEventListener('click', openModal);
+        document.getElementById('closeClosureModalBtn').addEventListener('click', closeModal);
+        document.getElementById('closeClosureModalIcon').addEventListener('click', closeModal);
+        document.getElementById('saveClosureBtn').addEventListener('click', createClosure);
+
+        try {
+            await loadTargets();
+        } catch (error) {
+            console.error('Error loading closure targets:', error);
+        }
+        loadClosures();
+    });
+})();
@@ -0,0 +1,89 @@
+(function () {
+    // A code scanned again within this time is ignored
+    const RESCAN_DELAY_MS = 5000;
+    const RESULT_DURATION_MS = 5000;
+    let lastToken = null;
+    let lastScanAt = 0;
+    let resultTimer = null;
+
+    function showResult(text, success) {
+        const result = document.getElementById('kiosk-result');
+        result.textContent = text;
+        result.className = 'kiosk-result ' + (success ? 'success' : 'error');
+        clearTimeout(resultTimer);
+        resultTimer = setTimeout(() => {
+            result.textContent = '';
+            result.className = 'kiosk-result';
+        }, RESULT_DURATION_MS);
+    }
+
+    async function checkIn(token) {
+        token = token.trim();
+        if (!token) return;
+
+        const now = Date.now();
+        if (token === lastToken && now - lastScanAt < RESCAN_DELAY_MS) return;
+        lastToken = token;
+        lastScanAt = now;
+
+        try {
+            const response = await fetch('/api/admin/checkin', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCookie('csrf_token'),
+                },
+                body: JSON.stringify({ token }),
+            });
+            const data = await response.json();
+
+            if (response.ok) {
+                const name = `${data.firstName} ${data.lastName}`.trim();
+                showResult(data.alreadyCheckedIn ? `${name}: check-in già registrato` : `Benvenuto/a ${name}!`, true);
+            } else {
+                showResult(data.error || 'Check-in non riuscito', false);
+            }
+        } catch (error) {
+            showResult('Errore di connessione', false);
+            console.error('Error:', error);
+        }
+    }
+
+    // startCamera scans QR codes with the camera where the browser supports it,
+    // otherwise codes are typed or read by a keyboard scanner
+    async function startCamera() {
+        if (!('BarcodeDetector' in window) || !navigator.mediaDevices) return;
+
+        try {
+            const detector = new BarcodeDetector({ formats: ['qr_code'] });
+            const stream = await navigator.mediaDevices.getUserMedia({ video: { facingMode: 'environment' } });
+            const video = document.getElementById('kiosk-video');
+            video.srcObject = stream;
+            video.classList.remove('is-hidden');
+
+            setInterval(async () => {
+                if (video.readyState < video.HAVE_CURRENT_DATA) return;
+                try {
+                    const codes = await detector.detect(video);
+                    if (codes.length > 0) checkIn(codes[0].rawValue);
+                } catch (error) {
+                    console.error('Error scanning:', error);
+                }
+            }, 500);
+        } catch (error) {
+            console.error('Camera unavailable:', error);
+        }
+    }
+
+    document.addEventListener('DOMContentLoaded', () => {
+        const input = document.getElementById('kiosk-token');
+        input.addEventListener('keydown', (event) => {
+            if (event.key !== 'Enter') return;
+            event.preventDefault();
+            checkIn(input.value);
+            input.value = '';
+        });
+
+        startCamera();
+    });
+})();
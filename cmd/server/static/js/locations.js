@@ -0,0 +1,164 @@
+(function () {
+    const endpoint = '/api/admin/locations';
+    let editingId = null;
+
+    function icon(name) {
+        const elem = document.createElement('span');
+        elem.className = 'material-icons';
+        elem.textContent = name;
+        return elem;
+    }
+
+    async function loadLocations() {
+        try {
+            const response = await fetch(endpoint);
+            if (!response.ok) throw new Error('Failed to load locations');
+            renderLocations(await response.json());
+        } catch (error) {
+            console.error('Error loading locations:', error);
+            UI.showToast('Errore nel caricamento delle sedi');
+        }
+    }
+
+    function renderLocations(locations) {
+        const body = document.getElementById('locations-table-body');
+        body.textContent = '';
+
+        if (locations.length === 0) {
+            const row = document.createElement('tr');
+            const cell = document.createElement('td');
+            cell.colSpan = 5;
+            cell.className = 'empty-cell';
+            cell.textContent = 'Nessuna sede';
+            row.appendChild(cell);
+            body.appendChild(row);
+            return;
+        }
+
+        locations.forEach(l => {
+            const row = document.createElement('tr');
+
+            const name = document.createElement('td');
+            name.textContent = l.name;
+            const address = document.createElement('td');
+            address.textContent = l.address || '-';
+            const timeZone = document.createElement('td');
+            timeZone.textContent = l.timeZone;
+
+            const status = document.createElement('td');
+            const pill = document.createElement('span');
+            pill.className = 'status-pill ' + (l.enabled ? 'enabled' : 'disabled');
+            pill.textContent = l.enabled ? 'Abilitata' : 'Disabilitata';
+            status.appendChild(pill);
+
+            const actions = document.createElement('td');
+            const buttons = document.createElement('div');
+            buttons.className = 'action-buttons';
+
+            const editButton = document.createElement('button');
+            editButton.className = 'btn-icon';
+            editButton.type = 'button';
+            editButton.title = 'Modifica';
+            editButton.appendChild(icon('edit'));
+            editButton.addEventListener('click', () => openModal(l));
+
+            const deleteButton = document.createElement('button');
+            deleteButton.className = 'btn-icon';
+            deleteButton.type = 'button';
+            deleteButton.title = 'Elimina';
+            deleteButton.appendChild(icon('delete'));
+            deleteButton.addEventListener('click', () => deleteLocation(l.id));
+
+            buttons.append(editButton, deleteButton);
+            actions.appendChild(buttons);
+
+            row.append(name, address, timeZone, status, actions);
+            body.appendChild(row);
+        });
+    }
+
+    function openModal(location) {
+        editingId = location ? location.id : null;
+        document.getElementById('locationModalTitle').textContent = location ? 'Modifica Sede' : 'Nuova Sede';
+        document.getElementById('location-name').value = location ? location.name : '';
+        document.getElementById('location-address').value = location ? location.address : '';
+        document.getElementById('location-timeZone').value = location ? location.timeZone : 'Europe/Rome';
+        document.getElementById('location-enabled').checked = location ? location.enabled : true;
+        document.getElementById('locationModal').style.display = 'block';
+    }
+
+    function closeModal() {
+        document.getElementById('locationModal').style.display = 'none';
+        document.getElementById('locationForm').reset();
+        editingId = null;
+    }
+
+    async function saveLocation() {
+        const name = document.getElementById('location-name').value.trim();
+        const address = document.getElementById('location-address').value.trim();
+        const timeZone = document.getElementById('location-timeZone').value.trim();
+        const enabled = document.getElementById('location-enabled').checked;
+
+        if (!name) {
+            UI.showToast('Il nome è obbligatorio');
+            return;
+        }
+
+        const url = editingId ? `${endpoint}/${editingId}` : endpoint;
+        try {
+            const response = await fetch(url, {
+                method: editingId ? 'PUT' : 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCookie('csrf_token'),
+                },
+                body: JSON.stringify({ name, address, timeZone, enabled }),
+            });
+
+            if (response.ok) {
+                UI.showToast(editingId ? 'Sede aggiornata con successo' : 'Sede creata con successo', true);
+                closeModal();
+                loadLocations();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante il salvataggio');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function deleteLocation(id) {
+        if (!confirm('Sei sicuro di voler eliminare questa sede?')) {
+            return;
+        }
+
+        try {
+            const response = await fetch(`${endpoint}/${id}`, {
+                method: 'DELETE',
+                headers: { 'X-CSRF-Token': getCookie('csrf_token') },
+            });
+
+            if (response.ok) {
+                UI.showToast('Sede eliminata con successo', true);
+                loadLocations();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante l\'eliminazione');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    document.addEventListener('DOMContentLoaded', () => {
+        document.getElementById('createLocationBtn').addEventListener('click', () => openModal(null));
+        document.getElementById('closeLocationModalBtn').addEventListener('click', closeModal);
+        document.getElementById('closeLocationModalIcon').addEventListener('click', closeModal);
+        document.getElementById('saveLocationBtn').addEventListener('click', saveLocation);
+
+        loadLocations();
+    });
+})();
@@ -0,0 +1,202 @@
+(function () {
+    const endpoint = '/api/admin/plans';
+    const subTypeLabels = { SHARED: 'Condiviso', SINGLE: 'Singolo' };
+    let plans = [];
+    let policies = {};
+    let editingId = null;
+
+    function icon(name) {
+        const elem = document.createElement('span');
+        elem.className = 'material-icons';
+        elem.textContent = name;
+        return elem;
+    }
+
+    function formatPrice(cents) {
+        return (cents / 100).toLocaleString('it-IT', { style: 'currency', currency: 'EUR' });
+    }
+
+    async function loadPolicies() {
+        const response = await fetch('/api/admin/policies');
+        if (!response.ok) throw new Error('Failed to load policies');
+        const list = await response.json();
+        const select = document.getElementById('plan-policy');
+        list.forEach(p => {
+            policies[p.id] = p.name;
+
+            const option = document.createElement('option');
+            option.value = p.id;
+            option.textContent = p.name;
+            select.appendChild(option);
+        });
+    }
+
+    async function loadPlans() {
+        try {
+            const response = await fetch(endpoint);
+            if (!response.ok) throw new Error('Failed to load plans');
+            plans = await response.json();
+            renderPlans();
+        } catch (error) {
+            console.error('Error loading plans:', error);
+            UI.showToast('Errore nel caricamento dei piani');
+        }
+    }
+
+    function renderPlans() {
+        const body = document.getElementById('plans-table-body');
+        body.textContent = '';
+
+        if (plans.length === 0) {
+            const row = document.createElement('tr');
+            const cell = document.createElement('td');
+            cell.colSpan = 8;
+            cell.className = 'empty-cell';
+            cell.textContent = 'Nessun piano: creane uno per registrare nuovi utenti';
+            row.appendChild(cell);
+            body.appendChild(row);
+            return;
+        }
+
+        plans.forEach(p => {
+            const row = document.createElement('tr');
+
+            const name = document.createElement('td');
+            name.textContent = p.name;
+            const duration = document.createElement('td');
+            duration.textContent = `${p.durationDays} giorni` + (p.maxFreezeDays ? `, ${p.maxFreezeDays} sospendibili` : '');
+            const accesses = document.createElement('td');
+            accesses.textContent = p.accesses;
+            const subType = document.createElement('td');
+            subType.textContent = subTypeLabels[p.subType] || p.subType;
+            const price = document.createElement('td');
+            price.textContent = formatPrice(p.priceCents);
+            const policy = document.createElement('td');
+            policy.textContent = p.policyId ? (policies[p.policyId] || '-') : 'Del tipo';
+
+            const status = document.createElement('td');
+            const badge = document.createElement('span');
+            badge.className = p.enabled ? 'badge badge-success' : 'badge badge-warning';
+            badge.textContent = p.enabled ? 'In vendita' : 'Ritirato';
+            status.appendChild(badge);
+
+            const actions = document.createElement('td');
+            const editButton = document.createElement('button');
+            editButton.className = 'btn-icon';
+            editButton.type = 'button';
+            editButton.title = 'Modifica';
+            editButton.appendChild(icon('edit'));
+            editButton.addEventListener('click', () => openModal(p));
+            const deleteButton = document.createElement('button');
+            deleteButton.className = 'btn-icon';
+            deleteButton.type = 'button';
+            deleteButton.title = 'Elimina';
+            deleteButton.appendChild(icon('delete'));
+            deleteButton.addEventListener('click', () => deletePlan(p.id));
+            actions.append(editButton, deleteButton);
+
+            row.append(name, duration, accesses, subType, price, policy, status, actions);
+            body.appendChild(row);
+        });
+    }
+
+    function openModal(plan) {
+        editingId = plan ? plan.id : null;
+        document.getElementById('planModalTitle').textContent = plan ? 'Modifica Piano' : 'Nuovo Piano';
+        document.getElementById('plan-name').value = plan ? plan.name : '';
+        document.getElementById('plan-duration').value = plan ? plan.durationDays : 30;
+        document.getElementById('plan-accesses').value = plan ? plan.accesses : 8;
+        document.getElementById('plan-freeze').value = plan ? plan.maxFreezeDays : 0;
+        document.getElementById('plan-subtype').value = plan ? plan.subType : 'SHARED';
+        document.getElementById('plan-price').value = plan ? (plan.priceCents / 100).toFixed(2) : 0;
+        document.getElementById('plan-policy').value = plan ? plan.policyId : 0;
+        document.getElementById('plan-enabled').checked = plan ? plan.enabled : true;
+        document.getElementById('planModal').style.display = 'block';
+    }
+
+    function closeModal() {
+        document.getElementById('planModal').style.display = 'none';
+        document.getElementById('planForm').reset();
+        editingId = null;
+    }
+
+    async function savePlan() {
+        const name = document.getElementById('plan-name').value.trim();
+        const durationDays = parseInt(document.getElementById('plan-duration').value, 10);
+        const accesses = parseInt(document.getElementById('plan-accesses').value, 10);
+        const maxFreezeDays = parseInt(document.getElementById('plan-freeze').value, 10) || 0;
+        const subType = document.getElementById('plan-subtype').value;
+        const priceCents = Math.round((parseFloat(document.getElementById('plan-price').value) || 0) * 100);
+        const policyId = parseInt(document.getElementById('plan-policy').value, 10) || 0;
+        const enabled = document.getElementById('plan-enabled').checked;
+
+        if (!name || !durationDays || isNaN(accesses)) {
+            UI.showToast('Nome, durata e accessi sono obbligatori');
+            return;
+        }
+
+        const url = editingId ? `${endpoint}/${editingId}` : endpoint;
+        try {
+            const response = await fetch(url, {
+                method: editingId ? 'PUT' : 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCookie('csrf_token'),
+                },
+                body: JSON.stringify({ name, durationDays, accesses, subType, priceCents, policyId, maxFreezeDays, enabled }),
+            });
+
+            if (response.ok) {
+                UI.showToast(editingId ? 'Piano aggiornato con successo' : 'Piano creato con successo', true);
+                closeModal();
+                loadPlans();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante il salvataggio');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function deletePlan(id) {
+        if (!confirm('Sei sicuro di voler eliminare questo piano?')) {
+            return;
+        }
+
+        try {
+            const response = await fetch(`${endpoint}/${id}`, {
+                method: 'DELETE',
+                headers: { 'X-CSRF-Token': getCookie('csrf_token') },
+            });
+
+            if (response.ok) {
+                UI.showToast('Piano eliminato con successo', true);
+                loadPlans();
+            } else if (response.status === 409) {
+                UI.showToast('Il piano ha già degli abbonamenti: ritiralo dalla vendita invece di eliminarlo');
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante l\'eliminazione');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    document.addEventListener('DOMContentLoaded', async () => {
+        document.getElementById('createPlanBtn').addEventListener('click', () => openModal(null));
+        document.getElementById('closePlanModalBtn').addEventListener('click', closeModal);
+        document.getElementById('closePlanModalIcon').addEventListener('click', closeModal);
+        document.getElementById('savePlanBtn').addEventListener('click', savePlan);
+
+        try {
+            await loadPolicies();
+        } catch (error) {
+            console.error('Error loading policies:', error);
+        }
+        loadPlans();
+    });
+})();
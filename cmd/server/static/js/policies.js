@@ -0,0 +1,330 @@
+(function () {
+    const endpoint = '/api/admin/policies';
+    const limitsEndpoint = '/api/admin/booking-limits';
+    const settingsEndpoint = '/api/admin/booking-settings';
+    const subTypeLabels = { SHARED: 'Condiviso', SINGLE: 'Singolo' };
+    let policies = [];
+    let editingId = null;
+
+    function icon(name) {
+        const elem = document.createElement('span');
+        elem.className = 'material-icons';
+        elem.textContent = name;
+        return elem;
+    }
+
+    function describeLateRefund(p) {
+        switch (p.lateRefund) {
+            case 'FULL':
+                return 'Rimborso sempre';
+            case 'PARTIAL':
+                return `Rimborso per ${p.freeLateCancels} al mese`;
+            default:
+                return 'Nessun rimborso';
+        }
+    }
+
+    function describeNoShows(p) {
+        if (p.maxNoShows === 0) return 'Nessun blocco';
+        return `Blocco dopo ${p.maxNoShows} in ${p.noShowWindowDays} giorni`;
+    }
+
+    async function loadPolicies() {
+        try {
+            const response = await fetch(endpoint);
+            if (!response.ok) throw new Error('Failed to load policies');
+            policies = await response.json();
+            renderPolicies();
+        } catch (error) {
+            console.error('Error loading policies:', error);
+            UI.showToast('Errore nel caricamento delle politiche');
+        }
+    }
+
+    function renderPolicies() {
+        const body = document.getElementById('policies-table-body');
+        body.textContent = '';
+
+        if (policies.length === 0) {
+            const row = document.createElement('tr');
+            const cell = document.createElement('td');
+            cell.colSpan = 6;
+            cell.className = 'empty-cell';
+            cell.textContent = 'Nessuna politica: vale il preavviso di 3 ore';
+            row.appendChild(cell);
+            body.appendChild(row);
+            return;
+        }
+
+        policies.forEach(p => {
+            const row = document.createElement('tr');
+
+            const name = document.createElement('td');
+            name.textContent = p.name;
+            const subType = document.createElement('td');
+            subType.textContent = subTypeLabels[p.subType] || '-';
+            const cutoff = document.createElement('td');
+            cutoff.textContent = `${p.cutoffHours} ore`;
+            const late = document.createElement('td');
+            late.textContent = describeLateRefund(p);
+            const noShows = document.createElement('td');
+            noShows.textContent = describeNoShows(p);
+
+            const actions = document.createElement('td');
+            const editButton = document.createElement('button');
+            editButton.className = 'btn-icon';
+            editButton.type = 'button';
+            editButton.title = 'Modifica';
+            editButton.appendChild(icon('edit'));
+            editButton.addEventListener('click', () => openModal(p));
+            const deleteButton = document.createElement('button');
+            deleteButton.className = 'btn-icon';
+            deleteButton.type = 'button';
+            deleteButton.title = 'Elimina';
+            deleteButton.appendChild(icon('delete'));
+            deleteButton.addEventListener('click', () => deletePolicy(p.id));
+            actions.append(editButton, deleteButton);
+
+            row.append(name, subType, cutoff, late, noShows, actions);
+            body.appendChild(row);
+        });
+    }
+
+    function openModal(policy) {
+        editingId = policy ? policy.id : null;
+        document.getElementById('policyModalTitle').textContent = policy ? 'Modifica Politica' : 'Nuova Politica';
+        document.getElementById('policy-name').value = policy ? policy.name : '';
+        document.getElementById('policy-subtype').value = policy ? policy.subType : '';
+        document.getElementById('policy-cutoff').value = policy ? policy.cutoffHours : 3;
+        document.getElementById('policy-late-refund').value = policy ? policy.lateRefund : 'NONE';
+        document.getElementById('policy-free-late').value = policy ? policy.freeLateCancels : 0;
+        document.getElementById('policy-unmarked').value = policy ? policy.unmarkedAttendance : 'ATTENDED';
+        document.getElementById('policy-max-no-shows').value = policy ? policy.maxNoShows : 0;
+        document.getElementById('policy-no-show-window').value = policy ? policy.noShowWindowDays : 30;
+        document.getElementById('policyModal').style.display = 'block';
+    }
+
+    function closeModal() {
+        document.getElementById('policyModal').style.display = 'none';
+        document.getElementById('policyForm').reset();
+        editingId = null;
+    }
+
+    async function savePolicy() {
+        const name = document.getElementById('policy-name').value.trim();
+        const subType = document.getElementById('policy-subtype').value;
+        const cutoffHours = parseInt(document.getElementById('policy-cutoff').value, 10);
+        const lateRefund = document.getElementById('policy-late-refund').value;
+        const freeLateCancels = parseInt(document.getElementById('policy-free-late').value, 10) || 0;
+        const unmarkedAttendance = document.getElementById('policy-unmarked').value;
+        const maxNoShows = parseInt(document.getElementById('policy-max-no-shows').value, 10) || 0;
+        const noShowWindowDays = parseInt(document.getElementById('policy-no-show-window').value, 10) || 30;
+
+        if (!name || isNaN(cutoffHours)) {
+            UI.showToast('Nome e preavviso sono obbligatori');
+            return;
+        }
+
+        const url = editingId ? `${endpoint}/${editingId}` : endpoint;
+        try {
+            const response = await fetch(url, {
+                method: editingId ? 'PUT' : 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCookie('csrf_token'),
+                },
+                body: JSON.stringify({
+                    name,
+                    subType,
+                    cutoffHours,
+                    lateRefund,
+                    freeLateCancels,
+                    unmarkedAttendance,
+                    maxNoShows,
+                    noShowWindowDays,
+                }),
+            });
+
+            if (response.ok) {
+                UI.showToast(editingId ? 'Politica aggiornata con successo' : 'Politica creata con successo', true);
+                closeModal();
+                loadPolicies();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante il salvataggio');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function deletePolicy(id) {
+        if (!confirm('Sei sicuro di voler eliminare questa politica? Le cancellazioni passate mantengono la loro motivazione.')) {
+            return;
+        }
+
+        try {
+            const response = await fetch(`${endpoint}/${id}`, {
+                method: 'DELETE',
+                headers: { 'X-CSRF-Token': getCookie('csrf_token') },
+            });
+
+            if (response.ok) {
+                UI.showToast('Politica eliminata con successo', true);
+                loadPolicies();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante l\'eliminazione');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function loadLimits() {
+        try {
+            const response = await fetch(limitsEndpoint);
+            if (!response.ok) throw new Error('Failed to load booking limits');
+            renderLimits(await response.json());
+        } catch (error) {
+            console.error('Error loading booking limits:', error);
+            UI.showToast('Errore nel caricamento dei limiti');
+        }
+    }
+
+    function limitInput(value) {
+        const input = document.createElement('input');
+        input.type = 'number';
+        input.min = '0';
+        input.value = value;
+        return input;
+    }
+
+    function renderLimits(limits) {
+        const body = document.getElementById('limits-table-body');
+        body.textContent = '';
+
+        limits.forEach(l => {
+            const row = document.createElement('tr');
+
+            const subType = document.createElement('td');
+            subType.textContent = subTypeLabels[l.subType] || l.subType;
+
+            const inputs = [l.maxPerDay, l.maxPerWeek, l.maxOpen].map(limitInput);
+            const cells = inputs.map(input => {
+                const cell = document.createElement('td');
+                cell.appendChild(input);
+                return cell;
+            });
+
+            const actions = document.createElement('td');
+            const saveButton = document.createElement('button');
+            saveButton.className = 'btn-icon';
+            saveButton.type = 'button';
+            saveButton.title = 'Salva';
+            saveButton.appendChild(icon('save'));
+            saveButton.addEventListener('click', () => saveLimit(l.subType, inputs.map(input => parseInt(input.value, 10) || 0)));
+            actions.appendChild(saveButton);
+
+            row.append(subType, ...cells, actions);
+            body.appendChild(row);
+        });
+    }
+
+    async function saveLimit(subType, [maxPerDay, maxPerWeek, maxOpen]) {
+        try {
+            const response = await fetch(`${limitsEndpoint}/${encodeURIComponent(subType)}`, {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCookie('csrf_token'),
+                },
+                body: JSON.stringify({ maxPerDay, maxPerWeek, maxOpen }),
+            });
+
+            if (response.ok) {
+                UI.showToast('Limiti aggiornati con successo', true);
+                loadLimits();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante il salvataggio');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function loadSettings() {
+        try {
+            const response = await fetch(settingsEndpoint);
+            if (!response.ok) throw new Error('Failed to load booking settings');
+            renderSettings(await response.json());
+        } catch (error) {
+            console.error('Error loading booking settings:', error);
+            UI.showToast('Errore nel caricamento della finestra di prenotazione');
+        }
+    }
+
+    function renderSettings(settings) {
+        document.getElementById('settings-horizon').value = settings.horizonDays;
+        document.getElementById('settings-leadTime').value = settings.leadTimeMinutes;
+        document.getElementById('settings-releaseEnabled').checked = settings.releaseEnabled;
+        document.getElementById('settings-releaseWeekday').value = String(settings.releaseWeekday);
+        document.getElementById('settings-releaseTime').value = settings.releaseTime;
+        toggleReleaseFields();
+    }
+
+    function toggleReleaseFields() {
+        const enabled = document.getElementById('settings-releaseEnabled').checked;
+        document.getElementById('settings-releaseWeekday').disabled = !enabled;
+        document.getElementById('settings-releaseTime').disabled = !enabled;
+    }
+
+    async function saveSettings() {
+        const settings = {
+            horizonDays: parseInt(document.getElementById('settings-horizon').value, 10) || 0,
+            leadTimeMinutes: parseInt(document.getElementById('settings-leadTime').value, 10) || 0,
+            releaseEnabled: document.getElementById('settings-releaseEnabled').checked,
+            releaseWeekday: parseInt(document.getElementById('settings-releaseWeekday').value, 10),
+            releaseTime: document.getElementById('settings-releaseTime').value || '18:00',
+        };
+
+        try {
+            const response = await fetch(settingsEndpoint, {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCookie('csrf_token'),
+                },
+                body: JSON.stringify(settings),
+            });
+
+            if (response.ok) {
+                UI.showToast('Finestra di prenotazione aggiornata', true);
+                renderSettings(await response.json());
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante il salvataggio');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    document.addEventListener('DOMContentLoaded', () => {
+        document.getElementById('createPolicyBtn').addEventListener('click', () => openModal(null));
+        document.getElementById('closePolicyModalBtn').addEventListener('click', closeModal);
+        document.getElementById('closePolicyModalIcon').addEventListener('click', closeModal);
+        document.getElementById('savePolicyBtn').addEventListener('click', savePolicy);
+        document.getElementById('saveSettingsBtn').addEventListener('click', saveSettings);
+        document.getElementById('settings-releaseEnabled').addEventListener('change', toggleReleaseFields);
+
+        loadPolicies();
+        loadLimits();
+        loadSettings();
+    });
+})();
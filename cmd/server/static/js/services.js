@@ -0,0 +1,394 @@
+(function () {
+    const endpoint = '/api/admin/services';
+    const resourcesEndpoint = '/api/admin/resources';
+    const kindLabels = { ROOM: 'Sala', EQUIPMENT: 'Attrezzatura' };
+    let instructors = {};
+    let services = [];
+    let resources = [];
+    let editingId = null;
+    let editingResourceId = null;
+
+    function icon(name) {
+        const elem = document.createElement('span');
+        elem.className = 'material-icons';
+        elem.textContent = name;
+        return elem;
+    }
+
+    async function loadInstructors() {
+        const response = await fetch('/api/admin/instructors');
+        if (!response.ok) throw new Error('Failed to load instructors');
+        const list = await response.json();
+        const container = document.getElementById('service-instructors');
+        list.forEach(i => {
+            instructors[i.ID] = `${i.FirstName} ${i.LastName}`.trim();
+
+            const label = document.createElement('label');
+            const checkbox = document.createElement('input');
+            checkbox.type = 'checkbox';
+            checkbox.value = i.ID;
+            label.append(checkbox, instructors[i.ID]);
+            container.appendChild(label);
+        });
+    }
+
+    async function loadServices() {
+        try {
+            const response = await fetch(endpoint);
+            if (!response.ok) throw new Error('Failed to load services');
+            services = await response.json();
+            renderServices();
+        } catch (error) {
+            console.error('Error loading services:', error);
+            UI.showToast('Errore nel caricamento dei servizi');
+        }
+    }
+
+    function renderServices() {
+        const body = document.getElementById('services-table-body');
+        body.textContent = '';
+
+        if (services.length === 0) {
+            const row = document.createElement('tr');
+            const cell = document.createElement('td');
+            cell.colSpan = 7;
+            cell.className = 'empty-cell';
+            cell.textContent = 'Nessun servizio: le prenotazioni durano un\'ora';
+            row.appendChild(cell);
+            body.appendChild(row);
+            return;
+        }
+
+        services.forEach(s => {
+            const row = document.createElement('tr');
+
+            const name = document.createElement('td');
+            name.textContent = s.name;
+            const duration = document.createElement('td');
+            duration.textContent = `${s.durationMinutes} min`;
+            const weight = document.createElement('td');
+            weight.textContent = s.capacityWeight;
+
+            const instructorCell = document.createElement('td');
+            instructorCell.textContent = s.instructorIds.length === 0
+                ? 'Tutti'
+                : s.instructorIds.map(id => instructors[id] || '-').join(', ');
+
+            const resourceCell = document.createElement('td');
+            resourceCell.textContent = s.resources.length === 0
+                ? '-'
+                : s.resources.map(r => {
+                    const resource = resources.find(item => item.id === r.resourceId);
+                    const name = resource ? resource.name : '-';
+                    return r.quantity > 1 ? `${name} x${r.quantity}` : name;
+                }).join(', ');
+
+            const status = document.createElement('td');
+            const badge = document.createElement('span');
+            badge.className = s.enabled ? 'badge badge-success' : 'badge badge-warning';
+            badge.textContent = s.enabled ? 'Attivo' : 'Disattivato';
+            status.appendChild(badge);
+
+            const actions = document.createElement('td');
+            const editButton = document.createElement('button');
+            editButton.className = 'btn-icon';
+            editButton.type = 'button';
+            editButton.title = 'Modifica';
+            editButton.appendChild(icon('edit'));
+            editButton.addEventListener('click', () => openModal(s));
+            const deleteButton = document.createElement('button');
+            deleteButton.className = 'btn-icon';
+            deleteButton.type = 'button';
+            deleteButton.title = 'Elimina';
+            deleteButton.appendChild(icon('delete'));
+            deleteButton.addEventListener('click', () => deleteService(s.id));
+            actions.append(editButton, deleteButton);
+
+            row.append(name, duration, weight, instructorCell, resourceCell, status, actions);
+            body.appendChild(row);
+        });
+    }
+
+    async function loadResources() {
+        try {
+            const response = await fetch(resourcesEndpoint);
+            if (!response.ok) throw new Error('Failed to load resources');
+            resources = await response.json();
+            renderResources();
+            renderResourceInputs();
+        } catch (error) {
+            console.error('Error loading resources:', error);
+            UI.showToast('Errore nel caricamento delle risorse');
+        }
+    }
+
+    function renderResources() {
+        const body = document.getElementById('resources-table-body');
+        body.textContent = '';
+
+        if (resources.length === 0) {
+            const row = document.createElement('tr');
+            const cell = document.createElement('td');
+            cell.colSpan = 5;
+            cell.className = 'empty-cell';
+            cell.textContent = 'Nessuna risorsa: conta solo la capienza degli istruttori';
+            row.appendChild(cell);
+            body.appendChild(row);
+            return;
+        }
+
+        resources.forEach(r => {
+            const row = document.createElement('tr');
+
+            const name = document.createElement('td');
+            name.textContent = r.name;
+            const kind = document.createElement('td');
+            kind.textContent = kindLabels[r.kind] || r.kind;
+            const capacity = document.createElement('td');
+            capacity.textContent = r.capacity;
+
+            const status = document.createElement('td');
+            const badge = document.createElement('span');
+            badge.className = r.enabled ? 'badge badge-success' : 'badge badge-warning';
+            badge.textContent = r.enabled ? 'Attiva' : 'Disattivata';
+            status.appendChild(badge);
+
+            const actions = document.createElement('td');
+            const editButton = document.createElement('button');
+            editButton.className = 'btn-icon';
+            editButton.type = 'button';
+            editButton.title = 'Modifica';
+            editButton.appendChild(icon('edit'));
+            editButton.addEventListener('click', () => openResourceModal(r));
+            const deleteButton = document.createElement('button');
+            deleteButton.className = 'btn-icon';
+            deleteButton.type = 'button';
+            deleteButton.title = 'Elimina';
+            deleteButton.appendChild(icon('delete'));
+            deleteButton.addEventListener('click', () => deleteResource(r.id));
+            actions.append(editButton, deleteButton);
+
+            row.append(name, kind, capacity, status, actions);
+            body.appendChild(row);
+        });
+    }
+
+    // renderResourceInputs lists every resource in the service form with the
+    // quantity a booking needs, 0 for none
+    function renderResourceInputs() {
+        const container = document.getElementById('service-resources');
+        container.textContent = '';
+        resources.forEach(r => {
+            const label = document.createElement('label');
+            const input = document.createElement('input');
+            input.type = 'number';
+            input.min = '0';
+            input.max = String(r.capacity);
+            input.value = '0';
+            input.dataset.resourceId = r.id;
+            label.append(`${r.name} (${kindLabels[r.kind] || r.kind})`, input);
+            container.appendChild(label);
+        });
+    }
+
+    function resourceInputs() {
+        return document.querySelectorAll('#service-resources input[type="number"]');
+    }
+
+    function openResourceModal(resource) {
+        editingResourceId = resource ? resource.id : null;
+        document.getElementById('resourceModalTitle').textContent = resource ? 'Modifica Risorsa' : 'Nuova Risorsa';
+        document.getElementById('resource-name').value = resource ? resource.name : '';
+        document.getElementById('resource-kind').value = resource ? resource.kind : 'ROOM';
+        document.getElementById('resource-capacity').value = resource ? resource.capacity : 1;
+        document.getElementById('resource-enabled').checked = resource ? resource.enabled : true;
+        document.getElementById('resourceModal').style.display = 'block';
+    }
+
+    function closeResourceModal() {
+        document.getElementById('resourceModal').style.display = 'none';
+        document.getElementById('resourceForm').reset();
+        editingResourceId = null;
+    }
+
+    async function saveResource() {
+        const name = document.getElementById('resource-name').value.trim();
+        const kind = document.getElementById('resource-kind').value;
+        const capacity = parseInt(document.getElementById('resource-capacity').value, 10);
+        const enabled = document.getElementById('resource-enabled').checked;
+
+        if (!name || !capacity) {
+            UI.showToast('Nome e capienza sono obbligatori');
+            return;
+        }
+
+        const url = editingResourceId ? `${resourcesEndpoint}/${editingResourceId}` : resourcesEndpoint;
+        try {
+            const response = await fetch(url, {
+                method: editingResourceId ? 'PUT' : 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCookie('csrf_token'),
+                },
+                body: JSON.stringify({ name, kind, capacity, enabled }),
+            });
+
+            if (response.ok) {
+                UI.showToast(editingResourceId ? 'Risorsa aggiornata con successo' : 'Risorsa creata con successo', true);
+                closeResourceModal();
+                await loadResources();
+                renderServices();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante il salvataggio');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function deleteResource(id) {
+        if (!confirm('Sei sicuro di voler eliminare questa risorsa? Servizi e prenotazioni non la richiederanno più.')) {
+            return;
+        }
+
+        try {
+            const response = await fetch(`${resourcesEndpoint}/${id}`, {
+                method: 'DELETE',
+                headers: { 'X-CSRF-Token': getCookie('csrf_token') },
+            });
+
+            if (response.ok) {
+                UI.showToast('Risorsa eliminata con successo', true);
+                await loadResources();
+                loadServices();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante l\'eliminazione');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    function instructorCheckboxes() {
+        return document.querySelectorAll('#service-instructors input[type="checkbox"]');
+    }
+
+    function openModal(service) {
+        editingId = service ? service.id : null;
+        document.getElementById('serviceModalTitle').textContent = service ? 'Modifica Servizio' : 'Nuovo Servizio';
+        document.getElementById('service-name').value = service ? service.name : '';
+        document.getElementById('service-duration').value = service ? service.durationMinutes : 60;
+        document.getElementById('service-weight').value = service ? service.capacityWeight : 1;
+        document.getElementById('service-enabled').checked = service ? service.enabled : true;
+        const selected = service ? service.instructorIds : [];
+        instructorCheckboxes().forEach(cb => {
+            cb.checked = selected.includes(parseInt(cb.value, 10));
+        });
+        const needed = service ? service.resources : [];
+        resourceInputs().forEach(input => {
+            const need = needed.find(r => r.resourceId === parseInt(input.dataset.resourceId, 10));
+            input.value = need ? need.quantity : 0;
+        });
+        document.getElementById('serviceModal').style.display = 'block';
+    }
+
+    function closeModal() {
+        document.getElementById('serviceModal').style.display = 'none';
+        document.getElementById('serviceForm').reset();
+        editingId = null;
+    }
+
+    async function saveService() {
+        const name = document.getElementById('service-name').value.trim();
+        const durationMinutes = parseInt(document.getElementById('service-duration').value, 10);
+        const capacityWeight = parseInt(document.getElementById('service-weight').value, 10) || 1;
+        const enabled = document.getElementById('service-enabled').checked;
+        const instructorIds = Array.from(instructorCheckboxes())
+            .filter(cb => cb.checked)
+            .map(cb => parseInt(cb.value, 10));
+        const resourceNeeds = Array.from(resourceInputs())
+            .map(input => ({
+                resourceId: parseInt(input.dataset.resourceId, 10),
+                quantity: parseInt(input.value, 10) || 0,
+            }))
+            .filter(r => r.quantity > 0);
+
+        if (!name || !durationMinutes) {
+            UI.showToast('Nome e durata sono obbligatori');
+            return;
+        }
+
+        const url = editingId ? `${endpoint}/${editingId}` : endpoint;
+        try {
+            const response = await fetch(url, {
+                method: editingId ? 'PUT' : 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': getCookie('csrf_token'),
+                },
+                body: JSON.stringify({ name, durationMinutes, capacityWeight, enabled, instructorIds, resources: resourceNeeds }),
+            });
+
+            if (response.ok) {
+                UI.showToast(editingId ? 'Servizio aggiornato con successo' : 'Servizio creato con successo', true);
+                closeModal();
+                loadServices();
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante il salvataggio');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    async function deleteService(id) {
+        if (!confirm('Sei sicuro di voler eliminare questo servizio? Le prenotazioni esistenti mantengono la loro durata.')) {
+            return;
+        }
+
+        try {
+            const response = await fetch(`${endpoint}/${id}`, {
+                method: 'DELETE',
+                headers: { 'X-CSRF-Token': getCookie('csrf_token') },
+            });
+
+            if (response.ok) {
+                UI.showToast('Servizio eliminato con successo', true);
+                loadServices();
+            } else if (response.status === 409) {
+                UI.showToast('Alcuni utenti hanno accessi per questo servizio: disattivalo invece di eliminarlo');
+            } else {
+                const error = await response.json();
+                UI.showToast(error.error || 'Errore durante l\'eliminazione');
+            }
+        } catch (error) {
+            UI.showToast('Errore di connessione');
+            console.error('Error:', error);
+        }
+    }
+
+    document.addEventListener('DOMContentLoaded', async () => {
+        document.getElementById('createServiceBtn').addEventListener('click', () => openModal(null));
+        document.getElementById('closeServiceModalBtn').addEventListener('click', closeModal);
+        document.getElementById('closeServiceModalIcon').addEventListener('click', closeModal);
+        document.getElementById('saveServiceBtn').addEventListener('click', saveService);
+        document.getElementById('createResourceBtn').addEventListener('click', () => openResourceModal(null));
+        document.getElementById('closeResourceModalBtn').addEventListener('click', closeResourceModal);
+        document.getElementById('closeResourceModalIcon').addEventListener('click', closeResourceModal);
+        document.getElementById('saveResourceBtn').addEventListener('click', saveResource);
+
+        try {
+            await loadInstructors();
+        } catch (error) {
+            console.error('Error loading instructors:', error);
+        }
+        await loadResources();
+        loadServices();
+    });
+})();
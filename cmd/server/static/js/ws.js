@@ -18,7 +18,7 @@ const WebSocketHandler = {
             this.ws.onmessage = (event) => {
                 try {
                     const data = JSON.parse(event.data);
-                    UI.showNotification(data.message, data.type === 'booking_created' ? 'success' : 'error');
+                    UI.showNotification(data.message, data.type === 'booking_deleted' ? 'error' : 'success');
                     Calendar.load();
                 } catch (e) {
                     console.error('Error parsing WebSocket message:', e);
@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/crypto"
+	"github.com/alarmfox/wellness-nutrition/app/middleware"
+	"github.com/alarmfox/wellness-nutrition/app/models"
+	"github.com/alarmfox/wellness-nutrition/app/qrcode"
+	"github.com/alarmfox/wellness-nutrition/app/websocket"
+)
+
+const (
+	// defaultStatsDays is the period covered by the admin attendance statistics
+	defaultStatsDays = 30
+	// checkInWindow is how long before and after StartsAt a member can check in
+	checkInWindow = 30 * time.Minute
+	// checkInTokenPrefix keeps check-in tokens apart from other signed tokens
+	checkInTokenPrefix = "checkin:"
+)
+
+type AttendanceHandler struct {
+	attendanceRepo *models.AttendanceRepository
+	bookingRepo    *models.BookingRepository
+	policyRepo     *models.CancellationPolicyRepository
+	userRepo       *models.UserRepository
+	locationRepo   *models.LocationRepository
+	hub            *websocket.Hub
+}
+
+func NewAttendanceHandler(
+	attendanceRepo *models.AttendanceRepository,
+	bookingRepo *models.BookingRepository,
+	policyRepo *models.CancellationPolicyRepository,
+	userRepo *models.UserRepository,
+	locationRepo *models.LocationRepository,
+	hub *websocket.Hub,
+) *AttendanceHandler {
+	return &AttendanceHandler{
+		attendanceRepo: attendanceRepo,
+		bookingRepo:    bookingRepo,
+		policyRepo:     policyRepo,
+		userRepo:       userRepo,
+		locationRepo:   locationRepo,
+		hub:            hub,
+	}
+}
+
+type rosterEntryResponse struct {
+	BookingID       int64     `json:"bookingId"`
+	UserID          string    `json:"userId"`
+	FirstName       string    `json:"firstName"`
+	LastName        string    `json:"lastName"`
+	InstructorID    int64     `json:"instructorId"`
+	InstructorName  string    `json:"instructorName"`
+	ServiceName     string    `json:"serviceName"`
+	StartsAt        time.Time `json:"startsAt"`
+	DurationMinutes int       `json:"durationMinutes"`
+	Attendance      string    `json:"attendance"`
+}
+
+// GetRoster lists the SIMPLE bookings of a day (today by default) with their
+// attendance, for admins to mark members in. With a locationId only the
+// bookings of that location are listed and the day is read in its time zone.
+func (h *AttendanceHandler) GetRoster(w http.ResponseWriter, r *http.Request) {
+	location, err := locationFilter(r, h.locationRepo)
+	if err != nil {
+		sendLocationFilterError(w, err)
+		return
+	}
+	loc := filterTimeZone(location)
+
+	now := time.Now().In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if date := r.URL.Query().Get("date"); date != "" {
+		day, err = time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid date"})
+			return
+		}
+	}
+
+	entries, err := h.attendanceRepo.GetRoster(day.UTC(), day.AddDate(0, 0, 1).UTC(), filterLocationID(location))
+	if err != nil {
+		log.Printf("Error getting roster: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []rosterEntryResponse{}
+	for _, e := range entries {
+		result = append(result, rosterEntryResponse{
+			BookingID:       e.BookingID,
+			UserID:          e.UserID,
+			FirstName:       e.FirstName,
+			LastName:        e.LastName,
+			InstructorID:    e.InstructorID,
+			InstructorName:  strings.TrimSpace(e.InstructorFirstName.String + " " + e.InstructorLastName.String),
+			ServiceName:     e.ServiceName.String,
+			StartsAt:        e.StartsAt,
+			DurationMinutes: e.DurationMinutes,
+			Attendance:      e.Attendance.String,
+		})
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+type MarkAttendanceRequest struct {
+	// Attendance is ATTENDED or NO_SHOW; empty clears the mark
+	Attendance models.Attendance `json:"attendance"`
+}
+
+// Mark sets the attendance of a SIMPLE booking. Bookings starting after
+// today cannot be marked attended or no-show yet.
+func (h *AttendanceHandler) Mark(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var req MarkAttendanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+	if req.Attendance != "" && !req.Attendance.Markable() {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": models.ErrInvalidAttendance.Error()})
+		return
+	}
+
+	booking, err := h.bookingRepo.GetByID(idInt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+			return
+		}
+		log.Printf("Error getting booking: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+	if booking.Type != models.BookingTypeSimple {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Only member bookings have attendance"})
+		return
+	}
+
+	if req.Attendance == models.AttendanceAttended || req.Attendance == models.AttendanceNoShow {
+		tomorrow := businessDayStart(time.Now()).AddDate(0, 0, 1)
+		if !booking.StartsAt.Before(tomorrow) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Booking has not started yet"})
+			return
+		}
+	}
+
+	if err := h.attendanceRepo.Mark(booking.ID, req.Attendance); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+			return
+		}
+		if errors.Is(err, models.ErrInvalidAttendance) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error marking attendance: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"bookingId":  booking.ID,
+		"attendance": req.Attendance,
+	})
+}
+
+type attendanceStatsResponse struct {
+	UserID      string `json:"userId"`
+	FirstName   string `json:"firstName"`
+	LastName    string `json:"lastName"`
+	Attended    int    `json:"attended"`
+	NoShows     int    `json:"noShows"`
+	LateCancels int    `json:"lateCancels"`
+}
+
+func newAttendanceStatsResponse(s *models.AttendanceStats) attendanceStatsResponse {
+	return attendanceStatsResponse{
+		UserID:      s.UserID,
+		FirstName:   s.FirstName,
+		LastName:    s.LastName,
+		Attended:    s.Attended,
+		NoShows:     s.NoShows,
+		LateCancels: s.LateCancels,
+	}
+}
+
+// GetStats returns per-member attendance statistics over the last days
+// (30 by default), most no-shows first, optionally of one location.
+func (h *AttendanceHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	location, err := locationFilter(r, h.locationRepo)
+	if err != nil {
+		sendLocationFilterError(w, err)
+		return
+	}
+
+	days := defaultStatsDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > 366 {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid days"})
+			return
+		}
+		days = n
+	}
+
+	stats, err := h.attendanceRepo.GetStats(time.Now().AddDate(0, 0, -days), filterLocationID(location))
+	if err != nil {
+		log.Printf("Error getting attendance stats: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []attendanceStatsResponse{}
+	for _, s := range stats {
+		result = append(result, newAttendanceStatsResponse(s))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// GetMine returns the attendance statistics of the current user within the
+// no-show window of their policy and whether new bookings are blocked.
+func (h *AttendanceHandler) GetMine(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	policy, err := h.policyRepo.GetForUser(user.ID, time.Now())
+	if err != nil {
+		log.Printf("Error getting cancellation policy: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	stats, err := h.attendanceRepo.GetUserStats(user.ID, policy.NoShowWindowStart(time.Now()))
+	if err != nil {
+		log.Printf("Error getting attendance stats: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"stats":      newAttendanceStatsResponse(stats),
+		"windowDays": policy.NoShowWindowDays,
+		"maxNoShows": policy.MaxNoShows,
+		"blocked":    policy.BlocksBooking(stats.NoShows),
+	})
+}
+
+// checkInToken returns the signed token of a booking, valid until the check-in closes
+func checkInToken(booking *models.Booking) string {
+	return crypto.CreateTimedToken(
+		checkInTokenPrefix+strconv.FormatInt(booking.ID, 10),
+		booking.StartsAt.Add(checkInWindow),
+	)
+}
+
+// GetCheckInCode returns the check-in QR code of an active booking of the
+// current user as an SVG image, until 30 minutes after the booking starts.
+func (h *AttendanceHandler) GetCheckInCode(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	booking, err := h.bookingRepo.GetByID(idInt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+			return
+		}
+		log.Printf("Error getting booking: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+	if booking.UserID.String != user.ID || booking.Type != models.BookingTypeSimple {
+		sendJSON(w, http.StatusForbidden, map[string]string{"error": "Forbidden"})
+		return
+	}
+	if booking.CancelledAt.Valid {
+		sendJSON(w, http.StatusConflict, map[string]string{"error": "Booking cancelled"})
+		return
+	}
+	if time.Now().After(booking.StartsAt.Add(checkInWindow)) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Check-in is closed"})
+		return
+	}
+
+	code, err := qrcode.Encode(checkInToken(booking))
+	if err != nil {
+		log.Printf("Error encoding check-in code: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(code.SVG()))
+}
+
+type CheckInRequest struct {
+	Token string `json:"token"`
+}
+
+// CheckIn marks a booking attended from the token scanned at the kiosk. The
+// booking must be active and start within 30 minutes before or after now.
+func (h *AttendanceHandler) CheckIn(w http.ResponseWriter, r *http.Request) {
+	var req CheckInRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	data, err := crypto.VerifyTimedToken(strings.TrimSpace(req.Token))
+	if err != nil {
+		if errors.Is(err, crypto.ErrExpiredToken) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Check-in is closed"})
+			return
+		}
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid check-in code"})
+		return
+	}
+	id, ok := strings.CutPrefix(data, checkInTokenPrefix)
+	if !ok {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid check-in code"})
+		return
+	}
+	idInt, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid check-in code"})
+		return
+	}
+
+	booking, err := h.bookingRepo.GetByID(idInt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+			return
+		}
+		log.Printf("Error getting booking: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+	if booking.Type != models.BookingTypeSimple {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Only member bookings have attendance"})
+		return
+	}
+	if booking.CancelledAt.Valid {
+		sendJSON(w, http.StatusConflict, map[string]string{"error": "Booking cancelled"})
+		return
+	}
+
+	now := time.Now()
+	if now.Before(booking.StartsAt.Add(-checkInWindow)) || now.After(booking.StartsAt.Add(checkInWindow)) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Check-in is open from 30 minutes before to 30 minutes after the booking"})
+		return
+	}
+
+	owner, err := h.userRepo.GetByID(booking.UserID.String)
+	if err != nil {
+		log.Printf("Error getting booking owner: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	checkedIn, err := h.attendanceRepo.CheckIn(booking.ID)
+	if err != nil {
+		log.Printf("Error checking in: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	if checkedIn {
+		if h.hub != nil {
+			userName := fmt.Sprintf("%s %s", owner.FirstName, owner.LastName)
+			h.hub.BroadcastJSON(
+				websocket.NotificationCheckedIn,
+				fmt.Sprintf("Check-in: %s - %s", userName, formatBusinessTime(booking.StartsAt, booking.Location())),
+				userName,
+				formatBusinessTime(booking.StartsAt, booking.Location()),
+			)
+		}
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"bookingId":        booking.ID,
+		"firstName":        owner.FirstName,
+		"lastName":         owner.LastName,
+		"startsAt":         booking.StartsAt,
+		"alreadyCheckedIn": !checkedIn,
+	})
+}
+
+// checkNoShowPenalty answers 403 and returns false when the user reached the
+// no-show limit of their policy and cannot make new bookings.
+func (h *BookingHandler) checkNoShowPenalty(w http.ResponseWriter, user *models.User) bool {
+	policy, err := h.policyRepo.GetForUser(user.ID, time.Now())
+	if err != nil {
+		log.Printf("Error getting cancellation policy: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return false
+	}
+	if policy.MaxNoShows == 0 {
+		return true
+	}
+
+	noShows, err := h.attendanceRepo.CountNoShows(user.ID, policy.NoShowWindowStart(time.Now()))
+	if err != nil {
+		log.Printf("Error counting no-shows: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return false
+	}
+	if policy.BlocksBooking(noShows) {
+		sendJSON(w, http.StatusForbidden, map[string]string{"error": "Booking blocked after too many no-shows"})
+		return false
+	}
+	return true
+}
+
+// businessDayStart returns midnight of the Europe/Rome day of t.
+func businessDayStart(t time.Time) time.Time {
+	loc := models.LoadTimeZone(models.BusinessTimeZone)
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
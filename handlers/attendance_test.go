@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/crypto"
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestCheckInRejectsInvalidTokens(t *testing.T) {
+	if err := crypto.InitializeSecretKey("check-in-test-secret"); err != nil {
+		t.Fatal(err)
+	}
+	h := NewAttendanceHandler(nil, nil, nil, nil, nil, nil)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"Garbage", "not-a-token"},
+		{"Session token", crypto.CreateTimedToken("session-id", time.Now().Add(time.Hour))},
+		{"Expired", checkInToken(&models.Booking{ID: 1, StartsAt: time.Now().Add(-time.Hour)})},
+		{"Tampered", strings.Replace(checkInToken(&models.Booking{ID: 1, StartsAt: time.Now()}), "checkin:1|", "checkin:2|", 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.NewReader(`{"token":"` + tt.token + `"}`)
+			w := httptest.NewRecorder()
+			h.CheckIn(w, httptest.NewRequest(http.MethodPost, "/api/admin/checkin", body))
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMarkRejectsLateCancel(t *testing.T) {
+	h := NewAttendanceHandler(nil, nil, nil, nil, nil, nil)
+
+	r := httptest.NewRequest(http.MethodPut, "/api/admin/bookings/1/attendance", strings.NewReader(`{"attendance":"LATE_CANCEL"}`))
+	r.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	h.Mark(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
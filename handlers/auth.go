@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"mime"
@@ -117,14 +118,20 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 }
 
 type UserHandler struct {
-	userRepo *models.UserRepository
-	mailer   *mail.Mailer
+	userRepo         *models.UserRepository
+	subscriptionRepo *models.SubscriptionRepository
+	ledgerRepo       *models.AccessLedgerRepository
+	walletRepo       *models.WalletRepository
+	mailer           *mail.Mailer
 }
 
-func NewUserHandler(userRepo *models.UserRepository, mailer *mail.Mailer) *UserHandler {
+func NewUserHandler(userRepo *models.UserRepository, subscriptionRepo *models.SubscriptionRepository, ledgerRepo *models.AccessLedgerRepository, walletRepo *models.WalletRepository, mailer *mail.Mailer) *UserHandler {
 	return &UserHandler{
-		userRepo: userRepo,
-		mailer:   mailer,
+		userRepo:         userRepo,
+		subscriptionRepo: subscriptionRepo,
+		ledgerRepo:       ledgerRepo,
+		walletRepo:       walletRepo,
+		mailer:           mailer,
 	}
 }
 
@@ -164,16 +171,16 @@ func (h *UserHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 }
 
 type CreateUserRequest struct {
-	FirstName         string   `json:"firstName"`
-	LastName          string   `json:"lastName"`
-	Email             string   `json:"email"`
-	Address           string   `json:"address"`
-	Cellphone         string   `json:"cellphone"`
-	SubType           string   `json:"subType"`
-	MedOk             bool     `json:"medOk"`
-	ExpiresAt         string   `json:"expiresAt"`
-	RemainingAccesses int      `json:"remainingAccesses"`
-	Goals             []string `json:"goals"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email"`
+	Address   string `json:"address"`
+	Cellphone string `json:"cellphone"`
+	MedOk     bool   `json:"medOk"`
+	// PlanID is the plan the member subscribes to from StartsOn, today when empty
+	PlanID   int64    `json:"planId"`
+	StartsOn string   `json:"startsOn"`
+	Goals    []string `json:"goals"`
 }
 
 func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -184,7 +191,7 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate required fields
-	if req.FirstName == "" || req.LastName == "" || req.Email == "" || req.Address == "" {
+	if req.FirstName == "" || req.LastName == "" || req.Email == "" || req.Address == "" || req.PlanID == 0 {
 		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Missing required fields"})
 		return
 	}
@@ -196,10 +203,9 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse expiration date
-	expiresAt, err := time.Parse("2006-01-02", req.ExpiresAt)
+	startsOn, err := parsePlanStart(req.StartsOn)
 	if err != nil {
-		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid expiration date format"})
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid start date format"})
 		return
 	}
 
@@ -227,17 +233,18 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 		Email:                      req.Email,
 		Address:                    req.Address,
 		Cellphone:                  sql.NullString{String: req.Cellphone, Valid: req.Cellphone != ""},
-		SubType:                    models.SubType(req.SubType),
 		MedOk:                      req.MedOk,
-		ExpiresAt:                  expiresAt,
-		RemainingAccesses:          req.RemainingAccesses,
 		Role:                       models.RoleUser,
 		VerificationToken:          sql.NullString{String: unsignedToken, Valid: true},
 		VerificationTokenExpiresIn: sql.NullTime{Time: tokenExpiresAt, Valid: true},
 		Goals:                      sql.NullString{String: goals, Valid: goals != ""},
 	}
 
-	if err := h.userRepo.Create(user); err != nil {
+	if _, err := h.userRepo.CreateWithPlan(user, req.PlanID, startsOn, actorID(r)); err != nil {
+		if errors.Is(err, models.ErrPlanUnavailable) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Plan not available"})
+			return
+		}
 		log.Printf("Error creating user: %v", err)
 		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create user"})
 		return
@@ -257,16 +264,18 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 type UpdateUserRequest struct {
-	ID                string   `json:"id"`
-	FirstName         string   `json:"firstName"`
-	LastName          string   `json:"lastName"`
-	Email             string   `json:"email"`
-	Address           string   `json:"address"`
-	Cellphone         string   `json:"cellphone"`
-	SubType           string   `json:"subType"`
-	MedOk             bool     `json:"medOk"`
-	ExpiresAt         string   `json:"expiresAt"`
-	RemainingAccesses int      `json:"remainingAccesses"`
+	ID        string `json:"id"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email"`
+	Address   string `json:"address"`
+	Cellphone string `json:"cellphone"`
+	MedOk     bool   `json:"medOk"`
+	// PlanID, when set, renews the member with that plan from StartsOn
+	PlanID   int64  `json:"planId"`
+	StartsOn string `json:"startsOn"`
+	// RemainingAccesses, when set, corrects the balance with a ledger adjustment
+	RemainingAccesses *int     `json:"remainingAccesses"`
 	Goals             []string `json:"goals"`
 }
 
@@ -285,10 +294,13 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse expiration date
-	expiresAt, err := time.Parse("2006-01-02", req.ExpiresAt)
+	startsOn, err := parsePlanStart(req.StartsOn)
 	if err != nil {
-		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid expiration date format"})
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid start date format"})
+		return
+	}
+	if req.RemainingAccesses != nil && *req.RemainingAccesses < 0 {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Remaining accesses cannot be negative"})
 		return
 	}
 
@@ -307,10 +319,7 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 	user.Email = req.Email
 	user.Address = req.Address
 	user.Cellphone = sql.NullString{String: req.Cellphone, Valid: req.Cellphone != ""}
-	user.SubType = models.SubType(req.SubType)
 	user.MedOk = req.MedOk
-	user.ExpiresAt = expiresAt
-	user.RemainingAccesses = req.RemainingAccesses
 	user.Goals = sql.NullString{String: goals, Valid: goals != ""}
 
 	// If email changed, reset verification and generate new token
@@ -340,12 +349,40 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.RemainingAccesses != nil {
+		if err := h.userRepo.SetAccesses(user.ID, *req.RemainingAccesses, actorID(r)); err != nil {
+			log.Printf("Error setting accesses: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update accesses"})
+			return
+		}
+	}
+
+	if req.PlanID != 0 {
+		if _, err := h.subscriptionRepo.Assign(user.ID, req.PlanID, startsOn, actorID(r)); err != nil {
+			if errors.Is(err, models.ErrPlanUnavailable) {
+				sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Plan not available"})
+				return
+			}
+			log.Printf("Error assigning plan: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to assign plan"})
+			return
+		}
+	}
+
 	sendJSON(w, http.StatusOK, map[string]interface{}{
 		"message":      "User updated successfully",
 		"emailChanged": emailChanged,
 	})
 }
 
+// parsePlanStart parses the optional first day of a plan, zero for today.
+func parsePlanStart(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
 type DeleteUsersRequest struct {
 	IDs []string `json:"ids"`
 }
@@ -588,3 +625,151 @@ func isJSONContentType(contentType string) bool {
 	}
 	return mediaType == "application/json"
 }
+
+type accessEntryResponse struct {
+	ID     int64               `json:"id"`
+	Delta  int                 `json:"delta"`
+	Reason models.AccessReason `json:"reason"`
+	// ServiceName is the wallet the change applies to, empty for the general balance
+	ServiceName string    `json:"serviceName"`
+	BookingID   int64     `json:"bookingId"`
+	ActorName   string    `json:"actorName"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// GetAccessLedger returns every change to the remaining accesses of a member,
+// the latest first.
+func (h *UserHandler) GetAccessLedger(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if _, err := h.userRepo.GetByID(userID); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+			return
+		}
+		log.Printf("Error getting user: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	entries, err := h.ledgerRepo.GetByUserID(userID)
+	if err != nil {
+		log.Printf("Error getting access ledger: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []accessEntryResponse{}
+	for _, e := range entries {
+		result = append(result, accessEntryResponse{
+			ID:          e.ID,
+			Delta:       e.Delta,
+			Reason:      e.Reason,
+			ServiceName: e.ServiceName,
+			BookingID:   e.BookingID.Int64,
+			ActorName:   e.ActorName,
+			CreatedAt:   e.CreatedAt,
+		})
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+type walletResponse struct {
+	ServiceID   int64  `json:"serviceId"`
+	ServiceName string `json:"serviceName"`
+	Remaining   int    `json:"remaining"`
+	// ExpiresOn is the last day a booking paid by the wallet can fall on
+	ExpiresOn string `json:"expiresOn"`
+}
+
+func newWalletResponse(w *models.Wallet) walletResponse {
+	return walletResponse{
+		ServiceID:   w.ServiceID,
+		ServiceName: w.ServiceName,
+		Remaining:   w.Remaining,
+		ExpiresOn:   w.ExpiresOn.Format("2006-01-02"),
+	}
+}
+
+// GetWallets returns the per-service balances of a member.
+func (h *UserHandler) GetWallets(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if _, err := h.userRepo.GetByID(userID); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+			return
+		}
+		log.Printf("Error getting user: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	wallets, err := h.walletRepo.GetByUserID(userID)
+	if err != nil {
+		log.Printf("Error getting wallets: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []walletResponse{}
+	for _, wallet := range wallets {
+		result = append(result, newWalletResponse(wallet))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+type TopUpWalletRequest struct {
+	ServiceID int64 `json:"serviceId"`
+	// Accesses are added to the wallet, a negative value takes them away
+	Accesses  int    `json:"accesses"`
+	ExpiresOn string `json:"expiresOn"`
+}
+
+// TopUpWallet adds accesses to the wallet of a member for one service,
+// opening it on the first top-up, and sets its expiry.
+func (h *UserHandler) TopUpWallet(w http.ResponseWriter, r *http.Request) {
+	var req TopUpWalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+	if req.ServiceID == 0 || req.ExpiresOn == "" {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Missing required fields"})
+		return
+	}
+	expiresOn, err := time.Parse("2006-01-02", req.ExpiresOn)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid expiry date format"})
+		return
+	}
+
+	userID := r.PathValue("id")
+	if _, err := h.userRepo.GetByID(userID); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+			return
+		}
+		log.Printf("Error getting user: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	wallet, err := h.walletRepo.TopUp(userID, req.ServiceID, req.Accesses, expiresOn, actorID(r))
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Service not found"})
+		case errors.Is(err, models.ErrNoAccesses):
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Not enough accesses in the wallet"})
+		case errors.Is(err, models.ErrInvalidTopUp):
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			log.Printf("Error topping up wallet: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newWalletResponse(wallet))
+}
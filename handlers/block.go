@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+// maxBlockDays limits how far a single bulk block can reach
+const maxBlockDays = 366
+
+type BlockRequest struct {
+	// InstructorID selects one instructor, zero blocks every enabled instructor
+	// of LocationID, or of every location when it is zero too
+	InstructorID int64              `json:"instructorId"`
+	LocationID   int64              `json:"locationId"`
+	Type         models.BookingType `json:"type"`
+	// From and To are days (YYYY-MM-DD) of each instructor's location, both inclusive
+	From string `json:"from"`
+	To   string `json:"to"`
+	// RRule optionally repeats the block weekly, e.g. FREQ=WEEKLY;BYDAY=MO,WE
+	RRule string `json:"rrule"`
+	// StartTime and EndTime restrict the block to a time window; without them
+	// the whole schedule of each instructor is blocked
+	StartTime       *models.ClockTime `json:"startTime"`
+	EndTime         *models.ClockTime `json:"endTime"`
+	AllowCollisions bool              `json:"allowCollisions"`
+}
+
+type blockCollision struct {
+	ID           int64     `json:"id"`
+	UserID       string    `json:"userId"`
+	FirstName    string    `json:"firstName"`
+	LastName     string    `json:"lastName"`
+	InstructorID int64     `json:"instructorId"`
+	StartsAt     time.Time `json:"startsAt"`
+	EndsAt       time.Time `json:"endsAt"`
+}
+
+// BlockRange blocks every slot of one or all instructors over a date range or
+// weekly recurrence in one transaction. SIMPLE bookings overlapping the blocked
+// time are reported; unless allowCollisions is set nothing is created when
+// there are any.
+func (h *BookingHandler) BlockRange(w http.ResponseWriter, r *http.Request) {
+	var req BlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	if !req.Type.IsBlocking() {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": models.ErrInvalidBlockType.Error()})
+		return
+	}
+
+	loc := models.LoadTimeZone(models.BusinessTimeZone)
+
+	from, err := time.ParseInLocation("2006-01-02", req.From, loc)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid from date"})
+		return
+	}
+
+	var to time.Time
+	if req.To != "" {
+		to, err = time.ParseInLocation("2006-01-02", req.To, loc)
+		if err != nil || to.Before(from) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid to date"})
+			return
+		}
+	}
+
+	limit := from.AddDate(0, 0, maxBlockDays-1)
+	var days []time.Time
+	if req.RRule != "" {
+		rule, err := models.ParseWeeklyRule(req.RRule)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		end := limit
+		if !to.IsZero() && to.Before(end) {
+			end = to
+		}
+		days = rule.Dates(from, end)
+	} else {
+		if to.IsZero() {
+			to = from
+		}
+		if to.After(limit) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Range too long"})
+			return
+		}
+		days = (&models.WeeklyRule{
+			Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday},
+		}).Dates(from, to)
+	}
+
+	var window *models.AvailabilityRange
+	if req.StartTime != nil || req.EndTime != nil {
+		if req.StartTime == nil || req.EndTime == nil || *req.EndTime <= *req.StartTime || *req.EndTime > 24*60 {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid time window"})
+			return
+		}
+		window = &models.AvailabilityRange{Start: *req.StartTime, End: *req.EndTime}
+	}
+
+	var instructors []*models.Instructor
+	if req.InstructorID != 0 {
+		instructor, err := h.instructorRepo.GetByID(req.InstructorID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
+				return
+			}
+			log.Printf("Error getting instructor: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return
+		}
+		instructors = append(instructors, instructor)
+	} else {
+		if req.LocationID != 0 {
+			instructors, err = h.instructorRepo.GetEnabledByLocation(req.LocationID)
+		} else {
+			instructors, err = h.instructorRepo.GetEnabled()
+		}
+		if err != nil {
+			log.Printf("Error getting instructors: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return
+		}
+	}
+
+	now := time.Now()
+	var blocks []*models.Booking
+	for _, instructor := range instructors {
+		var schedule models.WeeklySchedule
+		if window == nil {
+			schedule, err = h.availabilityRepo.GetByInstructorID(instructor.ID)
+			if err != nil {
+				log.Printf("Error getting instructor availability: %v", err)
+				sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+				return
+			}
+		}
+
+		for _, day := range days {
+			// Days are parsed as calendar dates; the block follows the instructor's clock
+			day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, instructor.Location())
+			ranges := schedule.RangesOn(day.Weekday())
+			if window != nil {
+				ranges = []models.AvailabilityRange{*window}
+			}
+			for _, rng := range ranges {
+				for _, chunk := range blockChunks(day, rng) {
+					if !chunk.StartsAt.After(now) {
+						continue
+					}
+					chunk.InstructorID = instructor.ID
+					chunk.Type = req.Type
+					blocks = append(blocks, chunk)
+				}
+			}
+		}
+	}
+
+	if len(blocks) == 0 {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "No future slots to block"})
+		return
+	}
+
+	result, err := h.bookingRepo.CreateBlocks(blocks, req.AllowCollisions)
+	if err != nil && !errors.Is(err, models.ErrBlockCollision) {
+		log.Printf("Error creating blocks: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	collisions := newBlockCollisions(result.Collisions)
+
+	if errors.Is(err, models.ErrBlockCollision) {
+		sendJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":      "Existing bookings collide with the block",
+			"collisions": collisions,
+		})
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"created":    len(result.Created),
+		"skipped":    result.Skipped,
+		"collisions": collisions,
+	})
+}
+
+func newBlockCollisions(bookings []*models.BookingWithUser) []blockCollision {
+	collisions := []blockCollision{}
+	for _, c := range bookings {
+		booking := models.Booking{StartsAt: c.StartsAt, DurationMinutes: c.DurationMinutes}
+		collisions = append(collisions, blockCollision{
+			ID:           c.ID,
+			UserID:       c.UserID.String,
+			FirstName:    c.UserFirstName.String,
+			LastName:     c.UserLastName.String,
+			InstructorID: c.InstructorID,
+			StartsAt:     c.StartsAt,
+			EndsAt:       booking.EndsAt(),
+		})
+	}
+	return collisions
+}
+
+// notifyBlockCancellations emails each member of the bookings a block
+// replaced a link to book again.
+func (h *BookingHandler) notifyBlockCancellations(cancelled []*models.BookingWithUser, timeZone, baseURL string) {
+	for _, c := range cancelled {
+		if c.UserID.Valid && c.UserEmail.Valid {
+			h.mailer.EnqueueSlotCancelledEmail(c.UserEmail.String, c.UserFirstName.String, c.StartsAt, timeZone, baseURL+"/user")
+		}
+	}
+}
+
+// blockChunks splits a time window of a local day into blocking bookings of
+// at most one hour, matching the hourly cells of the admin calendar.
+func blockChunks(day time.Time, window models.AvailabilityRange) []*models.Booking {
+	step := models.ClockTime(models.DefaultServiceDuration / time.Minute)
+
+	var chunks []*models.Booking
+	for minute := window.Start; minute < window.End; minute += step {
+		length := step
+		if minute+length > window.End {
+			length = window.End - minute
+		}
+		startsAt := time.Date(day.Year(), day.Month(), day.Day(), 0, int(minute), 0, 0, day.Location())
+		chunks = append(chunks, &models.Booking{
+			StartsAt:        startsAt.UTC(),
+			DurationMinutes: int(length),
+		})
+	}
+	return chunks
+}
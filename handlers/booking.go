@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
@@ -17,15 +16,25 @@ import (
 	"github.com/alarmfox/wellness-nutrition/app/websocket"
 )
 
-const businessTimeZone = "Europe/Rome"
-
 type BookingHandler struct {
-	bookingRepo    *models.BookingRepository
-	eventRepo      *models.EventRepository
-	userRepo       *models.UserRepository
-	instructorRepo *models.InstructorRepository
-	mailer         *mail.Mailer
-	hub            *websocket.Hub
+	bookingRepo      *models.BookingRepository
+	eventRepo        *models.EventRepository
+	userRepo         *models.UserRepository
+	instructorRepo   *models.InstructorRepository
+	availabilityRepo *models.AvailabilityRepository
+	closureRepo      *models.ClosureRepository
+	serviceRepo      *models.ServiceRepository
+	waitlistRepo     *models.WaitlistRepository
+	seriesRepo       *models.BookingSeriesRepository
+	policyRepo       *models.CancellationPolicyRepository
+	attendanceRepo   *models.AttendanceRepository
+	classRepo        *models.ClassRepository
+	resourceRepo     *models.ResourceRepository
+	settingsRepo     *models.BookingSettingsRepository
+	subscriptionRepo *models.SubscriptionRepository
+	walletRepo       *models.WalletRepository
+	mailer           *mail.Mailer
+	hub              *websocket.Hub
 }
 
 func NewBookingHandler(
@@ -33,16 +42,40 @@ func NewBookingHandler(
 	eventRepo *models.EventRepository,
 	userRepo *models.UserRepository,
 	instructorRepo *models.InstructorRepository,
+	availabilityRepo *models.AvailabilityRepository,
+	closureRepo *models.ClosureRepository,
+	serviceRepo *models.ServiceRepository,
+	waitlistRepo *models.WaitlistRepository,
+	seriesRepo *models.BookingSeriesRepository,
+	policyRepo *models.CancellationPolicyRepository,
+	attendanceRepo *models.AttendanceRepository,
+	classRepo *models.ClassRepository,
+	resourceRepo *models.ResourceRepository,
+	settingsRepo *models.BookingSettingsRepository,
+	subscriptionRepo *models.SubscriptionRepository,
+	walletRepo *models.WalletRepository,
 	mailer *mail.Mailer,
 	hub *websocket.Hub,
 ) *BookingHandler {
 	return &BookingHandler{
-		bookingRepo:    bookingRepo,
-		eventRepo:      eventRepo,
-		userRepo:       userRepo,
-		instructorRepo: instructorRepo,
-		mailer:         mailer,
-		hub:            hub,
+		bookingRepo:      bookingRepo,
+		eventRepo:        eventRepo,
+		userRepo:         userRepo,
+		instructorRepo:   instructorRepo,
+		availabilityRepo: availabilityRepo,
+		closureRepo:      closureRepo,
+		serviceRepo:      serviceRepo,
+		waitlistRepo:     waitlistRepo,
+		seriesRepo:       seriesRepo,
+		policyRepo:       policyRepo,
+		attendanceRepo:   attendanceRepo,
+		classRepo:        classRepo,
+		resourceRepo:     resourceRepo,
+		settingsRepo:     settingsRepo,
+		subscriptionRepo: subscriptionRepo,
+		walletRepo:       walletRepo,
+		mailer:           mailer,
+		hub:              hub,
 	}
 }
 
@@ -66,14 +99,13 @@ func (h *BookingHandler) GetCurrent(w http.ResponseWriter, r *http.Request) {
 type CreateBookingRequest struct {
 	StartsAt     string `json:"startsAt"`
 	InstructorID int64  `json:"instructorId"`
+	ServiceID    int64  `json:"serviceId"`
 }
 
 func (h *BookingHandler) Create(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUserFromContext(r.Context())
 
-	// Check if user can create booking
-	if time.Now().After(subscriptionExpiresAt(user.ExpiresAt)) || user.RemainingAccesses <= 0 {
-		sendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Subscription expired or no remaining accesses"})
+	if !h.checkNoShowPenalty(w, user) {
 		return
 	}
 
@@ -101,29 +133,67 @@ func (h *BookingHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !isBookableUserSlot(startsAt, user.ExpiresAt) {
+	schedule, err := h.availabilityRepo.GetByInstructorID(instructor.ID)
+	if err != nil {
+		log.Printf("Error getting instructor availability: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	closures, err := h.closureRepo.CalendarFor(instructor.ID, startsAt, startsAt)
+	if err != nil {
+		log.Printf("Error getting closures: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	service, err := h.lookupService(req.ServiceID, instructor.ID)
+	if err != nil {
+		sendServiceLookupError(w, err)
+		return
+	}
+
+	// Check if user can book the service
+	coveredUntil, ok := h.bookableUntil(w, user, serviceID(service).Int64)
+	if !ok {
+		return
+	}
+
+	settings, ok := h.bookingSettings(w)
+	if !ok {
+		return
+	}
+
+	if !isBookableUserSlot(startsAt, coveredUntil, settings, instructor.Location(), schedule, closures, serviceDuration(service)) {
 		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Slot not available"})
 		return
 	}
 
 	booking := models.Booking{
-		InstructorID: req.InstructorID,
-		UserID:       sql.NullString{Valid: true, String: user.ID},
-		StartsAt:     startsAt,
-		Type:         models.BookingTypeSimple,
+		InstructorID:    req.InstructorID,
+		UserID:          sql.NullString{Valid: true, String: user.ID},
+		StartsAt:        startsAt,
+		Type:            models.BookingTypeSimple,
+		ServiceID:       serviceID(service),
+		DurationMinutes: int(serviceDuration(service) / time.Minute),
+		BookedBy:        user.ID,
 	}
 
-	neededSlots := 1
-	if user.SubType == models.SubTypeSingle {
-		neededSlots = 2
-	}
+	neededSlots := models.BookingWeight(user.SubType, serviceCapacityWeight(service))
 
 	if err := h.bookingRepo.CreateUserBooking(&booking, neededSlots, instructor.MaxSlots); err != nil {
 		log.Printf("Error creating booking: %v", err)
-		if errors.Is(err, models.ErrSlotUnavailable) || errors.Is(err, models.ErrNoAccesses) {
+		if sendBookingLimitError(w, err) || sendUserOverlapError(w, err) || sendFrozenError(w, err) {
+			return
+		}
+		if errors.Is(err, models.ErrSlotUnavailable) || errors.Is(err, models.ErrNoAccesses) || errors.Is(err, models.ErrClosed) {
 			sendJSON(w, http.StatusConflict, map[string]string{"error": "Slot not available"})
 			return
 		}
+		if errors.Is(err, models.ErrResourceUnavailable) {
+			sendJSON(w, http.StatusConflict, map[string]string{"error": "Resource not available"})
+			return
+		}
 		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 		return
 	}
@@ -139,15 +209,15 @@ func (h *BookingHandler) Create(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Error creating event: %v", err)
 	}
 
-	h.mailer.EnqueueNewBookingNotification(user.FirstName, user.LastName, startsAt)
+	h.mailer.EnqueueNewBookingNotification(user.FirstName, user.LastName, startsAt, instructor.TimeZone)
 	//
 	// // Send WebSocket notification
 	if h.hub != nil {
 		h.hub.BroadcastJSON(
 			websocket.NotificationBookingCreated,
-			fmt.Sprintf("Nuova prenotazione: %s %s - %s", user.FirstName, user.LastName, formatBusinessTime(startsAt)),
+			fmt.Sprintf("Nuova prenotazione: %s %s - %s", user.FirstName, user.LastName, formatBusinessTime(startsAt, instructor.Location())),
 			fmt.Sprintf("%s %s", user.FirstName, user.LastName),
-			formatBusinessTime(startsAt),
+			formatBusinessTime(startsAt, instructor.Location()),
 		)
 	}
 
@@ -184,53 +254,63 @@ func (h *BookingHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete booking
-	if err := h.bookingRepo.Delete(idInt); err != nil {
-		log.Printf("Error deleting booking: %v", err)
-		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
-		return
+	// Bookings without a member, such as blocks, have no owner nor policy
+	var owner *models.User
+	if booking.UserID.Valid {
+		owner = user
+		if booking.UserID.String != user.ID {
+			owner, err = h.userRepo.GetByID(booking.UserID.String)
+			if err != nil {
+				log.Printf("Error getting user: %v", err)
+				sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+				return
+			}
+		}
 	}
 
-	// Refund policy: only refund if deleted 3+ hours before event
-	shouldRefund := time.Until(booking.StartsAt) >= 3*time.Hour
-
-	// Only increment accesses if cancelling 3+ hours before
-	if shouldRefund {
-		if err := h.userRepo.IncrementAccesses(booking.UserID.String); err != nil {
-			log.Printf("Error incrementing accesses: %v", err)
-		}
+	policy, err := h.bookingPolicy(booking)
+	if err != nil {
+		log.Printf("Error getting cancellation policy: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
 	}
 
-	// Create event
-	event := &models.Event{
-		UserID:     user.ID,
-		StartsAt:   booking.StartsAt,
-		Type:       models.EventTypeDeleted,
-		OccurredAt: time.Now().UTC(),
+	status := models.BookingStatusCancelledByUser
+	if owner == nil || user.ID != owner.ID {
+		status = models.BookingStatusCancelledByAdmin
 	}
-	if err := h.eventRepo.Create(event); err != nil {
-		log.Printf("Error creating event: %v", err)
+	cancelled, err := h.bookingRepo.CancelByPolicy(idInt, status, user.ID, policyDecision(policy))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+			return
+		}
+		log.Printf("Error cancelling booking: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
 	}
+	booking, decision := cancelled.Booking, cancelled.Decision
 
-	h.mailer.EnqueueDeleteBookingNotification(user.FirstName, user.LastName, booking.StartsAt)
+	h.mailer.EnqueueDeleteBookingNotification(user.FirstName, user.LastName, booking.StartsAt, booking.TimeZone)
 
 	// Send WebSocket notification
-	if h.hub != nil {
-		// Get user info for the notification
-		bookingUser, _ := h.userRepo.GetByID(booking.UserID.String)
-		userName := "Unknown"
-		if bookingUser != nil {
-			userName = fmt.Sprintf("%s %s", bookingUser.FirstName, bookingUser.LastName)
-		}
+	if h.hub != nil && owner != nil {
+		userName := fmt.Sprintf("%s %s", owner.FirstName, owner.LastName)
 		h.hub.BroadcastJSON(
 			websocket.NotificationBookingDeleted,
-			fmt.Sprintf("Prenotazione cancellata: %s - %s", userName, formatBusinessTime(booking.StartsAt)),
+			fmt.Sprintf("Prenotazione cancellata: %s - %s", userName, formatBusinessTime(booking.StartsAt, booking.Location())),
 			userName,
-			formatBusinessTime(booking.StartsAt),
+			formatBusinessTime(booking.StartsAt, booking.Location()),
 		)
 	}
 
-	sendJSON(w, http.StatusOK, map[string]string{"message": "Booking deleted successfully"})
+	h.promoteWaitlist(booking, getBaseURL(r))
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "Booking deleted successfully",
+		"refunded": decision.Refund,
+		"reason":   decision.Reason,
+	})
 }
 
 func (h *BookingHandler) DeleteAdmin(w http.ResponseWriter, r *http.Request) {
@@ -241,41 +321,57 @@ func (h *BookingHandler) DeleteAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	refund := r.URL.Query().Get("refund")
-	if refund == "" {
-		refund = "false"
-	}
-
-	refundBool, err := strconv.ParseBool(refund)
-	if err != nil {
-		log.Printf("Error deleting booking: %v", err)
-		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
-		return
+	// Without the refund flag the owner's cancellation policy decides
+	var override *bool
+	if refund := r.URL.Query().Get("refund"); refund != "" {
+		refundBool, err := strconv.ParseBool(refund)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid refund flag"})
+			return
+		}
+		override = &refundBool
 	}
 
 	booking, err := h.bookingRepo.GetByID(idInt)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+			return
+		}
 		log.Printf("Error deleting booking: %v", err)
 		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 		return
 	}
 
-	if booking.Type == models.BookingTypeSimple && refundBool {
-		slog.Debug("Refunding access")
-		if err := h.userRepo.IncrementAccesses(booking.UserID.String); err != nil {
-			log.Printf("Error refunding booking access: %v", err)
-			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to refund access"})
+	var decide func(*models.Booking, int) models.CancellationDecision
+	switch {
+	case override == nil:
+		policy, err := h.bookingPolicy(booking)
+		if err != nil {
+			log.Printf("Error getting cancellation policy: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 			return
 		}
+		decide = policyDecision(policy)
+	case *override:
+		decide = fixedDecision(models.CancellationDecision{Refund: true, Reason: "Cancellata dall'amministratore: accesso rimborsato"})
+	default:
+		decide = fixedDecision(models.CancellationDecision{Reason: "Cancellata dall'amministratore: accesso non rimborsato"})
 	}
 
-	// Delete booking
-	if err := h.bookingRepo.Delete(idInt); err != nil {
-		log.Printf("Error deleting booking: %v", err)
+	cancelled, err := h.bookingRepo.CancelByPolicy(idInt, models.BookingStatusCancelledByAdmin, actorID(r), decide)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+			return
+		}
+		log.Printf("Error cancelling booking: %v", err)
 		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 		return
 	}
 
+	h.promoteWaitlist(cancelled.Booking, getBaseURL(r))
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -309,9 +405,20 @@ func (h *BookingHandler) GetAllBookings(w http.ResponseWriter, r *http.Request)
 
 	var bookings []*models.BookingWithUser
 
-	// Filter by instructor if specified
+	var locationID int64
+	if value := r.URL.Query().Get("locationId"); value != "" {
+		locationID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid locationId"})
+			return
+		}
+	}
+
+	// Filter by instructor or location if specified
 	if instructorID != "" {
 		bookings, err = h.bookingRepo.GetWithUsersByInstructorAndDateRange(instructorID, from, to)
+	} else if locationID != 0 {
+		bookings, err = h.bookingRepo.GetWithUsersByLocationAndDateRange(locationID, from, to)
 	} else {
 		bookings, err = h.bookingRepo.GetWithUsersByDateRange(from, to)
 	}
@@ -327,8 +434,11 @@ func (h *BookingHandler) GetAllBookings(w http.ResponseWriter, r *http.Request)
 		ID           int64     `json:"id"`
 		StartsAt     time.Time `json:"startsAt"`
 		CreatedAt    time.Time `json:"createdAt"`
+		EndsAt       time.Time `json:"endsAt"`
 		InstructorId int64     `json:"instructorId"`
 		Type         string    `json:"type"`
+		ServiceID    *int64    `json:"serviceId"`
+		ServiceName  string    `json:"serviceName,omitempty"`
 		User         *struct {
 			ID        string `json:"id"`
 			FirstName string `json:"firstName"`
@@ -357,7 +467,13 @@ func (h *BookingHandler) GetAllBookings(w http.ResponseWriter, r *http.Request)
 		}
 		result[i].ID = booking.ID
 		result[i].StartsAt = booking.StartsAt
+		result[i].EndsAt = booking.StartsAt.Add(time.Duration(booking.DurationMinutes) * time.Minute)
 		result[i].CreatedAt = booking.CreatedAt
+		if booking.ServiceID.Valid {
+			id := booking.ServiceID.Int64
+			result[i].ServiceID = &id
+			result[i].ServiceName = booking.ServiceName.String
+		}
 
 		result[i].InstructorId = booking.InstructorID
 		result[i].Type = string(booking.Type)
@@ -372,7 +488,15 @@ func (h *BookingHandler) CreateBookingForUser(w http.ResponseWriter, r *http.Req
 		UserID       string             `json:"userId"`
 		StartsAt     string             `json:"startsAt"`
 		InstructorID int64              `json:"instructorId"`
+		ServiceID    int64              `json:"serviceId"`
 		Type         models.BookingType `json:"type"`
+		// ResourceIDs overrides the resources of the service, one unit each
+		ResourceIDs []int64 `json:"resourceIds"`
+		// AllowOverlap books the user even if they hold another booking at this time
+		AllowOverlap bool `json:"allowOverlap"`
+		// Confirmed cancels and refunds the SIMPLE bookings a block overlaps;
+		// without it they are only listed
+		Confirmed bool `json:"confirmed"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -398,11 +522,44 @@ func (h *BookingHandler) CreateBookingForUser(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	schedule, err := h.availabilityRepo.GetByInstructorID(instructor.ID)
+	if err != nil {
+		log.Printf("Error getting instructor availability: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	service, err := h.lookupService(req.ServiceID, instructor.ID)
+	if err != nil {
+		sendServiceLookupError(w, err)
+		return
+	}
+
+	if !isWithinSchedule(startsAt, instructor.Location(), schedule, serviceDuration(service)) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Slot outside instructor availability"})
+		return
+	}
+
 	booking := &models.Booking{
-		UserID:       sql.NullString{Valid: req.UserID != "", String: req.UserID},
-		InstructorID: req.InstructorID,
-		StartsAt:     startsAt,
-		Type:         req.Type,
+		UserID:          sql.NullString{Valid: req.UserID != "", String: req.UserID},
+		InstructorID:    req.InstructorID,
+		StartsAt:        startsAt,
+		Type:            req.Type,
+		ServiceID:       serviceID(service),
+		DurationMinutes: int(serviceDuration(service) / time.Minute),
+		AllowOverlap:    req.AllowOverlap,
+		BookedBy:        actorID(r),
+	}
+	if req.ResourceIDs != nil {
+		booking.Resources = []models.ResourceRequirement{}
+		seen := make(map[int64]bool)
+		for _, id := range req.ResourceIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			booking.Resources = append(booking.Resources, models.ResourceRequirement{ResourceID: id, Quantity: 1})
+		}
 	}
 
 	if booking.Type == models.BookingTypeSimple {
@@ -413,24 +570,60 @@ func (h *BookingHandler) CreateBookingForUser(w http.ResponseWriter, r *http.Req
 			return
 		}
 
-		neededSlots := 1
-		if user.SubType == models.SubTypeSingle {
-			neededSlots = 2
-		}
+		neededSlots := models.BookingWeight(user.SubType, serviceCapacityWeight(service))
 		if err := h.bookingRepo.CreateUserBooking(booking, neededSlots, instructor.MaxSlots); err != nil {
 			log.Printf("Error creating booking: %v", err)
-			if errors.Is(err, models.ErrSlotUnavailable) || errors.Is(err, models.ErrNoAccesses) {
+			if sendBookingLimitError(w, err) || sendUserOverlapError(w, err) || sendFrozenError(w, err) {
+				return
+			}
+			if errors.Is(err, models.ErrSlotUnavailable) || errors.Is(err, models.ErrNoAccesses) || errors.Is(err, models.ErrClosed) {
 				sendJSON(w, http.StatusConflict, map[string]string{"error": "Slot not available"})
 				return
 			}
+			if errors.Is(err, models.ErrResourceUnavailable) {
+				sendJSON(w, http.StatusConflict, map[string]string{"error": "Resource not available"})
+				return
+			}
 			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 			return
 		}
 
-		h.mailer.EnqueueNewBookingNotification(user.FirstName, user.LastName, startsAt)
-	} else if err := h.bookingRepo.Create(booking); err != nil {
-		log.Printf("Error creating booking: %v", err)
-		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		h.mailer.EnqueueNewBookingNotification(user.FirstName, user.LastName, startsAt, instructor.TimeZone)
+	} else {
+		cancelled, err := h.bookingRepo.CreateBlockCancelling(booking, req.Confirmed, actorID(r))
+		if err != nil {
+			if errors.Is(err, models.ErrBlockCollision) {
+				// Nothing was created: the admin confirms after reviewing the bookings
+				sendJSON(w, http.StatusOK, map[string]interface{}{
+					"hasBookings":  true,
+					"bookingCount": len(cancelled),
+					"bookings":     newBlockCollisions(cancelled),
+				})
+				return
+			}
+			log.Printf("Error creating booking: %v", err)
+			if errors.Is(err, models.ErrInvalidBlockType) {
+				sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			if sendUserOverlapError(w, err) {
+				return
+			}
+			if errors.Is(err, models.ErrResourceUnavailable) {
+				sendJSON(w, http.StatusConflict, map[string]string{"error": "Resource not available"})
+				return
+			}
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return
+		}
+
+		h.notifyBlockCancellations(cancelled, booking.TimeZone, getBaseURL(r))
+
+		sendJSON(w, http.StatusCreated, map[string]interface{}{
+			"message":        "Booking created successfully",
+			"bookingId":      booking.ID,
+			"cancelledCount": len(cancelled),
+		})
 		return
 	}
 
@@ -461,8 +654,29 @@ func (h *BookingHandler) GetAvailableSlots(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Verify instructor exists and can accept bookings
+	var requestedServiceID int64
+	if serviceIDStr := r.URL.Query().Get("serviceId"); serviceIDStr != "" {
+		requestedServiceID, err = strconv.ParseInt(serviceIDStr, 10, 64)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid serviceId"})
+			return
+		}
+	}
+
+	var locationID int64
+	if value := r.URL.Query().Get("locationId"); value != "" {
+		locationID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid locationId"})
+			return
+		}
+	}
+
+	// Verify instructor exists, works at the requested location and can accept bookings
 	instructor, err := h.instructorRepo.GetEnabledByID(instructorID)
+	if err == nil && locationID != 0 && instructor.LocationID != locationID {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
@@ -473,72 +687,113 @@ func (h *BookingHandler) GetAvailableSlots(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	settings, ok := h.bookingSettings(w)
+	if !ok {
+		return
+	}
+
+	service, err := h.lookupService(requestedServiceID, instructor.ID)
+	if err != nil {
+		sendServiceLookupError(w, err)
+		return
+	}
+	duration := serviceDuration(service)
+
 	now := time.Now().UTC()
-	// Calculate end date: 1 month from now or user's plan expiration, whichever is earlier
-	endDate := now.AddDate(0, 1, 0)
-	userExpiration := subscriptionExpiresAt(user.ExpiresAt)
-	if userExpiration.Before(endDate) {
+	coveredUntil, _, err := h.coverage(user, serviceID(service).Int64, now)
+	covered := err == nil
+	if err != nil && !errors.Is(err, models.ErrNoActiveSubscription) {
+		log.Printf("Error getting subscription: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	// Slots start after the lead time and end at the horizon or the end of the
+	// balance the user books the service with, whichever is earlier. Without
+	// one nothing is bookable.
+	startDate := settings.BookableFrom(now)
+	endDate := settings.Horizon(now)
+	if !covered {
+		endDate = startDate
+	} else if userExpiration := subscriptionExpiresAt(coveredUntil); userExpiration.Before(endDate) {
 		endDate = userExpiration
 	}
 
-	// Generate all possible slots from 7am to 9pm, Monday-Saturday
-	slots := generateSlots(now, endDate)
+	schedule, err := h.availabilityRepo.GetByInstructorID(instructor.ID)
+	if err != nil {
+		log.Printf("Error getting instructor availability: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
 
-	// Get all bookings for this instructor in the date range
-	bookings, err := h.bookingRepo.GetWithUsersByInstructorAndDateRange(instructorIDStr, now, endDate)
+	closures, err := h.closureRepo.CalendarFor(instructor.ID, startDate, endDate)
 	if err != nil {
-		log.Printf("Error getting bookings: %v", err)
+		log.Printf("Error getting closures: %v", err)
 		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 		return
 	}
 
-	// Build map of unavailable slots
-	unavailableSlots := make(map[int64]bool)
-	slotBookingCount := make(map[int64]int)
+	// Generate all possible slots from the instructor's weekly schedule, skipping closed days
+	slots := generateSlots(startDate, endDate, instructor.Location(), schedule, closures, duration)
+
+	// Get all bookings for this instructor that can overlap the date range
+	// (a booking lasts at most one day)
+	bookings, err := h.bookingRepo.GetWithUsersByInstructorAndDateRange(instructorIDStr, now.AddDate(0, 0, -1), endDate)
+	if err != nil {
+		log.Printf("Error getting bookings: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
 
+	// DISABLE, APPOINTMENT and MASSAGE block their whole interval, SIMPLE bookings
+	// take capacity weighted by subscription type and service
+	occupancies := make([]models.Occupancy, 0, len(bookings))
 	for _, booking := range bookings {
-		// Both booking times and slots are in UTC, so direct comparison works
-		slotKey := booking.StartsAt.Unix()
-
-		// Slot is unavailable if:
-		// 1. There's a DISABLE, APPOINTMENT, or MASSAGE booking
-		if booking.Type == models.BookingTypeDisable ||
-			booking.Type == models.BookingTypeAppointment ||
-			booking.Type == models.BookingTypeMassage {
-			unavailableSlots[slotKey] = true
-			continue
-		}
+		occupancies = append(occupancies, booking.Occupancy())
+	}
 
-		// 2. Count SIMPLE bookings weighted by subscription type
-		// SINGLE subscriptions consume 2 slots, SHARED consume 1
-		if booking.Type == models.BookingTypeSimple && booking.UserID.Valid {
-			if models.SubType(booking.UserSubType.String) == models.SubTypeSingle {
-				slotBookingCount[slotKey] += 2
-			} else {
-				slotBookingCount[slotKey]++
-			}
-		}
+	// The instructor takes no bookings while teaching a group class
+	classOccupancies, err := h.classRepo.GetOccupancies(instructor.ID, now.AddDate(0, 0, -1), endDate)
+	if err != nil {
+		log.Printf("Error getting class sessions: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
 	}
+	occupancies = append(occupancies, classOccupancies...)
 
-	// Filter slots based on availability rules
-	var availableSlots []time.Time
-	neededSlots := 1
-	if user.SubType == models.SubTypeSingle {
-		neededSlots = 2
+	// Rooms and machines are shared with the other instructors
+	var needs []models.ResourceRequirement
+	if service != nil {
+		needs = service.Resources
+	}
+	resources, err := h.resourceRepo.GetCalendar(needs, now.AddDate(0, 0, -1), endDate)
+	if err != nil {
+		log.Printf("Error getting resources: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
 	}
 
-	for _, slot := range slots {
-		slotKey := slot.Unix()
+	// Filter slots based on availability rules; full slots can still be waitlisted
+	// and slots not released yet are listed with their opening time
+	var availableSlots []time.Time
+	fullSlots := []time.Time{}
+	unreleasedSlots := []unreleasedSlot{}
+	neededSlots := models.BookingWeight(user.SubType, serviceCapacityWeight(service))
 
-		// Skip if explicitly unavailable (DISABLE, APPOINTMENT, MASSAGE)
-		if unavailableSlots[slotKey] {
+	for _, slot := range slots {
+		usedSlots, blocked := models.PeakLoad(occupancies, slot, slot.Add(duration))
+		if blocked || !resources.Fits(needs, slot, slot.Add(duration)) {
 			continue
 		}
 
-		usedSlots := slotBookingCount[slotKey]
+		if !settings.Released(slot, now, instructor.Location()) {
+			unreleasedSlots = append(unreleasedSlots, unreleasedSlot{StartsAt: slot, ReleasesAt: settings.ReleaseAt(slot, instructor.Location())})
+			continue
+		}
 
 		// Slot is unavailable if there isn't enough capacity for the current user
 		if usedSlots+neededSlots > instructor.MaxSlots {
+			fullSlots = append(fullSlots, slot)
 			continue
 		}
 
@@ -547,30 +802,42 @@ func (h *BookingHandler) GetAvailableSlots(w http.ResponseWriter, r *http.Reques
 	}
 
 	sendJSON(w, http.StatusOK, map[string]interface{}{
-		"slots": availableSlots,
+		"slots":           availableSlots,
+		"fullSlots":       fullSlots,
+		"unreleasedSlots": unreleasedSlots,
 	})
 }
 
-// generateSlots creates hourly Europe/Rome slots from 7am to 9pm, Monday-Saturday.
-// Slots are hourly intervals
-func generateSlots(start, end time.Time) []time.Time {
+// unreleasedSlot is a slot members can see but not book before ReleasesAt
+type unreleasedSlot struct {
+	StartsAt   time.Time `json:"startsAt"`
+	ReleasesAt time.Time `json:"releasesAt"`
+}
+
+// generateSlots creates slots inside the instructor's weekly schedule, read in
+// the time zone of their location, skipping days covered by a closure.
+// Slots are consecutive intervals of the given duration starting at the beginning of each range.
+func generateSlots(start, end time.Time, loc *time.Location, schedule models.WeeklySchedule, closures models.ClosureCalendar, duration time.Duration) []time.Time {
 	var slots []time.Time
-	loc, err := time.LoadLocation(businessTimeZone)
-	if err != nil {
-		panic(err)
-	}
 
 	startLocal := start.In(loc)
 	endLocal := end.In(loc)
 
 	currentDay := time.Date(startLocal.Year(), startLocal.Month(), startLocal.Day(), 0, 0, 0, 0, loc)
+	step := models.ClockTime(duration / time.Minute)
+	if step <= 0 {
+		return nil
+	}
 
 	for !currentDay.After(endLocal) {
-		// Only include Monday (1) through Saturday (6)
-		weekday := currentDay.Weekday()
-		if weekday >= time.Monday && weekday <= time.Saturday {
-			for hour := 7; hour <= 21; hour++ {
-				slotLocal := time.Date(currentDay.Year(), currentDay.Month(), currentDay.Day(), hour, 0, 0, 0, loc)
+		if closures.IsClosed(currentDay) {
+			currentDay = currentDay.AddDate(0, 0, 1)
+			continue
+		}
+
+		for _, r := range schedule.RangesOn(currentDay.Weekday()) {
+			for minute := r.Start; minute+step <= r.End; minute += step {
+				slotLocal := time.Date(currentDay.Year(), currentDay.Month(), currentDay.Day(), 0, int(minute), 0, 0, loc)
 				if !slotLocal.Before(startLocal) && slotLocal.Before(endLocal) {
 					slots = append(slots, slotLocal.UTC())
 				}
@@ -584,44 +851,181 @@ func generateSlots(start, end time.Time) []time.Time {
 }
 
 func subscriptionExpiresAt(expiresAt time.Time) time.Time {
-	loc, err := time.LoadLocation(businessTimeZone)
-	if err != nil {
-		panic(err)
-	}
+	loc := models.LoadTimeZone(models.BusinessTimeZone)
 	expiresAt = expiresAt.In(loc)
 	return time.Date(expiresAt.Year(), expiresAt.Month(), expiresAt.Day(), 23, 59, 59, int(time.Second-time.Nanosecond), loc)
 }
 
-func isBookableUserSlot(startsAt, expiresAt time.Time) bool {
-	loc, err := time.LoadLocation(businessTimeZone)
+// coverage returns the last day a member can book the service on and the
+// balance the booking would consume: their wallet for the service when it is
+// valid and not spent, their subscription and general balance otherwise. A zero
+// serviceID always uses the subscription. It returns
+// models.ErrNoActiveSubscription when that balance has expired.
+func (h *BookingHandler) coverage(user *models.User, serviceID int64, at time.Time) (time.Time, int, error) {
+	if serviceID != 0 {
+		// An expired or spent wallet leaves the service to the general balance
+		wallet, err := h.walletRepo.GetForService(user.ID, serviceID)
+		if err == nil && wallet.Covers(at) && wallet.Remaining > 0 {
+			return wallet.ExpiresOn, wallet.Remaining, nil
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return time.Time{}, 0, err
+		}
+	}
+
+	subscription, err := h.subscriptionRepo.GetActive(user.ID, at)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return subscription.CoveredUntil, user.RemainingAccesses, nil
+}
+
+// bookableUntil returns the last day the member can book the service on. It
+// answers 401 and returns false when their balance for it has expired or no
+// accesses are left.
+func (h *BookingHandler) bookableUntil(w http.ResponseWriter, user *models.User, serviceID int64) (time.Time, bool) {
+	coveredUntil, remaining, err := h.coverage(user, serviceID, time.Now())
+	if errors.Is(err, models.ErrNoActiveSubscription) || (err == nil && remaining <= 0) {
+		sendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Subscription expired or no remaining accesses"})
+		return time.Time{}, false
+	}
 	if err != nil {
-		panic(err)
+		log.Printf("Error getting subscription: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return time.Time{}, false
 	}
+	return coveredUntil, true
+}
+
+// isBookableUserSlot reports whether a member can book startsAt now: at a
+// bookable time and inside the instructor's open schedule.
+func isBookableUserSlot(startsAt, expiresAt time.Time, settings *models.BookingSettings, loc *time.Location, schedule models.WeeklySchedule, closures models.ClosureCalendar, duration time.Duration) bool {
+	return isBookableUserTime(startsAt, expiresAt, settings, loc) &&
+		!closures.IsClosed(startsAt.In(loc)) &&
+		isWithinSchedule(startsAt, loc, schedule, duration)
+}
 
-	now := time.Now().Add(time.Hour * 4).UTC()
-	endDate := now.AddDate(0, 1, 0)
+// isBookableUserTime reports whether a member can book or enroll at startsAt
+// now: between the lead time and the horizon of the settings, released in
+// the week of loc and before their plan expires.
+func isBookableUserTime(startsAt, expiresAt time.Time, settings *models.BookingSettings, loc *time.Location) bool {
+	now := time.Now().UTC()
+	endDate := settings.Horizon(now)
 	userExpiration := subscriptionExpiresAt(expiresAt)
 	if userExpiration.Before(endDate) {
 		endDate = userExpiration
 	}
 
-	local := startsAt.In(loc)
-	weekday := local.Weekday()
-	return startsAt.After(now) &&
+	return startsAt.After(settings.BookableFrom(now)) &&
 		!startsAt.After(endDate) &&
-		weekday >= time.Monday &&
-		weekday <= time.Saturday &&
-		local.Hour() >= 7 &&
-		local.Hour() <= 21 &&
-		local.Minute() == 0 &&
-		local.Second() == 0 &&
-		local.Nanosecond() == 0
+		settings.Released(startsAt, now, loc)
+}
+
+// isWithinSchedule reports whether a slot of the given duration starting at
+// startsAt is one of the slots generated from the instructor's weekly schedule.
+func isWithinSchedule(startsAt time.Time, loc *time.Location, schedule models.WeeklySchedule, duration time.Duration) bool {
+	return schedule.Covers(startsAt.In(loc), duration)
 }
 
-func formatBusinessTime(t time.Time) string {
-	loc, err := time.LoadLocation(businessTimeZone)
+var errServiceNotOffered = errors.New("service not offered by instructor")
+
+// lookupService returns the enabled service booked with the instructor.
+// A zero serviceID selects the standard session and returns a nil service.
+func (h *BookingHandler) lookupService(serviceID, instructorID int64) (*models.Service, error) {
+	if serviceID == 0 {
+		return nil, nil
+	}
+
+	service, err := h.serviceRepo.GetEnabledByID(serviceID)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if !service.OffersInstructor(instructorID) {
+		return nil, errServiceNotOffered
 	}
+	return service, nil
+}
+
+func sendServiceLookupError(w http.ResponseWriter, err error) {
+	if err == sql.ErrNoRows {
+		sendJSON(w, http.StatusNotFound, map[string]string{"error": "Service not found"})
+		return
+	}
+	if errors.Is(err, errServiceNotOffered) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Service not offered by instructor"})
+		return
+	}
+	log.Printf("Error getting service: %v", err)
+	sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+}
+
+// sendUserOverlapError writes a 409 when err reports that the member already
+// has a booking at that time, returning false otherwise.
+func sendUserOverlapError(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, models.ErrUserOverlap) {
+		return false
+	}
+	sendJSON(w, http.StatusConflict, map[string]string{"error": "User already has a booking at this time", "code": "USER_OVERLAP"})
+	return true
+}
+
+// actorID returns the id of the signed-in user acting on a request,
+// empty when there is none.
+func actorID(r *http.Request) string {
+	if user := middleware.GetUserFromContext(r.Context()); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+func serviceDuration(service *models.Service) time.Duration {
+	if service == nil {
+		return models.DefaultServiceDuration
+	}
+	return service.Duration()
+}
+
+func serviceCapacityWeight(service *models.Service) int {
+	if service == nil {
+		return 1
+	}
+	return service.CapacityWeight
+}
+
+func serviceID(service *models.Service) sql.NullInt64 {
+	if service == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: service.ID, Valid: true}
+}
+
+// bookingPolicy returns the cancellation policy of the member of a booking,
+// nil for bookings without one.
+func (h *BookingHandler) bookingPolicy(booking *models.Booking) (*models.CancellationPolicy, error) {
+	if !booking.UserID.Valid {
+		return nil, nil
+	}
+	return h.policyRepo.GetForUser(booking.UserID.String, time.Now())
+}
+
+// policyDecision applies policy to a booking cancelled now, for
+// BookingRepository.CancelByPolicy.
+func policyDecision(policy *models.CancellationPolicy) func(*models.Booking, int) models.CancellationDecision {
+	return func(booking *models.Booking, lateRefundsUsed int) models.CancellationDecision {
+		return policy.Decide(booking.StartsAt, time.Now(), lateRefundsUsed)
+	}
+}
+
+// fixedDecision returns decision for any booking, for
+// BookingRepository.CancelByPolicy.
+func fixedDecision(decision models.CancellationDecision) func(*models.Booking, int) models.CancellationDecision {
+	return func(*models.Booking, int) models.CancellationDecision {
+		return decision
+	}
+}
+
+// formatBusinessTime formats t for admin notifications in the time zone of
+// the location it refers to.
+func formatBusinessTime(t time.Time, loc *time.Location) string {
 	return t.In(loc).Format("02/01/2006 15:04")
 }
@@ -3,10 +3,12 @@ package handlers
 import (
 	"testing"
 	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
 )
 
 func TestGenerateSlots(t *testing.T) {
-	loc, err := time.LoadLocation(businessTimeZone)
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -14,7 +16,7 @@ func TestGenerateSlots(t *testing.T) {
 	start := time.Date(2024, 1, 1, 0, 0, 0, 0, loc) // Monday
 	end := time.Date(2024, 1, 8, 0, 0, 0, 0, loc)   // Next Monday
 
-	slots := generateSlots(start, end)
+	slots := generateSlots(start, end, loc, models.DefaultWeeklySchedule(), nil, models.DefaultServiceDuration)
 
 	// Should have slots from Monday to Saturday (6 days)
 	// Each day has 15 hours (7am-9pm inclusive, hourly slots)
@@ -53,7 +55,7 @@ func TestGenerateSlots(t *testing.T) {
 }
 
 func TestGenerateSlotsShortPeriod(t *testing.T) {
-	loc, err := time.LoadLocation(businessTimeZone)
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -62,7 +64,7 @@ func TestGenerateSlotsShortPeriod(t *testing.T) {
 	start := time.Date(2024, 1, 1, 10, 0, 0, 0, loc) // Monday 10am Rome
 	end := time.Date(2024, 1, 1, 15, 0, 0, 0, loc)   // Monday 3pm Rome
 
-	slots := generateSlots(start, end)
+	slots := generateSlots(start, end, loc, models.DefaultWeeklySchedule(), nil, models.DefaultServiceDuration)
 
 	// Should have slots from 10am to 2pm (5 slots)
 	if len(slots) != 5 {
@@ -71,7 +73,7 @@ func TestGenerateSlotsShortPeriod(t *testing.T) {
 }
 
 func TestGenerateSlotsStartBeforeSeven(t *testing.T) {
-	loc, err := time.LoadLocation(businessTimeZone)
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -80,7 +82,7 @@ func TestGenerateSlotsStartBeforeSeven(t *testing.T) {
 	start := time.Date(2024, 1, 1, 5, 0, 0, 0, loc) // Monday 5am Rome
 	end := time.Date(2024, 1, 1, 10, 0, 0, 0, loc)  // Monday 10am Rome
 
-	slots := generateSlots(start, end)
+	slots := generateSlots(start, end, loc, models.DefaultWeeklySchedule(), nil, models.DefaultServiceDuration)
 
 	// First slot should be at 7am or later
 	if len(slots) > 0 && slots[0].In(loc).Hour() < 7 {
@@ -89,22 +91,256 @@ func TestGenerateSlotsStartBeforeSeven(t *testing.T) {
 }
 
 func TestGenerateSlotsDSTOffsets(t *testing.T) {
-	loc, err := time.LoadLocation(businessTimeZone)
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	winterStart := time.Date(2024, 1, 1, 0, 0, 0, 0, loc)
 	winterEnd := time.Date(2024, 1, 2, 0, 0, 0, 0, loc)
-	winterSlots := generateSlots(winterStart, winterEnd)
+	winterSlots := generateSlots(winterStart, winterEnd, loc, models.DefaultWeeklySchedule(), nil, models.DefaultServiceDuration)
 	if got, want := winterSlots[0], time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC); !got.Equal(want) {
 		t.Fatalf("winter 07:00 Europe/Rome should be %s, got %s", want, got)
 	}
 
 	summerStart := time.Date(2024, 7, 1, 0, 0, 0, 0, loc)
 	summerEnd := time.Date(2024, 7, 2, 0, 0, 0, 0, loc)
-	summerSlots := generateSlots(summerStart, summerEnd)
+	summerSlots := generateSlots(summerStart, summerEnd, loc, models.DefaultWeeklySchedule(), nil, models.DefaultServiceDuration)
 	if got, want := summerSlots[0], time.Date(2024, 7, 1, 5, 0, 0, 0, time.UTC); !got.Equal(want) {
 		t.Fatalf("summer 07:00 Europe/Rome should be %s, got %s", want, got)
 	}
 }
+
+func TestGenerateSlotsFollowsInstructorSchedule(t *testing.T) {
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := models.WeeklySchedule{
+		{Weekday: time.Sunday, Start: 9 * 60, End: 12 * 60},
+		{Weekday: time.Tuesday, Start: 14 * 60, End: 16 * 60},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, loc) // Monday
+	end := time.Date(2024, 1, 8, 0, 0, 0, 0, loc)   // Next Monday
+
+	slots := generateSlots(start, end, loc, schedule, nil, models.DefaultServiceDuration)
+
+	// Tuesday 14:00, 15:00 and Sunday 09:00, 10:00, 11:00
+	if len(slots) != 5 {
+		t.Fatalf("Expected 5 slots, got %d", len(slots))
+	}
+	if got := slots[0].In(loc); got.Weekday() != time.Tuesday || got.Hour() != 14 {
+		t.Errorf("Expected first slot on Tuesday at 14:00, got %v", got)
+	}
+	if got := slots[len(slots)-1].In(loc); got.Weekday() != time.Sunday || got.Hour() != 11 {
+		t.Errorf("Expected last slot on Sunday at 11:00, got %v", got)
+	}
+}
+
+func TestGenerateSlotsInLocationTimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := models.WeeklySchedule{{Weekday: time.Monday, Start: 9 * 60, End: 11 * 60}}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, loc) // Monday
+	end := time.Date(2024, 1, 2, 0, 0, 0, 0, loc)
+
+	slots := generateSlots(start, end, loc, schedule, nil, models.DefaultServiceDuration)
+
+	if len(slots) != 2 {
+		t.Fatalf("Expected 2 slots, got %d", len(slots))
+	}
+	// 09:00 in New York is 14:00 UTC in winter
+	if want := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC); !slots[0].Equal(want) {
+		t.Errorf("Expected first slot at %s, got %s", want, slots[0].UTC())
+	}
+}
+
+func TestIsWithinSchedule(t *testing.T) {
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := models.WeeklySchedule{{Weekday: time.Monday, Start: 9*60 + 30, End: 12 * 60}}
+
+	cases := []struct {
+		startsAt time.Time
+		want     bool
+	}{
+		{time.Date(2024, 1, 1, 9, 30, 0, 0, loc), true},
+		{time.Date(2024, 1, 1, 10, 30, 0, 0, loc), true},
+		{time.Date(2024, 1, 1, 10, 0, 0, 0, loc), false},  // not aligned to the range
+		{time.Date(2024, 1, 1, 11, 30, 0, 0, loc), false}, // would end after 12:00
+		{time.Date(2024, 1, 2, 9, 30, 0, 0, loc), false},  // Tuesday
+	}
+
+	for _, c := range cases {
+		if got := isWithinSchedule(c.startsAt.UTC(), loc, schedule, models.DefaultServiceDuration); got != c.want {
+			t.Errorf("isWithinSchedule(%v) = %v, want %v", c.startsAt, got, c.want)
+		}
+	}
+}
+
+func TestIsBookableUserTime(t *testing.T) {
+	settings := &models.BookingSettings{HorizonDays: 30, LeadTimeMinutes: 60}
+	released := &models.BookingSettings{HorizonDays: 30, LeadTimeMinutes: 60, ReleaseEnabled: true, ReleaseWeekday: time.Sunday, ReleaseTime: 18 * 60}
+	now := time.Now()
+	expiresAt := now.AddDate(0, 2, 0)
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		startsAt  time.Time
+		expiresAt time.Time
+		settings  *models.BookingSettings
+		want      bool
+	}{
+		{"inside the window", now.AddDate(0, 0, 2), expiresAt, settings, true},
+		{"within the lead time", now.Add(30 * time.Minute), expiresAt, settings, false},
+		{"beyond the horizon", now.AddDate(0, 0, 31), expiresAt, settings, false},
+		{"after the plan expires", now.AddDate(0, 0, 2), now, settings, false},
+		{"not released yet", now.AddDate(0, 0, 20), expiresAt, released, false},
+	}
+
+	for _, c := range cases {
+		if got := isBookableUserTime(c.startsAt.UTC(), c.expiresAt, c.settings, loc); got != c.want {
+			t.Errorf("%s: isBookableUserTime(%v) = %v, want %v", c.name, c.startsAt, got, c.want)
+		}
+	}
+}
+
+func TestGenerateSlotsSkipsClosures(t *testing.T) {
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Week of Easter 2024: Easter Monday is April 1st
+	start := time.Date(2024, 4, 1, 0, 0, 0, 0, loc)
+	end := time.Date(2024, 4, 8, 0, 0, 0, 0, loc)
+	closures := models.ClosureCalendar(models.ItalianHolidays(2024))
+	closures = append(closures, models.Closure{
+		StartsOn: time.Date(2024, 4, 4, 0, 0, 0, 0, time.UTC),
+		EndsOn:   time.Date(2024, 4, 5, 0, 0, 0, 0, time.UTC),
+	})
+
+	slots := generateSlots(start, end, loc, models.DefaultWeeklySchedule(), closures, models.DefaultServiceDuration)
+
+	// Open on Tuesday, Wednesday and Saturday only
+	if len(slots) != 3*15 {
+		t.Fatalf("Expected %d slots, got %d", 3*15, len(slots))
+	}
+	for _, slot := range slots {
+		switch slot.In(loc).Day() {
+		case 1, 4, 5:
+			t.Fatalf("Found slot on a closed day: %v", slot.In(loc))
+		}
+	}
+}
+
+func TestGenerateSlotsUsesServiceDuration(t *testing.T) {
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := models.WeeklySchedule{{Weekday: time.Monday, Start: 9 * 60, End: 12 * 60}}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, loc)
+	end := time.Date(2024, 1, 2, 0, 0, 0, 0, loc)
+
+	// 45 minute consultations: 09:00, 09:45, 10:30, 11:15
+	slots := generateSlots(start, end, loc, schedule, nil, 45*time.Minute)
+	if len(slots) != 4 {
+		t.Fatalf("Expected 4 slots of 45 minutes, got %d", len(slots))
+	}
+	if got := slots[3].In(loc); got.Hour() != 11 || got.Minute() != 15 {
+		t.Errorf("Expected last slot at 11:15, got %v", got)
+	}
+
+	// 90 minute massages: 09:00, 10:30
+	slots = generateSlots(start, end, loc, schedule, nil, 90*time.Minute)
+	if len(slots) != 2 {
+		t.Fatalf("Expected 2 slots of 90 minutes, got %d", len(slots))
+	}
+
+	for _, slot := range slots {
+		if !isWithinSchedule(slot, loc, schedule, 90*time.Minute) {
+			t.Errorf("Generated slot %v is not within schedule", slot.In(loc))
+		}
+	}
+	if isWithinSchedule(time.Date(2024, 1, 1, 9, 45, 0, 0, loc), loc, schedule, 90*time.Minute) {
+		t.Error("09:45 is not on the 90 minute grid")
+	}
+}
+
+func TestSeriesOccurrencesKeepWallClockAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tuesdays at 18:00 around the end of daylight saving time (27 October 2024)
+	first := time.Date(2024, 10, 15, 18, 0, 0, 0, loc)
+	until := time.Date(2024, 11, 5, 23, 59, 59, 0, loc)
+
+	occurrences := seriesOccurrences(first, until, loc, maxSeriesOccurrences)
+	if len(occurrences) != 4 {
+		t.Fatalf("Expected 4 occurrences, got %d", len(occurrences))
+	}
+
+	for _, occurrence := range occurrences {
+		local := occurrence.In(loc)
+		if local.Weekday() != time.Tuesday || local.Hour() != 18 || local.Minute() != 0 {
+			t.Errorf("Expected Tuesday 18:00 Europe/Rome, got %v", local)
+		}
+	}
+}
+
+func TestSeriesOccurrencesLimit(t *testing.T) {
+	first := time.Date(2024, 1, 2, 17, 0, 0, 0, time.UTC)
+	until := first.AddDate(5, 0, 0)
+
+	if got := len(seriesOccurrences(first, until, time.UTC, 10)); got != 10 {
+		t.Errorf("Expected 10 occurrences, got %d", got)
+	}
+}
+
+func TestBlockChunks(t *testing.T) {
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, loc)
+	chunks := blockChunks(day, models.AvailabilityRange{Start: 9 * 60, End: 11*60 + 30})
+
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(chunks))
+	}
+
+	expected := []struct {
+		hour, minute, duration int
+	}{
+		{9, 0, 60},
+		{10, 0, 60},
+		{11, 0, 30},
+	}
+	for i, want := range expected {
+		local := chunks[i].StartsAt.In(loc)
+		if local.Hour() != want.hour || local.Minute() != want.minute {
+			t.Errorf("Chunk %d: expected %02d:%02d, got %s", i, want.hour, want.minute, local.Format("15:04"))
+		}
+		if chunks[i].DurationMinutes != want.duration {
+			t.Errorf("Chunk %d: expected %d minutes, got %d", i, want.duration, chunks[i].DurationMinutes)
+		}
+	}
+}
@@ -0,0 +1,634 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/middleware"
+	"github.com/alarmfox/wellness-nutrition/app/models"
+	"github.com/alarmfox/wellness-nutrition/app/websocket"
+)
+
+type ClassHandler struct {
+	classRepo      *models.ClassRepository
+	instructorRepo *models.InstructorRepository
+	eventRepo      *models.EventRepository
+	hub            *websocket.Hub
+}
+
+func NewClassHandler(
+	classRepo *models.ClassRepository,
+	instructorRepo *models.InstructorRepository,
+	eventRepo *models.EventRepository,
+	hub *websocket.Hub,
+) *ClassHandler {
+	return &ClassHandler{
+		classRepo:      classRepo,
+		instructorRepo: instructorRepo,
+		eventRepo:      eventRepo,
+		hub:            hub,
+	}
+}
+
+type classTemplateResponse struct {
+	ID              int64  `json:"id"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	Room            string `json:"room"`
+	ResourceID      *int64 `json:"resourceId"`
+	InstructorID    *int64 `json:"instructorId"`
+	Capacity        int    `json:"capacity"`
+	DurationMinutes int    `json:"durationMinutes"`
+}
+
+func newClassTemplateResponse(t *models.ClassTemplate) classTemplateResponse {
+	var instructorID, resourceID *int64
+	if t.InstructorID.Valid {
+		instructorID = &t.InstructorID.Int64
+	}
+	if t.ResourceID.Valid {
+		resourceID = &t.ResourceID.Int64
+	}
+	return classTemplateResponse{
+		ID:              t.ID,
+		Title:           t.Title,
+		Description:     t.Description,
+		Room:            t.Room,
+		ResourceID:      resourceID,
+		InstructorID:    instructorID,
+		Capacity:        t.Capacity,
+		DurationMinutes: t.DurationMinutes,
+	}
+}
+
+type classSessionResponse struct {
+	ID              int64     `json:"id"`
+	TemplateID      int64     `json:"templateId"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	Room            string    `json:"room"`
+	ResourceID      *int64    `json:"resourceId"`
+	InstructorID    int64     `json:"instructorId"`
+	InstructorName  string    `json:"instructorName"`
+	StartsAt        time.Time `json:"startsAt"`
+	EndsAt          time.Time `json:"endsAt"`
+	DurationMinutes int       `json:"durationMinutes"`
+	Capacity        int       `json:"capacity"`
+	Enrolled        int       `json:"enrolled"`
+	// IsEnrolled is only set for members
+	IsEnrolled bool `json:"isEnrolled"`
+}
+
+func newClassSessionResponse(s *models.ClassSessionWithDetails) classSessionResponse {
+	var resourceID *int64
+	if s.ResourceID.Valid {
+		resourceID = &s.ResourceID.Int64
+	}
+	return classSessionResponse{
+		ID:              s.ID,
+		TemplateID:      s.TemplateID,
+		Title:           s.Title,
+		Description:     s.Description,
+		Room:            s.Room,
+		ResourceID:      resourceID,
+		InstructorID:    s.InstructorID,
+		InstructorName:  strings.TrimSpace(s.InstructorFirstName.String + " " + s.InstructorLastName.String),
+		StartsAt:        s.StartsAt,
+		EndsAt:          s.EndsAt(),
+		DurationMinutes: s.DurationMinutes,
+		Capacity:        s.Capacity,
+		Enrolled:        s.Enrolled,
+	}
+}
+
+type ClassTemplateRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Room        string `json:"room"`
+	// ResourceID is the room sessions are held in, zero for none
+	ResourceID int64 `json:"resourceId"`
+	// InstructorID is the default instructor of new sessions, zero for none
+	InstructorID    int64 `json:"instructorId"`
+	Capacity        int   `json:"capacity"`
+	DurationMinutes int   `json:"durationMinutes"`
+}
+
+func (req ClassTemplateRequest) toTemplate(t *models.ClassTemplate) {
+	t.Title = req.Title
+	t.Description = strings.TrimSpace(req.Description)
+	t.Room = strings.TrimSpace(req.Room)
+	t.ResourceID = sql.NullInt64{Int64: req.ResourceID, Valid: req.ResourceID != 0}
+	t.InstructorID = sql.NullInt64{Int64: req.InstructorID, Valid: req.InstructorID != 0}
+	t.Capacity = req.Capacity
+	t.DurationMinutes = req.DurationMinutes
+}
+
+func sendClassError(w http.ResponseWriter, err error) {
+	switch {
+	case err == sql.ErrNoRows:
+		sendJSON(w, http.StatusNotFound, map[string]string{"error": "Class not found"})
+	case errors.Is(err, models.ErrInvalidClass):
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, models.ErrInstructorBusy), errors.Is(err, models.ErrClosed):
+		sendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, models.ErrResourceUnavailable):
+		sendJSON(w, http.StatusConflict, map[string]string{"error": "Resource not available"})
+	default:
+		log.Printf("Error saving class: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+}
+
+func (h *ClassHandler) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.classRepo.GetTemplates()
+	if err != nil {
+		log.Printf("Error getting class templates: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []classTemplateResponse{}
+	for _, t := range templates {
+		result = append(result, newClassTemplateResponse(t))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+func (h *ClassHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req ClassTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	template := &models.ClassTemplate{}
+	req.toTemplate(template)
+	if err := h.classRepo.CreateTemplate(template); err != nil {
+		sendClassError(w, err)
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, newClassTemplateResponse(template))
+}
+
+func (h *ClassHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var req ClassTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	template := &models.ClassTemplate{ID: idInt}
+	req.toTemplate(template)
+	if err := h.classRepo.UpdateTemplate(template); err != nil {
+		sendClassError(w, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newClassTemplateResponse(template))
+}
+
+func (h *ClassHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.classRepo.DeleteTemplate(idInt); err != nil {
+		sendClassError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSessions returns the sessions in the from/to range (last week to next
+// month by default) for the admin calendar, optionally of one instructor or
+// one location.
+func (h *ClassHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	from, err := time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+	if err != nil {
+		from = time.Now().AddDate(0, 0, -7)
+	}
+	to, err := time.Parse(time.RFC3339, r.URL.Query().Get("to"))
+	if err != nil {
+		to = time.Now().AddDate(0, 1, 0)
+	}
+
+	var instructorID int64
+	if value := r.URL.Query().Get("instructorId"); value != "" {
+		instructorID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid instructorId"})
+			return
+		}
+	}
+
+	var locationID int64
+	if value := r.URL.Query().Get("locationId"); value != "" {
+		locationID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid locationId"})
+			return
+		}
+	}
+
+	sessions, err := h.classRepo.GetSessions(from, to, instructorID, locationID)
+	if err != nil {
+		log.Printf("Error getting class sessions: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []classSessionResponse{}
+	for _, s := range sessions {
+		result = append(result, newClassSessionResponse(s))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+type ScheduleClassRequest struct {
+	TemplateID int64 `json:"templateId"`
+	// StartsAt is RFC3339 or a datetime-local value in the time zone of the
+	// instructor's location
+	StartsAt string `json:"startsAt"`
+	// InstructorID, Room, ResourceID and Capacity default to the template's
+	InstructorID int64  `json:"instructorId"`
+	Room         string `json:"room"`
+	ResourceID   int64  `json:"resourceId"`
+	Capacity     int    `json:"capacity"`
+}
+
+// ScheduleSession schedules a session of a class template.
+func (h *ClassHandler) ScheduleSession(w http.ResponseWriter, r *http.Request) {
+	var req ScheduleClassRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	template, err := h.classRepo.GetTemplateByID(req.TemplateID)
+	if err != nil {
+		sendClassError(w, err)
+		return
+	}
+
+	session := &models.ClassSession{
+		TemplateID:      template.ID,
+		InstructorID:    req.InstructorID,
+		Room:            strings.TrimSpace(req.Room),
+		ResourceID:      sql.NullInt64{Int64: req.ResourceID, Valid: req.ResourceID != 0},
+		Capacity:        req.Capacity,
+		DurationMinutes: template.DurationMinutes,
+	}
+	if session.InstructorID == 0 {
+		session.InstructorID = template.InstructorID.Int64
+	}
+	if session.Room == "" {
+		session.Room = template.Room
+	}
+	if !session.ResourceID.Valid {
+		session.ResourceID = template.ResourceID
+	}
+	if session.Capacity == 0 {
+		session.Capacity = template.Capacity
+	}
+
+	instructor, err := h.instructorRepo.GetEnabledByID(session.InstructorID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Instructor not found"})
+			return
+		}
+		log.Printf("Error getting instructor: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	startsAt, err := time.Parse(time.RFC3339, req.StartsAt)
+	if err != nil {
+		startsAt, err = time.ParseInLocation("2006-01-02T15:04", req.StartsAt, instructor.Location())
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid date format"})
+			return
+		}
+	}
+	if !startsAt.After(time.Now()) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Class must start in the future"})
+		return
+	}
+	session.StartsAt = startsAt.UTC()
+
+	if err := h.classRepo.ScheduleSession(session); err != nil {
+		sendClassError(w, err)
+		return
+	}
+
+	created, err := h.classRepo.GetSessionByID(session.ID)
+	if err != nil {
+		log.Printf("Error getting class session: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, newClassSessionResponse(created))
+}
+
+// DeleteSession cancels a session and refunds every participant.
+func (h *ClassHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	session, err := h.classRepo.GetSessionByID(idInt)
+	if err != nil {
+		sendClassError(w, err)
+		return
+	}
+
+	participants, err := h.classRepo.DeleteSession(idInt, actorID(r))
+	if err != nil {
+		sendClassError(w, err)
+		return
+	}
+
+	for _, p := range participants {
+		event := &models.Event{
+			UserID:       p.UserID,
+			StartsAt:     session.StartsAt,
+			Type:         models.EventTypeDeleted,
+			OccurredAt:   time.Now().UTC(),
+			Refunded:     sql.NullBool{Bool: true, Valid: true},
+			PolicyReason: sql.NullString{String: fmt.Sprintf("Classe %s annullata dallo studio: accesso rimborsato", session.Title), Valid: true},
+		}
+		if err := h.eventRepo.Create(event); err != nil {
+			log.Printf("Error creating event: %v", err)
+		}
+	}
+
+	if h.hub != nil {
+		h.hub.BroadcastJSON(
+			websocket.NotificationBookingDeleted,
+			fmt.Sprintf("Classe annullata: %s - %s", session.Title, formatBusinessTime(session.StartsAt, session.Location())),
+			"",
+			formatBusinessTime(session.StartsAt, session.Location()),
+		)
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "Class cancelled successfully",
+		"refunded": len(participants),
+	})
+}
+
+type classParticipantResponse struct {
+	UserID     string    `json:"userId"`
+	FirstName  string    `json:"firstName"`
+	LastName   string    `json:"lastName"`
+	Email      string    `json:"email"`
+	EnrolledAt time.Time `json:"enrolledAt"`
+}
+
+func (h *ClassHandler) GetParticipants(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	participants, err := h.classRepo.GetParticipants(idInt)
+	if err != nil {
+		log.Printf("Error getting class participants: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []classParticipantResponse{}
+	for _, p := range participants {
+		result = append(result, classParticipantResponse{
+			UserID:     p.UserID,
+			FirstName:  p.FirstName,
+			LastName:   p.LastName,
+			Email:      p.Email,
+			EnrolledAt: p.EnrolledAt,
+		})
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// GetClasses returns the upcoming sessions a member can see, up to one month
+// ahead and optionally of one location, and whether they are enrolled in each.
+func (h *BookingHandler) GetClasses(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	var locationID int64
+	if value := r.URL.Query().Get("locationId"); value != "" {
+		var err error
+		locationID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid locationId"})
+			return
+		}
+	}
+
+	from := time.Now().UTC()
+	to := from.AddDate(0, 1, 0)
+
+	sessions, err := h.classRepo.GetSessions(from, to, 0, locationID)
+	if err != nil {
+		log.Printf("Error getting class sessions: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	enrolled, err := h.classRepo.GetEnrolledSessionIDs(user.ID, from, to)
+	if err != nil {
+		log.Printf("Error getting class enrollments: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []classSessionResponse{}
+	for _, s := range sessions {
+		// Sessions already running are only listed to the members enrolled in them
+		if !s.StartsAt.After(from) && !enrolled[s.ID] {
+			continue
+		}
+		session := newClassSessionResponse(s)
+		session.IsEnrolled = enrolled[s.ID]
+		result = append(result, session)
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// EnrollClass enrols the current user in a session, consuming one access like
+// a regular booking.
+func (h *BookingHandler) EnrollClass(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	// Classes have no service: they always use the subscription
+	coveredUntil, ok := h.bookableUntil(w, user, 0)
+	if !ok {
+		return
+	}
+
+	if !h.checkNoShowPenalty(w, user) {
+		return
+	}
+
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	session, err := h.classRepo.GetSessionByID(idInt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Class not found"})
+			return
+		}
+		log.Printf("Error getting class session: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	settings, ok := h.bookingSettings(w)
+	if !ok {
+		return
+	}
+
+	// Classes open like regular slots: same lead time, horizon and release
+	if !isBookableUserTime(session.StartsAt, coveredUntil, settings, session.Location()) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Class not available"})
+		return
+	}
+
+	if err := h.classRepo.Enroll(session.ID, user.ID); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Class not found"})
+		case errors.Is(err, models.ErrAlreadyEnrolled):
+			sendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, models.ErrClassFull), errors.Is(err, models.ErrNoAccesses), errors.Is(err, models.ErrClosed):
+			sendJSON(w, http.StatusConflict, map[string]string{"error": "Class not available"})
+		case sendFrozenError(w, err), sendUserOverlapError(w, err), sendBookingLimitError(w, err):
+		default:
+			log.Printf("Error enrolling in class: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		return
+	}
+
+	event := &models.Event{
+		UserID:     user.ID,
+		StartsAt:   session.StartsAt,
+		Type:       models.EventTypeCreated,
+		OccurredAt: time.Now().UTC(),
+	}
+	if err := h.eventRepo.Create(event); err != nil {
+		log.Printf("Error creating event: %v", err)
+	}
+
+	h.mailer.EnqueueNewBookingNotification(user.FirstName, user.LastName, session.StartsAt, session.TimeZone)
+
+	if h.hub != nil {
+		userName := fmt.Sprintf("%s %s", user.FirstName, user.LastName)
+		h.hub.BroadcastJSON(
+			websocket.NotificationBookingCreated,
+			fmt.Sprintf("Iscrizione a %s: %s - %s", session.Title, userName, formatBusinessTime(session.StartsAt, session.Location())),
+			userName,
+			formatBusinessTime(session.StartsAt, session.Location()),
+		)
+	}
+
+	sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"message":   "Enrolled successfully",
+		"sessionId": session.ID,
+	})
+}
+
+// CancelClassEnrollment removes the current user from a session that has not
+// started yet, refunding the access as their cancellation policy decides.
+func (h *BookingHandler) CancelClassEnrollment(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	session, err := h.classRepo.GetSessionByID(idInt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Class not found"})
+			return
+		}
+		log.Printf("Error getting class session: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+	if !session.StartsAt.After(time.Now()) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Class already started"})
+		return
+	}
+
+	policy, err := h.policyRepo.GetForUser(user.ID, time.Now())
+	if err != nil {
+		log.Printf("Error getting cancellation policy: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	decision, err := h.classRepo.Unenroll(session.ID, user.ID, func(startsAt time.Time, lateRefundsUsed int) models.CancellationDecision {
+		return policy.Decide(startsAt, time.Now(), lateRefundsUsed)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Not enrolled in this class"})
+			return
+		}
+		log.Printf("Error cancelling class enrollment: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	h.mailer.EnqueueDeleteBookingNotification(user.FirstName, user.LastName, session.StartsAt, session.TimeZone)
+
+	if h.hub != nil {
+		userName := fmt.Sprintf("%s %s", user.FirstName, user.LastName)
+		h.hub.BroadcastJSON(
+			websocket.NotificationBookingDeleted,
+			fmt.Sprintf("Iscrizione a %s annullata: %s - %s", session.Title, userName, formatBusinessTime(session.StartsAt, session.Location())),
+			userName,
+			formatBusinessTime(session.StartsAt, session.Location()),
+		)
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "Enrollment cancelled successfully",
+		"refunded": decision.Refund,
+		"reason":   decision.Reason,
+	})
+}
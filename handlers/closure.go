@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+type ClosureHandler struct {
+	closureRepo    *models.ClosureRepository
+	instructorRepo *models.InstructorRepository
+	locationRepo   *models.LocationRepository
+}
+
+func NewClosureHandler(closureRepo *models.ClosureRepository, instructorRepo *models.InstructorRepository, locationRepo *models.LocationRepository) *ClosureHandler {
+	return &ClosureHandler{
+		closureRepo:    closureRepo,
+		instructorRepo: instructorRepo,
+		locationRepo:   locationRepo,
+	}
+}
+
+type closureResponse struct {
+	ID           int64  `json:"id,omitempty"`
+	InstructorID *int64 `json:"instructorId"`
+	LocationID   *int64 `json:"locationId"`
+	StartsOn     string `json:"startsOn"`
+	EndsOn       string `json:"endsOn"`
+	Reason       string `json:"reason"`
+	Holiday      bool   `json:"holiday"`
+}
+
+func newClosureResponse(c *models.Closure) closureResponse {
+	resp := closureResponse{
+		ID:       c.ID,
+		StartsOn: c.StartsOn.Format("2006-01-02"),
+		EndsOn:   c.EndsOn.Format("2006-01-02"),
+		Reason:   c.Reason,
+		Holiday:  c.Holiday,
+	}
+	if c.InstructorID.Valid {
+		id := c.InstructorID.Int64
+		resp.InstructorID = &id
+	}
+	if c.LocationID.Valid {
+		id := c.LocationID.Int64
+		resp.LocationID = &id
+	}
+	return resp
+}
+
+// GetAll returns the closures and the national holidays of a year (default: current year)
+func (h *ClosureHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	year := time.Now().Year()
+	if yearStr := r.URL.Query().Get("year"); yearStr != "" {
+		parsed, err := strconv.Atoi(yearStr)
+		if err != nil || parsed < 2000 || parsed > 2100 {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid year"})
+			return
+		}
+		year = parsed
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	closures, err := h.closureRepo.GetAll(from, to)
+	if err != nil {
+		log.Printf("Error getting closures: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []closureResponse{}
+	for _, c := range closures {
+		result = append(result, newClosureResponse(c))
+	}
+	for _, holiday := range models.ItalianHolidays(year) {
+		result = append(result, newClosureResponse(&holiday))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// CreateClosureRequest closes one instructor, one location or, with neither,
+// every location.
+type CreateClosureRequest struct {
+	InstructorID *int64 `json:"instructorId"`
+	LocationID   *int64 `json:"locationId"`
+	StartsOn     string `json:"startsOn"`
+	EndsOn       string `json:"endsOn"`
+	Reason       string `json:"reason"`
+}
+
+func (h *ClosureHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateClosureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	startsOn, err := time.Parse("2006-01-02", req.StartsOn)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid start date format"})
+		return
+	}
+
+	endsOn := startsOn
+	if req.EndsOn != "" {
+		endsOn, err = time.Parse("2006-01-02", req.EndsOn)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid end date format"})
+			return
+		}
+	}
+
+	if endsOn.Before(startsOn) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "End date must not be before start date"})
+		return
+	}
+
+	if req.InstructorID != nil && req.LocationID != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "A closure applies to an instructor or a location, not both"})
+		return
+	}
+
+	closure := &models.Closure{
+		StartsOn: startsOn,
+		EndsOn:   endsOn,
+		Reason:   req.Reason,
+	}
+
+	if req.InstructorID != nil {
+		if _, err := h.instructorRepo.GetByID(*req.InstructorID); err != nil {
+			if err == sql.ErrNoRows {
+				sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
+				return
+			}
+			log.Printf("Error getting instructor: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return
+		}
+		closure.InstructorID = sql.NullInt64{Int64: *req.InstructorID, Valid: true}
+	}
+
+	if req.LocationID != nil {
+		if _, err := h.locationRepo.GetByID(*req.LocationID); err != nil {
+			if err == sql.ErrNoRows {
+				sendJSON(w, http.StatusNotFound, map[string]string{"error": "Location not found"})
+				return
+			}
+			log.Printf("Error getting location: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return
+		}
+		closure.LocationID = sql.NullInt64{Int64: *req.LocationID, Valid: true}
+	}
+
+	if err := h.closureRepo.Create(closure); err != nil {
+		log.Printf("Error creating closure: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, newClosureResponse(closure))
+}
+
+func (h *ClosureHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.closureRepo.Delete(idInt); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Closure not found"})
+			return
+		}
+		log.Printf("Error deleting closure: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
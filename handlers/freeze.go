@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+type FreezeRequest struct {
+	// StartsOn and EndsOn are days (YYYY-MM-DD) of the business time zone, both inclusive
+	StartsOn string `json:"startsOn"`
+	EndsOn   string `json:"endsOn"`
+	Reason   string `json:"reason"`
+}
+
+type freezeResponse struct {
+	ID             int64     `json:"id"`
+	SubscriptionID int64     `json:"subscriptionId"`
+	StartsOn       string    `json:"startsOn"`
+	EndsOn         string    `json:"endsOn"`
+	Days           int       `json:"days"`
+	Reason         string    `json:"reason"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func newFreezeResponse(f *models.Freeze) freezeResponse {
+	return freezeResponse{
+		ID:             f.ID,
+		SubscriptionID: f.SubscriptionID,
+		StartsOn:       f.StartsOn.Format("2006-01-02"),
+		EndsOn:         f.EndsOn.Format("2006-01-02"),
+		Days:           f.Days(),
+		Reason:         f.Reason,
+		CreatedAt:      f.CreatedAt,
+	}
+}
+
+// sendFrozenError reports a booking refused because the member's
+// subscription is frozen on that day.
+func sendFrozenError(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, models.ErrSubscriptionFrozen) {
+		return false
+	}
+	sendJSON(w, http.StatusConflict, map[string]string{"error": "Subscription frozen on that day", "code": "FROZEN"})
+	return true
+}
+
+// GetFreezes returns every freeze of a member, the latest first.
+func (h *BookingHandler) GetFreezes(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if _, err := h.userRepo.GetByID(userID); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+			return
+		}
+		log.Printf("Error getting user: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	freezes, err := h.subscriptionRepo.GetFreezes(userID)
+	if err != nil {
+		log.Printf("Error getting freezes: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []freezeResponse{}
+	for _, f := range freezes {
+		result = append(result, newFreezeResponse(f))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// FreezeSubscription pauses the subscription of a member over a range of
+// days and pushes its expiry forward by as many days. The member's bookings
+// and class enrollments inside the range are cancelled with a refund, and the
+// member is emailed the new expiry.
+func (h *BookingHandler) FreezeSubscription(w http.ResponseWriter, r *http.Request) {
+	var req FreezeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	startsOn, err := time.Parse("2006-01-02", req.StartsOn)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid start date"})
+		return
+	}
+	endsOn, err := time.Parse("2006-01-02", req.EndsOn)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid end date"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(r.PathValue("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+			return
+		}
+		log.Printf("Error getting user: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	by := actorID(r)
+	result, err := h.subscriptionRepo.Freeze(&models.Freeze{
+		UserID:    user.ID,
+		StartsOn:  startsOn,
+		EndsOn:    endsOn,
+		Reason:    req.Reason,
+		CreatedBy: sql.NullString{String: by, Valid: by != ""},
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidFreeze):
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, models.ErrNoActiveSubscription):
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "No subscription on the first day of the freeze"})
+		case errors.Is(err, models.ErrFreezeOverlap), errors.Is(err, models.ErrFreezeLimit):
+			sendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			log.Printf("Error freezing subscription: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		return
+	}
+
+	freeze := result.Freeze
+	log.Printf("Subscription %d of user %s frozen from %s to %s by %s: %s", freeze.SubscriptionID, user.ID,
+		freeze.StartsOn.Format("2006-01-02"), freeze.EndsOn.Format("2006-01-02"), by, freeze.Reason)
+
+	decision := models.CancellationDecision{Refund: true, Reason: "Abbonamento sospeso: accesso rimborsato"}
+	cancelled := make([]time.Time, 0, len(result.Cancelled)+len(result.Classes))
+	for _, b := range result.Cancelled {
+		cancelled = append(cancelled, b.StartsAt)
+	}
+	cancelled = append(cancelled, result.Classes...)
+	for _, startsAt := range cancelled {
+		event := &models.Event{
+			UserID:     user.ID,
+			StartsAt:   startsAt,
+			Type:       models.EventTypeDeleted,
+			OccurredAt: time.Now().UTC(),
+		}
+		decision.Record(event)
+		if err := h.eventRepo.Create(event); err != nil {
+			log.Printf("Error creating event: %v", err)
+		}
+	}
+
+	baseURL := getBaseURL(r)
+	for _, b := range result.Cancelled {
+		h.promoteWaitlist(&models.Booking{
+			InstructorID:    b.InstructorID,
+			StartsAt:        b.StartsAt,
+			ServiceID:       b.ServiceID,
+			DurationMinutes: b.DurationMinutes,
+		}, baseURL)
+	}
+
+	expiresOn := freeze.EndsOn
+	if updated, err := h.userRepo.GetByID(user.ID); err != nil {
+		log.Printf("Error getting user: %v", err)
+	} else {
+		expiresOn = updated.ExpiresAt
+	}
+	h.mailer.EnqueueFreezeEmail(user.Email, user.FirstName, freeze.StartsOn, freeze.EndsOn, expiresOn, len(cancelled))
+
+	sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"freeze":         newFreezeResponse(freeze),
+		"cancelledCount": len(cancelled),
+		"expiresAt":      expiresOn.Format("2006-01-02"),
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+type bookingHistoryEntry struct {
+	ID           int64                `json:"id"`
+	InstructorID int64                `json:"instructorId"`
+	StartsAt     time.Time            `json:"startsAt"`
+	EndsAt       time.Time            `json:"endsAt"`
+	Type         models.BookingType   `json:"type"`
+	Status       models.BookingStatus `json:"status"`
+	CancelledAt  *time.Time           `json:"cancelledAt,omitempty"`
+	// CancelledBy is the member or admin who cancelled, empty for the system
+	CancelledBy string `json:"cancelledBy,omitempty"`
+	Refunded    *bool  `json:"refunded,omitempty"`
+}
+
+func newBookingHistoryEntry(b *models.Booking) bookingHistoryEntry {
+	entry := bookingHistoryEntry{
+		ID:           b.ID,
+		InstructorID: b.InstructorID,
+		StartsAt:     b.StartsAt,
+		EndsAt:       b.EndsAt(),
+		Type:         b.Type,
+		Status:       b.Status,
+		CancelledBy:  b.CancelledBy.String,
+	}
+	if b.CancelledAt.Valid {
+		entry.CancelledAt = &b.CancelledAt.Time
+	}
+	if b.Refunded.Valid {
+		entry.Refunded = &b.Refunded.Bool
+	}
+	return entry
+}
+
+// GetUserHistory returns every booking of a member with its status, the
+// cancelled ones included, most recent first.
+func (h *BookingHandler) GetUserHistory(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if _, err := h.userRepo.GetByID(userID); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+			return
+		}
+		log.Printf("Error getting user: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	bookings, err := h.bookingRepo.GetHistoryByUserID(userID)
+	if err != nil {
+		log.Printf("Error getting booking history: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	history := []bookingHistoryEntry{}
+	for _, b := range bookings {
+		history = append(history, newBookingHistoryEntry(b))
+	}
+	sendJSON(w, http.StatusOK, history)
+}
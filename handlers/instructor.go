@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -13,19 +14,31 @@ import (
 )
 
 type InstructorHandler struct {
-	instructorRepo *models.InstructorRepository
-	cacheMu        sync.Mutex
-	cacheExpiresAt time.Time
-	enabledCache   []*models.Instructor
+	instructorRepo   *models.InstructorRepository
+	availabilityRepo *models.AvailabilityRepository
+	locationRepo     *models.LocationRepository
+	cacheMu          sync.Mutex
+	cacheExpiresAt   time.Time
+	enabledCache     []*models.Instructor
 }
 
-func NewInstructorHandler(instructorRepo *models.InstructorRepository) *InstructorHandler {
+func NewInstructorHandler(instructorRepo *models.InstructorRepository, availabilityRepo *models.AvailabilityRepository, locationRepo *models.LocationRepository) *InstructorHandler {
 	return &InstructorHandler{
-		instructorRepo: instructorRepo,
+		instructorRepo:   instructorRepo,
+		availabilityRepo: availabilityRepo,
+		locationRepo:     locationRepo,
 	}
 }
 
+// GetAll returns the enabled instructors, only those of a location when
+// locationId is given.
 func (h *InstructorHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	location, err := locationFilter(r, h.locationRepo)
+	if err != nil {
+		sendLocationFilterError(w, err)
+		return
+	}
+
 	instructors, err := h.getEnabledInstructors()
 	if err != nil {
 		log.Printf("Error getting instructors: %v", err)
@@ -33,6 +46,16 @@ func (h *InstructorHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if location != nil {
+		atLocation := []*models.Instructor{}
+		for _, instructor := range instructors {
+			if instructor.LocationID == location.ID {
+				atLocation = append(atLocation, instructor)
+			}
+		}
+		instructors = atLocation
+	}
+
 	sendJSON(w, http.StatusOK, instructors)
 }
 
@@ -68,10 +91,12 @@ func (h *InstructorHandler) invalidateEnabledCache() {
 }
 
 type CreateInstructorRequest struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	MaxSlots  int    `json:"maxSlots"`
-	Enabled   *bool  `json:"enabled"`
+	FirstName    string                `json:"firstName"`
+	LastName     string                `json:"lastName"`
+	MaxSlots     int                   `json:"maxSlots"`
+	Enabled      *bool                 `json:"enabled"`
+	LocationID   int64                 `json:"locationId"`
+	Availability models.WeeklySchedule `json:"availability"`
 }
 
 func (h *InstructorHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -90,19 +115,32 @@ func (h *InstructorHandler) Create(w http.ResponseWriter, r *http.Request) {
 	if req.MaxSlots <= 0 {
 		req.MaxSlots = 2
 	}
+	if req.Availability == nil {
+		req.Availability = models.DefaultWeeklySchedule()
+	}
+	if err := req.Availability.Validate(); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 	enabled := true
 	if req.Enabled != nil {
 		enabled = *req.Enabled
 	}
+	if req.LocationID != 0 {
+		if _, ok := h.getLocation(w, req.LocationID); !ok {
+			return
+		}
+	}
 
 	// Create instructor
 	instructor := &models.Instructor{
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		MaxSlots:  req.MaxSlots,
-		Enabled:   enabled,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		FirstName:  req.FirstName,
+		LastName:   req.LastName,
+		MaxSlots:   req.MaxSlots,
+		Enabled:    enabled,
+		LocationID: req.LocationID,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
 	}
 
 	if err := h.instructorRepo.Create(instructor); err != nil {
@@ -112,14 +150,21 @@ func (h *InstructorHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 	h.invalidateEnabledCache()
 
+	if err := h.availabilityRepo.Replace(instructor.ID, req.Availability); err != nil {
+		log.Printf("Error setting instructor availability: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
 	sendJSON(w, http.StatusCreated, instructor)
 }
 
 type UpdateInstructorRequest struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	MaxSlots  int    `json:"maxSlots"`
-	Enabled   *bool  `json:"enabled"`
+	FirstName  string `json:"firstName"`
+	LastName   string `json:"lastName"`
+	MaxSlots   int    `json:"maxSlots"`
+	Enabled    *bool  `json:"enabled"`
+	LocationID int64  `json:"locationId"`
 }
 
 func (h *InstructorHandler) Update(w http.ResponseWriter, r *http.Request) {
@@ -157,8 +202,21 @@ func (h *InstructorHandler) Update(w http.ResponseWriter, r *http.Request) {
 	if req.Enabled != nil {
 		instructor.Enabled = *req.Enabled
 	}
+	if req.LocationID != 0 && req.LocationID != instructor.LocationID {
+		location, ok := h.getLocation(w, req.LocationID)
+		if !ok {
+			return
+		}
+		instructor.LocationID = location.ID
+		instructor.LocationName = location.Name
+		instructor.TimeZone = location.TimeZone
+	}
 
 	if err := h.instructorRepo.Update(instructor); err != nil {
+		if errors.Is(err, models.ErrInstructorArchived) {
+			sendJSON(w, http.StatusConflict, map[string]string{"error": "Instructor is archived"})
+			return
+		}
 		log.Printf("Error updating instructor: %v", err)
 		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
 		return
@@ -168,6 +226,8 @@ func (h *InstructorHandler) Update(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, http.StatusOK, instructor)
 }
 
+// Delete archives an instructor, keeping the bookings they held. Upcoming
+// member bookings are listed back until they are reassigned or cancelled.
 func (h *InstructorHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
@@ -177,12 +237,113 @@ func (h *InstructorHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.instructorRepo.Delete(idInt); err != nil {
-		log.Printf("Error deleting instructor: %v", err)
-		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	upcoming, err := h.instructorRepo.Archive(idInt, time.Now())
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
+		case errors.Is(err, models.ErrInstructorHasBookings):
+			sendJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":    "Instructor has upcoming bookings to reassign or cancel",
+				"code":     "HAS_BOOKINGS",
+				"bookings": newBlockCollisions(upcoming),
+			})
+		case errors.Is(err, models.ErrInstructorHasClasses):
+			sendJSON(w, http.StatusConflict, map[string]string{
+				"error": "Instructor has upcoming class sessions",
+				"code":  "HAS_CLASSES",
+			})
+		default:
+			log.Printf("Error archiving instructor: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
 		return
 	}
 	h.invalidateEnabledCache()
 
-	sendJSON(w, http.StatusOK, map[string]string{"message": "Instructor deleted successfully"})
+	sendJSON(w, http.StatusOK, map[string]string{"message": "Instructor archived successfully"})
+}
+
+func (h *InstructorHandler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.instructorRepo.GetByID(idInt); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
+			return
+		}
+		log.Printf("Error getting instructor: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	schedule, err := h.availabilityRepo.GetByInstructorID(idInt)
+	if err != nil {
+		log.Printf("Error getting instructor availability: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, schedule)
+}
+
+type UpdateAvailabilityRequest struct {
+	Ranges models.WeeklySchedule `json:"ranges"`
+}
+
+func (h *InstructorHandler) UpdateAvailability(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var req UpdateAvailabilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	if err := req.Ranges.Validate(); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.instructorRepo.GetByID(idInt); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
+			return
+		}
+		log.Printf("Error getting instructor: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	if err := h.availabilityRepo.Replace(idInt, req.Ranges); err != nil {
+		log.Printf("Error updating instructor availability: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, req.Ranges)
+}
+
+// getLocation loads the location an instructor is assigned to, writing the
+// error response when it does not exist.
+func (h *InstructorHandler) getLocation(w http.ResponseWriter, id int64) (*models.Location, bool) {
+	location, err := h.locationRepo.GetByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Location not found"})
+			return nil, false
+		}
+		log.Printf("Error getting location: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return nil, false
+	}
+	return location, true
 }
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+// bookingLimitCodes tell the UI which limit a refused booking hit.
+var bookingLimitCodes = []struct {
+	err  error
+	code string
+}{
+	{models.ErrDailyLimit, "DAILY_LIMIT"},
+	{models.ErrWeeklyLimit, "WEEKLY_LIMIT"},
+	{models.ErrOpenBookingsLimit, "OPEN_BOOKINGS_LIMIT"},
+}
+
+// sendBookingLimitError writes a 409 with the code of the limit err reports,
+// returning false when err is not a booking limit.
+func sendBookingLimitError(w http.ResponseWriter, err error) bool {
+	for _, limit := range bookingLimitCodes {
+		if errors.Is(err, limit.err) {
+			sendJSON(w, http.StatusConflict, map[string]string{"error": limit.err.Error(), "code": limit.code})
+			return true
+		}
+	}
+	return false
+}
+
+type BookingLimitHandler struct {
+	limitRepo *models.BookingLimitRepository
+}
+
+func NewBookingLimitHandler(limitRepo *models.BookingLimitRepository) *BookingLimitHandler {
+	return &BookingLimitHandler{limitRepo: limitRepo}
+}
+
+type bookingLimitResponse struct {
+	SubType    models.SubType `json:"subType"`
+	MaxPerDay  int            `json:"maxPerDay"`
+	MaxPerWeek int            `json:"maxPerWeek"`
+	MaxOpen    int            `json:"maxOpen"`
+}
+
+func newBookingLimitResponse(l *models.BookingLimit) bookingLimitResponse {
+	return bookingLimitResponse{
+		SubType:    l.SubType,
+		MaxPerDay:  l.MaxPerDay,
+		MaxPerWeek: l.MaxPerWeek,
+		MaxOpen:    l.MaxOpen,
+	}
+}
+
+// BookingLimitRequest sets the limits of a subscription type, zero for none.
+type BookingLimitRequest struct {
+	MaxPerDay  int `json:"maxPerDay"`
+	MaxPerWeek int `json:"maxPerWeek"`
+	MaxOpen    int `json:"maxOpen"`
+}
+
+func (h *BookingLimitHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	limits, err := h.limitRepo.GetAll()
+	if err != nil {
+		log.Printf("Error getting booking limits: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []bookingLimitResponse{}
+	for _, l := range limits {
+		result = append(result, newBookingLimitResponse(l))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+func (h *BookingLimitHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req BookingLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	limit := &models.BookingLimit{
+		SubType:    models.SubType(r.PathValue("subType")),
+		MaxPerDay:  req.MaxPerDay,
+		MaxPerWeek: req.MaxPerWeek,
+		MaxOpen:    req.MaxOpen,
+	}
+	if err := h.limitRepo.Save(limit); err != nil {
+		if errors.Is(err, models.ErrInvalidBookingLimit) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error saving booking limit: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newBookingLimitResponse(limit))
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+type LocationHandler struct {
+	locationRepo *models.LocationRepository
+}
+
+func NewLocationHandler(locationRepo *models.LocationRepository) *LocationHandler {
+	return &LocationHandler{locationRepo: locationRepo}
+}
+
+type locationResponse struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	TimeZone string `json:"timeZone"`
+	Enabled  bool   `json:"enabled"`
+}
+
+func newLocationResponse(l *models.Location) locationResponse {
+	return locationResponse{
+		ID:       l.ID,
+		Name:     l.Name,
+		Address:  l.Address,
+		TimeZone: l.TimeZone,
+		Enabled:  l.Enabled,
+	}
+}
+
+type LocationRequest struct {
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	TimeZone string `json:"timeZone"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+func (req LocationRequest) toLocation(location *models.Location) {
+	location.Name = req.Name
+	location.Address = req.Address
+	location.TimeZone = req.TimeZone
+	location.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+// GetAll returns every location to admins.
+func (h *LocationHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	locations, err := h.locationRepo.GetAll()
+	h.sendLocations(w, locations, err)
+}
+
+// GetEnabled returns the locations members can book at.
+func (h *LocationHandler) GetEnabled(w http.ResponseWriter, r *http.Request) {
+	locations, err := h.locationRepo.GetEnabled()
+	h.sendLocations(w, locations, err)
+}
+
+func (h *LocationHandler) sendLocations(w http.ResponseWriter, locations []*models.Location, err error) {
+	if err != nil {
+		log.Printf("Error getting locations: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []locationResponse{}
+	for _, location := range locations {
+		result = append(result, newLocationResponse(location))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+func (h *LocationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req LocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	location := &models.Location{}
+	req.toLocation(location)
+	if err := h.locationRepo.Create(location); err != nil {
+		if errors.Is(err, models.ErrInvalidLocation) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error creating location: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, newLocationResponse(location))
+}
+
+func (h *LocationHandler) Update(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var req LocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	location := &models.Location{ID: idInt}
+	req.toLocation(location)
+	if err := h.locationRepo.Update(location); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Location not found"})
+			return
+		}
+		if errors.Is(err, models.ErrInvalidLocation) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error updating location: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newLocationResponse(location))
+}
+
+func (h *LocationHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.locationRepo.Delete(idInt); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Location not found"})
+			return
+		}
+		if errors.Is(err, models.ErrLocationInUse) {
+			sendJSON(w, http.StatusConflict, map[string]string{"error": "Location has instructors or bookings"})
+			return
+		}
+		log.Printf("Error deleting location: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errInvalidLocationFilter = errors.New("invalid locationId")
+
+// locationFilter reads the optional locationId query parameter and returns
+// the location it selects, or nil when the parameter is missing.
+func locationFilter(r *http.Request, locationRepo *models.LocationRepository) (*models.Location, error) {
+	value := r.URL.Query().Get("locationId")
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errInvalidLocationFilter
+	}
+	return locationRepo.GetByID(id)
+}
+
+func sendLocationFilterError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInvalidLocationFilter) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid locationId"})
+		return
+	}
+	if err == sql.ErrNoRows {
+		sendJSON(w, http.StatusNotFound, map[string]string{"error": "Location not found"})
+		return
+	}
+	log.Printf("Error getting location: %v", err)
+	sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+}
+
+// filterLocationID returns the id of a location filter, zero for no filter.
+func filterLocationID(location *models.Location) int64 {
+	if location == nil {
+		return 0
+	}
+	return location.ID
+}
+
+// filterTimeZone returns the time zone of a location filter, the business
+// time zone for no filter.
+func filterTimeZone(location *models.Location) *time.Location {
+	if location == nil {
+		return models.LoadTimeZone(models.BusinessTimeZone)
+	}
+	return models.LoadTimeZone(location.TimeZone)
+}
@@ -18,6 +18,7 @@ type PageHandler struct {
 	eventRepo      *models.EventRepository
 	instructorRepo *models.InstructorRepository
 	questionRepo   *models.QuestionRepository
+	walletRepo     *models.WalletRepository
 	tpl            *template.Template
 }
 
@@ -27,6 +28,7 @@ func NewPageHandler(
 	eventRepo *models.EventRepository,
 	instructorRepo *models.InstructorRepository,
 	questionRepo *models.QuestionRepository,
+	walletRepo *models.WalletRepository,
 	tpl *template.Template,
 ) *PageHandler {
 	return &PageHandler{
@@ -35,6 +37,7 @@ func NewPageHandler(
 		eventRepo:      eventRepo,
 		instructorRepo: instructorRepo,
 		questionRepo:   questionRepo,
+		walletRepo:     walletRepo,
 		tpl:            tpl,
 	}
 }
@@ -86,13 +89,11 @@ func (h *PageHandler) ServeUserDashboard(w http.ResponseWriter, r *http.Request)
 		StartsAtFormatted string
 		CreatedAt         string
 		InstructorName    string
+		ServiceName       string
+		ServiceID         int64
 	}
 
 	var displayBookings []BookingDisplay
-	loc, err := time.LoadLocation(businessTimeZone)
-	if err != nil {
-		panic(err)
-	}
 	for _, b := range bookings {
 		instructorName := ""
 		if b.InstructorFirstName.Valid {
@@ -105,13 +106,35 @@ func (h *PageHandler) ServeUserDashboard(w http.ResponseWriter, r *http.Request)
 			instructorName += b.InstructorLastName.String
 		}
 
-		startsAt := b.StartsAt.In(loc)
+		startsAt := b.StartsAt.In(models.LoadTimeZone(b.TimeZone))
 		displayBookings = append(displayBookings, BookingDisplay{
 			ID:                b.ID,
 			StartsAt:          b.StartsAt.Format(time.RFC3339),
 			StartsAtFormatted: startsAt.Format("02 Jan 2006, 15:04"),
 			CreatedAt:         b.CreatedAt.Format(time.RFC3339),
 			InstructorName:    instructorName,
+			ServiceName:       b.ServiceName.String,
+			ServiceID:         b.ServiceID.Int64,
+		})
+	}
+
+	// Services with their own balance, shown next to the general one
+	type WalletDisplay struct {
+		ServiceName string
+		Remaining   int
+		ExpiresAt   string
+	}
+
+	wallets, err := h.walletRepo.GetByUserID(user.ID)
+	if err != nil {
+		log.Printf("Error getting wallets: %v", err)
+	}
+	var displayWallets []WalletDisplay
+	for _, wallet := range wallets {
+		displayWallets = append(displayWallets, WalletDisplay{
+			ServiceName: wallet.ServiceName,
+			Remaining:   wallet.Remaining,
+			ExpiresAt:   wallet.ExpiresOn.Format("02 Jan 2006"),
 		})
 	}
 
@@ -119,6 +142,7 @@ func (h *PageHandler) ServeUserDashboard(w http.ResponseWriter, r *http.Request)
 		"User":              user,
 		"ExpiresAt":         user.ExpiresAt.Format("02 Jan 2006"),
 		"RemainingAccesses": user.RemainingAccesses,
+		"Wallets":           displayWallets,
 		"Bookings":          displayBookings,
 	}
 
@@ -285,6 +309,8 @@ func (h *PageHandler) ServeEvents(w http.ResponseWriter, r *http.Request) {
 		Type       string
 		OccurredAt string
 		StartsAt   string
+		Refunded   sql.NullBool
+		Reason     string
 	}
 
 	var displayEvents []EventDisplay
@@ -303,6 +329,8 @@ func (h *PageHandler) ServeEvents(w http.ResponseWriter, r *http.Request) {
 			Type:       string(e.Type),
 			OccurredAt: e.OccurredAt.Format(time.RFC3339),
 			StartsAt:   e.StartsAt.Format(time.RFC3339),
+			Refunded:   e.Refunded,
+			Reason:     e.PolicyReason.String,
 		})
 	}
 
@@ -403,23 +431,29 @@ func (h *PageHandler) ServeInstructors(w http.ResponseWriter, r *http.Request) {
 
 	// Format instructor data for display
 	type InstructorDisplay struct {
-		ID        int64
-		FirstName string
-		LastName  string
-		MaxSlots  int
-		Enabled   bool
-		CreatedAt string
+		ID           int64
+		FirstName    string
+		LastName     string
+		MaxSlots     int
+		Enabled      bool
+		Archived     bool
+		LocationID   int64
+		LocationName string
+		CreatedAt    string
 	}
 
 	var displayInstructors []InstructorDisplay
 	for _, i := range instructors {
 		displayInstructors = append(displayInstructors, InstructorDisplay{
-			ID:        i.ID,
-			FirstName: i.FirstName,
-			LastName:  i.LastName,
-			MaxSlots:  i.MaxSlots,
-			Enabled:   i.Enabled,
-			CreatedAt: i.CreatedAt.Format("02 Jan 2006"),
+			ID:           i.ID,
+			FirstName:    i.FirstName,
+			LastName:     i.LastName,
+			MaxSlots:     i.MaxSlots,
+			Enabled:      i.Enabled,
+			Archived:     i.ArchivedAt != nil,
+			LocationID:   i.LocationID,
+			LocationName: i.LocationName,
+			CreatedAt:    i.CreatedAt.Format("02 Jan 2006"),
 		})
 	}
 
@@ -434,6 +468,118 @@ func (h *PageHandler) ServeInstructors(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *PageHandler) ServeLocations(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil || user.Role != models.RoleAdmin {
+		http.Redirect(w, r, "/signin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.ExecuteTemplate(w, "locations.html", nil); err != nil {
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *PageHandler) ServeClosures(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil || user.Role != models.RoleAdmin {
+		http.Redirect(w, r, "/signin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.ExecuteTemplate(w, "closures.html", nil); err != nil {
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *PageHandler) ServeServices(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil || user.Role != models.RoleAdmin {
+		http.Redirect(w, r, "/signin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.ExecuteTemplate(w, "services.html", nil); err != nil {
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *PageHandler) ServeClasses(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil || user.Role != models.RoleAdmin {
+		http.Redirect(w, r, "/signin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.ExecuteTemplate(w, "classes.html", nil); err != nil {
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *PageHandler) ServePlans(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil || user.Role != models.RoleAdmin {
+		http.Redirect(w, r, "/signin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.ExecuteTemplate(w, "plans.html", nil); err != nil {
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *PageHandler) ServePolicies(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil || user.Role != models.RoleAdmin {
+		http.Redirect(w, r, "/signin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.ExecuteTemplate(w, "policies.html", nil); err != nil {
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *PageHandler) ServeAttendance(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil || user.Role != models.RoleAdmin {
+		http.Redirect(w, r, "/signin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.ExecuteTemplate(w, "attendance.html", nil); err != nil {
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *PageHandler) ServeKiosk(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil || user.Role != models.RoleAdmin {
+		http.Redirect(w, r, "/signin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.ExecuteTemplate(w, "kiosk.html", nil); err != nil {
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
 func (h *PageHandler) ServeUserView(w http.ResponseWriter, r *http.Request) {
 	// Create mock user data for simulation
 	mockUser := &models.User{
@@ -454,6 +600,8 @@ func (h *PageHandler) ServeUserView(w http.ResponseWriter, r *http.Request) {
 		StartsAtFormatted string
 		CreatedAt         string
 		InstructorName    string
+		ServiceName       string
+		ServiceID         int64
 	}
 
 	mockBookings := []BookingDisplay{
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/middleware"
+	"github.com/alarmfox/wellness-nutrition/app/models"
+	"github.com/alarmfox/wellness-nutrition/app/payments"
+)
+
+type PaymentHandler struct {
+	paymentRepo *models.PaymentRepository
+	planRepo    *models.PlanRepository
+	userRepo    *models.UserRepository
+	// provider is nil when online payments are disabled
+	provider payments.Provider
+	currency string
+}
+
+func NewPaymentHandler(paymentRepo *models.PaymentRepository, planRepo *models.PlanRepository, userRepo *models.UserRepository, provider payments.Provider, currency string) *PaymentHandler {
+	return &PaymentHandler{paymentRepo: paymentRepo, planRepo: planRepo, userRepo: userRepo, provider: provider, currency: currency}
+}
+
+type paymentResponse struct {
+	ID             int64                `json:"id"`
+	PlanID         int64                `json:"planId"`
+	PlanName       string               `json:"planName"`
+	AmountCents    int                  `json:"amountCents"`
+	Currency       string               `json:"currency"`
+	Provider       string               `json:"provider"`
+	Status         models.PaymentStatus `json:"status"`
+	SubscriptionID *int64               `json:"subscriptionId"`
+	PaidAt         *time.Time           `json:"paidAt"`
+	CreatedAt      time.Time            `json:"createdAt"`
+}
+
+func newPaymentResponse(p *models.Payment) paymentResponse {
+	resp := paymentResponse{
+		ID:          p.ID,
+		PlanID:      p.PlanID,
+		PlanName:    p.PlanName,
+		AmountCents: p.AmountCents,
+		Currency:    p.Currency,
+		Provider:    p.Provider,
+		Status:      p.Status,
+		CreatedAt:   p.CreatedAt,
+	}
+	if p.SubscriptionID.Valid {
+		resp.SubscriptionID = &p.SubscriptionID.Int64
+	}
+	if p.PaidAt.Valid {
+		resp.PaidAt = &p.PaidAt.Time
+	}
+	return resp
+}
+
+// GetPlans returns the plans a member can buy online, none when payments are
+// disabled.
+func (h *PaymentHandler) GetPlans(w http.ResponseWriter, r *http.Request) {
+	result := []planResponse{}
+	if h.provider == nil {
+		sendJSON(w, http.StatusOK, result)
+		return
+	}
+
+	plans, err := h.planRepo.GetAll()
+	if err != nil {
+		log.Printf("Error getting plans: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+	for _, p := range plans {
+		if p.Enabled && p.PriceCents > 0 {
+			result = append(result, newPlanResponse(p))
+		}
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+type CheckoutRequest struct {
+	PlanID int64 `json:"planId"`
+}
+
+// CreateCheckout opens a payment for a plan and returns the checkout page of
+// the provider the member is sent to.
+func (h *PaymentHandler) CreateCheckout(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		sendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	if h.provider == nil {
+		sendJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "Online payments are not available"})
+		return
+	}
+
+	var req CheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	payment, err := h.paymentRepo.Start(user.ID, req.PlanID, h.currency, h.provider.Name())
+	if err != nil {
+		if errors.Is(err, models.ErrPlanUnavailable) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Plan is not available"})
+			return
+		}
+		log.Printf("Error starting payment: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	baseURL := getBaseURL(r)
+	checkout, err := h.provider.CreateCheckout(r.Context(), payments.CheckoutRequest{
+		Reference:     strconv.FormatInt(payment.ID, 10),
+		Description:   payment.PlanName,
+		AmountCents:   payment.AmountCents,
+		Currency:      payment.Currency,
+		CustomerEmail: user.Email,
+		SuccessURL:    baseURL + "/user?payment=success",
+		CancelURL:     baseURL + "/user?payment=cancelled",
+	})
+	if err != nil {
+		log.Printf("Error creating checkout for payment %d: %v", payment.ID, err)
+		if _, err := h.paymentRepo.MarkFailed(payment.ID, payment.Provider, ""); err != nil {
+			log.Printf("Error failing payment %d: %v", payment.ID, err)
+		}
+		sendJSON(w, http.StatusBadGateway, map[string]string{"error": "Payment service unavailable"})
+		return
+	}
+	if err := h.paymentRepo.SetCheckout(payment.ID, checkout.ID); err != nil {
+		log.Printf("Error saving checkout of payment %d: %v", payment.ID, err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"payment": newPaymentResponse(payment),
+		"url":     checkout.URL,
+	})
+}
+
+// applyEvent records the outcome of a checkout on the payment it was opened
+// for, granting the plan when it was paid.
+func (h *PaymentHandler) applyEvent(event *payments.Event) (*models.Payment, error) {
+	id, err := strconv.ParseInt(event.Reference, 10, 64)
+	if err != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	if event.Type == payments.EventPaid {
+		payment, err := h.paymentRepo.MarkPaid(id, h.provider.Name(), event.CheckoutID, event.AmountCents)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Payment %d paid, subscription %d granted", payment.ID, payment.SubscriptionID.Int64)
+		return payment, nil
+	}
+	return h.paymentRepo.MarkFailed(id, h.provider.Name(), event.CheckoutID)
+}
+
+// Webhook receives the signed notifications of the provider. Events about
+// unknown payments or of no interest are acknowledged so the provider stops
+// retrying them; failures to save are not, so it retries later.
+func (h *PaymentHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	if h.provider == nil {
+		sendJSON(w, http.StatusNotFound, map[string]string{"error": "Online payments are not available"})
+		return
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	event, err := h.provider.ParseWebhook(payload, r.Header)
+	if err != nil {
+		if errors.Is(err, payments.ErrUnhandledEvent) {
+			sendJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+			return
+		}
+		log.Printf("Rejected payment webhook: %v", err)
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid webhook"})
+		return
+	}
+
+	if _, err := h.applyEvent(event); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			log.Printf("Payment webhook %s for unknown payment %q", event.ID, event.Reference)
+			sendJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+		case errors.Is(err, models.ErrPaymentMismatch):
+			log.Printf("Payment webhook %s does not match payment %q", event.ID, event.Reference)
+			sendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			log.Printf("Error applying payment webhook %s: %v", event.ID, err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		return
+	}
+
+	sendJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+type FakeCheckoutRequest struct {
+	Session string `json:"session"`
+	Paid    bool   `json:"paid"`
+}
+
+// CompleteFakeCheckout ends a checkout of the fake provider as the member
+// chose on its page, delivering the webhook the provider would send.
+func (h *PaymentHandler) CompleteFakeCheckout(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		sendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	fake, ok := h.provider.(*payments.FakeProvider)
+	if !ok {
+		sendJSON(w, http.StatusNotFound, map[string]string{"error": "Not found"})
+		return
+	}
+
+	var req FakeCheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	// Members can only settle their own checkouts
+	checkout, err := fake.Request(req.Session)
+	if err != nil {
+		sendJSON(w, http.StatusNotFound, map[string]string{"error": "Checkout not found"})
+		return
+	}
+	id, _ := strconv.ParseInt(checkout.Reference, 10, 64)
+	payment, err := h.paymentRepo.GetByID(id)
+	if err != nil || payment.UserID != user.ID {
+		sendJSON(w, http.StatusNotFound, map[string]string{"error": "Checkout not found"})
+		return
+	}
+
+	payload, header, err := fake.Complete(req.Session, req.Paid)
+	if err != nil {
+		log.Printf("Error completing fake checkout: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+	event, err := fake.ParseWebhook(payload, header)
+	if err != nil {
+		log.Printf("Error parsing fake webhook: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+	payment, err = h.applyEvent(event)
+	if err != nil {
+		log.Printf("Error applying fake webhook: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	url := checkout.CancelURL
+	if payment.Status == models.PaymentPaid {
+		url = checkout.SuccessURL
+	}
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"payment": newPaymentResponse(payment),
+		"url":     url,
+	})
+}
+
+// GetUserPayments returns the online payments of a member, the latest first.
+func (h *PaymentHandler) GetUserPayments(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if _, err := h.userRepo.GetByID(userID); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+			return
+		}
+		log.Printf("Error getting user: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	list, err := h.paymentRepo.GetByUserID(userID)
+	if err != nil {
+		log.Printf("Error getting payments: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []paymentResponse{}
+	for _, p := range list {
+		result = append(result, newPaymentResponse(p))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+type PlanHandler struct {
+	planRepo   *models.PlanRepository
+	policyRepo *models.CancellationPolicyRepository
+}
+
+func NewPlanHandler(planRepo *models.PlanRepository, policyRepo *models.CancellationPolicyRepository) *PlanHandler {
+	return &PlanHandler{planRepo: planRepo, policyRepo: policyRepo}
+}
+
+type planResponse struct {
+	ID           int64          `json:"id"`
+	Name         string         `json:"name"`
+	DurationDays int            `json:"durationDays"`
+	Accesses     int            `json:"accesses"`
+	SubType      models.SubType `json:"subType"`
+	PriceCents   int            `json:"priceCents"`
+	// PolicyID is zero when the policy of the subscription type applies
+	PolicyID      int64 `json:"policyId"`
+	MaxFreezeDays int   `json:"maxFreezeDays"`
+	Enabled       bool  `json:"enabled"`
+}
+
+func newPlanResponse(p *models.Plan) planResponse {
+	return planResponse{
+		ID:            p.ID,
+		Name:          p.Name,
+		DurationDays:  p.DurationDays,
+		Accesses:      p.Accesses,
+		SubType:       p.SubType,
+		PriceCents:    p.PriceCents,
+		PolicyID:      p.PolicyID.Int64,
+		MaxFreezeDays: p.MaxFreezeDays,
+		Enabled:       p.Enabled,
+	}
+}
+
+type PlanRequest struct {
+	Name          string         `json:"name"`
+	DurationDays  int            `json:"durationDays"`
+	Accesses      int            `json:"accesses"`
+	SubType       models.SubType `json:"subType"`
+	PriceCents    int            `json:"priceCents"`
+	PolicyID      int64          `json:"policyId"`
+	MaxFreezeDays int            `json:"maxFreezeDays"`
+	Enabled       *bool          `json:"enabled"`
+}
+
+// toPlan validates the request and fills plan with its values.
+func (h *PlanHandler) toPlan(w http.ResponseWriter, req PlanRequest, plan *models.Plan) bool {
+	if req.PolicyID != 0 {
+		if _, err := h.policyRepo.GetByID(req.PolicyID); err != nil {
+			if err == sql.ErrNoRows {
+				sendJSON(w, http.StatusNotFound, map[string]string{"error": "Policy not found"})
+				return false
+			}
+			log.Printf("Error getting cancellation policy: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return false
+		}
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	plan.Name = req.Name
+	plan.DurationDays = req.DurationDays
+	plan.Accesses = req.Accesses
+	plan.SubType = req.SubType
+	plan.PriceCents = req.PriceCents
+	plan.PolicyID = sql.NullInt64{Int64: req.PolicyID, Valid: req.PolicyID != 0}
+	plan.MaxFreezeDays = req.MaxFreezeDays
+	plan.Enabled = enabled
+	return true
+}
+
+func sendPlanSaveError(w http.ResponseWriter, err error) {
+	switch {
+	case err == sql.ErrNoRows:
+		sendJSON(w, http.StatusNotFound, map[string]string{"error": "Plan not found"})
+	case errors.Is(err, models.ErrInvalidPlan):
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		log.Printf("Error saving plan: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+}
+
+// GetAll returns the plan catalog
+func (h *PlanHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	plans, err := h.planRepo.GetAll()
+	if err != nil {
+		log.Printf("Error getting plans: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []planResponse{}
+	for _, p := range plans {
+		result = append(result, newPlanResponse(p))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+func (h *PlanHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req PlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	plan := &models.Plan{}
+	if !h.toPlan(w, req, plan) {
+		return
+	}
+	if err := h.planRepo.Create(plan); err != nil {
+		sendPlanSaveError(w, err)
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, newPlanResponse(plan))
+}
+
+func (h *PlanHandler) Update(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var req PlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	plan := &models.Plan{ID: idInt}
+	if !h.toPlan(w, req, plan) {
+		return
+	}
+	if err := h.planRepo.Update(plan); err != nil {
+		sendPlanSaveError(w, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newPlanResponse(plan))
+}
+
+func (h *PlanHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.planRepo.Delete(idInt); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Plan not found"})
+		case errors.Is(err, models.ErrPlanInUse):
+			sendJSON(w, http.StatusConflict, map[string]string{"error": "Plan has subscriptions, disable it instead"})
+		default:
+			log.Printf("Error deleting plan: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type subscriptionResponse struct {
+	ID       int64                   `json:"id"`
+	PlanID   int64                   `json:"planId"`
+	PlanName string                  `json:"planName"`
+	SubType  models.SubType          `json:"subType"`
+	Kind     models.SubscriptionKind `json:"kind"`
+	// StartsOn and EndsOn are the first and last valid day
+	StartsOn      string    `json:"startsOn"`
+	EndsOn        string    `json:"endsOn"`
+	Accesses      int       `json:"accesses"`
+	PriceCents    int       `json:"priceCents"`
+	MaxFreezeDays int       `json:"maxFreezeDays"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// GetSubscriptions returns every purchase and renewal of a member, the latest first.
+func (h *UserHandler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if _, err := h.userRepo.GetByID(userID); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+			return
+		}
+		log.Printf("Error getting user: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	subscriptions, err := h.subscriptionRepo.GetByUserID(userID)
+	if err != nil {
+		log.Printf("Error getting subscriptions: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []subscriptionResponse{}
+	for _, s := range subscriptions {
+		result = append(result, subscriptionResponse{
+			ID:            s.ID,
+			PlanID:        s.PlanID.Int64,
+			PlanName:      s.PlanName,
+			SubType:       s.SubType,
+			Kind:          s.Kind,
+			StartsOn:      s.StartsOn.Format("2006-01-02"),
+			EndsOn:        s.EndsOn.Format("2006-01-02"),
+			Accesses:      s.Accesses,
+			PriceCents:    s.PriceCents,
+			MaxFreezeDays: s.MaxFreezeDays,
+			CreatedAt:     s.CreatedAt,
+		})
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
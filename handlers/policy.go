@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/middleware"
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+// maxCancellationHistory is how many past cancellations a user can review
+const maxCancellationHistory = 20
+
+type PolicyHandler struct {
+	policyRepo *models.CancellationPolicyRepository
+}
+
+func NewPolicyHandler(policyRepo *models.CancellationPolicyRepository) *PolicyHandler {
+	return &PolicyHandler{policyRepo: policyRepo}
+}
+
+type policyResponse struct {
+	ID                 int64             `json:"id"`
+	Name               string            `json:"name"`
+	SubType            string            `json:"subType"`
+	CutoffHours        int               `json:"cutoffHours"`
+	LateRefund         models.LateRefund `json:"lateRefund"`
+	FreeLateCancels    int               `json:"freeLateCancels"`
+	UnmarkedAttendance models.Attendance `json:"unmarkedAttendance"`
+	MaxNoShows         int               `json:"maxNoShows"`
+	NoShowWindowDays   int               `json:"noShowWindowDays"`
+}
+
+func newPolicyResponse(p *models.CancellationPolicy) policyResponse {
+	return policyResponse{
+		ID:                 p.ID,
+		Name:               p.Name,
+		SubType:            p.SubType.String,
+		CutoffHours:        p.CutoffHours,
+		LateRefund:         p.LateRefund,
+		FreeLateCancels:    p.FreeLateCancels,
+		UnmarkedAttendance: p.UnmarkedAttendance,
+		MaxNoShows:         p.MaxNoShows,
+		NoShowWindowDays:   p.NoShowWindowDays,
+	}
+}
+
+type PolicyRequest struct {
+	Name string `json:"name"`
+	// SubType is the subscription type the policy applies to, empty for none
+	SubType         string            `json:"subType"`
+	CutoffHours     int               `json:"cutoffHours"`
+	LateRefund      models.LateRefund `json:"lateRefund"`
+	FreeLateCancels int               `json:"freeLateCancels"`
+	// UnmarkedAttendance defaults to ATTENDED and NoShowWindowDays to 30
+	UnmarkedAttendance models.Attendance `json:"unmarkedAttendance"`
+	MaxNoShows         int               `json:"maxNoShows"`
+	NoShowWindowDays   int               `json:"noShowWindowDays"`
+}
+
+func (req PolicyRequest) toPolicy(policy *models.CancellationPolicy) {
+	policy.Name = req.Name
+	policy.SubType = sql.NullString{String: req.SubType, Valid: req.SubType != ""}
+	policy.CutoffHours = req.CutoffHours
+	policy.LateRefund = req.LateRefund
+	policy.FreeLateCancels = req.FreeLateCancels
+
+	defaults := models.DefaultCancellationPolicy()
+	policy.UnmarkedAttendance = req.UnmarkedAttendance
+	if policy.UnmarkedAttendance == "" {
+		policy.UnmarkedAttendance = defaults.UnmarkedAttendance
+	}
+	policy.MaxNoShows = req.MaxNoShows
+	policy.NoShowWindowDays = req.NoShowWindowDays
+	if policy.NoShowWindowDays == 0 {
+		policy.NoShowWindowDays = defaults.NoShowWindowDays
+	}
+}
+
+func sendPolicySaveError(w http.ResponseWriter, err error) {
+	switch {
+	case err == sql.ErrNoRows:
+		sendJSON(w, http.StatusNotFound, map[string]string{"error": "Policy not found"})
+	case errors.Is(err, models.ErrInvalidPolicy):
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, models.ErrPolicyTaken):
+		sendJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		log.Printf("Error saving cancellation policy: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+}
+
+func (h *PolicyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.policyRepo.GetAll()
+	if err != nil {
+		log.Printf("Error getting cancellation policies: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []policyResponse{}
+	for _, p := range policies {
+		result = append(result, newPolicyResponse(p))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+func (h *PolicyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req PolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	policy := &models.CancellationPolicy{}
+	req.toPolicy(policy)
+	if err := h.policyRepo.Create(policy); err != nil {
+		sendPolicySaveError(w, err)
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, newPolicyResponse(policy))
+}
+
+func (h *PolicyHandler) Update(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var req PolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	policy := &models.CancellationPolicy{ID: idInt}
+	req.toPolicy(policy)
+	if err := h.policyRepo.Update(policy); err != nil {
+		sendPolicySaveError(w, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newPolicyResponse(policy))
+}
+
+func (h *PolicyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.policyRepo.Delete(idInt); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Policy not found"})
+			return
+		}
+		log.Printf("Error deleting cancellation policy: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type cancellationResponse struct {
+	StartsAt    time.Time `json:"startsAt"`
+	CancelledAt time.Time `json:"cancelledAt"`
+	Refunded    bool      `json:"refunded"`
+	Late        bool      `json:"late"`
+	Reason      string    `json:"reason"`
+}
+
+// GetCancellations returns the latest cancellations of the current user and
+// why each one was or was not refunded.
+func (h *BookingHandler) GetCancellations(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	events, err := h.eventRepo.GetCancellationsByUserID(user.ID, maxCancellationHistory)
+	if err != nil {
+		log.Printf("Error getting cancellations: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []cancellationResponse{}
+	for _, e := range events {
+		result = append(result, cancellationResponse{
+			StartsAt:    e.StartsAt,
+			CancelledAt: e.OccurredAt,
+			Refunded:    e.Refunded.Bool,
+			Late:        e.LateCancel,
+			Reason:      e.PolicyReason.String,
+		})
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+// Reasons reported for bookings that stay with their instructor
+const (
+	reassignConflictUnavailable = "unavailable"
+	reassignConflictClosed      = "closed"
+	reassignConflictSchedule    = "outside_schedule"
+)
+
+type ReassignRequest struct {
+	FromInstructorID int64 `json:"fromInstructorId"`
+	ToInstructorID   int64 `json:"toInstructorId"`
+	// From and To are days (YYYY-MM-DD) of the business time zone, both inclusive
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Notify emails each member whose booking moved
+	Notify bool `json:"notify"`
+}
+
+type reassignedBooking struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"userId"`
+	FirstName string    `json:"firstName"`
+	LastName  string    `json:"lastName"`
+	StartsAt  time.Time `json:"startsAt"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+func newReassignedBooking(b *models.BookingWithUser, reason string) reassignedBooking {
+	return reassignedBooking{
+		ID:        b.ID,
+		UserID:    b.UserID.String,
+		FirstName: b.UserFirstName.String,
+		LastName:  b.UserLastName.String,
+		StartsAt:  b.StartsAt,
+		Reason:    reason,
+	}
+}
+
+// ReassignInstructor moves the future SIMPLE bookings of an instructor over a
+// range of days to a substitute, at the same times, and offers the places they
+// free to the waitlist of the instructor. Bookings that do not fit the
+// substitute's schedule, closures or capacity are reported and left alone.
+func (h *BookingHandler) ReassignInstructor(w http.ResponseWriter, r *http.Request) {
+	var req ReassignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	loc := models.LoadTimeZone(models.BusinessTimeZone)
+	from, err := time.ParseInLocation("2006-01-02", req.From, loc)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid from date"})
+		return
+	}
+	to := from
+	if req.To != "" {
+		to, err = time.ParseInLocation("2006-01-02", req.To, loc)
+		if err != nil || to.Before(from) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid to date"})
+			return
+		}
+	}
+	if to.After(from.AddDate(0, 0, maxBlockDays-1)) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Range too long"})
+		return
+	}
+
+	// Past bookings keep the instructor who held them
+	start := from
+	if now := time.Now(); start.Before(now) {
+		start = now
+	}
+
+	if _, err := h.instructorRepo.GetByID(req.FromInstructorID); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
+			return
+		}
+		log.Printf("Error getting instructor: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	substitute, err := h.instructorRepo.GetEnabledByID(req.ToInstructorID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Substitute instructor not found"})
+			return
+		}
+		log.Printf("Error getting instructor: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	schedule, err := h.availabilityRepo.GetByInstructorID(substitute.ID)
+	if err != nil {
+		log.Printf("Error getting instructor availability: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result, err := h.bookingRepo.Reassign(models.Reassignment{
+		FromInstructorID: req.FromInstructorID,
+		ToInstructorID:   substitute.ID,
+		From:             start.UTC(),
+		To:               to.AddDate(0, 0, 1).UTC(),
+		Schedule:         schedule,
+		Location:         substitute.Location(),
+		MaxSlots:         substitute.MaxSlots,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrSameInstructor) || errors.Is(err, models.ErrInvalidReassignRange) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error reassigning bookings: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	substituteName := strings.TrimSpace(substitute.FirstName + " " + substitute.LastName)
+	moved := []reassignedBooking{}
+	for _, b := range result.Moved {
+		moved = append(moved, newReassignedBooking(b, ""))
+
+		// The place the booking held with the original instructor is free now
+		h.promoteWaitlist(&models.Booking{InstructorID: req.FromInstructorID, StartsAt: b.StartsAt, DurationMinutes: b.DurationMinutes}, getBaseURL(r))
+
+		if !b.UserID.Valid {
+			continue
+		}
+
+		event := &models.Event{
+			UserID:     b.UserID.String,
+			StartsAt:   b.StartsAt,
+			Type:       models.EventTypeReassigned,
+			OccurredAt: time.Now().UTC(),
+		}
+		if err := h.eventRepo.Create(event); err != nil {
+			log.Printf("Error creating event: %v", err)
+		}
+
+		if req.Notify && b.UserEmail.Valid {
+			h.mailer.EnqueueReassignmentEmail(b.UserEmail.String, b.UserFirstName.String, b.StartsAt, substitute.TimeZone, substituteName)
+		}
+	}
+
+	conflicts := []reassignedBooking{}
+	for _, c := range result.Conflicts {
+		reason := reassignConflictUnavailable
+		switch {
+		case errors.Is(c.Err, models.ErrClosed):
+			reason = reassignConflictClosed
+		case errors.Is(c.Err, models.ErrOutsideSchedule):
+			reason = reassignConflictSchedule
+		}
+		conflicts = append(conflicts, newReassignedBooking(c.Booking, reason))
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"moved":     moved,
+		"conflicts": conflicts,
+	})
+}
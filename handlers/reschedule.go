@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/middleware"
+	"github.com/alarmfox/wellness-nutrition/app/models"
+	"github.com/alarmfox/wellness-nutrition/app/websocket"
+)
+
+type RescheduleBookingRequest struct {
+	StartsAt string `json:"startsAt"`
+	// InstructorID is optional, zero keeps the current instructor
+	InstructorID int64 `json:"instructorId"`
+}
+
+// Reschedule moves one of the current user's bookings to a new time and/or
+// instructor without consuming or refunding an access.
+func (h *BookingHandler) Reschedule(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	h.reschedule(w, r, user, false)
+}
+
+// RescheduleAdmin moves any SIMPLE booking. Admins are not bound to the
+// booking lead time and horizon, only to the instructor schedule.
+func (h *BookingHandler) RescheduleAdmin(w http.ResponseWriter, r *http.Request) {
+	h.reschedule(w, r, nil, true)
+}
+
+func (h *BookingHandler) reschedule(w http.ResponseWriter, r *http.Request, user *models.User, isAdmin bool) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var req RescheduleBookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	startsAt, err := time.Parse(time.RFC3339, req.StartsAt)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid date format"})
+		return
+	}
+	startsAt = startsAt.UTC()
+
+	booking, err := h.bookingRepo.GetByID(idInt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+			return
+		}
+		log.Printf("Error getting booking: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	if !isAdmin && (user == nil || booking.UserID.String != user.ID) {
+		sendJSON(w, http.StatusForbidden, map[string]string{"error": "Forbidden"})
+		return
+	}
+
+	if booking.Type != models.BookingTypeSimple || !booking.StartsAt.After(time.Now()) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Only upcoming bookings can be rescheduled"})
+		return
+	}
+
+	owner := user
+	if owner == nil {
+		owner, err = h.userRepo.GetByID(booking.UserID.String)
+		if err != nil {
+			log.Printf("Error getting user: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return
+		}
+	}
+
+	instructorID := req.InstructorID
+	if instructorID == 0 {
+		instructorID = booking.InstructorID
+	}
+
+	instructor, err := h.instructorRepo.GetEnabledByID(instructorID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
+			return
+		}
+		log.Printf("Error getting instructor: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	schedule, err := h.availabilityRepo.GetByInstructorID(instructor.ID)
+	if err != nil {
+		log.Printf("Error getting instructor availability: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	// The booked service moves along with the booking
+	var service *models.Service
+	if booking.ServiceID.Valid {
+		service, err = h.lookupService(booking.ServiceID.Int64, instructor.ID)
+		if err != nil {
+			sendServiceLookupError(w, err)
+			return
+		}
+	}
+	duration := booking.Duration()
+
+	if isAdmin {
+		if !isWithinSchedule(startsAt, instructor.Location(), schedule, duration) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Slot outside instructor availability"})
+			return
+		}
+	} else {
+		closures, err := h.closureRepo.CalendarFor(instructor.ID, startsAt, startsAt)
+		if err != nil {
+			log.Printf("Error getting closures: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return
+		}
+		settings, ok := h.bookingSettings(w)
+		if !ok {
+			return
+		}
+		// Moving a booking keeps its access, so only the validity matters
+		coveredUntil, _, err := h.coverage(owner, booking.ServiceID.Int64, time.Now())
+		if err != nil && !errors.Is(err, models.ErrNoActiveSubscription) {
+			log.Printf("Error getting subscription: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return
+		}
+		if err != nil || !isBookableUserSlot(startsAt, coveredUntil, settings, instructor.Location(), schedule, closures, duration) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Slot not available"})
+			return
+		}
+	}
+
+	neededSlots := models.BookingWeight(owner.SubType, serviceCapacityWeight(service))
+	moved, err := h.bookingRepo.Reschedule(booking.ID, instructor.ID, startsAt, neededSlots, instructor.MaxSlots)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+			return
+		}
+		if errors.Is(err, models.ErrNotReschedulable) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Only upcoming bookings can be rescheduled"})
+			return
+		}
+		if errors.Is(err, models.ErrSlotUnavailable) || errors.Is(err, models.ErrClosed) {
+			sendJSON(w, http.StatusConflict, map[string]string{"error": "Slot not available"})
+			return
+		}
+		if errors.Is(err, models.ErrResourceUnavailable) {
+			sendJSON(w, http.StatusConflict, map[string]string{"error": "Resource not available"})
+			return
+		}
+		if errors.Is(err, models.ErrBookingChanged) {
+			sendJSON(w, http.StatusConflict, map[string]string{"error": err.Error(), "code": "RETRY"})
+			return
+		}
+		if sendBookingLimitError(w, err) || sendUserOverlapError(w, err) || sendFrozenError(w, err) {
+			return
+		}
+		log.Printf("Error rescheduling booking: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	event := &models.Event{
+		UserID:     owner.ID,
+		StartsAt:   moved.StartsAt,
+		Type:       models.EventTypeRescheduled,
+		OccurredAt: time.Now().UTC(),
+	}
+	if err := h.eventRepo.Create(event); err != nil {
+		log.Printf("Error creating event: %v", err)
+	}
+
+	h.mailer.EnqueueRescheduleNotification(owner.FirstName, owner.LastName, booking.StartsAt, booking.TimeZone, moved.StartsAt, moved.TimeZone)
+
+	if h.hub != nil {
+		userName := fmt.Sprintf("%s %s", owner.FirstName, owner.LastName)
+		h.hub.BroadcastJSON(
+			websocket.NotificationBookingRescheduled,
+			fmt.Sprintf("Prenotazione spostata: %s - %s → %s", userName, formatBusinessTime(booking.StartsAt, booking.Location()), formatBusinessTime(moved.StartsAt, moved.Location())),
+			userName,
+			formatBusinessTime(moved.StartsAt, moved.Location()),
+		)
+	}
+
+	// The old slot is free now
+	h.promoteWaitlist(booking, getBaseURL(r))
+
+	sendJSON(w, http.StatusOK, moved)
+}
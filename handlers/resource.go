@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+type ResourceHandler struct {
+	resourceRepo *models.ResourceRepository
+}
+
+func NewResourceHandler(resourceRepo *models.ResourceRepository) *ResourceHandler {
+	return &ResourceHandler{resourceRepo: resourceRepo}
+}
+
+type resourceResponse struct {
+	ID       int64               `json:"id"`
+	Name     string              `json:"name"`
+	Kind     models.ResourceKind `json:"kind"`
+	Capacity int                 `json:"capacity"`
+	Enabled  bool                `json:"enabled"`
+}
+
+func newResourceResponse(r *models.Resource) resourceResponse {
+	return resourceResponse{
+		ID:       r.ID,
+		Name:     r.Name,
+		Kind:     r.Kind,
+		Capacity: r.Capacity,
+		Enabled:  r.Enabled,
+	}
+}
+
+type ResourceRequest struct {
+	Name     string              `json:"name"`
+	Kind     models.ResourceKind `json:"kind"`
+	Capacity int                 `json:"capacity"`
+	Enabled  *bool               `json:"enabled"`
+}
+
+func (req ResourceRequest) toResource(resource *models.Resource) {
+	resource.Name = req.Name
+	resource.Kind = req.Kind
+	if resource.Kind == "" {
+		resource.Kind = models.ResourceKindRoom
+	}
+	resource.Capacity = req.Capacity
+	resource.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+func (h *ResourceHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	resources, err := h.resourceRepo.GetAll()
+	if err != nil {
+		log.Printf("Error getting resources: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []resourceResponse{}
+	for _, resource := range resources {
+		result = append(result, newResourceResponse(resource))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+func (h *ResourceHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req ResourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	resource := &models.Resource{}
+	req.toResource(resource)
+	if err := h.resourceRepo.Create(resource); err != nil {
+		if errors.Is(err, models.ErrInvalidResource) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error creating resource: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, newResourceResponse(resource))
+}
+
+func (h *ResourceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var req ResourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	resource := &models.Resource{ID: idInt}
+	req.toResource(resource)
+	if err := h.resourceRepo.Update(resource); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Resource not found"})
+			return
+		}
+		if errors.Is(err, models.ErrInvalidResource) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error updating resource: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newResourceResponse(resource))
+}
+
+func (h *ResourceHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.resourceRepo.Delete(idInt); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Resource not found"})
+			return
+		}
+		log.Printf("Error deleting resource: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/middleware"
+	"github.com/alarmfox/wellness-nutrition/app/models"
+	"github.com/alarmfox/wellness-nutrition/app/websocket"
+)
+
+// maxSeriesOccurrences caps a weekly series to roughly one year
+const maxSeriesOccurrences = 53
+
+// Reasons reported for occurrences of a series that could not be booked
+const (
+	seriesConflictUnavailable = "unavailable"
+	seriesConflictClosed      = "closed"
+	seriesConflictNoAccesses  = "no_accesses"
+	seriesConflictLimit       = "limit"
+	seriesConflictOverlap     = "overlap"
+	seriesConflictFrozen      = "frozen"
+	seriesConflictNotReleased = "not_released"
+	seriesConflictError       = "error"
+)
+
+type CreateSeriesRequest struct {
+	StartsAt     string `json:"startsAt"`
+	InstructorID int64  `json:"instructorId"`
+	ServiceID    int64  `json:"serviceId"`
+	// Until is an optional last day (YYYY-MM-DD); the series always stops at
+	// the end of the subscription.
+	Until string `json:"until"`
+}
+
+type seriesConflict struct {
+	StartsAt time.Time `json:"startsAt"`
+	Reason   string    `json:"reason"`
+}
+
+type seriesResponse struct {
+	SeriesID  int64            `json:"seriesId"`
+	Booked    []time.Time      `json:"booked"`
+	Conflicts []seriesConflict `json:"conflicts"`
+}
+
+type seriesListItem struct {
+	ID               int64     `json:"id"`
+	InstructorID     int64     `json:"instructorId"`
+	InstructorName   string    `json:"instructorName"`
+	ServiceName      string    `json:"serviceName"`
+	StartsAt         time.Time `json:"startsAt"`
+	Until            time.Time `json:"until"`
+	UpcomingBookings int       `json:"upcomingBookings"`
+}
+
+// GetSeries returns the weekly series of the current user that still have upcoming bookings
+func (h *BookingHandler) GetSeries(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		sendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	series, err := h.seriesRepo.GetByUserID(user.ID)
+	if err != nil {
+		log.Printf("Error getting booking series: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []seriesListItem{}
+	for _, s := range series {
+		result = append(result, seriesListItem{
+			ID:               s.ID,
+			InstructorID:     s.InstructorID,
+			InstructorName:   fmt.Sprintf("%s %s", s.InstructorFirstName.String, s.InstructorLastName.String),
+			ServiceName:      s.ServiceName.String,
+			StartsAt:         s.StartsAt,
+			Until:            s.Until,
+			UpcomingBookings: s.UpcomingBookings,
+		})
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// CreateSeries books the same slot every week until the balance it uses ends.
+// Every occurrence goes through CreateUserBooking on its own: the response
+// lists the booked dates and the ones that conflicted.
+func (h *BookingHandler) CreateSeries(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	if !h.checkNoShowPenalty(w, user) {
+		return
+	}
+
+	var req CreateSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	startsAt, err := time.Parse(time.RFC3339, req.StartsAt)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid date format"})
+		return
+	}
+	startsAt = startsAt.UTC()
+
+	instructor, err := h.instructorRepo.GetEnabledByID(req.InstructorID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
+			return
+		}
+		log.Printf("Error getting instructor: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	schedule, err := h.availabilityRepo.GetByInstructorID(instructor.ID)
+	if err != nil {
+		log.Printf("Error getting instructor availability: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	service, err := h.lookupService(req.ServiceID, instructor.ID)
+	if err != nil {
+		sendServiceLookupError(w, err)
+		return
+	}
+	duration := serviceDuration(service)
+
+	coveredUntil, ok := h.bookableUntil(w, user, serviceID(service).Int64)
+	if !ok {
+		return
+	}
+
+	loc := instructor.Location()
+	until := subscriptionExpiresAt(coveredUntil)
+	if req.Until != "" {
+		day, err := time.ParseInLocation("2006-01-02", req.Until, loc)
+		if err != nil {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid until date"})
+			return
+		}
+		if lastDay := subscriptionExpiresAt(day); lastDay.Before(until) {
+			until = lastDay
+		}
+	}
+
+	settings, ok := h.bookingSettings(w)
+	if !ok {
+		return
+	}
+
+	// Occurrences follow the same horizon and release schedule as single
+	// bookings: the series ends at the horizon and occurrences not released
+	// yet are reported as conflicts
+	now := time.Now()
+	if horizon := settings.Horizon(now); horizon.Before(until) {
+		until = horizon
+	}
+	until = until.UTC()
+	if !startsAt.After(settings.BookableFrom(now)) || !settings.Released(startsAt, now, loc) || startsAt.After(until) || !isWithinSchedule(startsAt, loc, schedule, duration) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Slot not available"})
+		return
+	}
+
+	occurrences := seriesOccurrences(startsAt, until, loc, maxSeriesOccurrences)
+
+	closures, err := h.closureRepo.CalendarFor(instructor.ID, startsAt, until)
+	if err != nil {
+		log.Printf("Error getting closures: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	series := &models.BookingSeries{
+		UserID:       user.ID,
+		InstructorID: instructor.ID,
+		ServiceID:    serviceID(service),
+		StartsAt:     startsAt,
+		Until:        occurrences[len(occurrences)-1],
+	}
+	if err := h.seriesRepo.Create(series); err != nil {
+		log.Printf("Error creating booking series: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	neededSlots := models.BookingWeight(user.SubType, serviceCapacityWeight(service))
+	result := seriesResponse{SeriesID: series.ID, Booked: []time.Time{}, Conflicts: []seriesConflict{}}
+	outOfAccesses := false
+
+	for _, occurrence := range occurrences {
+		if outOfAccesses {
+			result.Conflicts = append(result.Conflicts, seriesConflict{StartsAt: occurrence, Reason: seriesConflictNoAccesses})
+			continue
+		}
+		if closures.IsClosed(occurrence.In(loc)) || !isWithinSchedule(occurrence, loc, schedule, duration) {
+			result.Conflicts = append(result.Conflicts, seriesConflict{StartsAt: occurrence, Reason: seriesConflictClosed})
+			continue
+		}
+		if !settings.Released(occurrence, now, loc) {
+			result.Conflicts = append(result.Conflicts, seriesConflict{StartsAt: occurrence, Reason: seriesConflictNotReleased})
+			continue
+		}
+
+		booking := models.Booking{
+			InstructorID:    instructor.ID,
+			UserID:          sql.NullString{Valid: true, String: user.ID},
+			StartsAt:        occurrence,
+			Type:            models.BookingTypeSimple,
+			ServiceID:       serviceID(service),
+			DurationMinutes: int(duration / time.Minute),
+			SeriesID:        sql.NullInt64{Int64: series.ID, Valid: true},
+			BookedBy:        user.ID,
+		}
+		if err := h.bookingRepo.CreateUserBooking(&booking, neededSlots, instructor.MaxSlots); err != nil {
+			reason := seriesConflictError
+			switch {
+			case errors.Is(err, models.ErrNoAccesses):
+				reason = seriesConflictNoAccesses
+				outOfAccesses = true
+			case errors.Is(err, models.ErrClosed):
+				reason = seriesConflictClosed
+			case errors.Is(err, models.ErrBookingLimit):
+				reason = seriesConflictLimit
+			case errors.Is(err, models.ErrUserOverlap):
+				reason = seriesConflictOverlap
+			case errors.Is(err, models.ErrSubscriptionFrozen):
+				reason = seriesConflictFrozen
+			case errors.Is(err, models.ErrSlotUnavailable), errors.Is(err, models.ErrResourceUnavailable):
+				reason = seriesConflictUnavailable
+			default:
+				log.Printf("Error creating series booking: %v", err)
+			}
+			result.Conflicts = append(result.Conflicts, seriesConflict{StartsAt: occurrence, Reason: reason})
+			continue
+		}
+
+		result.Booked = append(result.Booked, occurrence)
+
+		event := &models.Event{
+			UserID:     user.ID,
+			StartsAt:   occurrence,
+			Type:       models.EventTypeCreated,
+			OccurredAt: time.Now().UTC(),
+		}
+		if err := h.eventRepo.Create(event); err != nil {
+			log.Printf("Error creating event: %v", err)
+		}
+	}
+
+	if len(result.Booked) == 0 {
+		if err := h.seriesRepo.Delete(series.ID); err != nil {
+			log.Printf("Error deleting empty booking series: %v", err)
+		}
+		sendJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     "Slot not available",
+			"conflicts": result.Conflicts,
+		})
+		return
+	}
+
+	h.mailer.EnqueueSeriesNotification(user.FirstName, user.LastName, result.Booked, instructor.TimeZone, false)
+
+	if h.hub != nil {
+		h.hub.BroadcastJSON(
+			websocket.NotificationBookingCreated,
+			fmt.Sprintf("Nuova prenotazione ricorrente: %s %s - %d date dal %s", user.FirstName, user.LastName, len(result.Booked), formatBusinessTime(result.Booked[0], loc)),
+			fmt.Sprintf("%s %s", user.FirstName, user.LastName),
+			formatBusinessTime(result.Booked[0], loc),
+		)
+	}
+
+	sendJSON(w, http.StatusCreated, result)
+}
+
+// DeleteSeries cancels every upcoming booking of a series owned by the current
+// user. Each booking is refunded according to the user's cancellation policy.
+func (h *BookingHandler) DeleteSeries(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	policy, err := h.policyRepo.GetForUser(user.ID, time.Now())
+	if err != nil {
+		log.Printf("Error getting cancellation policy: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	cancelled, refunded, err := h.seriesRepo.Cancel(idInt, user.ID, policy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Series not found"})
+			return
+		}
+		log.Printf("Error cancelling booking series: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	// A series is booked with a single instructor, so all its dates share a location
+	dates := make([]time.Time, 0, len(cancelled))
+	timeZone := ""
+	for _, booking := range cancelled {
+		timeZone = booking.TimeZone
+		dates = append(dates, booking.StartsAt)
+
+		h.promoteWaitlist(booking.Booking, getBaseURL(r))
+	}
+
+	if len(dates) > 0 {
+		h.mailer.EnqueueSeriesNotification(user.FirstName, user.LastName, dates, timeZone, true)
+
+		if h.hub != nil {
+			h.hub.BroadcastJSON(
+				websocket.NotificationBookingDeleted,
+				fmt.Sprintf("Prenotazione ricorrente cancellata: %s %s - %d date dal %s", user.FirstName, user.LastName, len(dates), formatBusinessTime(dates[0], models.LoadTimeZone(timeZone))),
+				fmt.Sprintf("%s %s", user.FirstName, user.LastName),
+				formatBusinessTime(dates[0], models.LoadTimeZone(timeZone)),
+			)
+		}
+	}
+
+	sendJSON(w, http.StatusOK, map[string]int{
+		"cancelled": len(cancelled),
+		"refunded":  refunded,
+	})
+}
+
+// seriesOccurrences returns the weekly repetitions of first up to until, at the
+// same wall-clock time of loc, capped to limit occurrences.
+func seriesOccurrences(first, until time.Time, loc *time.Location, limit int) []time.Time {
+	firstLocal := first.In(loc)
+	var occurrences []time.Time
+	for week := 0; week < limit; week++ {
+		occurrence := firstLocal.AddDate(0, 0, 7*week)
+		if occurrence.After(until) {
+			break
+		}
+		occurrences = append(occurrences, occurrence.UTC())
+	}
+	return occurrences
+}
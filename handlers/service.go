@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+type ServiceHandler struct {
+	serviceRepo    *models.ServiceRepository
+	instructorRepo *models.InstructorRepository
+	resourceRepo   *models.ResourceRepository
+}
+
+func NewServiceHandler(serviceRepo *models.ServiceRepository, instructorRepo *models.InstructorRepository, resourceRepo *models.ResourceRepository) *ServiceHandler {
+	return &ServiceHandler{
+		serviceRepo:    serviceRepo,
+		instructorRepo: instructorRepo,
+		resourceRepo:   resourceRepo,
+	}
+}
+
+type resourceRequirement struct {
+	ResourceID int64 `json:"resourceId"`
+	Quantity   int   `json:"quantity"`
+}
+
+type serviceResponse struct {
+	ID              int64                 `json:"id"`
+	Name            string                `json:"name"`
+	DurationMinutes int                   `json:"durationMinutes"`
+	CapacityWeight  int                   `json:"capacityWeight"`
+	Enabled         bool                  `json:"enabled"`
+	InstructorIDs   []int64               `json:"instructorIds"`
+	Resources       []resourceRequirement `json:"resources"`
+}
+
+func newServiceResponse(s *models.Service) serviceResponse {
+	instructorIDs := s.InstructorIDs
+	if instructorIDs == nil {
+		instructorIDs = []int64{}
+	}
+	resources := []resourceRequirement{}
+	for _, need := range s.Resources {
+		resources = append(resources, resourceRequirement{ResourceID: need.ResourceID, Quantity: need.Quantity})
+	}
+	return serviceResponse{
+		ID:              s.ID,
+		Name:            s.Name,
+		DurationMinutes: s.DurationMinutes,
+		CapacityWeight:  s.CapacityWeight,
+		Enabled:         s.Enabled,
+		InstructorIDs:   instructorIDs,
+		Resources:       resources,
+	}
+}
+
+// GetAll returns every service for the admin catalog
+func (h *ServiceHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	services, err := h.serviceRepo.GetAll()
+	if err != nil {
+		log.Printf("Error getting services: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []serviceResponse{}
+	for _, s := range services {
+		result = append(result, newServiceResponse(s))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// GetEnabled returns the services users can book
+func (h *ServiceHandler) GetEnabled(w http.ResponseWriter, r *http.Request) {
+	services, err := h.serviceRepo.GetEnabled()
+	if err != nil {
+		log.Printf("Error getting services: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []serviceResponse{}
+	for _, s := range services {
+		result = append(result, newServiceResponse(s))
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+type ServiceRequest struct {
+	Name            string                `json:"name"`
+	DurationMinutes int                   `json:"durationMinutes"`
+	CapacityWeight  int                   `json:"capacityWeight"`
+	Enabled         *bool                 `json:"enabled"`
+	InstructorIDs   []int64               `json:"instructorIds"`
+	Resources       []resourceRequirement `json:"resources"`
+}
+
+// toService validates the request and fills service with its values.
+func (h *ServiceHandler) toService(w http.ResponseWriter, req ServiceRequest, service *models.Service) bool {
+	if req.CapacityWeight <= 0 {
+		req.CapacityWeight = 1
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	for _, instructorID := range req.InstructorIDs {
+		if _, err := h.instructorRepo.GetByID(instructorID); err != nil {
+			if err == sql.ErrNoRows {
+				sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
+				return false
+			}
+			log.Printf("Error getting instructor: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return false
+		}
+	}
+
+	service.Resources = nil
+	for _, need := range req.Resources {
+		if _, err := h.resourceRepo.GetByID(need.ResourceID); err != nil {
+			if err == sql.ErrNoRows {
+				sendJSON(w, http.StatusNotFound, map[string]string{"error": "Resource not found"})
+				return false
+			}
+			log.Printf("Error getting resource: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+			return false
+		}
+		if need.Quantity == 0 {
+			need.Quantity = 1
+		}
+		service.Resources = append(service.Resources, models.ResourceRequirement{ResourceID: need.ResourceID, Quantity: need.Quantity})
+	}
+
+	service.Name = req.Name
+	service.DurationMinutes = req.DurationMinutes
+	service.CapacityWeight = req.CapacityWeight
+	service.Enabled = enabled
+	service.InstructorIDs = req.InstructorIDs
+
+	if err := service.Validate(); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+func (h *ServiceHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req ServiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	service := &models.Service{}
+	if !h.toService(w, req, service) {
+		return
+	}
+
+	if err := h.serviceRepo.Create(service); err != nil {
+		log.Printf("Error creating service: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, newServiceResponse(service))
+}
+
+func (h *ServiceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var req ServiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	service := &models.Service{ID: idInt}
+	if !h.toService(w, req, service) {
+		return
+	}
+
+	if err := h.serviceRepo.Update(service); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Service not found"})
+			return
+		}
+		log.Printf("Error updating service: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newServiceResponse(service))
+}
+
+func (h *ServiceHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.serviceRepo.Delete(idInt); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Service not found"})
+		case errors.Is(err, models.ErrServiceInUse):
+			sendJSON(w, http.StatusConflict, map[string]string{"error": "Members hold accesses for this service, disable it instead"})
+		default:
+			log.Printf("Error deleting service: %v", err)
+			sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+type BookingSettingsHandler struct {
+	settingsRepo *models.BookingSettingsRepository
+}
+
+func NewBookingSettingsHandler(settingsRepo *models.BookingSettingsRepository) *BookingSettingsHandler {
+	return &BookingSettingsHandler{settingsRepo: settingsRepo}
+}
+
+type bookingSettingsResponse struct {
+	HorizonDays     int              `json:"horizonDays"`
+	LeadTimeMinutes int              `json:"leadTimeMinutes"`
+	ReleaseEnabled  bool             `json:"releaseEnabled"`
+	ReleaseWeekday  time.Weekday     `json:"releaseWeekday"`
+	ReleaseTime     models.ClockTime `json:"releaseTime"`
+}
+
+func newBookingSettingsResponse(s *models.BookingSettings) bookingSettingsResponse {
+	return bookingSettingsResponse{
+		HorizonDays:     s.HorizonDays,
+		LeadTimeMinutes: s.LeadTimeMinutes,
+		ReleaseEnabled:  s.ReleaseEnabled,
+		ReleaseWeekday:  s.ReleaseWeekday,
+		ReleaseTime:     s.ReleaseTime,
+	}
+}
+
+// BookingSettingsRequest replaces the booking settings. ReleaseWeekday counts
+// from Sunday (0) and ReleaseTime is "HH:MM" in the business time zone.
+type BookingSettingsRequest struct {
+	HorizonDays     int              `json:"horizonDays"`
+	LeadTimeMinutes int              `json:"leadTimeMinutes"`
+	ReleaseEnabled  bool             `json:"releaseEnabled"`
+	ReleaseWeekday  time.Weekday     `json:"releaseWeekday"`
+	ReleaseTime     models.ClockTime `json:"releaseTime"`
+}
+
+func (h *BookingSettingsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.settingsRepo.Get()
+	if err != nil {
+		log.Printf("Error getting booking settings: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newBookingSettingsResponse(settings))
+}
+
+func (h *BookingSettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req BookingSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	settings := &models.BookingSettings{
+		HorizonDays:     req.HorizonDays,
+		LeadTimeMinutes: req.LeadTimeMinutes,
+		ReleaseEnabled:  req.ReleaseEnabled,
+		ReleaseWeekday:  req.ReleaseWeekday,
+		ReleaseTime:     req.ReleaseTime,
+	}
+	if err := h.settingsRepo.Save(settings); err != nil {
+		if errors.Is(err, models.ErrInvalidBookingSettings) {
+			sendJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Error saving booking settings: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, newBookingSettingsResponse(settings))
+}
+
+// bookingSettings loads the booking settings, writing a 500 and returning
+// false when they cannot be read.
+func (h *BookingHandler) bookingSettings(w http.ResponseWriter) (*models.BookingSettings, bool) {
+	settings, err := h.settingsRepo.Get()
+	if err != nil {
+		log.Printf("Error getting booking settings: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return nil, false
+	}
+	return settings, true
+}
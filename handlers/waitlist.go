@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/middleware"
+	"github.com/alarmfox/wellness-nutrition/app/models"
+	"github.com/alarmfox/wellness-nutrition/app/websocket"
+)
+
+type waitlistEntryResponse struct {
+	ID              int64     `json:"id"`
+	InstructorID    int64     `json:"instructorId"`
+	InstructorName  string    `json:"instructorName"`
+	ServiceID       *int64    `json:"serviceId"`
+	ServiceName     string    `json:"serviceName"`
+	StartsAt        time.Time `json:"startsAt"`
+	DurationMinutes int       `json:"durationMinutes"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// GetWaitlist returns the waitlist entries of the current user
+func (h *BookingHandler) GetWaitlist(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		sendJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	entries, err := h.waitlistRepo.GetByUserID(user.ID)
+	if err != nil {
+		log.Printf("Error getting waitlist: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	result := []waitlistEntryResponse{}
+	for _, e := range entries {
+		var id *int64
+		if e.ServiceID.Valid {
+			id = &e.ServiceID.Int64
+		}
+		result = append(result, waitlistEntryResponse{
+			ID:              e.ID,
+			InstructorID:    e.InstructorID,
+			InstructorName:  fmt.Sprintf("%s %s", e.InstructorFirstName.String, e.InstructorLastName.String),
+			ServiceID:       id,
+			ServiceName:     e.ServiceName.String,
+			StartsAt:        e.StartsAt,
+			DurationMinutes: e.DurationMinutes,
+			CreatedAt:       e.CreatedAt,
+		})
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// JoinWaitlist puts the current user in line for a slot that is full.
+// Slots that still have capacity must be booked directly.
+func (h *BookingHandler) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	if !h.checkNoShowPenalty(w, user) {
+		return
+	}
+
+	var req CreateBookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	startsAt, err := time.Parse(time.RFC3339, req.StartsAt)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid date format"})
+		return
+	}
+	startsAt = startsAt.UTC()
+
+	instructor, err := h.instructorRepo.GetEnabledByID(req.InstructorID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Instructor not found"})
+			return
+		}
+		log.Printf("Error getting instructor: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	schedule, err := h.availabilityRepo.GetByInstructorID(instructor.ID)
+	if err != nil {
+		log.Printf("Error getting instructor availability: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	closures, err := h.closureRepo.CalendarFor(instructor.ID, startsAt, startsAt)
+	if err != nil {
+		log.Printf("Error getting closures: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	service, err := h.lookupService(req.ServiceID, instructor.ID)
+	if err != nil {
+		sendServiceLookupError(w, err)
+		return
+	}
+	duration := serviceDuration(service)
+
+	coveredUntil, ok := h.bookableUntil(w, user, serviceID(service).Int64)
+	if !ok {
+		return
+	}
+
+	settings, ok := h.bookingSettings(w)
+	if !ok {
+		return
+	}
+
+	if !isBookableUserSlot(startsAt, coveredUntil, settings, instructor.Location(), schedule, closures, duration) {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Slot not available"})
+		return
+	}
+
+	bookings, err := h.bookingRepo.GetWithUsersByInstructorAndDateRange(strconv.FormatInt(instructor.ID, 10), startsAt.AddDate(0, 0, -1), startsAt.Add(duration))
+	if err != nil {
+		log.Printf("Error getting bookings: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	occupancies := make([]models.Occupancy, 0, len(bookings))
+	for _, booking := range bookings {
+		occupancies = append(occupancies, booking.Occupancy())
+	}
+
+	// Blocked slots never free up through cancellations, open slots can be booked now
+	usedSlots, blocked := models.PeakLoad(occupancies, startsAt, startsAt.Add(duration))
+	if blocked {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Slot not available"})
+		return
+	}
+	if usedSlots+models.BookingWeight(user.SubType, serviceCapacityWeight(service)) <= instructor.MaxSlots {
+		sendJSON(w, http.StatusConflict, map[string]string{"error": "Slot is not full, book it directly"})
+		return
+	}
+
+	entry := &models.WaitlistEntry{
+		UserID:          user.ID,
+		InstructorID:    instructor.ID,
+		ServiceID:       serviceID(service),
+		DurationMinutes: int(duration / time.Minute),
+		StartsAt:        startsAt,
+	}
+	if err := h.waitlistRepo.Create(entry); err != nil {
+		if errors.Is(err, models.ErrAlreadyWaitlisted) {
+			sendJSON(w, http.StatusConflict, map[string]string{"error": "Already on the waitlist"})
+			return
+		}
+		log.Printf("Error creating waitlist entry: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":       entry.ID,
+		"startsAt": entry.StartsAt,
+	})
+}
+
+// LeaveWaitlist removes one of the current user's waitlist entries
+func (h *BookingHandler) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	idInt, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		sendJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	if err := h.waitlistRepo.DeleteForUser(idInt, user.ID); err != nil {
+		if err == sql.ErrNoRows {
+			sendJSON(w, http.StatusNotFound, map[string]string{"error": "Waitlist entry not found"})
+			return
+		}
+		log.Printf("Error deleting waitlist entry: %v", err)
+		sendJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// promoteWaitlist books waiting users into the capacity released by a
+// cancelled booking. Entries are tried in waitlist order; users that no longer
+// fit or have no accesses left are skipped and keep their place.
+func (h *BookingHandler) promoteWaitlist(freed *models.Booking, baseURL string) {
+	if h.waitlistRepo == nil {
+		return
+	}
+
+	entries, err := h.waitlistRepo.GetCandidates(freed.InstructorID, freed.StartsAt, freed.EndsAt())
+	if err != nil {
+		log.Printf("Error getting waitlist candidates: %v", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	instructor, err := h.instructorRepo.GetByID(freed.InstructorID)
+	if err != nil {
+		log.Printf("Error getting instructor: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		user, err := h.userRepo.GetByID(entry.UserID)
+		if err != nil {
+			log.Printf("Error getting waitlisted user: %v", err)
+			continue
+		}
+		coveredUntil, _, err := h.coverage(user, entry.ServiceID.Int64, time.Now())
+		if err != nil {
+			if !errors.Is(err, models.ErrNoActiveSubscription) {
+				log.Printf("Error getting waitlisted user subscription: %v", err)
+			}
+			continue
+		}
+		if entry.StartsAt.After(subscriptionExpiresAt(coveredUntil)) {
+			continue
+		}
+
+		neededSlots := models.BookingWeight(entry.UserSubType, entry.CapacityWeight)
+		booking, err := h.waitlistRepo.Promote(&entry.WaitlistEntry, neededSlots, instructor.MaxSlots)
+		if err != nil {
+			if !errors.Is(err, models.ErrSlotUnavailable) &&
+				!errors.Is(err, models.ErrResourceUnavailable) &&
+				!errors.Is(err, models.ErrNoAccesses) &&
+				!errors.Is(err, models.ErrBookingLimit) &&
+				!errors.Is(err, models.ErrUserOverlap) &&
+				!errors.Is(err, models.ErrClosed) &&
+				!errors.Is(err, models.ErrSubscriptionFrozen) &&
+				!errors.Is(err, models.ErrWaitlistEntryGone) {
+				log.Printf("Error promoting waitlist entry %d: %v", entry.ID, err)
+			}
+			continue
+		}
+
+		event := &models.Event{
+			UserID:     user.ID,
+			StartsAt:   booking.StartsAt,
+			Type:       models.EventTypeWaitlistPromoted,
+			OccurredAt: time.Now().UTC(),
+		}
+		if err := h.eventRepo.Create(event); err != nil {
+			log.Printf("Error creating event: %v", err)
+		}
+
+		h.mailer.EnqueueWaitlistPromotionEmail(user.Email, user.FirstName, booking.StartsAt, booking.TimeZone, baseURL+"/user")
+		h.mailer.EnqueueNewBookingNotification(user.FirstName, user.LastName, booking.StartsAt, booking.TimeZone)
+
+		if h.hub != nil {
+			userName := fmt.Sprintf("%s %s", user.FirstName, user.LastName)
+			h.hub.BroadcastJSON(
+				websocket.NotificationWaitlistPromoted,
+				fmt.Sprintf("Prenotazione dalla lista d'attesa: %s - %s", userName, formatBusinessTime(booking.StartsAt, booking.Location())),
+				userName,
+				formatBusinessTime(booking.StartsAt, booking.Location()),
+			)
+			h.hub.NotifyUser(
+				user.ID,
+				websocket.NotificationWaitlistPromoted,
+				fmt.Sprintf("Si è liberato un posto: prenotazione confermata per il %s", formatBusinessTime(booking.StartsAt, booking.Location())),
+				userName,
+				formatBusinessTime(booking.StartsAt, booking.Location()),
+			)
+		}
+	}
+}
@@ -10,9 +10,11 @@ type MailerInterface interface {
 	SendEmail(to, subject string, data EmailData) error
 	SendWelcomeEmail(email, firstName, verificationURL string) error
 	SendResetEmail(email, firstName, verificationURL string) error
-	SendNewBookingNotification(firstName, lastName string, startsAt time.Time) error
-	SendDeleteBookingNotification(firstName, lastName string, startsAt time.Time) error
-	SendReminderEmail(email, firstName string, startsAt time.Time) error
+	SendNewBookingNotification(firstName, lastName string, startsAt time.Time, timeZone string) error
+	SendDeleteBookingNotification(firstName, lastName string, startsAt time.Time, timeZone string) error
+	SendReminderEmail(email, firstName string, startsAt time.Time, timeZone string) error
+	SendWaitlistPromotionEmail(email, firstName string, startsAt time.Time, timeZone, dashboardURL string) error
+	SendSlotCancelledEmail(email, firstName string, startsAt time.Time, timeZone, rebookURL string) error
 }
 
 // Ensure Mailer implements MailerInterface
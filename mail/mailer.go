@@ -14,8 +14,6 @@ import (
 	"time"
 )
 
-const businessTimeZone = "Europe/Rome"
-
 var itMonths = map[string]string{
 	"January":   "Gennaio",
 	"February":  "Febbraio",
@@ -392,9 +390,9 @@ func (m *Mailer) SendResetEmail(email, firstName, verificationURL string) error
 	return m.SendEmail(email, "Ripristino password", data)
 }
 
-func (m *Mailer) SendNewBookingNotification(firstName, lastName string, startsAt time.Time) error {
+func (m *Mailer) SendNewBookingNotification(firstName, lastName string, startsAt time.Time, timeZone string) error {
 	notifyEmail := os.Getenv("EMAIL_NOTIFY_ADDRESS")
-	localTime, err := formatUserTime(startsAt, businessTimeZone)
+	localTime, err := formatUserTime(startsAt, timeZone)
 	if err != nil {
 		return err
 	}
@@ -410,9 +408,9 @@ func (m *Mailer) SendNewBookingNotification(firstName, lastName string, startsAt
 	return m.SendEmail(notifyEmail, "Nuova prenotazione", data)
 }
 
-func (m *Mailer) EnqueueNewBookingNotification(firstName, lastName string, startsAt time.Time) {
+func (m *Mailer) EnqueueNewBookingNotification(firstName, lastName string, startsAt time.Time, timeZone string) {
 	notifyEmail := os.Getenv("EMAIL_NOTIFY_ADDRESS")
-	localTime, err := formatUserTime(startsAt, businessTimeZone)
+	localTime, err := formatUserTime(startsAt, timeZone)
 	if err != nil {
 		log.Printf("failed to format new booking notification: %v", err)
 		return
@@ -429,10 +427,10 @@ func (m *Mailer) EnqueueNewBookingNotification(firstName, lastName string, start
 	m.EnqueueEmail(notifyEmail, "Nuova prenotazione", data)
 }
 
-func (m *Mailer) SendDeleteBookingNotification(firstName, lastName string, startsAt time.Time) error {
+func (m *Mailer) SendDeleteBookingNotification(firstName, lastName string, startsAt time.Time, timeZone string) error {
 	notifyEmail := os.Getenv("EMAIL_NOTIFY_ADDRESS")
 
-	localTime, err := formatUserTime(startsAt, businessTimeZone)
+	localTime, err := formatUserTime(startsAt, timeZone)
 	if err != nil {
 		return err
 	}
@@ -448,10 +446,10 @@ func (m *Mailer) SendDeleteBookingNotification(firstName, lastName string, start
 	return m.SendEmail(notifyEmail, "Prenotazione cancellata", data)
 }
 
-func (m *Mailer) EnqueueDeleteBookingNotification(firstName, lastName string, startsAt time.Time) {
+func (m *Mailer) EnqueueDeleteBookingNotification(firstName, lastName string, startsAt time.Time, timeZone string) {
 	notifyEmail := os.Getenv("EMAIL_NOTIFY_ADDRESS")
 
-	localTime, err := formatUserTime(startsAt, businessTimeZone)
+	localTime, err := formatUserTime(startsAt, timeZone)
 	if err != nil {
 		log.Printf("failed to format delete booking notification: %v", err)
 		return
@@ -468,8 +466,8 @@ func (m *Mailer) EnqueueDeleteBookingNotification(firstName, lastName string, st
 	m.EnqueueEmail(notifyEmail, "Prenotazione cancellata", data)
 }
 
-func (m *Mailer) SendReminderEmail(email, firstName string, startsAt time.Time) error {
-	localTime, err := formatUserTime(startsAt, businessTimeZone)
+func (m *Mailer) SendReminderEmail(email, firstName string, startsAt time.Time, timeZone string) error {
+	localTime, err := formatUserTime(startsAt, timeZone)
 	if err != nil {
 		return err
 	}
@@ -486,6 +484,181 @@ func (m *Mailer) SendReminderEmail(email, firstName string, startsAt time.Time)
 	return m.SendEmail(email, "Promemoria prenotazione - Wellness & Nutrition", data)
 }
 
+// EnqueueRescheduleNotification tells the administrator that a booking was
+// moved; each time is shown in the time zone of its location.
+func (m *Mailer) EnqueueRescheduleNotification(firstName, lastName string, from time.Time, fromTimeZone string, to time.Time, toTimeZone string) {
+	notifyEmail := os.Getenv("EMAIL_NOTIFY_ADDRESS")
+
+	fromTime, err := formatUserTime(from, fromTimeZone)
+	if err != nil {
+		log.Printf("failed to format reschedule notification: %v", err)
+		return
+	}
+	toTime, err := formatUserTime(to, toTimeZone)
+	if err != nil {
+		log.Printf("failed to format reschedule notification: %v", err)
+		return
+	}
+
+	data := EmailData{
+		Name: "amministratore",
+		Intro: fmt.Sprintf("La prenotazione di %s %s è stata spostata dal %s al %s",
+			firstName, lastName, fromTime, toTime),
+		Title:     "Prenotazione spostata",
+		Signature: "Saluti,",
+	}
+
+	m.EnqueueEmail(notifyEmail, "Prenotazione spostata", data)
+}
+
+// EnqueueSeriesNotification tells the administrator that a member booked or
+// cancelled a weekly series, listing every affected date.
+func (m *Mailer) EnqueueSeriesNotification(firstName, lastName string, dates []time.Time, timeZone string, cancelled bool) {
+	notifyEmail := os.Getenv("EMAIL_NOTIFY_ADDRESS")
+
+	localTimes := make([]string, 0, len(dates))
+	for _, d := range dates {
+		localTime, err := formatUserTime(d, timeZone)
+		if err != nil {
+			log.Printf("failed to format series notification: %v", err)
+			return
+		}
+		localTimes = append(localTimes, localTime)
+	}
+
+	title, action := "Nuova prenotazione ricorrente", "inserita"
+	if cancelled {
+		title, action = "Prenotazione ricorrente cancellata", "cancellata"
+	}
+
+	data := EmailData{
+		Name: "amministratore",
+		Intro: fmt.Sprintf("Una prenotazione settimanale è stata %s da %s %s per le seguenti date: %s",
+			action, firstName, lastName, strings.Join(localTimes, ", ")),
+		Title:     title,
+		Signature: "Saluti,",
+	}
+
+	m.EnqueueEmail(notifyEmail, title, data)
+}
+
+func waitlistPromotionEmailData(firstName, localTime, dashboardURL string) EmailData {
+	return EmailData{
+		Name:         firstName,
+		Intro:        "Si è liberato un posto per cui eri in lista d'attesa.",
+		Title:        "Prenotazione confermata",
+		Instructions: fmt.Sprintf("La tua prenotazione per %s è stata confermata ed è stato scalato un accesso. Se non puoi partecipare, cancellala dalla tua area personale:", localTime),
+		ButtonText:   "Le mie prenotazioni",
+		ButtonLink:   dashboardURL,
+		Signature:    "Grazie per averci scelto",
+		Outro:        fmt.Sprintf("Hai bisogno di aiuto? Invia un messaggio a %s e saremo felici di aiutarti", os.Getenv("EMAIL_NOTIFY_ADDRESS")),
+	}
+}
+
+func (m *Mailer) SendWaitlistPromotionEmail(email, firstName string, startsAt time.Time, timeZone, dashboardURL string) error {
+	localTime, err := formatUserTime(startsAt, timeZone)
+	if err != nil {
+		return err
+	}
+
+	return m.SendEmail(email, "Prenotazione confermata dalla lista d'attesa", waitlistPromotionEmailData(firstName, localTime, dashboardURL))
+}
+
+func (m *Mailer) EnqueueWaitlistPromotionEmail(email, firstName string, startsAt time.Time, timeZone, dashboardURL string) {
+	localTime, err := formatUserTime(startsAt, timeZone)
+	if err != nil {
+		log.Printf("failed to format waitlist promotion email: %v", err)
+		return
+	}
+
+	m.EnqueueEmail(email, "Prenotazione confermata dalla lista d'attesa", waitlistPromotionEmailData(firstName, localTime, dashboardURL))
+}
+
+func slotCancelledEmailData(firstName, localTime, rebookURL string) EmailData {
+	return EmailData{
+		Name:         firstName,
+		Intro:        fmt.Sprintf("La tua prenotazione per %s è stata cancellata perché lo slot non è più disponibile.", localTime),
+		Title:        "Prenotazione cancellata",
+		Instructions: "Ti abbiamo restituito l'accesso. Puoi scegliere un nuovo orario dalla tua area personale:",
+		ButtonText:   "Prenota di nuovo",
+		ButtonLink:   rebookURL,
+		Signature:    "Ci scusiamo per il disagio",
+		Outro:        fmt.Sprintf("Hai bisogno di aiuto? Invia un messaggio a %s e saremo felici di aiutarti", os.Getenv("EMAIL_NOTIFY_ADDRESS")),
+	}
+}
+
+func (m *Mailer) SendSlotCancelledEmail(email, firstName string, startsAt time.Time, timeZone, rebookURL string) error {
+	localTime, err := formatUserTime(startsAt, timeZone)
+	if err != nil {
+		return err
+	}
+
+	return m.SendEmail(email, "Prenotazione cancellata", slotCancelledEmailData(firstName, localTime, rebookURL))
+}
+
+// EnqueueSlotCancelledEmail tells a member that the admin blocked the slot of
+// their booking, the access was refunded and they can book again.
+func (m *Mailer) EnqueueSlotCancelledEmail(email, firstName string, startsAt time.Time, timeZone, rebookURL string) {
+	localTime, err := formatUserTime(startsAt, timeZone)
+	if err != nil {
+		log.Printf("failed to format slot cancelled email: %v", err)
+		return
+	}
+
+	m.EnqueueEmail(email, "Prenotazione cancellata", slotCancelledEmailData(firstName, localTime, rebookURL))
+}
+
+// EnqueueReassignmentEmail tells a member that their booking is now held by
+// another instructor at the same time.
+func (m *Mailer) EnqueueReassignmentEmail(email, firstName string, startsAt time.Time, timeZone, instructorName string) {
+	localTime, err := formatUserTime(startsAt, timeZone)
+	if err != nil {
+		log.Printf("failed to format reassignment email: %v", err)
+		return
+	}
+
+	data := EmailData{
+		Name:      firstName,
+		Intro:     fmt.Sprintf("La tua prenotazione per %s si terrà con %s.", localTime, instructorName),
+		Title:     "Cambio istruttore",
+		Outro:     fmt.Sprintf("Hai bisogno di aiuto? Invia un messaggio a %s e saremo felici di aiutarti", os.Getenv("EMAIL_NOTIFY_ADDRESS")),
+		Signature: "Grazie per averci scelto",
+	}
+
+	m.EnqueueEmail(email, "Cambio istruttore", data)
+}
+
+// EnqueueFreezeEmail tells a member that their subscription is frozen from
+// startsOn to endsOn, how many of their bookings were cancelled and when the
+// subscription now expires. The dates are civil dates.
+func (m *Mailer) EnqueueFreezeEmail(email, firstName string, startsOn, endsOn, expiresOn time.Time, cancelled int) {
+	intro := fmt.Sprintf("Il tuo abbonamento è sospeso dal %s al %s e ora scade il %s.",
+		formatUserDate(startsOn), formatUserDate(endsOn), formatUserDate(expiresOn))
+	switch {
+	case cancelled == 1:
+		intro += " Abbiamo cancellato la prenotazione che avevi in quei giorni e ti abbiamo restituito l'accesso."
+	case cancelled > 1:
+		intro += fmt.Sprintf(" Abbiamo cancellato le %d prenotazioni che avevi in quei giorni e ti abbiamo restituito gli accessi.", cancelled)
+	}
+
+	data := EmailData{
+		Name:      firstName,
+		Intro:     intro,
+		Title:     "Abbonamento sospeso",
+		Outro:     fmt.Sprintf("Hai bisogno di aiuto? Invia un messaggio a %s e saremo felici di aiutarti", os.Getenv("EMAIL_NOTIFY_ADDRESS")),
+		Signature: "Grazie per averci scelto",
+	}
+
+	m.EnqueueEmail(email, "Abbonamento sospeso", data)
+}
+
+// formatUserDate formats a civil date in Italian.
+func formatUserDate(d time.Time) string {
+	english := d.Format("02 January 2006")
+	return strings.ReplaceAll(english, d.Month().String(), itMonths[d.Month().String()])
+}
+
+// formatUserTime formats t in Italian in the time zone of a location.
 func formatUserTime(t time.Time, tz string) (string, error) {
 	loc, err := time.LoadLocation(tz)
 	if err != nil {
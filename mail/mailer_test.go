@@ -66,7 +66,7 @@ func TestMockMailer(t *testing.T) {
 		mailer.Reset()
 
 		startsAt := time.Now().Add(24 * time.Hour)
-		err := mailer.SendNewBookingNotification("John", "Doe", startsAt)
+		err := mailer.SendNewBookingNotification("John", "Doe", startsAt, "Europe/Rome")
 		if err != nil {
 			t.Fatalf("Failed to send booking notification: %v", err)
 		}
@@ -85,7 +85,7 @@ func TestMockMailer(t *testing.T) {
 		mailer.Reset()
 
 		startsAt := time.Now().Add(24 * time.Hour)
-		err := mailer.SendDeleteBookingNotification("John", "Doe", startsAt)
+		err := mailer.SendDeleteBookingNotification("John", "Doe", startsAt, "Europe/Rome")
 		if err != nil {
 			t.Fatalf("Failed to send delete notification: %v", err)
 		}
@@ -104,7 +104,7 @@ func TestMockMailer(t *testing.T) {
 		mailer.Reset()
 
 		startsAt := time.Now().Add(24 * time.Hour)
-		err := mailer.SendReminderEmail("user@example.com", "John", startsAt)
+		err := mailer.SendReminderEmail("user@example.com", "John", startsAt, "Europe/Rome")
 		if err != nil {
 			t.Fatalf("Failed to send reminder email: %v", err)
 		}
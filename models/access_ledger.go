@@ -0,0 +1,187 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type AccessReason string
+
+const (
+	// AccessOpening is the balance a member had when the ledger started or
+	// when they were created
+	AccessOpening AccessReason = "OPENING"
+	// AccessPlan adds the accesses of a purchased or renewed plan
+	AccessPlan AccessReason = "PLAN"
+	// AccessExpiry drops the accesses left when a lapsed member starts a new plan
+	AccessExpiry      AccessReason = "EXPIRY"
+	AccessBooking     AccessReason = "BOOKING"
+	AccessRefund      AccessReason = "REFUND"
+	AccessClass       AccessReason = "CLASS"
+	AccessClassRefund AccessReason = "CLASS_REFUND"
+	// AccessAdjustment is a manual correction
+	AccessAdjustment AccessReason = "ADJUSTMENT"
+	// AccessTopUp adds accesses to the wallet of a service
+	AccessTopUp AccessReason = "TOP_UP"
+)
+
+// AccessEntry is one change to the remaining accesses of a member. ServiceID
+// is the wallet it applies to, NULL for the general balance, and ActorID is
+// NULL when the system made the change.
+type AccessEntry struct {
+	ID          int64
+	UserID      string
+	Delta       int
+	Reason      AccessReason
+	ServiceID   sql.NullInt64
+	ServiceName string
+	BookingID   sql.NullInt64
+	ActorID     sql.NullString
+	// ActorName is the full name of the actor, empty without one
+	ActorName string
+	CreatedAt time.Time
+}
+
+// AccessChange describes a change to apply with changeAccessesTx. ServiceID
+// is zero for the general balance; BookingID and ActorID are left empty when
+// they do not apply.
+type AccessChange struct {
+	UserID    string
+	Delta     int
+	Reason    AccessReason
+	ServiceID int64
+	BookingID int64
+	ActorID   string
+}
+
+// changeAccessesTx applies a change to the general balance or to a wallet of
+// a member and appends it to the ledger. A negative change that would leave
+// the balance below zero, or a change to a wallet the member does not have,
+// returns ErrNoAccesses. A zero change does nothing.
+func changeAccessesTx(tx *sql.Tx, c AccessChange) error {
+	if c.Delta == 0 {
+		return nil
+	}
+
+	query := `
+		UPDATE users SET remaining_accesses = remaining_accesses + $2
+		WHERE id = $1 AND ($2 > 0 OR remaining_accesses + $2 >= 0)
+	`
+	args := []any{c.UserID, c.Delta}
+	if c.ServiceID != 0 {
+		query = `
+			UPDATE access_wallets SET remaining = remaining + $2, updated_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND service_id = $3 AND ($2 > 0 OR remaining + $2 >= 0)
+		`
+		args = append(args, c.ServiceID)
+	}
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoAccesses
+	}
+
+	return insertAccessEntry(tx, c)
+}
+
+// insertAccessEntry appends a change already applied to the cached balance.
+func insertAccessEntry(tx *sql.Tx, c AccessChange) error {
+	_, err := tx.Exec(`
+		INSERT INTO access_ledger (user_id, delta, reason, service_id, booking_id, actor_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, c.UserID, c.Delta, c.Reason,
+		sql.NullInt64{Int64: c.ServiceID, Valid: c.ServiceID != 0},
+		sql.NullInt64{Int64: c.BookingID, Valid: c.BookingID != 0},
+		sql.NullString{String: c.ActorID, Valid: c.ActorID != ""})
+	return err
+}
+
+// AccessMismatch is a balance of a member that differs from the sum of its
+// ledger. ServiceID is the wallet, zero for the general balance.
+type AccessMismatch struct {
+	UserID    string
+	Email     string
+	ServiceID int64
+	Cached    int
+	LedgerSum int
+}
+
+type AccessLedgerRepository struct {
+	db *sql.DB
+}
+
+func NewAccessLedgerRepository(db *sql.DB) *AccessLedgerRepository {
+	return &AccessLedgerRepository{db: db}
+}
+
+// GetByUserID returns the ledger of a member, the latest change first.
+func (r *AccessLedgerRepository) GetByUserID(userID string) ([]*AccessEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT l.id, l.user_id, l.delta, l.reason, l.service_id, COALESCE(s.name, ''), l.booking_id, l.actor_id,
+			COALESCE(TRIM(a.first_name || ' ' || COALESCE(a.last_name, '')), ''), l.created_at
+		FROM access_ledger l
+		LEFT JOIN services s ON s.id = l.service_id
+		LEFT JOIN users a ON a.id = l.actor_id
+		WHERE l.user_id = $1
+		ORDER BY l.created_at DESC, l.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*AccessEntry
+	for rows.Next() {
+		var e AccessEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Delta, &e.Reason, &e.ServiceID, &e.ServiceName, &e.BookingID, &e.ActorID, &e.ActorName, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Reconcile returns the general balances and wallets whose cached value does
+// not match the sum of their ledger.
+func (r *AccessLedgerRepository) Reconcile() ([]AccessMismatch, error) {
+	rows, err := r.db.Query(`
+		WITH balances AS (
+			SELECT id AS user_id, 0 AS service_id, remaining_accesses AS cached FROM users
+			UNION ALL
+			SELECT user_id, service_id, remaining FROM access_wallets
+		), sums AS (
+			SELECT user_id, COALESCE(service_id, 0) AS service_id, SUM(delta) AS total
+			FROM access_ledger
+			GROUP BY user_id, COALESCE(service_id, 0)
+		)
+		SELECT b.user_id, u.email, b.service_id, b.cached, COALESCE(s.total, 0)
+		FROM balances b
+		JOIN users u ON u.id = b.user_id
+		LEFT JOIN sums s ON s.user_id = b.user_id AND s.service_id = b.service_id
+		WHERE b.cached <> COALESCE(s.total, 0)
+		ORDER BY u.email, b.service_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mismatches []AccessMismatch
+	for rows.Next() {
+		var m AccessMismatch
+		if err := rows.Scan(&m.UserID, &m.Email, &m.ServiceID, &m.Cached, &m.LedgerSum); err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, m)
+	}
+
+	return mismatches, rows.Err()
+}
@@ -0,0 +1,270 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Attendance records whether a member showed up to a SIMPLE booking.
+// LATE_CANCEL is only found on bookings marked before late cancellations were
+// recorded by cancelling the booking.
+type Attendance string
+
+const (
+	AttendanceAttended   Attendance = "ATTENDED"
+	AttendanceNoShow     Attendance = "NO_SHOW"
+	AttendanceLateCancel Attendance = "LATE_CANCEL"
+)
+
+var ErrInvalidAttendance = errors.New("attendance must be ATTENDED or NO_SHOW")
+
+func (a Attendance) Valid() bool {
+	switch a {
+	case AttendanceAttended, AttendanceNoShow, AttendanceLateCancel:
+		return true
+	}
+	return false
+}
+
+// Markable reports whether a booking can be marked with a. Late cancellations
+// go through BookingRepository.CancelByPolicy, which frees the booking's
+// place and records it as late.
+func (a Attendance) Markable() bool {
+	return a == AttendanceAttended || a == AttendanceNoShow
+}
+
+// RosterEntry is a SIMPLE booking as listed on the admin attendance roster.
+type RosterEntry struct {
+	BookingID           int64
+	UserID              string
+	FirstName           string
+	LastName            string
+	InstructorID        int64
+	InstructorFirstName sql.NullString
+	InstructorLastName  sql.NullString
+	ServiceName         sql.NullString
+	StartsAt            time.Time
+	DurationMinutes     int
+	Attendance          sql.NullString
+	AttendanceMarkedAt  sql.NullTime
+}
+
+// AttendanceStats counts the attendance outcomes of a member. LateCancels
+// includes both bookings marked LATE_CANCEL and late cancellation events.
+type AttendanceStats struct {
+	UserID      string
+	FirstName   string
+	LastName    string
+	Attended    int
+	NoShows     int
+	LateCancels int
+}
+
+type AttendanceRepository struct {
+	db *sql.DB
+}
+
+func NewAttendanceRepository(db *sql.DB) *AttendanceRepository {
+	return &AttendanceRepository{db: db}
+}
+
+// GetRoster returns the SIMPLE bookings starting in [from, to) ordered by time,
+// at one location when locationID is not zero.
+func (r *AttendanceRepository) GetRoster(from, to time.Time, locationID int64) ([]*RosterEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT b.id, b.user_id, u.first_name, u.last_name, b.instructor_id,
+			   i.first_name, `+instructorLastName+`, s.name, b.starts_at, b.duration_minutes,
+			   b.attendance, b.attendance_marked_at
+		FROM bookings b
+		JOIN users u ON u.id = b.user_id
+		LEFT JOIN instructors i ON i.id = b.instructor_id
+		LEFT JOIN services s ON s.id = b.service_id
+		WHERE b.type = 'SIMPLE' AND b.cancelled_at IS NULL AND b.starts_at >= $1 AND b.starts_at < $2
+			AND ($3 = 0 OR b.location_id = $3)
+		ORDER BY b.starts_at ASC, u.last_name ASC, u.first_name ASC
+	`, from, to, locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*RosterEntry
+	for rows.Next() {
+		var entry RosterEntry
+		err := rows.Scan(
+			&entry.BookingID,
+			&entry.UserID,
+			&entry.FirstName,
+			&entry.LastName,
+			&entry.InstructorID,
+			&entry.InstructorFirstName,
+			&entry.InstructorLastName,
+			&entry.ServiceName,
+			&entry.StartsAt,
+			&entry.DurationMinutes,
+			&entry.Attendance,
+			&entry.AttendanceMarkedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// Mark sets the attendance of an active SIMPLE booking, an empty attendance
+// clears it. The booking status follows: attended, no_show or confirmed.
+func (r *AttendanceRepository) Mark(bookingID int64, attendance Attendance) error {
+	if attendance != "" && !attendance.Markable() {
+		return ErrInvalidAttendance
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE bookings
+		SET attendance = NULLIF($2, ''), attendance_marked_at = CASE WHEN $2 = '' THEN NULL ELSE CURRENT_TIMESTAMP END,
+			status = CASE $2 WHEN 'ATTENDED' THEN 'attended' WHEN 'NO_SHOW' THEN 'no_show' ELSE 'confirmed' END
+		WHERE id = $1 AND type = 'SIMPLE' AND cancelled_at IS NULL
+	`, bookingID, string(attendance))
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CheckIn marks a SIMPLE booking attended and reports whether it was not
+// attended already, so repeated scans are harmless.
+func (r *AttendanceRepository) CheckIn(bookingID int64) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE bookings
+		SET attendance = 'ATTENDED', attendance_marked_at = CURRENT_TIMESTAMP, status = 'attended'
+		WHERE id = $1 AND type = 'SIMPLE' AND cancelled_at IS NULL AND attendance IS DISTINCT FROM 'ATTENDED'
+	`, bookingID)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// MarkUnmarked gives every unmarked SIMPLE booking ended before the given
+// time the unmarked_attendance of the policy its owner was on that day,
+// ATTENDED when there is none. It returns how many bookings were marked.
+func (r *AttendanceRepository) MarkUnmarked(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE bookings b
+		SET attendance = m.attendance, attendance_marked_at = CURRENT_TIMESTAMP,
+			status = CASE m.attendance WHEN 'NO_SHOW' THEN 'no_show' ELSE 'attended' END
+		FROM (
+			SELECT ub.id, COALESCE(p.unmarked_attendance, 'ATTENDED') AS attendance
+			FROM bookings ub
+			JOIN users u ON u.id = ub.user_id
+			LEFT JOIN locations l ON l.id = ub.location_id
+			LEFT JOIN cancellation_policies p
+				ON p.id = `+memberPolicyID(`(ub.starts_at AT TIME ZONE COALESCE(l.time_zone, '`+BusinessTimeZone+`'))::date`)+`
+			WHERE ub.type = 'SIMPLE'
+				AND ub.cancelled_at IS NULL
+				AND ub.attendance IS NULL
+				AND ub.starts_at + ub.duration_minutes * INTERVAL '1 minute' <= $1
+		) m
+		WHERE b.id = m.id
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CountNoShows counts the no-shows of a user on bookings started since the given time.
+func (r *AttendanceRepository) CountNoShows(userID string, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM bookings
+		WHERE user_id = $1 AND attendance = 'NO_SHOW' AND starts_at >= $2
+	`, userID, since).Scan(&count)
+	return count, err
+}
+
+const attendanceStatsQuery = `
+	WITH marked AS (
+		SELECT user_id,
+			   COUNT(*) FILTER (WHERE attendance = 'ATTENDED') AS attended,
+			   COUNT(*) FILTER (WHERE attendance = 'NO_SHOW') AS no_shows,
+			   COUNT(*) FILTER (WHERE attendance = 'LATE_CANCEL') AS late_cancels
+		FROM bookings
+		WHERE type = 'SIMPLE' AND cancelled_at IS NULL AND attendance IS NOT NULL AND starts_at >= $1
+			AND ($2 = 0 OR location_id = $2)
+		GROUP BY user_id
+	), cancelled AS (
+		SELECT user_id, COUNT(*) AS late_cancels
+		FROM bookings
+		WHERE type = 'SIMPLE' AND late_cancel = TRUE AND starts_at >= $1
+			AND ($2 = 0 OR location_id = $2)
+		GROUP BY user_id
+	)
+	SELECT u.id, u.first_name, u.last_name,
+		   COALESCE(m.attended, 0), COALESCE(m.no_shows, 0),
+		   COALESCE(m.late_cancels, 0) + COALESCE(c.late_cancels, 0)
+	FROM users u
+	LEFT JOIN marked m ON m.user_id = u.id
+	LEFT JOIN cancelled c ON c.user_id = u.id
+`
+
+// GetStats returns the attendance statistics since the given time of every
+// user with at least one marked booking or late cancellation, most no-shows first.
+// With a locationID only the bookings of that location count.
+func (r *AttendanceRepository) GetStats(since time.Time, locationID int64) ([]*AttendanceStats, error) {
+	rows, err := r.db.Query(attendanceStatsQuery+`
+		WHERE m.user_id IS NOT NULL OR c.user_id IS NOT NULL
+		ORDER BY 5 DESC, u.last_name ASC, u.first_name ASC
+	`, since, locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*AttendanceStats
+	for rows.Next() {
+		s, err := scanAttendanceStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+// GetUserStats returns the attendance statistics of one user since the given time.
+func (r *AttendanceRepository) GetUserStats(userID string, since time.Time) (*AttendanceStats, error) {
+	return scanAttendanceStats(r.db.QueryRow(attendanceStatsQuery+` WHERE u.id = $3`, since, 0, userID))
+}
+
+func scanAttendanceStats(row rowScanner) (*AttendanceStats, error) {
+	var stats AttendanceStats
+	err := row.Scan(
+		&stats.UserID,
+		&stats.FirstName,
+		&stats.LastName,
+		&stats.Attended,
+		&stats.NoShows,
+		&stats.LateCancels,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
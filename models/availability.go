@@ -0,0 +1,196 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ClockTime is a wall-clock time of day expressed in minutes since midnight.
+type ClockTime int
+
+var ErrInvalidAvailability = errors.New("invalid availability")
+
+func ParseClockTime(s string) (ClockTime, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: %w", s, err)
+	}
+	return ClockTime(t.Hour()*60 + t.Minute()), nil
+}
+
+func (c ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+func (c ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *ClockTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	// "24:00" is accepted as the end of the day
+	if s == "24:00" {
+		*c = 24 * 60
+		return nil
+	}
+	parsed, err := ParseClockTime(s)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// AvailabilityRange is a weekly recurring time range in business local time.
+type AvailabilityRange struct {
+	Weekday time.Weekday `json:"weekday"`
+	Start   ClockTime    `json:"start"`
+	End     ClockTime    `json:"end"`
+}
+
+// WeeklySchedule lists the ranges during which an instructor accepts bookings.
+type WeeklySchedule []AvailabilityRange
+
+// DefaultWeeklySchedule is the schedule assigned to new instructors:
+// Monday to Saturday with hourly slots from 07:00 to 21:00.
+func DefaultWeeklySchedule() WeeklySchedule {
+	var schedule WeeklySchedule
+	for day := time.Monday; day <= time.Saturday; day++ {
+		schedule = append(schedule, AvailabilityRange{Weekday: day, Start: 7 * 60, End: 22 * 60})
+	}
+	return schedule
+}
+
+// Validate checks that every range is well formed and that ranges on the
+// same weekday do not overlap.
+func (s WeeklySchedule) Validate() error {
+	sorted := make(WeeklySchedule, len(s))
+	copy(sorted, s)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Weekday != sorted[j].Weekday {
+			return sorted[i].Weekday < sorted[j].Weekday
+		}
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	for i, r := range sorted {
+		if r.Weekday < time.Sunday || r.Weekday > time.Saturday {
+			return fmt.Errorf("%w: weekday %d out of range", ErrInvalidAvailability, r.Weekday)
+		}
+		if r.Start < 0 || r.End > 24*60 || r.Start >= r.End {
+			return fmt.Errorf("%w: %s-%s is not a valid range", ErrInvalidAvailability, r.Start, r.End)
+		}
+		if i > 0 && sorted[i-1].Weekday == r.Weekday && sorted[i-1].End > r.Start {
+			return fmt.Errorf("%w: overlapping ranges on weekday %d", ErrInvalidAvailability, r.Weekday)
+		}
+	}
+	return nil
+}
+
+// Covers reports whether a slot starting at local and lasting d fits entirely
+// inside one range and is aligned to the range's slot grid.
+func (s WeeklySchedule) Covers(local time.Time, d time.Duration) bool {
+	if local.Second() != 0 || local.Nanosecond() != 0 {
+		return false
+	}
+	minute := ClockTime(local.Hour()*60 + local.Minute())
+	length := ClockTime(d / time.Minute)
+	if length <= 0 {
+		return false
+	}
+	for _, r := range s {
+		if r.Weekday != local.Weekday() {
+			continue
+		}
+		if minute >= r.Start && minute+length <= r.End && (minute-r.Start)%length == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RangesOn returns the ranges for the given weekday sorted by start time.
+func (s WeeklySchedule) RangesOn(day time.Weekday) []AvailabilityRange {
+	var ranges []AvailabilityRange
+	for _, r := range s {
+		if r.Weekday == day {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	return ranges
+}
+
+type AvailabilityRepository struct {
+	db *sql.DB
+}
+
+func NewAvailabilityRepository(db *sql.DB) *AvailabilityRepository {
+	return &AvailabilityRepository{db: db}
+}
+
+func (r *AvailabilityRepository) GetByInstructorID(instructorID int64) (WeeklySchedule, error) {
+	query := `
+		SELECT weekday,
+			   EXTRACT(HOUR FROM start_time)::int * 60 + EXTRACT(MINUTE FROM start_time)::int,
+			   CASE WHEN end_time = TIME '24:00' THEN 1440
+			        ELSE EXTRACT(HOUR FROM end_time)::int * 60 + EXTRACT(MINUTE FROM end_time)::int END
+		FROM instructor_availability
+		WHERE instructor_id = $1
+		ORDER BY weekday, start_time
+	`
+
+	rows, err := r.db.Query(query, instructorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedule := WeeklySchedule{}
+	for rows.Next() {
+		var ar AvailabilityRange
+		if err := rows.Scan(&ar.Weekday, &ar.Start, &ar.End); err != nil {
+			return nil, err
+		}
+		schedule = append(schedule, ar)
+	}
+
+	return schedule, rows.Err()
+}
+
+// Replace atomically swaps the whole weekly schedule of an instructor.
+func (r *AvailabilityRepository) Replace(instructorID int64, schedule WeeklySchedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM instructor_availability WHERE instructor_id = $1`, instructorID); err != nil {
+		return err
+	}
+
+	for _, ar := range schedule {
+		_, err := tx.Exec(`
+			INSERT INTO instructor_availability (instructor_id, weekday, start_time, end_time)
+			VALUES ($1, $2, $3, $4)
+		`, instructorID, int(ar.Weekday), ar.Start.String(), ar.End.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
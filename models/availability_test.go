@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestWeeklyScheduleValidate(t *testing.T) {
+	t.Run("Default schedule is valid", func(t *testing.T) {
+		if err := models.DefaultWeeklySchedule().Validate(); err != nil {
+			t.Fatalf("Expected default schedule to be valid: %v", err)
+		}
+	})
+
+	t.Run("Rejects overlapping ranges", func(t *testing.T) {
+		schedule := models.WeeklySchedule{
+			{Weekday: time.Monday, Start: 9 * 60, End: 12 * 60},
+			{Weekday: time.Monday, Start: 11 * 60, End: 14 * 60},
+		}
+		if err := schedule.Validate(); !errors.Is(err, models.ErrInvalidAvailability) {
+			t.Fatalf("Expected ErrInvalidAvailability, got %v", err)
+		}
+	})
+
+	t.Run("Rejects empty range", func(t *testing.T) {
+		schedule := models.WeeklySchedule{{Weekday: time.Sunday, Start: 10 * 60, End: 10 * 60}}
+		if err := schedule.Validate(); !errors.Is(err, models.ErrInvalidAvailability) {
+			t.Fatalf("Expected ErrInvalidAvailability, got %v", err)
+		}
+	})
+}
+
+func TestAvailabilityRangeJSON(t *testing.T) {
+	var r models.AvailabilityRange
+	if err := json.Unmarshal([]byte(`{"weekday":0,"start":"08:30","end":"24:00"}`), &r); err != nil {
+		t.Fatalf("Failed to unmarshal range: %v", err)
+	}
+
+	if r.Weekday != time.Sunday || r.Start != 8*60+30 || r.End != 24*60 {
+		t.Fatalf("Unexpected range: %+v", r)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("Failed to marshal range: %v", err)
+	}
+	if string(data) != `{"weekday":0,"start":"08:30","end":"24:00"}` {
+		t.Fatalf("Unexpected JSON: %s", data)
+	}
+}
@@ -0,0 +1,217 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrBlockCollision   = errors.New("block collides with existing bookings")
+	ErrInvalidBlockType = errors.New("block type must be DISABLE, APPOINTMENT or MASSAGE")
+)
+
+// BlockResult summarizes a bulk block: the rows inserted, the blocks skipped
+// because an identical one already existed, and the SIMPLE bookings that
+// overlap the blocked time.
+type BlockResult struct {
+	Created    []*Booking
+	Skipped    int
+	Collisions []*BookingWithUser
+}
+
+// CreateBlocks inserts DISABLE, APPOINTMENT or MASSAGE bookings in a single
+// transaction, holding the same per-instructor, per-day locks as
+// CreateUserBooking. When a SIMPLE booking overlaps a block and
+// allowCollisions is false nothing is inserted and ErrBlockCollision is
+// returned together with the colliding bookings.
+func (r *BookingRepository) CreateBlocks(blocks []*Booking, allowCollisions bool) (*BlockResult, error) {
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Take the locks in a fixed order so concurrent bulk blocks cannot deadlock
+	keySet := make(map[int64]bool)
+	locs := make(map[int64]*time.Location)
+	for _, block := range blocks {
+		loc, ok := locs[block.InstructorID]
+		if !ok {
+			if loc, err = instructorTimeZoneTx(tx, block.InstructorID); err != nil {
+				return nil, err
+			}
+			locs[block.InstructorID] = loc
+		}
+		keySet[bookingLockKey(block.InstructorID, block.StartsAt, loc)] = true
+	}
+	keys := make([]int64, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, key := range keys {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, key); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &BlockResult{}
+	seen := make(map[int64]bool)
+
+	for _, block := range blocks {
+		if !block.Type.IsBlocking() {
+			return nil, ErrInvalidBlockType
+		}
+		block.DurationMinutes = int(block.Duration() / time.Minute)
+
+		var exists bool
+		err := tx.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM bookings
+				WHERE instructor_id = $1 AND starts_at = $2 AND type = $3 AND cancelled_at IS NULL
+			)
+		`, block.InstructorID, block.StartsAt, block.Type).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			result.Skipped++
+			continue
+		}
+
+		collisions, err := overlappingSimpleBookingsTx(tx, block.InstructorID, block.StartsAt, block.EndsAt())
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range collisions {
+			if !seen[c.ID] {
+				seen[c.ID] = true
+				result.Collisions = append(result.Collisions, c)
+			}
+		}
+
+		if err := insertBookingTx(tx, block); err != nil {
+			return nil, err
+		}
+		result.Created = append(result.Created, block)
+	}
+
+	sort.Slice(result.Collisions, func(i, j int) bool {
+		return result.Collisions[i].StartsAt.Before(result.Collisions[j].StartsAt)
+	})
+
+	if len(result.Collisions) > 0 && !allowCollisions {
+		return result, ErrBlockCollision
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// blockCancellation is the outcome recorded for the bookings a block cancels.
+var blockCancellation = CancellationDecision{Refund: true, Reason: "Slot bloccato dall'amministratore: accesso rimborsato"}
+
+// CreateBlockCancelling inserts one DISABLE, APPOINTMENT or MASSAGE booking
+// holding the same per-instructor, per-day lock as CreateUserBooking, and
+// returns the SIMPLE bookings it overlaps. Unless cancel is set nothing is
+// inserted when there are any and ErrBlockCollision is returned; otherwise they
+// are cancelled by the admin cancelledBy, their members' accesses refunded and
+// their cancellation events recorded in the same transaction.
+func (r *BookingRepository) CreateBlockCancelling(block *Booking, cancel bool, cancelledBy string) ([]*BookingWithUser, error) {
+	if !block.Type.IsBlocking() {
+		return nil, ErrInvalidBlockType
+	}
+
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockInstructorDayTx(tx, block.InstructorID, block.StartsAt); err != nil {
+		return nil, err
+	}
+
+	block.DurationMinutes = int(block.Duration() / time.Minute)
+	collisions, err := overlappingSimpleBookingsTx(tx, block.InstructorID, block.StartsAt, block.EndsAt())
+	if err != nil {
+		return nil, err
+	}
+	if len(collisions) > 0 && !cancel {
+		return collisions, ErrBlockCollision
+	}
+
+	cancellation := Cancellation{Status: BookingStatusCancelledByAdmin, By: cancelledBy, Refunded: true}
+	now := time.Now()
+	for _, c := range collisions {
+		if _, err := cancelBookingTx(tx, c.ID, cancellation, now); err != nil {
+			return nil, err
+		}
+		if !c.UserID.Valid {
+			continue
+		}
+		event := &Event{
+			UserID:     c.UserID.String,
+			StartsAt:   c.StartsAt,
+			Type:       EventTypeDeleted,
+			OccurredAt: now.UTC(),
+		}
+		blockCancellation.Record(event)
+		if err := createEventTx(tx, event); err != nil {
+			return nil, err
+		}
+	}
+
+	// Checked after the cancellations, which free the member's time and the resources
+	if block.UserID.Valid && !block.AllowOverlap {
+		if err := checkUserOverlapTx(tx, block.UserID.String, block.StartsAt, block.EndsAt(), 0); err != nil {
+			return nil, err
+		}
+	}
+	if err := resolveBookingResourcesTx(tx, block); err != nil {
+		return nil, err
+	}
+	if err := checkResourcesTx(tx, block.Resources, block.StartsAt, block.EndsAt(), 0); err != nil {
+		return nil, err
+	}
+
+	if err := insertBookingTx(tx, block); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return collisions, nil
+}
+
+// overlappingSimpleBookingsTx locks and returns the SIMPLE bookings of an
+// instructor whose interval overlaps [from, to).
+func overlappingSimpleBookingsTx(tx *sql.Tx, instructorID int64, from, to time.Time) ([]*BookingWithUser, error) {
+	rows, err := tx.Query(`
+		SELECT b.id, b.user_id, b.instructor_id, b.created_at, b.starts_at, b.type,
+			   u.first_name, u.last_name, u.email, u.sub_type,
+			   b.service_id, s.name, b.capacity_weight, b.duration_minutes
+		FROM bookings b
+		LEFT JOIN users u ON u.id = b.user_id
+		LEFT JOIN services s ON s.id = b.service_id
+		WHERE b.instructor_id = $1
+			AND b.type = 'SIMPLE'
+			AND b.cancelled_at IS NULL
+			AND b.starts_at < $3
+			AND b.starts_at + b.duration_minutes * INTERVAL '1 minute' > $2
+		ORDER BY b.starts_at ASC
+		FOR UPDATE OF b
+	`, instructorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanBookingsWithUsers(rows)
+}
@@ -6,16 +6,55 @@ import (
 	"errors"
 	"fmt"
 	"hash/fnv"
+	"sort"
 	"time"
 )
 
 type Booking struct {
-	ID           int64
-	UserID       sql.NullString
-	InstructorID int64
-	CreatedAt    time.Time
-	StartsAt     time.Time
-	Type         BookingType
+	ID              int64
+	UserID          sql.NullString
+	InstructorID    int64
+	CreatedAt       time.Time
+	StartsAt        time.Time
+	Type            BookingType
+	ServiceID       sql.NullInt64
+	DurationMinutes int
+	SeriesID        sql.NullInt64
+	// LocationID is the location of the instructor when the booking was made
+	// and TimeZone the time zone of that location
+	LocationID int64
+	TimeZone   string
+	// Resources are the rooms and machines the booking holds; when nil a new
+	// booking takes the ones its service needs
+	Resources []ResourceRequirement
+	// AllowOverlap lets an admin book a member over another of their bookings
+	AllowOverlap bool
+	// BookedBy is the user creating the booking, recorded in the access
+	// ledger; empty when the system books it
+	BookedBy string
+	Status   BookingStatus
+	// CancelledAt, CancelledBy and Refunded are set once the booking is
+	// cancelled; CancelledBy is NULL when the system cancelled it
+	CancelledAt sql.NullTime
+	CancelledBy sql.NullString
+	Refunded    sql.NullBool
+}
+
+// Location returns the time zone of the location the booking is at.
+func (b *Booking) Location() *time.Location {
+	return LoadTimeZone(b.TimeZone)
+}
+
+// Duration returns the booked length, defaulting to a standard session.
+func (b *Booking) Duration() time.Duration {
+	if b.DurationMinutes <= 0 {
+		return DefaultServiceDuration
+	}
+	return time.Duration(b.DurationMinutes) * time.Minute
+}
+
+func (b *Booking) EndsAt() time.Time {
+	return b.StartsAt.Add(b.Duration())
 }
 
 type BookingType string
@@ -27,22 +66,131 @@ const (
 	BookingTypeDisable     BookingType = "DISABLE"
 )
 
+// BookingStatus is where a booking is in its lifecycle. Cancelled bookings
+// are kept for history but no longer hold their slot.
+type BookingStatus string
+
+const (
+	BookingStatusConfirmed        BookingStatus = "confirmed"
+	BookingStatusCancelledByUser  BookingStatus = "cancelled_by_user"
+	BookingStatusCancelledByAdmin BookingStatus = "cancelled_by_admin"
+	BookingStatusAttended         BookingStatus = "attended"
+	BookingStatusNoShow           BookingStatus = "no_show"
+)
+
+// Active reports whether a booking in this status still holds its slot.
+func (s BookingStatus) Active() bool {
+	return s != BookingStatusCancelledByUser && s != BookingStatusCancelledByAdmin
+}
+
 var (
-	ErrSlotUnavailable = errors.New("slot unavailable")
-	ErrNoAccesses      = errors.New("no remaining accesses")
+	ErrSlotUnavailable  = errors.New("slot unavailable")
+	ErrNoAccesses       = errors.New("no remaining accesses")
+	ErrNotReschedulable = errors.New("only upcoming SIMPLE bookings can be rescheduled")
+	// ErrBookingChanged reports a booking moved by someone else while it was
+	// being rescheduled; the request can be retried
+	ErrBookingChanged      = errors.New("booking changed meanwhile, retry")
+	ErrUserOverlap         = errors.New("member already has a booking at this time")
+	ErrInvalidCancellation = errors.New("a booking is cancelled by its member or by an admin")
 )
 
+// IsBlocking reports whether a booking of this type makes the instructor
+// unavailable for its whole interval.
+func (t BookingType) IsBlocking() bool {
+	return t == BookingTypeDisable || t == BookingTypeMassage || t == BookingTypeAppointment
+}
+
+// BookingWeight is the capacity a SIMPLE booking takes from its instructor:
+// SINGLE subscriptions count double and the service weight multiplies it.
+func BookingWeight(subType SubType, capacityWeight int) int {
+	if capacityWeight <= 0 {
+		capacityWeight = 1
+	}
+	if subType == SubTypeSingle {
+		return 2 * capacityWeight
+	}
+	return capacityWeight
+}
+
+// Occupancy is a booked interval as seen by capacity checks.
+type Occupancy struct {
+	StartsAt time.Time
+	EndsAt   time.Time
+	Weight   int
+	Blocking bool
+}
+
+// PeakLoad returns the highest total weight booked at any instant of [start, end)
+// and whether a blocking occupancy overlaps the interval.
+func PeakLoad(occupancies []Occupancy, start, end time.Time) (int, bool) {
+	type change struct {
+		at    time.Time
+		delta int
+	}
+
+	var changes []change
+	for _, o := range occupancies {
+		if !o.StartsAt.Before(end) || !o.EndsAt.After(start) {
+			continue
+		}
+		if o.Blocking {
+			return 0, true
+		}
+		from, to := o.StartsAt, o.EndsAt
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		changes = append(changes, change{at: from, delta: o.Weight}, change{at: to, delta: -o.Weight})
+	}
+
+	// Intervals are half-open: at the same instant, releases come before new arrivals
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].at.Equal(changes[j].at) {
+			return changes[i].at.Before(changes[j].at)
+		}
+		return changes[i].delta < changes[j].delta
+	})
+
+	load, peak := 0, 0
+	for _, c := range changes {
+		load += c.delta
+		if load > peak {
+			peak = load
+		}
+	}
+	return peak, false
+}
+
 type BookingWithUser struct {
-	ID            int64
-	UserID        sql.NullString
-	InstructorID  int64
-	CreatedAt     time.Time
-	StartsAt      time.Time
-	Type          BookingType
-	UserFirstName sql.NullString
-	UserLastName  sql.NullString
-	UserEmail     sql.NullString
-	UserSubType   sql.NullString
+	ID              int64
+	UserID          sql.NullString
+	InstructorID    int64
+	CreatedAt       time.Time
+	StartsAt        time.Time
+	Type            BookingType
+	UserFirstName   sql.NullString
+	UserLastName    sql.NullString
+	UserEmail       sql.NullString
+	UserSubType     sql.NullString
+	ServiceID       sql.NullInt64
+	ServiceName     sql.NullString
+	CapacityWeight  int
+	DurationMinutes int
+}
+
+// Occupancy returns the interval and capacity the booking takes from its instructor.
+func (b *BookingWithUser) Occupancy() Occupancy {
+	booking := Booking{StartsAt: b.StartsAt, DurationMinutes: b.DurationMinutes}
+	occupancy := Occupancy{StartsAt: booking.StartsAt, EndsAt: booking.EndsAt()}
+	if b.Type.IsBlocking() {
+		occupancy.Blocking = true
+	} else if b.Type == BookingTypeSimple {
+		occupancy.Weight = BookingWeight(SubType(b.UserSubType.String), b.CapacityWeight)
+	}
+	return occupancy
 }
 
 type BookingWithInstructor struct {
@@ -54,21 +202,56 @@ type BookingWithInstructor struct {
 	Type                BookingType
 	InstructorFirstName sql.NullString
 	InstructorLastName  sql.NullString
+	ServiceID           sql.NullInt64
+	ServiceName         sql.NullString
+	DurationMinutes     int
+	// TimeZone is the time zone of the booking's location
+	TimeZone string
 }
 
 type BookingRepository struct {
 	db *sql.DB
 }
 
+const bookingColumns = `id, user_id, instructor_id, created_at, starts_at, type, service_id, duration_minutes, series_id,
+	location_id, (SELECT time_zone FROM locations WHERE locations.id = bookings.location_id),
+	status, cancelled_at, cancelled_by, refunded`
+
+func scanBooking(row rowScanner) (*Booking, error) {
+	var booking Booking
+	err := row.Scan(
+		&booking.ID,
+		&booking.UserID,
+		&booking.InstructorID,
+		&booking.CreatedAt,
+		&booking.StartsAt,
+		&booking.Type,
+		&booking.ServiceID,
+		&booking.DurationMinutes,
+		&booking.SeriesID,
+		&booking.LocationID,
+		&booking.TimeZone,
+		&booking.Status,
+		&booking.CancelledAt,
+		&booking.CancelledBy,
+		&booking.Refunded,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
 func NewBookingRepository(db *sql.DB) *BookingRepository {
 	return &BookingRepository{db: db}
 }
 
 func (r *BookingRepository) GetByUserID(userID string) ([]*Booking, error) {
 	query := `
-		SELECT id, user_id, instructor_id, created_at, starts_at
+		SELECT ` + bookingColumns + `
 		FROM bookings
 		WHERE user_id = $1
+			AND cancelled_at IS NULL
 			AND starts_at > date_trunc('month', CURRENT_TIMESTAMP)
 		ORDER BY starts_at DESC
 	`
@@ -81,18 +264,11 @@ func (r *BookingRepository) GetByUserID(userID string) ([]*Booking, error) {
 
 	var bookings []*Booking
 	for rows.Next() {
-		var booking Booking
-		err := rows.Scan(
-			&booking.ID,
-			&booking.UserID,
-			&booking.InstructorID,
-			&booking.CreatedAt,
-			&booking.StartsAt,
-		)
+		booking, err := scanBooking(rows)
 		if err != nil {
 			return nil, err
 		}
-		bookings = append(bookings, &booking)
+		bookings = append(bookings, booking)
 	}
 
 	return bookings, rows.Err()
@@ -101,10 +277,13 @@ func (r *BookingRepository) GetByUserID(userID string) ([]*Booking, error) {
 func (r *BookingRepository) GetByUserIDWithInstructor(userID string) ([]*BookingWithInstructor, error) {
 	query := `
 		SELECT b.id, b.user_id, b.instructor_id, b.created_at, b.starts_at, b.type,
-			   i.first_name, i.last_name
+			   i.first_name, ` + instructorLastName + `, b.service_id, s.name, b.duration_minutes, l.time_zone
 		FROM bookings b
 		LEFT JOIN instructors i ON i.id = b.instructor_id
+		LEFT JOIN services s ON s.id = b.service_id
+		JOIN locations l ON l.id = b.location_id
 		WHERE b.user_id = $1
+			AND b.cancelled_at IS NULL
 			AND b.starts_at > date_trunc('month', CURRENT_TIMESTAMP)
 		ORDER BY b.starts_at DESC
 	`
@@ -127,6 +306,10 @@ func (r *BookingRepository) GetByUserIDWithInstructor(userID string) ([]*Booking
 			&booking.Type,
 			&booking.InstructorFirstName,
 			&booking.InstructorLastName,
+			&booking.ServiceID,
+			&booking.ServiceName,
+			&booking.DurationMinutes,
+			&booking.TimeZone,
 		)
 		if err != nil {
 			return nil, err
@@ -137,22 +320,60 @@ func (r *BookingRepository) GetByUserIDWithInstructor(userID string) ([]*Booking
 	return bookings, rows.Err()
 }
 
+// Create inserts a booking without capacity checks; only the resources it
+// needs are locked and checked, and a member's booking must not overlap
+// their other bookings unless AllowOverlap is set.
 func (r *BookingRepository) Create(booking *Booking) error {
-	query := `
-		INSERT INTO bookings (user_id, instructor_id, starts_at, type)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
-	`
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	err := r.db.QueryRow(query,
-		booking.UserID,
-		booking.InstructorID,
-		booking.StartsAt,
-		booking.Type).
-		Scan(&booking.ID)
-	return err
+	if err := checkInstructorActiveTx(tx, booking.InstructorID); err != nil {
+		return err
+	}
+
+	booking.DurationMinutes = int(booking.Duration() / time.Minute)
+	if booking.UserID.Valid && !booking.AllowOverlap {
+		if err := checkUserOverlapTx(tx, booking.UserID.String, booking.StartsAt, booking.EndsAt(), 0); err != nil {
+			return err
+		}
+	}
+	if err := resolveBookingResourcesTx(tx, booking); err != nil {
+		return err
+	}
+	if err := checkResourcesTx(tx, booking.Resources, booking.StartsAt, booking.EndsAt(), 0); err != nil {
+		return err
+	}
+
+	if err := insertBookingTx(tx, booking); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+func insertBookingTx(tx *sql.Tx, booking *Booking) error {
+	err := tx.QueryRow(`
+		INSERT INTO bookings (user_id, instructor_id, starts_at, type, service_id, duration_minutes, series_id, location_id, capacity_weight)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, (SELECT location_id FROM instructors WHERE id = $2),
+			COALESCE((SELECT capacity_weight FROM services WHERE id = $5), 1))
+		RETURNING id, created_at, location_id, (SELECT time_zone FROM locations WHERE locations.id = bookings.location_id), status
+	`, booking.UserID, booking.InstructorID, booking.StartsAt, booking.Type, booking.ServiceID, booking.DurationMinutes, booking.SeriesID).
+		Scan(&booking.ID, &booking.CreatedAt, &booking.LocationID, &booking.TimeZone, &booking.Status)
+	if err != nil {
+		return err
+	}
+	return insertBookingResourcesTx(tx, booking.ID, booking.Resources)
+}
+
+// CreateUserBooking consumes one access, from the member's wallet for the
+// service when they have one and from the general balance otherwise, and
+// inserts a SIMPLE booking if the instructor has neededSlots free capacity,
+// the resources it needs are free for the whole booked interval and the
+// member is within their booking limits, not frozen on that day and has no
+// other booking overlapping it.
 func (r *BookingRepository) CreateUserBooking(booking *Booking, neededSlots, maxSlots int) error {
 	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
 	if err != nil {
@@ -160,42 +381,240 @@ func (r *BookingRepository) CreateUserBooking(booking *Booking, neededSlots, max
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, bookingLockKey(booking.InstructorID, booking.StartsAt)); err != nil {
+	if err := createUserBookingTx(tx, booking, neededSlots, maxSlots); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// createUserBookingTx runs the locked capacity check, access consumption and
+// insert of CreateUserBooking inside an existing serializable transaction.
+func createUserBookingTx(tx *sql.Tx, booking *Booking, neededSlots, maxSlots int) error {
+	if err := lockInstructorDayTx(tx, booking.InstructorID, booking.StartsAt); err != nil {
+		return err
+	}
+
+	if err := checkInstructorActiveTx(tx, booking.InstructorID); err != nil {
+		return err
+	}
+
+	closed, err := isClosedTx(tx, booking.InstructorID, booking.StartsAt)
+	if err != nil {
 		return err
 	}
+	if closed {
+		return ErrClosed
+	}
+
+	loc, err := instructorTimeZoneTx(tx, booking.InstructorID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkBookingLimitsTx(tx, booking.UserID.String, booking.StartsAt, loc, 0, time.Now()); err != nil {
+		return err
+	}
+	if err := checkNotFrozenTx(tx, booking.UserID.String, booking.StartsAt, loc); err != nil {
+		return err
+	}
+
+	booking.DurationMinutes = int(booking.Duration() / time.Minute)
+	if !booking.AllowOverlap {
+		if err := checkUserOverlapTx(tx, booking.UserID.String, booking.StartsAt, booking.EndsAt(), 0); err != nil {
+			return err
+		}
+	}
+
+	if err := checkCapacityTx(tx, booking.InstructorID, booking.StartsAt, booking.EndsAt(), 0, neededSlots, maxSlots); err != nil {
+		return err
+	}
+
+	if err := resolveBookingResourcesTx(tx, booking); err != nil {
+		return err
+	}
+	if err := checkResourcesTx(tx, booking.Resources, booking.StartsAt, booking.EndsAt(), 0); err != nil {
+		return err
+	}
+
+	walletID, err := bookingWalletTx(tx, booking.UserID.String, booking.ServiceID, booking.StartsAt)
+	if err != nil {
+		return err
+	}
+	if err := insertBookingTx(tx, booking); err != nil {
+		return err
+	}
+	return changeAccessesTx(tx, AccessChange{
+		UserID:    booking.UserID.String,
+		Delta:     -1,
+		Reason:    AccessBooking,
+		ServiceID: walletID,
+		BookingID: booking.ID,
+		ActorID:   booking.BookedBy,
+	})
+}
+
+// Reschedule moves an upcoming SIMPLE booking to a new instructor and start time
+// in one serializable transaction, holding the advisory locks of both the old
+// and the new day. The access count is left untouched.
+func (r *BookingRepository) Reschedule(id, instructorID int64, startsAt time.Time, neededSlots, maxSlots int) (*Booking, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := scanBooking(tx.QueryRow(`
+		SELECT `+bookingColumns+`
+		FROM bookings
+		WHERE id = $1 AND cancelled_at IS NULL
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	// Lock both days in a fixed order so opposite moves cannot deadlock
+	currentLoc, err := instructorTimeZoneTx(tx, current.InstructorID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := instructorTimeZoneTx(tx, instructorID)
+	if err != nil {
+		return nil, err
+	}
+	keys := []int64{bookingLockKey(current.InstructorID, current.StartsAt, currentLoc), bookingLockKey(instructorID, startsAt, loc)}
+	if keys[1] < keys[0] {
+		keys[0], keys[1] = keys[1], keys[0]
+	}
+	for i, key := range keys {
+		if i > 0 && key == keys[i-1] {
+			continue
+		}
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, key); err != nil {
+			return nil, err
+		}
+	}
+
+	// Re-read under the locks: the booking may have been moved or deleted
+	// meanwhile, and a moved booking is no longer covered by the locks taken
+	lockedInstructorID, lockedStartsAt := current.InstructorID, current.StartsAt
+	err = tx.QueryRow(`
+		SELECT instructor_id, starts_at, type, status
+		FROM bookings
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&current.InstructorID, &current.StartsAt, &current.Type, &current.Status)
+	if err != nil {
+		return nil, err
+	}
+	if current.InstructorID != lockedInstructorID || !current.StartsAt.Equal(lockedStartsAt) {
+		return nil, ErrBookingChanged
+	}
+	if current.Type != BookingTypeSimple || current.Status != BookingStatusConfirmed || !current.StartsAt.After(time.Now()) {
+		return nil, ErrNotReschedulable
+	}
+
+	if err := checkInstructorActiveTx(tx, instructorID); err != nil {
+		return nil, err
+	}
+
+	closed, err := isClosedTx(tx, instructorID, startsAt)
+	if err != nil {
+		return nil, err
+	}
+	if closed {
+		return nil, ErrClosed
+	}
+
+	moved := *current
+	moved.InstructorID = instructorID
+	moved.StartsAt = startsAt
+
+	if err := checkNotFrozenTx(tx, moved.UserID.String, moved.StartsAt, loc); err != nil {
+		return nil, err
+	}
+
+	// The booking leaves its old day and week, so it is not counted against
+	// the limits of the new ones
+	if err := checkBookingLimitsTx(tx, moved.UserID.String, moved.StartsAt, loc, moved.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := checkUserOverlapTx(tx, moved.UserID.String, moved.StartsAt, moved.EndsAt(), moved.ID); err != nil {
+		return nil, err
+	}
+
+	if err := checkCapacityTx(tx, moved.InstructorID, moved.StartsAt, moved.EndsAt(), moved.ID, neededSlots, maxSlots); err != nil {
+		return nil, err
+	}
+
+	// The booking keeps its resources and needs them free at the new time
+	moved.Resources, err = bookingResourcesTx(tx, moved.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResourcesTx(tx, moved.Resources, moved.StartsAt, moved.EndsAt(), moved.ID); err != nil {
+		return nil, err
+	}
 
+	var taken bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM bookings
+			WHERE user_id = $1 AND instructor_id = $2 AND starts_at = $3 AND id <> $4 AND cancelled_at IS NULL
+		)
+	`, moved.UserID, moved.InstructorID, moved.StartsAt, moved.ID).Scan(&taken)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrSlotUnavailable
+	}
+
+	// Moving to an instructor of another location moves the booking there too
+	err = tx.QueryRow(`
+		UPDATE bookings
+		SET instructor_id = $1, starts_at = $2, location_id = (SELECT location_id FROM instructors WHERE id = $1)
+		WHERE id = $3
+		RETURNING location_id, (SELECT time_zone FROM locations WHERE locations.id = bookings.location_id)
+	`, moved.InstructorID, moved.StartsAt, moved.ID).Scan(&moved.LocationID, &moved.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &moved, nil
+}
+
+// checkCapacityTx locks the bookings of an instructor overlapping [startsAt, endsAt),
+// ignoring excludeID, and returns ErrSlotUnavailable unless neededSlots still fit.
+// The caller must hold the advisory locks of the affected days.
+func checkCapacityTx(tx *sql.Tx, instructorID int64, startsAt, endsAt time.Time, excludeID int64, neededSlots, maxSlots int) error {
 	rows, err := tx.Query(`
-		SELECT b.type, COALESCE(u.sub_type, '')
+		SELECT b.type, b.starts_at, b.duration_minutes, COALESCE(u.sub_type, ''), b.capacity_weight
 		FROM bookings b
 		LEFT JOIN users u ON u.id = b.user_id
-		WHERE b.instructor_id = $1 AND b.starts_at = $2
+		WHERE b.instructor_id = $1
+			AND b.cancelled_at IS NULL
+			AND b.starts_at < $3
+			AND b.starts_at + b.duration_minutes * INTERVAL '1 minute' > $2
+			AND b.id <> $4
 		FOR UPDATE OF b
-	`, booking.InstructorID, booking.StartsAt)
+	`, instructorID, startsAt, endsAt, excludeID)
 	if err != nil {
 		return err
 	}
 
-	usedSlots := 0
+	var occupancies []Occupancy
 	for rows.Next() {
-		var bookingType BookingType
-		var subType string
-		if err := rows.Scan(&bookingType, &subType); err != nil {
+		var existing BookingWithUser
+		if err := rows.Scan(&existing.Type, &existing.StartsAt, &existing.DurationMinutes, &existing.UserSubType, &existing.CapacityWeight); err != nil {
 			rows.Close()
 			return err
 		}
-
-		if bookingType == BookingTypeDisable || bookingType == BookingTypeMassage || bookingType == BookingTypeAppointment {
-			rows.Close()
-			return ErrSlotUnavailable
-		}
-
-		if bookingType == BookingTypeSimple {
-			if subType == string(SubTypeSingle) {
-				usedSlots += 2
-			} else {
-				usedSlots++
-			}
-		}
+		occupancies = append(occupancies, existing.Occupancy())
 	}
 	if err := rows.Close(); err != nil {
 		return err
@@ -204,50 +623,229 @@ func (r *BookingRepository) CreateUserBooking(booking *Booking, neededSlots, max
 		return err
 	}
 
-	if usedSlots+neededSlots > maxSlots {
+	teaching, err := hasClassSessionTx(tx, instructorID, startsAt, endsAt)
+	if err != nil {
+		return err
+	}
+	if teaching {
+		return ErrSlotUnavailable
+	}
+
+	usedSlots, blocked := PeakLoad(occupancies, startsAt, endsAt)
+	if blocked || usedSlots+neededSlots > maxSlots {
 		return ErrSlotUnavailable
 	}
+	return nil
+}
 
-	result, err := tx.Exec(
-		`UPDATE users SET remaining_accesses = remaining_accesses - 1 WHERE id = $1 AND remaining_accesses > 0`,
-		booking.UserID.String,
-	)
-	if err != nil {
+// checkUserOverlapTx locks the user row, so concurrent bookings and class
+// enrollments of the same member serialize, and returns ErrUserOverlap if any
+// of their bookings other than excludeID or of their classes overlaps
+// [startsAt, endsAt), whatever the instructor or service.
+func checkUserOverlapTx(tx *sql.Tx, userID string, startsAt, endsAt time.Time, excludeID int64) error {
+	if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+
+	var overlapping bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM bookings
+			WHERE user_id = $1
+				AND cancelled_at IS NULL
+				AND starts_at < $3
+				AND starts_at + duration_minutes * INTERVAL '1 minute' > $2
+				AND id <> $4
+		) OR EXISTS (
+			SELECT 1 FROM class_enrollments ce
+			JOIN class_sessions cs ON cs.id = ce.session_id
+			WHERE ce.user_id = $1
+				AND ce.cancelled_at IS NULL
+				AND cs.starts_at < $3
+				AND cs.starts_at + cs.duration_minutes * INTERVAL '1 minute' > $2
+		)
+	`, userID, startsAt, endsAt, excludeID).Scan(&overlapping)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return ErrNoAccesses
+	if overlapping {
+		return ErrUserOverlap
 	}
+	return nil
+}
 
-	err = tx.QueryRow(`
-		INSERT INTO bookings (user_id, instructor_id, starts_at, type)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
-	`, booking.UserID, booking.InstructorID, booking.StartsAt, booking.Type).Scan(&booking.ID)
+// Cancellation records who cancelled a booking, whether its access was
+// refunded and whether it came inside the cutoff of the member's policy. By
+// is the id of the member or admin, empty for the system.
+type Cancellation struct {
+	Status   BookingStatus
+	By       string
+	Refunded bool
+	Late     bool
+}
+
+// Cancel marks an active booking cancelled and, when c.Refunded, gives its
+// member the access back in the same transaction. It returns the cancelled
+// booking, or sql.ErrNoRows when it does not exist or is already cancelled.
+func (r *BookingRepository) Cancel(id int64, c Cancellation) (*Booking, error) {
+	tx, err := r.db.BeginTx(context.Background(), nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	return tx.Commit()
+	booking, err := cancelBookingTx(tx, id, c, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return booking, nil
 }
 
-func (r *BookingRepository) Delete(id int64) error {
-	query := `DELETE from bookings WHERE id = $1`
-	_, err := r.db.Exec(query, id)
-	return err
+// CancelByPolicy cancels an active booking and records its cancellation
+// event in one transaction. For a member's booking, decide returns the
+// outcome for the locked booking given the late cancellations already
+// refunded to the member this month; their user row is locked while those
+// are counted, so concurrent cancellations cannot both take the last free
+// one. Other bookings are cancelled without refund nor event. It returns
+// sql.ErrNoRows when the booking does not exist or is already cancelled.
+func (r *BookingRepository) CancelByPolicy(id int64, status BookingStatus, by string, decide func(booking *Booking, lateRefundsUsed int) CancellationDecision) (*CancelledBooking, error) {
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userID sql.NullString
+	if err := tx.QueryRow(`SELECT user_id FROM bookings WHERE id = $1`, id).Scan(&userID); err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID.String); err != nil {
+			return nil, err
+		}
+	}
+
+	booking, err := scanBooking(tx.QueryRow(`
+		SELECT `+bookingColumns+` FROM bookings WHERE id = $1 AND cancelled_at IS NULL FOR UPDATE
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var decision CancellationDecision
+	member := booking.UserID.Valid && booking.Type == BookingTypeSimple
+	if member {
+		lateRefundsUsed, err := countLateRefundsTx(tx, booking.UserID.String, BusinessMonthStart(now))
+		if err != nil {
+			return nil, err
+		}
+		decision = decide(booking, lateRefundsUsed)
+	}
+
+	booking, err = cancelBookingTx(tx, id, Cancellation{Status: status, By: by, Refunded: decision.Refund, Late: decision.Late}, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if member {
+		event := &Event{
+			UserID:     booking.UserID.String,
+			StartsAt:   booking.StartsAt,
+			Type:       EventTypeDeleted,
+			OccurredAt: now.UTC(),
+		}
+		decision.Record(event)
+		if err := createEventTx(tx, event); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &CancelledBooking{Booking: booking, Decision: decision}, nil
+}
+
+// cancelBookingTx runs Cancel inside an existing transaction. Bookings
+// without a member record no refund.
+func cancelBookingTx(tx *sql.Tx, id int64, c Cancellation, at time.Time) (*Booking, error) {
+	if c.Status.Active() {
+		return nil, ErrInvalidCancellation
+	}
+
+	booking, err := scanBooking(tx.QueryRow(`
+		UPDATE bookings
+		SET status = $2, cancelled_at = $3, cancelled_by = NULLIF($4, ''),
+			refunded = CASE WHEN user_id IS NULL THEN NULL ELSE $5 END, late_cancel = $6
+		WHERE id = $1 AND cancelled_at IS NULL
+		RETURNING `+bookingColumns+`
+	`, id, c.Status, at.UTC(), c.By, c.Refunded, c.Late))
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.UserID.Valid && c.Refunded {
+		walletID, err := refundWalletTx(tx, booking.ID)
+		if err != nil {
+			return nil, err
+		}
+		err = changeAccessesTx(tx, AccessChange{
+			UserID:    booking.UserID.String,
+			Delta:     1,
+			Reason:    AccessRefund,
+			ServiceID: walletID,
+			BookingID: booking.ID,
+			ActorID:   c.By,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return booking, nil
+}
+
+// GetHistoryByUserID returns every booking of a member, cancelled ones
+// included, most recent first.
+func (r *BookingRepository) GetHistoryByUserID(userID string) ([]*Booking, error) {
+	query := `
+		SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE user_id = $1
+		ORDER BY starts_at DESC
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookings []*Booking
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+
+	return bookings, rows.Err()
 }
 
 func (r *BookingRepository) GetWithUsersByDateRange(from, to time.Time) ([]*BookingWithUser, error) {
 	query := `
 		SELECT b.id, b.user_id, b.instructor_id, b.created_at, b.starts_at, b.type,
-			   u.first_name, u.last_name, u.email, u.sub_type
+			   u.first_name, u.last_name, u.email, u.sub_type,
+			   b.service_id, s.name, b.capacity_weight, b.duration_minutes
 		FROM bookings b
 		LEFT JOIN users u ON u.id = b.user_id
-		WHERE b.starts_at >= $1 AND b.starts_at <= $2
+		LEFT JOIN services s ON s.id = b.service_id
+		WHERE b.starts_at >= $1 AND b.starts_at <= $2 AND b.cancelled_at IS NULL
 		ORDER BY b.starts_at ASC
 	`
 
@@ -257,16 +855,33 @@ func (r *BookingRepository) GetWithUsersByDateRange(from, to time.Time) ([]*Book
 func (r *BookingRepository) GetWithUsersByInstructorAndDateRange(instructorID string, from, to time.Time) ([]*BookingWithUser, error) {
 	query := `
 		SELECT b.id, b.user_id, b.instructor_id, b.created_at, b.starts_at, b.type,
-			   u.first_name, u.last_name, u.email, u.sub_type
+			   u.first_name, u.last_name, u.email, u.sub_type,
+			   b.service_id, s.name, b.capacity_weight, b.duration_minutes
 		FROM bookings b
 		LEFT JOIN users u ON u.id = b.user_id
-		WHERE b.instructor_id = $1 AND b.starts_at >= $2 AND b.starts_at <= $3
+		LEFT JOIN services s ON s.id = b.service_id
+		WHERE b.instructor_id = $1 AND b.starts_at >= $2 AND b.starts_at <= $3 AND b.cancelled_at IS NULL
 		ORDER BY b.starts_at ASC
 	`
 
 	return r.queryWithUsers(query, instructorID, from, to)
 }
 
+func (r *BookingRepository) GetWithUsersByLocationAndDateRange(locationID int64, from, to time.Time) ([]*BookingWithUser, error) {
+	query := `
+		SELECT b.id, b.user_id, b.instructor_id, b.created_at, b.starts_at, b.type,
+			   u.first_name, u.last_name, u.email, u.sub_type,
+			   b.service_id, s.name, b.capacity_weight, b.duration_minutes
+		FROM bookings b
+		LEFT JOIN users u ON u.id = b.user_id
+		LEFT JOIN services s ON s.id = b.service_id
+		WHERE b.location_id = $1 AND b.starts_at >= $2 AND b.starts_at <= $3 AND b.cancelled_at IS NULL
+		ORDER BY b.starts_at ASC
+	`
+
+	return r.queryWithUsers(query, locationID, from, to)
+}
+
 func (r *BookingRepository) queryWithUsers(query string, args ...interface{}) ([]*BookingWithUser, error) {
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
@@ -274,6 +889,11 @@ func (r *BookingRepository) queryWithUsers(query string, args ...interface{}) ([
 	}
 	defer rows.Close()
 
+	return scanBookingsWithUsers(rows)
+}
+
+// scanBookingsWithUsers reads rows selected with the columns of queryWithUsers.
+func scanBookingsWithUsers(rows *sql.Rows) ([]*BookingWithUser, error) {
 	var bookings []*BookingWithUser
 	for rows.Next() {
 		var booking BookingWithUser
@@ -288,6 +908,10 @@ func (r *BookingRepository) queryWithUsers(query string, args ...interface{}) ([
 			&booking.UserLastName,
 			&booking.UserEmail,
 			&booking.UserSubType,
+			&booking.ServiceID,
+			&booking.ServiceName,
+			&booking.CapacityWeight,
+			&booking.DurationMinutes,
 		)
 		if err != nil {
 			return nil, err
@@ -298,40 +922,55 @@ func (r *BookingRepository) queryWithUsers(query string, args ...interface{}) ([
 	return bookings, rows.Err()
 }
 
-func bookingLockKey(instructorID int64, startsAt time.Time) int64 {
+// bookingLockKey serializes bookings of the same instructor on the same day of
+// loc, the time zone of their location, since bookings with different start
+// times can still overlap.
+func bookingLockKey(instructorID int64, startsAt time.Time, loc *time.Location) int64 {
 	h := fnv.New64a()
-	fmt.Fprintf(h, "%d:%d", instructorID, startsAt.Unix())
+	fmt.Fprintf(h, "%d:%s", instructorID, startsAt.In(loc).Format("2006-01-02"))
 	return int64(h.Sum64())
 }
 
+// instructorTimeZoneTx returns the time zone of the location of an
+// instructor, the business one when the instructor does not exist so that
+// the checks after the lock report it.
+func instructorTimeZoneTx(tx *sql.Tx, instructorID int64) (*time.Location, error) {
+	var name string
+	err := tx.QueryRow(`
+		SELECT l.time_zone FROM instructors i JOIN locations l ON l.id = i.location_id WHERE i.id = $1
+	`, instructorID).Scan(&name)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return LoadTimeZone(name), nil
+}
+
+// lockInstructorDayTx takes the advisory lock of the day of startsAt for an
+// instructor.
+func lockInstructorDayTx(tx *sql.Tx, instructorID int64, startsAt time.Time) error {
+	loc, err := instructorTimeZoneTx(tx, instructorID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, bookingLockKey(instructorID, startsAt, loc))
+	return err
+}
+
 func (r *BookingRepository) GetByID(id int64) (*Booking, error) {
 	query := `
-		SELECT id, user_id, instructor_id, created_at, starts_at, type
+		SELECT ` + bookingColumns + `
 		FROM bookings
-		WHERE id = $1
+		WHERE id = $1 AND cancelled_at IS NULL
 	`
 
-	var booking Booking
-	err := r.db.QueryRow(query, id).Scan(
-		&booking.ID,
-		&booking.UserID,
-		&booking.InstructorID,
-		&booking.CreatedAt,
-		&booking.StartsAt,
-		&booking.Type,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &booking, nil
+	return scanBooking(r.db.QueryRow(query, id))
 }
 
 func (r *BookingRepository) GetByDateRange(from, to time.Time) ([]*Booking, error) {
 	query := `
-		SELECT id, user_id, instructor_id, created_at, starts_at, type
+		SELECT ` + bookingColumns + `
 		FROM bookings
-		WHERE starts_at >= $1 AND starts_at <= $2
+		WHERE starts_at >= $1 AND starts_at <= $2 AND cancelled_at IS NULL
 		ORDER BY starts_at ASC
 	`
 
@@ -343,19 +982,11 @@ func (r *BookingRepository) GetByDateRange(from, to time.Time) ([]*Booking, erro
 
 	var bookings []*Booking
 	for rows.Next() {
-		var booking Booking
-		err := rows.Scan(
-			&booking.ID,
-			&booking.UserID,
-			&booking.InstructorID,
-			&booking.CreatedAt,
-			&booking.StartsAt,
-			&booking.Type,
-		)
+		booking, err := scanBooking(rows)
 		if err != nil {
 			return nil, err
 		}
-		bookings = append(bookings, &booking)
+		bookings = append(bookings, booking)
 	}
 
 	return bookings, rows.Err()
@@ -363,9 +994,9 @@ func (r *BookingRepository) GetByDateRange(from, to time.Time) ([]*Booking, erro
 
 func (r *BookingRepository) GetByInstructorAndDateRange(instructorID string, from, to time.Time) ([]*Booking, error) {
 	query := `
-		SELECT id, user_id, instructor_id, created_at, starts_at, type
+		SELECT ` + bookingColumns + `
 		FROM bookings
-		WHERE instructor_id = $1 AND starts_at >= $2 AND starts_at <= $3
+		WHERE instructor_id = $1 AND starts_at >= $2 AND starts_at <= $3 AND cancelled_at IS NULL
 		ORDER BY starts_at ASC
 	`
 
@@ -377,19 +1008,11 @@ func (r *BookingRepository) GetByInstructorAndDateRange(instructorID string, fro
 
 	var bookings []*Booking
 	for rows.Next() {
-		var booking Booking
-		err := rows.Scan(
-			&booking.ID,
-			&booking.UserID,
-			&booking.InstructorID,
-			&booking.CreatedAt,
-			&booking.StartsAt,
-			&booking.Type,
-		)
+		booking, err := scanBooking(rows)
 		if err != nil {
 			return nil, err
 		}
-		bookings = append(bookings, &booking)
+		bookings = append(bookings, booking)
 	}
 
 	return bookings, rows.Err()
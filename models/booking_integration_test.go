@@ -2,6 +2,7 @@ package models_test
 
 import (
 	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -169,7 +170,7 @@ func TestBookingRepository_Integration(t *testing.T) {
 		}
 	})
 
-	t.Run("Delete Booking", func(t *testing.T) {
+	t.Run("Cancel Booking", func(t *testing.T) {
 		testutil.TruncateTables(t, db, "bookings")
 
 		booking := &models.Booking{
@@ -187,17 +188,908 @@ func TestBookingRepository_Integration(t *testing.T) {
 			t.Fatalf("Failed to create booking: %v", err)
 		}
 
-		// Delete booking
-		err = bookingRepo.Delete(booking.ID)
+		before, err := userRepo.GetByID(user.ID)
 		if err != nil {
-			t.Fatalf("Failed to delete booking: %v", err)
+			t.Fatalf("Failed to get user: %v", err)
 		}
 
-		// Try to retrieve deleted booking
+		cancelled, err := bookingRepo.Cancel(booking.ID, models.Cancellation{
+			Status:   models.BookingStatusCancelledByUser,
+			By:       user.ID,
+			Refunded: true,
+		})
+		if err != nil {
+			t.Fatalf("Failed to cancel booking: %v", err)
+		}
+		if cancelled.Status != models.BookingStatusCancelledByUser || !cancelled.CancelledAt.Valid {
+			t.Errorf("Expected booking cancelled by user, got %+v", cancelled)
+		}
+
+		// A cancelled booking is no longer active
 		_, err = bookingRepo.GetByID(booking.ID)
 		if err != sql.ErrNoRows {
 			t.Errorf("Expected ErrNoRows, got %v", err)
 		}
+		if _, err := bookingRepo.Cancel(booking.ID, models.Cancellation{Status: models.BookingStatusCancelledByAdmin}); err != sql.ErrNoRows {
+			t.Errorf("Expected ErrNoRows cancelling twice, got %v", err)
+		}
+
+		after, err := userRepo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		if after.RemainingAccesses != before.RemainingAccesses+1 {
+			t.Errorf("Expected one access refunded: %d, got %d", before.RemainingAccesses+1, after.RemainingAccesses)
+		}
+
+		// It stays in the member's history with who cancelled it
+		history, err := bookingRepo.GetHistoryByUserID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get history: %v", err)
+		}
+		if len(history) != 1 || history[0].CancelledBy.String != user.ID || !history[0].Refunded.Bool {
+			t.Errorf("Expected the cancelled booking in the history, got %v", history)
+		}
+
+		// The slot can be booked again
+		again := &models.Booking{
+			UserID:       booking.UserID,
+			InstructorID: booking.InstructorID,
+			StartsAt:     booking.StartsAt,
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.Create(again); err != nil {
+			t.Errorf("Failed to book the cancelled slot again: %v", err)
+		}
+	})
+
+	t.Run("Shared Resource Across Instructors", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "resources", "services")
+
+		other := &models.Instructor{FirstName: "Other", LastName: "Instructor", MaxSlots: 5, Enabled: true}
+		if err := instructorRepo.Create(other); err != nil {
+			t.Fatalf("Failed to create second instructor: %v", err)
+		}
+
+		resourceRepo := models.NewResourceRepository(db)
+		reformer := &models.Resource{Name: "Reformer", Kind: models.ResourceKindEquipment, Capacity: 1, Enabled: true}
+		if err := resourceRepo.Create(reformer); err != nil {
+			t.Fatalf("Failed to create resource: %v", err)
+		}
+
+		service := &models.Service{
+			Name:            "Reformer",
+			DurationMinutes: 60,
+			CapacityWeight:  1,
+			Enabled:         true,
+			Resources:       []models.ResourceRequirement{{ResourceID: reformer.ID, Quantity: 1}},
+		}
+		if err := models.NewServiceRepository(db).Create(service); err != nil {
+			t.Fatalf("Failed to create service: %v", err)
+		}
+
+		startsAt := time.Now().Add(48 * time.Hour).Truncate(time.Hour).UTC()
+		first := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     startsAt,
+			Type:         models.BookingTypeSimple,
+			ServiceID:    sql.NullInt64{Int64: service.ID, Valid: true},
+		}
+		if err := bookingRepo.CreateUserBooking(first, 1, instructor.MaxSlots); err != nil {
+			t.Fatalf("Failed to create first booking: %v", err)
+		}
+
+		// Another member: the other instructor has capacity but the only reformer is taken
+		member := &models.User{
+			ID:                uuid.New().String(),
+			FirstName:         "Other",
+			LastName:          "User",
+			Email:             "other@example.com",
+			Role:              models.RoleUser,
+			SubType:           models.SubTypeSingle,
+			ExpiresAt:         time.Now().Add(30 * 24 * time.Hour),
+			RemainingAccesses: 10,
+		}
+		if err := userRepo.Create(member); err != nil {
+			t.Fatalf("Failed to create second user: %v", err)
+		}
+		second := &models.Booking{
+			UserID:       sql.NullString{String: member.ID, Valid: true},
+			InstructorID: other.ID,
+			StartsAt:     startsAt.Add(30 * time.Minute),
+			Type:         models.BookingTypeSimple,
+			ServiceID:    sql.NullInt64{Int64: service.ID, Valid: true},
+		}
+		if err := bookingRepo.CreateUserBooking(second, 1, other.MaxSlots); !errors.Is(err, models.ErrResourceUnavailable) {
+			t.Fatalf("Expected ErrResourceUnavailable, got %v", err)
+		}
+
+		second.StartsAt = startsAt.Add(time.Hour)
+		if err := bookingRepo.CreateUserBooking(second, 1, other.MaxSlots); err != nil {
+			t.Fatalf("Expected booking after the first to succeed, got %v", err)
+		}
+	})
+
+	t.Run("User Overlap Across Instructors", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings")
+
+		other := &models.Instructor{FirstName: "Third", LastName: "Instructor", MaxSlots: 5, Enabled: true}
+		if err := instructorRepo.Create(other); err != nil {
+			t.Fatalf("Failed to create second instructor: %v", err)
+		}
+
+		startsAt := time.Now().Add(72 * time.Hour).Truncate(time.Hour).UTC()
+		first := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     startsAt,
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.CreateUserBooking(first, 1, instructor.MaxSlots); err != nil {
+			t.Fatalf("Failed to create first booking: %v", err)
+		}
+
+		second := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: other.ID,
+			StartsAt:     startsAt.Add(30 * time.Minute),
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.CreateUserBooking(second, 1, other.MaxSlots); !errors.Is(err, models.ErrUserOverlap) {
+			t.Fatalf("Expected ErrUserOverlap, got %v", err)
+		}
+
+		appointment := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: other.ID,
+			StartsAt:     startsAt,
+			Type:         models.BookingTypeAppointment,
+		}
+		if err := bookingRepo.Create(appointment); !errors.Is(err, models.ErrUserOverlap) {
+			t.Fatalf("Expected ErrUserOverlap from admin creation, got %v", err)
+		}
+
+		appointment.AllowOverlap = true
+		if err := bookingRepo.Create(appointment); err != nil {
+			t.Fatalf("Expected overridden booking to succeed, got %v", err)
+		}
+
+		second.StartsAt = startsAt.Add(time.Hour)
+		if err := bookingRepo.CreateUserBooking(second, 1, other.MaxSlots); err != nil {
+			t.Fatalf("Expected booking after the first to succeed, got %v", err)
+		}
+	})
+
+	t.Run("Reschedule Within Booking Limits", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "booking_limits")
+		defer testutil.TruncateTables(t, db, "booking_limits")
+		if _, err := db.Exec(`UPDATE users SET remaining_accesses = 10 WHERE id = $1`, user.ID); err != nil {
+			t.Fatalf("Failed to reset accesses: %v", err)
+		}
+
+		limitRepo := models.NewBookingLimitRepository(db)
+		if err := limitRepo.Save(&models.BookingLimit{SubType: models.SubTypeSingle, MaxPerDay: 1}); err != nil {
+			t.Fatalf("Failed to save limit: %v", err)
+		}
+
+		loc := models.LoadTimeZone(models.BusinessTimeZone)
+		day := time.Now().In(loc).AddDate(0, 0, 3)
+		morning := time.Date(day.Year(), day.Month(), day.Day(), 10, 0, 0, 0, loc).UTC()
+		first := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     morning,
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.CreateUserBooking(first, 1, instructor.MaxSlots); err != nil {
+			t.Fatalf("Failed to create first booking: %v", err)
+		}
+		second := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     morning.AddDate(0, 0, 1),
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.CreateUserBooking(second, 1, instructor.MaxSlots); err != nil {
+			t.Fatalf("Failed to create second booking: %v", err)
+		}
+
+		// Moving into a day already at its limit is refused
+		if _, err := bookingRepo.Reschedule(second.ID, instructor.ID, morning.Add(2*time.Hour), 1, instructor.MaxSlots); !errors.Is(err, models.ErrDailyLimit) {
+			t.Errorf("Expected ErrDailyLimit, got %v", err)
+		}
+		// Moving within the same day does not count the booking twice
+		if _, err := bookingRepo.Reschedule(first.ID, instructor.ID, morning.Add(2*time.Hour), 1, instructor.MaxSlots); err != nil {
+			t.Errorf("Expected a move within the day to succeed, got %v", err)
+		}
+	})
+
+	t.Run("User Overlap With Classes", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "class_enrollments", "class_sessions", "class_templates")
+		if _, err := db.Exec(`UPDATE users SET remaining_accesses = 10 WHERE id = $1`, user.ID); err != nil {
+			t.Fatalf("Failed to reset accesses: %v", err)
+		}
+
+		classRepo := models.NewClassRepository(db)
+		teacher := &models.Instructor{FirstName: "Class", LastName: "Instructor", MaxSlots: 5, Enabled: true}
+		if err := instructorRepo.Create(teacher); err != nil {
+			t.Fatalf("Failed to create class instructor: %v", err)
+		}
+		template := &models.ClassTemplate{Title: "Pilates", Room: "Sala 1", Capacity: 10, DurationMinutes: 60}
+		if err := classRepo.CreateTemplate(template); err != nil {
+			t.Fatalf("Failed to create class template: %v", err)
+		}
+
+		startsAt := time.Now().Add(120 * time.Hour).Truncate(time.Hour).UTC()
+		booking := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     startsAt,
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.CreateUserBooking(booking, 1, instructor.MaxSlots); err != nil {
+			t.Fatalf("Failed to create booking: %v", err)
+		}
+
+		overlapping := &models.ClassSession{TemplateID: template.ID, InstructorID: teacher.ID, Room: template.Room, Capacity: 10, StartsAt: startsAt.Add(30 * time.Minute), DurationMinutes: 60}
+		later := &models.ClassSession{TemplateID: template.ID, InstructorID: teacher.ID, Room: template.Room, Capacity: 10, StartsAt: startsAt.Add(3 * time.Hour), DurationMinutes: 60}
+		for _, session := range []*models.ClassSession{overlapping, later} {
+			if err := classRepo.ScheduleSession(session); err != nil {
+				t.Fatalf("Failed to schedule session: %v", err)
+			}
+		}
+
+		if err := classRepo.Enroll(overlapping.ID, user.ID); !errors.Is(err, models.ErrUserOverlap) {
+			t.Errorf("Expected ErrUserOverlap enrolling during a booking, got %v", err)
+		}
+		if err := classRepo.Enroll(later.ID, user.ID); err != nil {
+			t.Fatalf("Failed to enroll: %v", err)
+		}
+
+		during := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     later.StartsAt,
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.CreateUserBooking(during, 1, instructor.MaxSlots); !errors.Is(err, models.ErrUserOverlap) {
+			t.Errorf("Expected ErrUserOverlap booking during a class, got %v", err)
+		}
+	})
+
+	t.Run("Class Enrollments Count Toward Booking Limits", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "booking_limits", "class_enrollments", "class_sessions", "class_templates")
+		defer testutil.TruncateTables(t, db, "booking_limits")
+		if _, err := db.Exec(`UPDATE users SET remaining_accesses = 10 WHERE id = $1`, user.ID); err != nil {
+			t.Fatalf("Failed to reset accesses: %v", err)
+		}
+
+		limitRepo := models.NewBookingLimitRepository(db)
+		if err := limitRepo.Save(&models.BookingLimit{SubType: models.SubTypeSingle, MaxPerDay: 1}); err != nil {
+			t.Fatalf("Failed to save limit: %v", err)
+		}
+
+		classRepo := models.NewClassRepository(db)
+		teacher := &models.Instructor{FirstName: "Limit", LastName: "Teacher", MaxSlots: 5, Enabled: true}
+		if err := instructorRepo.Create(teacher); err != nil {
+			t.Fatalf("Failed to create class instructor: %v", err)
+		}
+		template := &models.ClassTemplate{Title: "Yoga", Room: "Sala 2", Capacity: 10, DurationMinutes: 60}
+		if err := classRepo.CreateTemplate(template); err != nil {
+			t.Fatalf("Failed to create class template: %v", err)
+		}
+
+		loc := models.LoadTimeZone(models.BusinessTimeZone)
+		day := time.Now().In(loc).AddDate(0, 0, 3)
+		morning := time.Date(day.Year(), day.Month(), day.Day(), 9, 0, 0, 0, loc).UTC()
+		first := &models.ClassSession{TemplateID: template.ID, InstructorID: teacher.ID, Room: template.Room, Capacity: 10, StartsAt: morning, DurationMinutes: 60}
+		second := &models.ClassSession{TemplateID: template.ID, InstructorID: teacher.ID, Room: template.Room, Capacity: 10, StartsAt: morning.Add(3 * time.Hour), DurationMinutes: 60}
+		for _, session := range []*models.ClassSession{first, second} {
+			if err := classRepo.ScheduleSession(session); err != nil {
+				t.Fatalf("Failed to schedule session: %v", err)
+			}
+		}
+
+		if err := classRepo.Enroll(first.ID, user.ID); err != nil {
+			t.Fatalf("Failed to enroll: %v", err)
+		}
+		if err := classRepo.Enroll(second.ID, user.ID); !errors.Is(err, models.ErrDailyLimit) {
+			t.Errorf("Expected ErrDailyLimit for a second class, got %v", err)
+		}
+		booking := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     morning.Add(5 * time.Hour),
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.CreateUserBooking(booking, 1, instructor.MaxSlots); !errors.Is(err, models.ErrDailyLimit) {
+			t.Errorf("Expected ErrDailyLimit for a booking on a class day, got %v", err)
+		}
+	})
+
+	t.Run("Block Cancels And Refunds Overlapping Bookings", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "events")
+
+		startsAt := time.Now().Add(96 * time.Hour).Truncate(time.Hour).UTC()
+		booking := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     startsAt,
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.CreateUserBooking(booking, 1, instructor.MaxSlots); err != nil {
+			t.Fatalf("Failed to create booking: %v", err)
+		}
+		before, err := userRepo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+
+		block := &models.Booking{InstructorID: instructor.ID, StartsAt: startsAt, Type: models.BookingTypeDisable}
+		affected, err := bookingRepo.CreateBlockCancelling(block, false, "")
+		if !errors.Is(err, models.ErrBlockCollision) {
+			t.Fatalf("Expected ErrBlockCollision, got %v", err)
+		}
+		if len(affected) != 1 || affected[0].ID != booking.ID {
+			t.Fatalf("Expected the booking to be reported, got %v", affected)
+		}
+		if _, err := bookingRepo.GetByID(booking.ID); err != nil {
+			t.Fatalf("Expected booking kept without confirmation, got %v", err)
+		}
+
+		affected, err = bookingRepo.CreateBlockCancelling(block, true, "")
+		if err != nil {
+			t.Fatalf("Failed to create confirmed block: %v", err)
+		}
+		if len(affected) != 1 {
+			t.Errorf("Expected 1 cancelled booking, got %d", len(affected))
+		}
+		if _, err := bookingRepo.GetByID(booking.ID); err != sql.ErrNoRows {
+			t.Errorf("Expected booking cancelled, got %v", err)
+		}
+
+		after, err := userRepo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		if after.RemainingAccesses != before.RemainingAccesses+1 {
+			t.Errorf("Expected access refunded: %d, got %d", before.RemainingAccesses+1, after.RemainingAccesses)
+		}
+
+		// The cancellation event is written with the block
+		events, err := models.NewEventRepository(db).GetCancellationsByUserID(user.ID, 10)
+		if err != nil {
+			t.Fatalf("Failed to get events: %v", err)
+		}
+		if len(events) != 1 || !events[0].Refunded.Bool {
+			t.Errorf("Expected one refunded cancellation event, got %+v", events)
+		}
+	})
+
+	t.Run("Reassign Bookings To Substitute", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings")
+
+		substitute := &models.Instructor{FirstName: "Substitute", LastName: "Instructor", MaxSlots: 5, Enabled: true}
+		if err := instructorRepo.Create(substitute); err != nil {
+			t.Fatalf("Failed to create substitute: %v", err)
+		}
+
+		loc := models.LoadTimeZone(models.BusinessTimeZone)
+		day := time.Now().In(loc).AddDate(0, 0, 7)
+		for day.Weekday() != time.Tuesday {
+			day = day.AddDate(0, 0, 1)
+		}
+		tuesday := time.Date(day.Year(), day.Month(), day.Day(), 10, 0, 0, 0, loc)
+		sunday := tuesday.AddDate(0, 0, 5)
+
+		inSchedule := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     tuesday.UTC(),
+			Type:         models.BookingTypeSimple,
+		}
+		outsideSchedule := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     sunday.UTC(),
+			Type:         models.BookingTypeSimple,
+		}
+		for _, b := range []*models.Booking{inSchedule, outsideSchedule} {
+			if err := bookingRepo.Create(b); err != nil {
+				t.Fatalf("Failed to create booking: %v", err)
+			}
+		}
+
+		result, err := bookingRepo.Reassign(models.Reassignment{
+			FromInstructorID: instructor.ID,
+			ToInstructorID:   substitute.ID,
+			From:             tuesday.AddDate(0, 0, -1).UTC(),
+			To:               sunday.AddDate(0, 0, 1).UTC(),
+			Schedule:         models.DefaultWeeklySchedule(),
+			Location:         loc,
+			MaxSlots:         substitute.MaxSlots,
+		})
+		if err != nil {
+			t.Fatalf("Failed to reassign bookings: %v", err)
+		}
+		if len(result.Moved) != 1 || result.Moved[0].ID != inSchedule.ID {
+			t.Errorf("Expected the Tuesday booking to move, got %v", result.Moved)
+		}
+		if len(result.Conflicts) != 1 || !errors.Is(result.Conflicts[0].Err, models.ErrOutsideSchedule) {
+			t.Errorf("Expected the Sunday booking outside the schedule, got %v", result.Conflicts)
+		}
+
+		moved, err := bookingRepo.GetByID(inSchedule.ID)
+		if err != nil {
+			t.Fatalf("Failed to get booking: %v", err)
+		}
+		if moved.InstructorID != substitute.ID {
+			t.Errorf("Expected instructor %d, got %d", substitute.ID, moved.InstructorID)
+		}
+	})
+
+	t.Run("Archive Instructor Keeps History", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings")
+
+		leaving := &models.Instructor{FirstName: "Leaving", LastName: "Instructor", MaxSlots: 2, Enabled: true}
+		if err := instructorRepo.Create(leaving); err != nil {
+			t.Fatalf("Failed to create instructor: %v", err)
+		}
+
+		past := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: leaving.ID,
+			StartsAt:     time.Now().Add(-48 * time.Hour).Truncate(time.Hour).UTC(),
+			Type:         models.BookingTypeSimple,
+		}
+		upcoming := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: leaving.ID,
+			StartsAt:     time.Now().Add(48 * time.Hour).Truncate(time.Hour).UTC(),
+			Type:         models.BookingTypeSimple,
+		}
+		for _, b := range []*models.Booking{past, upcoming} {
+			if err := bookingRepo.Create(b); err != nil {
+				t.Fatalf("Failed to create booking: %v", err)
+			}
+		}
+
+		blocking, err := instructorRepo.Archive(leaving.ID, time.Now())
+		if !errors.Is(err, models.ErrInstructorHasBookings) {
+			t.Fatalf("Expected ErrInstructorHasBookings, got %v", err)
+		}
+		if len(blocking) != 1 || blocking[0].ID != upcoming.ID {
+			t.Errorf("Expected the upcoming booking to block archiving, got %v", blocking)
+		}
+
+		if _, err := bookingRepo.Cancel(upcoming.ID, models.Cancellation{Status: models.BookingStatusCancelledByAdmin}); err != nil {
+			t.Fatalf("Failed to cancel booking: %v", err)
+		}
+		if _, err := instructorRepo.Archive(leaving.ID, time.Now()); err != nil {
+			t.Fatalf("Failed to archive instructor: %v", err)
+		}
+
+		archived, err := instructorRepo.GetByID(leaving.ID)
+		if err != nil {
+			t.Fatalf("Failed to get instructor: %v", err)
+		}
+		if archived.ArchivedAt == nil || archived.Enabled {
+			t.Errorf("Expected instructor archived and disabled, got %+v", archived)
+		}
+		if _, err := bookingRepo.GetByID(past.ID); err != nil {
+			t.Errorf("Expected past booking kept, got %v", err)
+		}
+		if err := instructorRepo.Update(archived); !errors.Is(err, models.ErrInstructorArchived) {
+			t.Errorf("Expected ErrInstructorArchived on update, got %v", err)
+		}
+
+		late := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: leaving.ID,
+			StartsAt:     time.Now().Add(72 * time.Hour).Truncate(time.Hour).UTC(),
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.CreateUserBooking(late, 1, leaving.MaxSlots); !errors.Is(err, models.ErrSlotUnavailable) {
+			t.Errorf("Expected ErrSlotUnavailable booking an archived instructor, got %v", err)
+		}
+	})
+
+	t.Run("Service Wallet", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "services")
+
+		serviceRepo := models.NewServiceRepository(db)
+		walletRepo := models.NewWalletRepository(db)
+		massage := &models.Service{Name: "Massaggio", DurationMinutes: 60, CapacityWeight: 1, Enabled: true}
+		if err := serviceRepo.Create(massage); err != nil {
+			t.Fatalf("Failed to create service: %v", err)
+		}
+
+		before, err := userRepo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		if _, err := walletRepo.TopUp(user.ID, massage.ID, 2, time.Now().AddDate(0, 1, 0), ""); err != nil {
+			t.Fatalf("Failed to top up wallet: %v", err)
+		}
+
+		booking := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     time.Now().Add(96 * time.Hour).Truncate(time.Hour).UTC(),
+			Type:         models.BookingTypeSimple,
+			ServiceID:    sql.NullInt64{Int64: massage.ID, Valid: true},
+		}
+		if err := bookingRepo.CreateUserBooking(booking, 1, instructor.MaxSlots); err != nil {
+			t.Fatalf("Failed to book the service: %v", err)
+		}
+
+		wallet, err := walletRepo.GetForService(user.ID, massage.ID)
+		if err != nil {
+			t.Fatalf("Failed to get wallet: %v", err)
+		}
+		after, err := userRepo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		if wallet.Remaining != 1 || after.RemainingAccesses != before.RemainingAccesses {
+			t.Errorf("Expected the wallet to pay (1 left, general %d), got %d left, general %d",
+				before.RemainingAccesses, wallet.Remaining, after.RemainingAccesses)
+		}
+
+		// The refund goes back to the wallet that paid
+		if _, err := bookingRepo.Cancel(booking.ID, models.Cancellation{Status: models.BookingStatusCancelledByUser, By: user.ID, Refunded: true}); err != nil {
+			t.Fatalf("Failed to cancel booking: %v", err)
+		}
+		wallet, err = walletRepo.GetForService(user.ID, massage.ID)
+		if err != nil {
+			t.Fatalf("Failed to get wallet: %v", err)
+		}
+		if wallet.Remaining != 2 {
+			t.Errorf("Expected 2 accesses back in the wallet, got %d", wallet.Remaining)
+		}
+
+		// An expired wallet falls back to the general balance
+		if _, err := walletRepo.TopUp(user.ID, massage.ID, 0, time.Now().AddDate(0, 0, -2), ""); err != nil {
+			t.Fatalf("Failed to move wallet expiry: %v", err)
+		}
+		booking.StartsAt = booking.StartsAt.Add(time.Hour)
+		if err := bookingRepo.CreateUserBooking(booking, 1, instructor.MaxSlots); err != nil {
+			t.Fatalf("Expected the general balance to pay with an expired wallet, got %v", err)
+		}
+		after, err = userRepo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		wallet, err = walletRepo.GetForService(user.ID, massage.ID)
+		if err != nil {
+			t.Fatalf("Failed to get wallet: %v", err)
+		}
+		if wallet.Remaining != 2 || after.RemainingAccesses != before.RemainingAccesses-1 {
+			t.Errorf("Expected the general balance to pay (wallet 2, general %d), got wallet %d, general %d",
+				before.RemainingAccesses-1, wallet.Remaining, after.RemainingAccesses)
+		}
+
+		if err := serviceRepo.Delete(massage.ID); !errors.Is(err, models.ErrServiceInUse) {
+			t.Errorf("Expected ErrServiceInUse, got %v", err)
+		}
+	})
+
+	t.Run("Late Cancellations Count From Bookings", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "events")
+
+		booking := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     time.Now().Add(2 * time.Hour).Truncate(time.Minute).UTC(),
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.Create(booking); err != nil {
+			t.Fatalf("Failed to create booking: %v", err)
+		}
+		if _, err := bookingRepo.Cancel(booking.ID, models.Cancellation{Status: models.BookingStatusCancelledByUser, By: user.ID, Refunded: true, Late: true}); err != nil {
+			t.Fatalf("Failed to cancel booking: %v", err)
+		}
+
+		// No event was written: the booking alone records the late cancellation
+		count, err := models.NewEventRepository(db).CountLateRefunds(user.ID, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to count late refunds: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected 1 late refund, got %d", count)
+		}
+
+		stats, err := models.NewAttendanceRepository(db).GetUserStats(user.ID, time.Now().AddDate(-1, 0, 0))
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.LateCancels != 1 {
+			t.Errorf("Expected 1 late cancellation, got %d", stats.LateCancels)
+		}
+	})
+
+	t.Run("Cancel By Policy", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "events")
+
+		policy := &models.CancellationPolicy{CutoffHours: 3, LateRefund: models.LateRefundPartial, FreeLateCancels: 1}
+		decide := func(b *models.Booking, lateRefundsUsed int) models.CancellationDecision {
+			return policy.Decide(b.StartsAt, time.Now(), lateRefundsUsed)
+		}
+
+		// Only the first late cancellation of the month is refunded
+		for i, want := range []bool{true, false} {
+			booking := &models.Booking{
+				UserID:       sql.NullString{String: user.ID, Valid: true},
+				InstructorID: instructor.ID,
+				StartsAt:     time.Now().Add(time.Duration(i+1) * time.Hour).Truncate(time.Minute).UTC(),
+				Type:         models.BookingTypeSimple,
+			}
+			if err := bookingRepo.Create(booking); err != nil {
+				t.Fatalf("Failed to create booking: %v", err)
+			}
+			cancelled, err := bookingRepo.CancelByPolicy(booking.ID, models.BookingStatusCancelledByUser, user.ID, decide)
+			if err != nil {
+				t.Fatalf("Failed to cancel booking: %v", err)
+			}
+			if !cancelled.Decision.Late || cancelled.Decision.Refund != want {
+				t.Errorf("Cancellation %d: expected late with refund %v, got %+v", i+1, want, cancelled.Decision)
+			}
+		}
+
+		events, err := models.NewEventRepository(db).GetAll()
+		if err != nil {
+			t.Fatalf("Failed to get events: %v", err)
+		}
+		if len(events) != 2 {
+			t.Errorf("Expected 2 cancellation events, got %d", len(events))
+		}
+
+		// A booking without a member is cancelled without a decision
+		block := &models.Booking{
+			InstructorID: instructor.ID,
+			StartsAt:     time.Now().Add(5 * time.Hour).Truncate(time.Minute).UTC(),
+			Type:         models.BookingTypeSimple,
+		}
+		if err := bookingRepo.Create(block); err != nil {
+			t.Fatalf("Failed to create booking: %v", err)
+		}
+		cancelled, err := bookingRepo.CancelByPolicy(block.ID, models.BookingStatusCancelledByAdmin, "", func(*models.Booking, int) models.CancellationDecision {
+			t.Error("Expected no decision for a booking without a member")
+			return models.CancellationDecision{}
+		})
+		if err != nil {
+			t.Fatalf("Failed to cancel booking: %v", err)
+		}
+		if cancelled.Refunded.Valid {
+			t.Errorf("Expected no refund outcome, got %v", cancelled.Refunded)
+		}
+		if _, err := bookingRepo.CancelByPolicy(block.ID, models.BookingStatusCancelledByAdmin, "", decide); err != sql.ErrNoRows {
+			t.Errorf("Expected sql.ErrNoRows cancelling twice, got %v", err)
+		}
+	})
+
+	t.Run("Capacity Weight Snapshot", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "services")
+
+		serviceRepo := models.NewServiceRepository(db)
+		heavy := &models.Service{Name: "Circuito", DurationMinutes: 60, CapacityWeight: 3, Enabled: true}
+		if err := serviceRepo.Create(heavy); err != nil {
+			t.Fatalf("Failed to create service: %v", err)
+		}
+
+		booking := &models.Booking{
+			UserID:          sql.NullString{String: user.ID, Valid: true},
+			InstructorID:    instructor.ID,
+			StartsAt:        time.Now().Add(24 * time.Hour).Truncate(time.Hour).UTC(),
+			Type:            models.BookingTypeSimple,
+			ServiceID:       sql.NullInt64{Int64: heavy.ID, Valid: true},
+			DurationMinutes: heavy.DurationMinutes,
+		}
+		if err := bookingRepo.Create(booking); err != nil {
+			t.Fatalf("Failed to create booking: %v", err)
+		}
+
+		// Editing the service leaves the weight of existing bookings alone
+		heavy.CapacityWeight = 1
+		if err := serviceRepo.Update(heavy); err != nil {
+			t.Fatalf("Failed to update service: %v", err)
+		}
+
+		bookings, err := bookingRepo.GetWithUsersByDateRange(booking.StartsAt.Add(-time.Minute), booking.StartsAt.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to get bookings: %v", err)
+		}
+		if len(bookings) != 1 || bookings[0].CapacityWeight != 3 {
+			t.Errorf("Expected one booking weighing 3, got %+v", bookings)
+		}
+	})
+
+	t.Run("Class Session Holds Its Room", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "class_enrollments", "class_sessions", "class_templates", "resources", "services")
+
+		room := &models.Resource{Name: "Sala 1", Kind: models.ResourceKindRoom, Capacity: 1, Enabled: true}
+		if err := models.NewResourceRepository(db).Create(room); err != nil {
+			t.Fatalf("Failed to create resource: %v", err)
+		}
+		service := &models.Service{
+			Name:            "Pilates privato",
+			DurationMinutes: 60,
+			CapacityWeight:  1,
+			Enabled:         true,
+			Resources:       []models.ResourceRequirement{{ResourceID: room.ID, Quantity: 1}},
+		}
+		if err := models.NewServiceRepository(db).Create(service); err != nil {
+			t.Fatalf("Failed to create service: %v", err)
+		}
+
+		classRepo := models.NewClassRepository(db)
+		teacher := &models.Instructor{FirstName: "Room", LastName: "Instructor", MaxSlots: 5, Enabled: true}
+		if err := instructorRepo.Create(teacher); err != nil {
+			t.Fatalf("Failed to create class instructor: %v", err)
+		}
+		template := &models.ClassTemplate{Title: "Pilates", Room: room.Name, ResourceID: sql.NullInt64{Int64: room.ID, Valid: true}, Capacity: 10, DurationMinutes: 60}
+		if err := classRepo.CreateTemplate(template); err != nil {
+			t.Fatalf("Failed to create class template: %v", err)
+		}
+
+		startsAt := time.Now().Add(144 * time.Hour).Truncate(time.Hour).UTC()
+		session := &models.ClassSession{TemplateID: template.ID, InstructorID: teacher.ID, Room: template.Room, ResourceID: template.ResourceID, Capacity: 10, StartsAt: startsAt, DurationMinutes: 60}
+		if err := classRepo.ScheduleSession(session); err != nil {
+			t.Fatalf("Failed to schedule session: %v", err)
+		}
+
+		// Bookings of other instructors cannot take the room while the class runs
+		booking := &models.Booking{
+			UserID:       sql.NullString{String: user.ID, Valid: true},
+			InstructorID: instructor.ID,
+			StartsAt:     startsAt.Add(30 * time.Minute),
+			Type:         models.BookingTypeSimple,
+			ServiceID:    sql.NullInt64{Int64: service.ID, Valid: true},
+		}
+		if err := bookingRepo.CreateUserBooking(booking, 1, instructor.MaxSlots); !errors.Is(err, models.ErrResourceUnavailable) {
+			t.Errorf("Expected ErrResourceUnavailable booking the room during a class, got %v", err)
+		}
+
+		// Nor can a session be scheduled in the room once it is booked
+		booking.StartsAt = startsAt.Add(2 * time.Hour)
+		if err := bookingRepo.CreateUserBooking(booking, 1, instructor.MaxSlots); err != nil {
+			t.Fatalf("Failed to create booking after the class: %v", err)
+		}
+		other := &models.Instructor{FirstName: "Other", LastName: "Room Instructor", MaxSlots: 5, Enabled: true}
+		if err := instructorRepo.Create(other); err != nil {
+			t.Fatalf("Failed to create second class instructor: %v", err)
+		}
+		clash := &models.ClassSession{TemplateID: template.ID, InstructorID: other.ID, Room: template.Room, ResourceID: template.ResourceID, Capacity: 10, StartsAt: booking.StartsAt, DurationMinutes: 60}
+		if err := classRepo.ScheduleSession(clash); !errors.Is(err, models.ErrResourceUnavailable) {
+			t.Errorf("Expected ErrResourceUnavailable scheduling a class in a booked room, got %v", err)
+		}
+	})
+
+	t.Run("Late Class Cancellations Count Toward Allowance", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "events", "class_enrollments", "class_sessions", "class_templates")
+		if _, err := db.Exec(`UPDATE users SET remaining_accesses = 10 WHERE id = $1`, user.ID); err != nil {
+			t.Fatalf("Failed to reset accesses: %v", err)
+		}
+
+		classRepo := models.NewClassRepository(db)
+		teacher := &models.Instructor{FirstName: "Late", LastName: "Instructor", MaxSlots: 5, Enabled: true}
+		if err := instructorRepo.Create(teacher); err != nil {
+			t.Fatalf("Failed to create class instructor: %v", err)
+		}
+		template := &models.ClassTemplate{Title: "Yoga", Capacity: 10, DurationMinutes: 60}
+		if err := classRepo.CreateTemplate(template); err != nil {
+			t.Fatalf("Failed to create class template: %v", err)
+		}
+		session := &models.ClassSession{TemplateID: template.ID, InstructorID: teacher.ID, Capacity: 10, StartsAt: time.Now().Add(3 * time.Hour).Truncate(time.Minute).UTC(), DurationMinutes: 60}
+		if err := classRepo.ScheduleSession(session); err != nil {
+			t.Fatalf("Failed to schedule session: %v", err)
+		}
+		if err := classRepo.Enroll(session.ID, user.ID); err != nil {
+			t.Fatalf("Failed to enroll: %v", err)
+		}
+		decision, err := classRepo.Unenroll(session.ID, user.ID, func(time.Time, int) models.CancellationDecision {
+			return models.CancellationDecision{Refund: true, Late: true}
+		})
+		if err != nil {
+			t.Fatalf("Failed to unenroll: %v", err)
+		}
+		if !decision.Refund {
+			t.Errorf("Expected the decision to be returned, got %+v", decision)
+		}
+
+		// The cancellation event is written with the enrolment
+		events, err := models.NewEventRepository(db).GetCancellationsByUserID(user.ID, 10)
+		if err != nil {
+			t.Fatalf("Failed to get events: %v", err)
+		}
+		if len(events) != 1 || !events[0].LateCancel {
+			t.Errorf("Expected one late cancellation event, got %+v", events)
+		}
+
+		count, err := models.NewEventRepository(db).CountLateRefunds(user.ID, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to count late refunds: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected 1 late refund, got %d", count)
+		}
+
+		// The cancelled enrolment neither holds a place nor blocks enrolling again
+		if err := classRepo.Enroll(session.ID, user.ID); err != nil {
+			t.Fatalf("Failed to enroll again: %v", err)
+		}
+		participants, err := classRepo.GetParticipants(session.ID)
+		if err != nil {
+			t.Fatalf("Failed to get participants: %v", err)
+		}
+		if len(participants) != 1 {
+			t.Errorf("Expected 1 participant, got %d", len(participants))
+		}
+	})
+
+	t.Run("Enroll Rejects Closed Days", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "class_enrollments", "class_sessions", "class_templates", "closures")
+
+		classRepo := models.NewClassRepository(db)
+		teacher := &models.Instructor{FirstName: "Closed", LastName: "Instructor", MaxSlots: 5, Enabled: true}
+		if err := instructorRepo.Create(teacher); err != nil {
+			t.Fatalf("Failed to create class instructor: %v", err)
+		}
+		template := &models.ClassTemplate{Title: "Functional", Capacity: 10, DurationMinutes: 60}
+		if err := classRepo.CreateTemplate(template); err != nil {
+			t.Fatalf("Failed to create class template: %v", err)
+		}
+		session := &models.ClassSession{TemplateID: template.ID, InstructorID: teacher.ID, Capacity: 10, StartsAt: time.Now().Add(168 * time.Hour).Truncate(time.Hour).UTC(), DurationMinutes: 60}
+		if err := classRepo.ScheduleSession(session); err != nil {
+			t.Fatalf("Failed to schedule session: %v", err)
+		}
+
+		// The closure is added after the session was scheduled
+		loc, err := time.LoadLocation(models.BusinessTimeZone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		day := session.StartsAt.In(loc)
+		closure := &models.Closure{InstructorID: sql.NullInt64{Int64: teacher.ID, Valid: true}, StartsOn: day, EndsOn: day, Reason: "Ferie"}
+		if err := models.NewClosureRepository(db).Create(closure); err != nil {
+			t.Fatalf("Failed to create closure: %v", err)
+		}
+
+		if err := classRepo.Enroll(session.ID, user.ID); !errors.Is(err, models.ErrClosed) {
+			t.Errorf("Expected ErrClosed enrolling on a closed day, got %v", err)
+		}
+	})
+
+	t.Run("Bulk Blocks Snapshot Like Bookings", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "bookings", "services")
+
+		service := &models.Service{Name: "Massaggio", DurationMinutes: 60, CapacityWeight: 2, Enabled: true}
+		if err := models.NewServiceRepository(db).Create(service); err != nil {
+			t.Fatalf("Failed to create service: %v", err)
+		}
+
+		block := &models.Booking{
+			InstructorID: instructor.ID,
+			StartsAt:     time.Now().Add(216 * time.Hour).Truncate(time.Hour).UTC(),
+			Type:         models.BookingTypeMassage,
+			ServiceID:    sql.NullInt64{Int64: service.ID, Valid: true},
+		}
+		result, err := bookingRepo.CreateBlocks([]*models.Booking{block}, false)
+		if err != nil {
+			t.Fatalf("Failed to create blocks: %v", err)
+		}
+		if len(result.Created) != 1 || block.ID == 0 || block.CreatedAt.IsZero() {
+			t.Fatalf("Expected the block to be created, got %+v", result)
+		}
+
+		bookings, err := bookingRepo.GetWithUsersByDateRange(block.StartsAt.Add(-time.Minute), block.StartsAt.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to get bookings: %v", err)
+		}
+		if len(bookings) != 1 || bookings[0].CapacityWeight != 2 {
+			t.Errorf("Expected one block weighing 2, got %+v", bookings)
+		}
 	})
 
 	_ = instructorRepo // Suppress unused warning
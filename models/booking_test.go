@@ -0,0 +1,66 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestPeakLoad(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	occupancies := []models.Occupancy{
+		{StartsAt: at(9, 0), EndsAt: at(10, 0), Weight: 1},
+		{StartsAt: at(9, 45), EndsAt: at(11, 15), Weight: 2},
+		{StartsAt: at(11, 15), EndsAt: at(12, 0), Weight: 1},
+	}
+
+	cases := []struct {
+		name       string
+		start, end time.Time
+		want       int
+	}{
+		{"overlap of first two bookings", at(9, 0), at(10, 0), 3},
+		{"single booking", at(10, 0), at(11, 0), 2},
+		{"back to back bookings do not overlap", at(11, 0), at(12, 0), 2},
+		{"free interval", at(12, 0), at(13, 0), 0},
+		{"interval ending when a booking starts", at(8, 0), at(9, 0), 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, blocked := models.PeakLoad(occupancies, c.start, c.end)
+			if blocked {
+				t.Fatal("Expected interval not to be blocked")
+			}
+			if got != c.want {
+				t.Errorf("PeakLoad = %d, want %d", got, c.want)
+			}
+		})
+	}
+
+	t.Run("blocking booking", func(t *testing.T) {
+		blocking := append(occupancies, models.Occupancy{StartsAt: at(13, 0), EndsAt: at(14, 30), Blocking: true})
+		if _, blocked := models.PeakLoad(blocking, at(14, 0), at(15, 0)); !blocked {
+			t.Error("Expected overlapping blocking booking to block the interval")
+		}
+		if _, blocked := models.PeakLoad(blocking, at(14, 30), at(15, 30)); blocked {
+			t.Error("Expected interval after the blocking booking to be free")
+		}
+	})
+}
+
+func TestBookingWeight(t *testing.T) {
+	if got := models.BookingWeight(models.SubTypeShared, 1); got != 1 {
+		t.Errorf("SHARED weight = %d, want 1", got)
+	}
+	if got := models.BookingWeight(models.SubTypeSingle, 1); got != 2 {
+		t.Errorf("SINGLE weight = %d, want 2", got)
+	}
+	if got := models.BookingWeight(models.SubTypeShared, 3); got != 3 {
+		t.Errorf("SHARED weight with service weight 3 = %d, want 3", got)
+	}
+}
@@ -0,0 +1,631 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidClass    = errors.New("invalid class")
+	ErrClassFull       = errors.New("class is full")
+	ErrAlreadyEnrolled = errors.New("already enrolled in this class")
+	ErrInstructorBusy  = errors.New("instructor is busy at this time")
+)
+
+// ClassTemplate describes a group class. Sessions copy its room, capacity and
+// duration when scheduled so later edits do not change booked sessions.
+// ResourceID is the room as a resource, shared with bookings; Room is only
+// its label.
+type ClassTemplate struct {
+	ID              int64
+	Title           string
+	Description     string
+	Room            string
+	ResourceID      sql.NullInt64
+	InstructorID    sql.NullInt64
+	Capacity        int
+	DurationMinutes int
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (t *ClassTemplate) Validate() error {
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidClass)
+	}
+	if t.Capacity <= 0 {
+		return fmt.Errorf("%w: capacity must be positive", ErrInvalidClass)
+	}
+	if t.DurationMinutes <= 0 || t.DurationMinutes > 24*60 {
+		return fmt.Errorf("%w: duration must be between 1 and 1440 minutes", ErrInvalidClass)
+	}
+	return nil
+}
+
+// ClassSession is a scheduled occurrence of a class template. While it runs
+// it holds one unit of its resource, if any.
+type ClassSession struct {
+	ID              int64
+	TemplateID      int64
+	InstructorID    int64
+	Room            string
+	ResourceID      sql.NullInt64
+	Capacity        int
+	StartsAt        time.Time
+	DurationMinutes int
+	CreatedAt       time.Time
+}
+
+func (s *ClassSession) EndsAt() time.Time {
+	return s.StartsAt.Add(time.Duration(s.DurationMinutes) * time.Minute)
+}
+
+// Occupancy returns the interval the session takes from its instructor: the
+// instructor takes no regular bookings while teaching a class.
+func (s *ClassSession) Occupancy() Occupancy {
+	return Occupancy{StartsAt: s.StartsAt, EndsAt: s.EndsAt(), Blocking: true}
+}
+
+// Resources returns the resources the session needs.
+func (s *ClassSession) Resources() []ResourceRequirement {
+	if !s.ResourceID.Valid {
+		return nil
+	}
+	return []ResourceRequirement{{ResourceID: s.ResourceID.Int64, Quantity: 1}}
+}
+
+type ClassSessionWithDetails struct {
+	ClassSession
+	Title               string
+	Description         string
+	InstructorFirstName sql.NullString
+	InstructorLastName  sql.NullString
+	// TimeZone is the time zone of the instructor's location
+	TimeZone string
+	Enrolled int
+}
+
+// Location returns the time zone of the location the session is held at.
+func (s *ClassSessionWithDetails) Location() *time.Location {
+	return LoadTimeZone(s.TimeZone)
+}
+
+// ClassParticipant is a member enrolled in a session.
+type ClassParticipant struct {
+	UserID     string
+	FirstName  string
+	LastName   string
+	Email      string
+	EnrolledAt time.Time
+}
+
+type ClassRepository struct {
+	db *sql.DB
+}
+
+func NewClassRepository(db *sql.DB) *ClassRepository {
+	return &ClassRepository{db: db}
+}
+
+const classTemplateColumns = `id, title, description, room, resource_id, instructor_id, capacity, duration_minutes, created_at, updated_at`
+
+func scanClassTemplate(row rowScanner) (*ClassTemplate, error) {
+	var t ClassTemplate
+	err := row.Scan(
+		&t.ID,
+		&t.Title,
+		&t.Description,
+		&t.Room,
+		&t.ResourceID,
+		&t.InstructorID,
+		&t.Capacity,
+		&t.DurationMinutes,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *ClassRepository) GetTemplates() ([]*ClassTemplate, error) {
+	rows, err := r.db.Query(`SELECT ` + classTemplateColumns + ` FROM class_templates ORDER BY title ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*ClassTemplate
+	for rows.Next() {
+		t, err := scanClassTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+
+	return templates, rows.Err()
+}
+
+func (r *ClassRepository) GetTemplateByID(id int64) (*ClassTemplate, error) {
+	return scanClassTemplate(r.db.QueryRow(`SELECT `+classTemplateColumns+` FROM class_templates WHERE id = $1`, id))
+}
+
+func (r *ClassRepository) CreateTemplate(t *ClassTemplate) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+		INSERT INTO class_templates (title, description, room, resource_id, instructor_id, capacity, duration_minutes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`, t.Title, t.Description, t.Room, t.ResourceID, t.InstructorID, t.Capacity, t.DurationMinutes).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+}
+
+func (r *ClassRepository) UpdateTemplate(t *ClassTemplate) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+		UPDATE class_templates
+		SET title = $2, description = $3, room = $4, resource_id = $5, instructor_id = $6, capacity = $7, duration_minutes = $8, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, t.ID, t.Title, t.Description, t.Room, t.ResourceID, t.InstructorID, t.Capacity, t.DurationMinutes).Scan(&t.CreatedAt, &t.UpdatedAt)
+}
+
+// DeleteTemplate removes a template that has no upcoming sessions, past
+// sessions are removed with it.
+func (r *ClassRepository) DeleteTemplate(id int64) error {
+	var upcoming bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM class_sessions WHERE template_id = $1 AND starts_at > CURRENT_TIMESTAMP)
+	`, id).Scan(&upcoming)
+	if err != nil {
+		return err
+	}
+	if upcoming {
+		return fmt.Errorf("%w: the class has upcoming sessions", ErrInvalidClass)
+	}
+
+	result, err := r.db.Exec(`DELETE FROM class_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+const classSessionDetailsQuery = `
+	SELECT cs.id, cs.template_id, cs.instructor_id, cs.room, cs.resource_id, cs.capacity, cs.starts_at, cs.duration_minutes, cs.created_at,
+		   ct.title, ct.description, i.first_name, ` + instructorLastName + `, COALESCE(l.time_zone, ''),
+		   (SELECT COUNT(*) FROM class_enrollments ce WHERE ce.session_id = cs.id AND ce.cancelled_at IS NULL)
+	FROM class_sessions cs
+	JOIN class_templates ct ON ct.id = cs.template_id
+	LEFT JOIN instructors i ON i.id = cs.instructor_id
+	LEFT JOIN locations l ON l.id = i.location_id
+`
+
+func scanClassSession(row rowScanner) (*ClassSessionWithDetails, error) {
+	var s ClassSessionWithDetails
+	err := row.Scan(
+		&s.ID,
+		&s.TemplateID,
+		&s.InstructorID,
+		&s.Room,
+		&s.ResourceID,
+		&s.Capacity,
+		&s.StartsAt,
+		&s.DurationMinutes,
+		&s.CreatedAt,
+		&s.Title,
+		&s.Description,
+		&s.InstructorFirstName,
+		&s.InstructorLastName,
+		&s.TimeZone,
+		&s.Enrolled,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *ClassRepository) querySessions(query string, args ...interface{}) ([]*ClassSessionWithDetails, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*ClassSessionWithDetails
+	for rows.Next() {
+		s, err := scanClassSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// GetSessions returns the sessions overlapping [from, to), of one instructor
+// when instructorID is not zero and of one location when locationID is not zero.
+func (r *ClassRepository) GetSessions(from, to time.Time, instructorID, locationID int64) ([]*ClassSessionWithDetails, error) {
+	return r.querySessions(classSessionDetailsQuery+`
+		WHERE cs.starts_at < $2
+			AND cs.starts_at + cs.duration_minutes * INTERVAL '1 minute' > $1
+			AND ($3 = 0 OR cs.instructor_id = $3)
+			AND ($4 = 0 OR i.location_id = $4)
+		ORDER BY cs.starts_at ASC, ct.title ASC
+	`, from, to, instructorID, locationID)
+}
+
+// GetSessionsByUserID returns the sessions starting after the given time the user is enrolled in.
+func (r *ClassRepository) GetSessionsByUserID(userID string, after time.Time) ([]*ClassSessionWithDetails, error) {
+	return r.querySessions(classSessionDetailsQuery+`
+		JOIN class_enrollments mine ON mine.session_id = cs.id AND mine.user_id = $1 AND mine.cancelled_at IS NULL
+		WHERE cs.starts_at > $2
+		ORDER BY cs.starts_at ASC
+	`, userID, after)
+}
+
+func (r *ClassRepository) GetSessionByID(id int64) (*ClassSessionWithDetails, error) {
+	return scanClassSession(r.db.QueryRow(classSessionDetailsQuery+` WHERE cs.id = $1`, id))
+}
+
+// ScheduleSession creates a session if its instructor is open that day and
+// has neither bookings nor other sessions during it, and its resource is
+// free. It holds the same advisory lock as user bookings of the instructor's
+// day.
+func (r *ClassRepository) ScheduleSession(session *ClassSession) error {
+	if session.Capacity <= 0 {
+		return fmt.Errorf("%w: capacity must be positive", ErrInvalidClass)
+	}
+	if session.DurationMinutes <= 0 || session.DurationMinutes > 24*60 {
+		return fmt.Errorf("%w: duration must be between 1 and 1440 minutes", ErrInvalidClass)
+	}
+
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockInstructorDayTx(tx, session.InstructorID, session.StartsAt); err != nil {
+		return err
+	}
+
+	closed, err := isClosedTx(tx, session.InstructorID, session.StartsAt)
+	if err != nil {
+		return err
+	}
+	if closed {
+		return ErrClosed
+	}
+
+	var booked bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM bookings
+			WHERE instructor_id = $1
+				AND cancelled_at IS NULL
+				AND starts_at < $3
+				AND starts_at + duration_minutes * INTERVAL '1 minute' > $2
+		)
+	`, session.InstructorID, session.StartsAt, session.EndsAt()).Scan(&booked)
+	if err != nil {
+		return err
+	}
+	teaching, err := hasClassSessionTx(tx, session.InstructorID, session.StartsAt, session.EndsAt())
+	if err != nil {
+		return err
+	}
+	if booked || teaching {
+		return ErrInstructorBusy
+	}
+
+	if err := checkResourcesTx(tx, session.Resources(), session.StartsAt, session.EndsAt(), 0); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO class_sessions (template_id, instructor_id, room, resource_id, capacity, starts_at, duration_minutes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, session.TemplateID, session.InstructorID, session.Room, session.ResourceID, session.Capacity, session.StartsAt, session.DurationMinutes).Scan(&session.ID, &session.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteSession removes a session and gives every participant their access
+// back on behalf of by. It returns the participants for notifications.
+func (r *ClassRepository) DeleteSession(id int64, by string) ([]*ClassParticipant, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(classParticipantsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	participants, err := scanParticipants(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range participants {
+		if err := changeAccessesTx(tx, AccessChange{UserID: p.UserID, Delta: 1, Reason: AccessClassRefund, ActorID: by}); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM class_sessions WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return participants, tx.Commit()
+}
+
+const classParticipantsQuery = `
+	SELECT u.id, u.first_name, COALESCE(u.last_name, ''), u.email, ce.created_at
+	FROM class_enrollments ce
+	JOIN users u ON u.id = ce.user_id
+	WHERE ce.session_id = $1 AND ce.cancelled_at IS NULL
+	ORDER BY ce.created_at ASC, ce.id ASC
+`
+
+// GetParticipants returns the members enrolled in a session in enrolment order.
+func (r *ClassRepository) GetParticipants(sessionID int64) ([]*ClassParticipant, error) {
+	rows, err := r.db.Query(classParticipantsQuery, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return scanParticipants(rows)
+}
+
+func scanParticipants(rows *sql.Rows) ([]*ClassParticipant, error) {
+	defer rows.Close()
+
+	var participants []*ClassParticipant
+	for rows.Next() {
+		var p ClassParticipant
+		if err := rows.Scan(&p.UserID, &p.FirstName, &p.LastName, &p.Email, &p.EnrolledAt); err != nil {
+			return nil, err
+		}
+		participants = append(participants, &p)
+	}
+
+	return participants, rows.Err()
+}
+
+// GetEnrolledSessionIDs returns the sessions overlapping [from, to) the user is enrolled in.
+func (r *ClassRepository) GetEnrolledSessionIDs(userID string, from, to time.Time) (map[int64]bool, error) {
+	rows, err := r.db.Query(`
+		SELECT ce.session_id
+		FROM class_enrollments ce
+		JOIN class_sessions cs ON cs.id = ce.session_id
+		WHERE ce.user_id = $1
+			AND ce.cancelled_at IS NULL
+			AND cs.starts_at < $3
+			AND cs.starts_at + cs.duration_minutes * INTERVAL '1 minute' > $2
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrolled := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		enrolled[id] = true
+	}
+
+	return enrolled, rows.Err()
+}
+
+// Enroll adds the user to a session and consumes one access like
+// CreateUserBooking, within the same booking limits and unless a closure
+// added after scheduling covers it, holding the session row
+// while counting its places. The user row is locked first, like DeleteSession
+// does through the refunds.
+func (r *ClassRepository) Enroll(sessionID int64, userID string) error {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return err
+	}
+
+	var capacity, durationMinutes int
+	var instructorID int64
+	var startsAt time.Time
+	err = tx.QueryRow(`
+		SELECT capacity, instructor_id, starts_at, duration_minutes FROM class_sessions WHERE id = $1 FOR UPDATE
+	`, sessionID).Scan(&capacity, &instructorID, &startsAt, &durationMinutes)
+	if err != nil {
+		return err
+	}
+	closed, err := isClosedTx(tx, instructorID, startsAt)
+	if err != nil {
+		return err
+	}
+	if closed {
+		return ErrClosed
+	}
+	loc, err := instructorTimeZoneTx(tx, instructorID)
+	if err != nil {
+		return err
+	}
+	if err := checkNotFrozenTx(tx, userID, startsAt, loc); err != nil {
+		return err
+	}
+
+	var enrolled int
+	var already bool
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2) > 0
+		FROM class_enrollments
+		WHERE session_id = $1 AND cancelled_at IS NULL
+	`, sessionID, userID).Scan(&enrolled, &already)
+	if err != nil {
+		return err
+	}
+	if already {
+		return ErrAlreadyEnrolled
+	}
+	if enrolled >= capacity {
+		return ErrClassFull
+	}
+
+	endsAt := startsAt.Add(time.Duration(durationMinutes) * time.Minute)
+	if err := checkUserOverlapTx(tx, userID, startsAt, endsAt, 0); err != nil {
+		return err
+	}
+	if err := checkBookingLimitsTx(tx, userID, startsAt, loc, 0, time.Now()); err != nil {
+		return err
+	}
+
+	if err := changeAccessesTx(tx, AccessChange{UserID: userID, Delta: -1, Reason: AccessClass, ActorID: userID}); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO class_enrollments (session_id, user_id) VALUES ($1, $2)`, sessionID, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Unenroll cancels the enrolment of the user in a session and records its
+// cancellation event in one transaction, giving the access back when it is
+// refunded. decide returns the outcome for a session starting at startsAt
+// given the late cancellations already refunded to the user this month; the
+// user row is locked while those are counted, as in CancelByPolicy. The
+// cancelled enrolment is kept so late cancellations count toward the monthly
+// allowance. It returns sql.ErrNoRows when the user is not enrolled.
+func (r *ClassRepository) Unenroll(sessionID int64, userID string, decide func(startsAt time.Time, lateRefundsUsed int) CancellationDecision) (CancellationDecision, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return CancellationDecision{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return CancellationDecision{}, err
+	}
+
+	var enrollmentID int64
+	var startsAt time.Time
+	err = tx.QueryRow(`
+		SELECT ce.id, cs.starts_at
+		FROM class_enrollments ce
+		JOIN class_sessions cs ON cs.id = ce.session_id
+		WHERE ce.session_id = $1 AND ce.user_id = $2 AND ce.cancelled_at IS NULL
+		FOR UPDATE OF ce
+	`, sessionID, userID).Scan(&enrollmentID, &startsAt)
+	if err != nil {
+		return CancellationDecision{}, err
+	}
+
+	now := time.Now()
+	lateRefundsUsed, err := countLateRefundsTx(tx, userID, BusinessMonthStart(now))
+	if err != nil {
+		return CancellationDecision{}, err
+	}
+	decision := decide(startsAt, lateRefundsUsed)
+
+	_, err = tx.Exec(`
+		UPDATE class_enrollments
+		SET cancelled_at = $2, refunded = $3, late_cancel = $4
+		WHERE id = $1
+	`, enrollmentID, now.UTC(), decision.Refund, decision.Late)
+	if err != nil {
+		return CancellationDecision{}, err
+	}
+
+	if decision.Refund {
+		if err := changeAccessesTx(tx, AccessChange{UserID: userID, Delta: 1, Reason: AccessClassRefund, ActorID: userID}); err != nil {
+			return CancellationDecision{}, err
+		}
+	}
+
+	event := &Event{
+		UserID:     userID,
+		StartsAt:   startsAt,
+		Type:       EventTypeDeleted,
+		OccurredAt: now.UTC(),
+	}
+	decision.Record(event)
+	if err := createEventTx(tx, event); err != nil {
+		return CancellationDecision{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return CancellationDecision{}, err
+	}
+	return decision, nil
+}
+
+// GetOccupancies returns the sessions of an instructor overlapping [from, to)
+// as blocking occupancies for slot generation.
+func (r *ClassRepository) GetOccupancies(instructorID int64, from, to time.Time) ([]Occupancy, error) {
+	sessions, err := r.GetSessions(from, to, instructorID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	occupancies := make([]Occupancy, 0, len(sessions))
+	for _, s := range sessions {
+		occupancies = append(occupancies, s.Occupancy())
+	}
+	return occupancies, nil
+}
+
+// hasClassSessionTx reports whether the instructor teaches a class during [startsAt, endsAt).
+func hasClassSessionTx(tx *sql.Tx, instructorID int64, startsAt, endsAt time.Time) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM class_sessions
+			WHERE instructor_id = $1
+				AND starts_at < $3
+				AND starts_at + duration_minutes * INTERVAL '1 minute' > $2
+		)
+	`, instructorID, startsAt, endsAt).Scan(&exists)
+	return exists, err
+}
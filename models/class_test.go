@@ -0,0 +1,58 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestClassTemplateValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template models.ClassTemplate
+		wantErr  bool
+	}{
+		{"Valid", models.ClassTemplate{Title: " Pilates ", Capacity: 8, DurationMinutes: 50}, false},
+		{"Blank title", models.ClassTemplate{Title: "  ", Capacity: 8, DurationMinutes: 50}, true},
+		{"No capacity", models.ClassTemplate{Title: "Pilates", Capacity: 0, DurationMinutes: 50}, true},
+		{"No duration", models.ClassTemplate{Title: "Pilates", Capacity: 8, DurationMinutes: 0}, true},
+		{"Longer than a day", models.ClassTemplate{Title: "Pilates", Capacity: 8, DurationMinutes: 24*60 + 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.template.Validate()
+			if tt.wantErr {
+				if !errors.Is(err, models.ErrInvalidClass) {
+					t.Errorf("Expected ErrInvalidClass, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if tt.template.Title != "Pilates" {
+				t.Errorf("Expected trimmed title, got %q", tt.template.Title)
+			}
+		})
+	}
+}
+
+func TestClassSessionBlocksInstructor(t *testing.T) {
+	startsAt := time.Date(2024, 3, 15, 18, 30, 0, 0, time.UTC)
+	session := models.ClassSession{StartsAt: startsAt, DurationMinutes: 50}
+
+	if want := startsAt.Add(50 * time.Minute); !session.EndsAt().Equal(want) {
+		t.Errorf("Expected session to end at %v, got %v", want, session.EndsAt())
+	}
+
+	occupancies := []models.Occupancy{session.Occupancy()}
+	if _, blocked := models.PeakLoad(occupancies, startsAt.Add(-30*time.Minute), startsAt.Add(30*time.Minute)); !blocked {
+		t.Error("Expected a booking overlapping the class to be blocked")
+	}
+	if _, blocked := models.PeakLoad(occupancies, session.EndsAt(), session.EndsAt().Add(time.Hour)); blocked {
+		t.Error("Expected a booking right after the class to be allowed")
+	}
+}
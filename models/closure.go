@@ -0,0 +1,199 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// BusinessTimeZone is the time zone of the first location, used for dates not
+// tied to a location such as subscription expiries.
+const BusinessTimeZone = "Europe/Rome"
+
+var ErrClosed = errors.New("studio or instructor closed")
+
+// Closure is a range of whole days, inclusive, during which no slot can be booked.
+// A closure without instructor applies to its location, or to every location
+// when it has none. Days are those of the instructor's location.
+type Closure struct {
+	ID           int64
+	InstructorID sql.NullInt64
+	LocationID   sql.NullInt64
+	StartsOn     time.Time
+	EndsOn       time.Time
+	Reason       string
+	Holiday      bool
+	CreatedAt    time.Time
+}
+
+// ClosureCalendar is a set of closures that can be queried by local day.
+type ClosureCalendar []Closure
+
+// IsClosed reports whether the calendar day of local is covered by a closure.
+// local must already be expressed in the time zone of the instructor's location.
+func (c ClosureCalendar) IsClosed(local time.Time) bool {
+	day := civilDate(local.Year(), local.Month(), local.Day())
+	for _, closure := range c {
+		if !day.Before(closure.StartsOn) && !day.After(closure.EndsOn) {
+			return true
+		}
+	}
+	return false
+}
+
+type ClosureRepository struct {
+	db *sql.DB
+}
+
+func NewClosureRepository(db *sql.DB) *ClosureRepository {
+	return &ClosureRepository{db: db}
+}
+
+// GetAll returns the configured closures overlapping [from, to], ordered by start date.
+func (r *ClosureRepository) GetAll(from, to time.Time) ([]*Closure, error) {
+	query := `
+		SELECT id, instructor_id, location_id, starts_on, ends_on, reason, created_at
+		FROM closures
+		WHERE ends_on >= $1::date AND starts_on <= $2::date
+		ORDER BY starts_on, id
+	`
+
+	rows, err := r.db.Query(query, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var closures []*Closure
+	for rows.Next() {
+		var closure Closure
+		err := rows.Scan(
+			&closure.ID,
+			&closure.InstructorID,
+			&closure.LocationID,
+			&closure.StartsOn,
+			&closure.EndsOn,
+			&closure.Reason,
+			&closure.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		closures = append(closures, &closure)
+	}
+
+	return closures, rows.Err()
+}
+
+// CalendarFor returns the closures that apply to an instructor between the two
+// local days, including closures of their location, studio-wide closures and
+// national holidays.
+func (r *ClosureRepository) CalendarFor(instructorID int64, from, to time.Time) (ClosureCalendar, error) {
+	query := `
+		SELECT id, instructor_id, location_id, starts_on, ends_on, reason, created_at
+		FROM closures
+		WHERE ` + closureAppliesTo + `
+			AND ends_on >= $2::date AND starts_on <= $3::date
+	`
+
+	rows, err := r.db.Query(query, instructorID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calendar ClosureCalendar
+	for rows.Next() {
+		var closure Closure
+		err := rows.Scan(
+			&closure.ID,
+			&closure.InstructorID,
+			&closure.LocationID,
+			&closure.StartsOn,
+			&closure.EndsOn,
+			&closure.Reason,
+			&closure.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		calendar = append(calendar, closure)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for year := from.Year(); year <= to.Year(); year++ {
+		calendar = append(calendar, ItalianHolidays(year)...)
+	}
+
+	return calendar, nil
+}
+
+func (r *ClosureRepository) Create(closure *Closure) error {
+	query := `
+		INSERT INTO closures (instructor_id, location_id, starts_on, ends_on, reason)
+		VALUES ($1, $2, $3::date, $4::date, $5)
+		RETURNING id, created_at
+	`
+
+	return r.db.QueryRow(query,
+		closure.InstructorID,
+		closure.LocationID,
+		closure.StartsOn.Format("2006-01-02"),
+		closure.EndsOn.Format("2006-01-02"),
+		closure.Reason,
+	).Scan(&closure.ID, &closure.CreatedAt)
+}
+
+func (r *ClosureRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM closures WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// closureAppliesTo selects the closures of the instructor $1: their own, those
+// of their location and those of every location.
+const closureAppliesTo = `(
+	instructor_id = $1
+	OR (instructor_id IS NULL AND location_id IS NULL)
+	OR (instructor_id IS NULL AND location_id = (SELECT location_id FROM instructors WHERE id = $1))
+)`
+
+// isClosedTx checks closures for the local day of startsAt inside a booking transaction.
+func isClosedTx(tx *sql.Tx, instructorID int64, startsAt time.Time) (bool, error) {
+	var timeZone string
+	err := tx.QueryRow(`
+		SELECT l.time_zone
+		FROM instructors i
+		JOIN locations l ON l.id = i.location_id
+		WHERE i.id = $1
+	`, instructorID).Scan(&timeZone)
+	if err != nil {
+		return false, err
+	}
+
+	local := startsAt.In(LoadTimeZone(timeZone))
+	if ClosureCalendar(ItalianHolidays(local.Year())).IsClosed(local) {
+		return true, nil
+	}
+
+	var closed bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM closures
+			WHERE `+closureAppliesTo+`
+				AND $2::date BETWEEN starts_on AND ends_on
+		)
+	`, instructorID, local.Format("2006-01-02")).Scan(&closed)
+	return closed, err
+}
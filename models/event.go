@@ -16,14 +16,24 @@ const (
 	EventTypeSlotMassage     EventType = "SLOT_MASSAGE"
 	EventTypeSlotAppointment EventType = "SLOT_APPOINTMENT"
 	EventTypeSlotUnreserved  EventType = "SLOT_UNRESERVED"
+
+	EventTypeWaitlistPromoted EventType = "WAITLIST_PROMOTED"
+	EventTypeRescheduled      EventType = "RESCHEDULED"
+	EventTypeReassigned       EventType = "REASSIGNED"
 )
 
+// Event is an entry of the booking log. Cancellation events also carry the
+// refund outcome and the policy that decided it.
 type Event struct {
-	ID         int
-	UserID     string
-	StartsAt   time.Time
-	Type       EventType
-	OccurredAt time.Time
+	ID           int
+	UserID       string
+	StartsAt     time.Time
+	Type         EventType
+	OccurredAt   time.Time
+	Refunded     sql.NullBool
+	LateCancel   bool
+	PolicyID     sql.NullInt64
+	PolicyReason sql.NullString
 }
 
 type EventWithUser struct {
@@ -32,6 +42,10 @@ type EventWithUser struct {
 	StartsAt      time.Time
 	Type          EventType
 	OccurredAt    time.Time
+	Refunded      sql.NullBool
+	LateCancel    bool
+	PolicyID      sql.NullInt64
+	PolicyReason  sql.NullString
 	UserFirstName sql.NullString
 	UserLastName  sql.NullString
 }
@@ -44,26 +58,80 @@ func NewEventRepository(db *sql.DB) *EventRepository {
 	return &EventRepository{db: db}
 }
 
-func (r *EventRepository) Create(event *Event) error {
-	query := `
-		INSERT INTO events (user_id, starts_at, type, occurred_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
-	`
+const createEventQuery = `
+	INSERT INTO events (user_id, starts_at, type, occurred_at, refunded, late_cancel, policy_id, policy_reason)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id
+`
 
-	err := r.db.QueryRow(query, event.UserID, event.StartsAt, event.Type, event.OccurredAt).Scan(&event.ID)
+func (r *EventRepository) Create(event *Event) error {
+	err := r.db.QueryRow(createEventQuery, event.UserID, event.StartsAt, event.Type, event.OccurredAt,
+		event.Refunded, event.LateCancel, event.PolicyID, event.PolicyReason).Scan(&event.ID)
 	return err
 }
 
+// createEventTx runs Create inside an existing transaction.
+func createEventTx(tx *sql.Tx, event *Event) error {
+	return tx.QueryRow(createEventQuery, event.UserID, event.StartsAt, event.Type, event.OccurredAt,
+		event.Refunded, event.LateCancel, event.PolicyID, event.PolicyReason).Scan(&event.ID)
+}
+
 func (r *EventRepository) GetAll() ([]*Event, error) {
 	query := `
-		SELECT id, user_id, starts_at, type, occurred_at
+		SELECT id, user_id, starts_at, type, occurred_at, refunded, late_cancel, policy_id, policy_reason
 		FROM events
 		ORDER BY occurred_at DESC
 		LIMIT 100
 	`
 
-	rows, err := r.db.Query(query)
+	return r.queryMany(query)
+}
+
+// GetCancellationsByUserID returns the latest cancellations of a user with
+// their refund outcome.
+func (r *EventRepository) GetCancellationsByUserID(userID string, limit int) ([]*Event, error) {
+	query := `
+		SELECT id, user_id, starts_at, type, occurred_at, refunded, late_cancel, policy_id, policy_reason
+		FROM events
+		WHERE user_id = $1 AND type = 'DELETED'
+		ORDER BY occurred_at DESC
+		LIMIT $2
+	`
+
+	return r.queryMany(query, userID, limit)
+}
+
+// CountLateRefunds counts the late cancellations of a user refunded since the
+// given time, used for the monthly allowance of PARTIAL policies. They are
+// read from the cancelled bookings and class enrolments, which outlive the
+// events.
+func (r *EventRepository) CountLateRefunds(userID string, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRow(countLateRefundsQuery, userID, since).Scan(&count)
+	return count, err
+}
+
+const countLateRefundsQuery = `
+	SELECT COUNT(*)
+	FROM (
+		SELECT 1 FROM bookings
+		WHERE user_id = $1 AND late_cancel = TRUE AND refunded = TRUE AND cancelled_at >= $2
+		UNION ALL
+		SELECT 1 FROM class_enrollments
+		WHERE user_id = $1 AND late_cancel = TRUE AND refunded = TRUE AND cancelled_at >= $2
+	) late
+`
+
+// countLateRefundsTx runs CountLateRefunds inside an existing transaction.
+// Callers lock the user row first so concurrent cancellations count in turn.
+func countLateRefundsTx(tx *sql.Tx, userID string, since time.Time) (int, error) {
+	var count int
+	err := tx.QueryRow(countLateRefundsQuery, userID, since).Scan(&count)
+	return count, err
+}
+
+func (r *EventRepository) queryMany(query string, args ...interface{}) ([]*Event, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -78,6 +146,10 @@ func (r *EventRepository) GetAll() ([]*Event, error) {
 			&event.StartsAt,
 			&event.Type,
 			&event.OccurredAt,
+			&event.Refunded,
+			&event.LateCancel,
+			&event.PolicyID,
+			&event.PolicyReason,
 		)
 		if err != nil {
 			return nil, err
@@ -91,6 +163,7 @@ func (r *EventRepository) GetAll() ([]*Event, error) {
 func (r *EventRepository) GetAllWithUsers() ([]*EventWithUser, error) {
 	query := `
 		SELECT e.id, e.user_id, e.starts_at, e.type, e.occurred_at,
+			   e.refunded, e.late_cancel, e.policy_id, e.policy_reason,
 			   u.first_name, u.last_name
 		FROM events e
 		LEFT JOIN users u ON u.id = e.user_id
@@ -113,6 +186,10 @@ func (r *EventRepository) GetAllWithUsers() ([]*EventWithUser, error) {
 			&event.StartsAt,
 			&event.Type,
 			&event.OccurredAt,
+			&event.Refunded,
+			&event.LateCancel,
+			&event.PolicyID,
+			&event.PolicyReason,
 			&event.UserFirstName,
 			&event.UserLastName,
 		)
@@ -0,0 +1,308 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidFreeze      = errors.New("invalid freeze")
+	ErrFreezeOverlap      = errors.New("freeze overlaps another freeze")
+	ErrFreezeLimit        = errors.New("not enough freeze days left on the subscription")
+	ErrSubscriptionFrozen = errors.New("subscription frozen on that day")
+)
+
+// Freeze pauses the subscription of a member from StartsOn to EndsOn
+// included, both civil dates. Its days are added to the end of the
+// subscription it pauses and of the renewals queued after it.
+type Freeze struct {
+	ID             int64
+	UserID         string
+	SubscriptionID int64
+	StartsOn       time.Time
+	EndsOn         time.Time
+	Reason         string
+	// CreatedBy is the admin who froze the subscription
+	CreatedBy sql.NullString
+	CreatedAt time.Time
+}
+
+// Days returns the number of days the freeze lasts.
+func (f *Freeze) Days() int {
+	return int(f.EndsOn.Sub(f.StartsOn).Hours()/24) + 1
+}
+
+// FreezeResult is a freeze together with what it cancelled: the bookings and
+// the class enrollments of the member inside it, all refunded.
+type FreezeResult struct {
+	Freeze    *Freeze
+	Cancelled []*BookingWithUser
+	// Classes are the start times of the sessions the member was removed from
+	Classes []time.Time
+}
+
+const freezeColumns = `id, user_id, subscription_id, starts_on, ends_on, reason, created_by, created_at`
+
+func scanFreeze(row rowScanner) (*Freeze, error) {
+	var f Freeze
+	err := row.Scan(
+		&f.ID,
+		&f.UserID,
+		&f.SubscriptionID,
+		&f.StartsOn,
+		&f.EndsOn,
+		&f.Reason,
+		&f.CreatedBy,
+		&f.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetFreezes returns every freeze of a member, the latest first.
+func (r *SubscriptionRepository) GetFreezes(userID string) ([]*Freeze, error) {
+	rows, err := r.db.Query(`
+		SELECT `+freezeColumns+`
+		FROM subscription_freezes
+		WHERE user_id = $1
+		ORDER BY starts_on DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var freezes []*Freeze
+	for rows.Next() {
+		f, err := scanFreeze(rows)
+		if err != nil {
+			return nil, err
+		}
+		freezes = append(freezes, f)
+	}
+
+	return freezes, rows.Err()
+}
+
+// Freeze pauses the subscription a member is on at f.StartsOn, which cannot be
+// in the past. It fails with ErrFreezeOverlap when another freeze of the
+// member shares a day, and with ErrFreezeLimit when the subscription has fewer
+// freeze days left. In the same transaction the subscription, the renewals
+// after it and the service wallets valid on f.StartsOn are pushed forward by
+// the frozen days, and the upcoming bookings and class enrollments inside the
+// freeze are cancelled by f.CreatedBy with a refund.
+func (r *SubscriptionRepository) Freeze(f *Freeze) (*FreezeResult, error) {
+	f.Reason = strings.TrimSpace(f.Reason)
+	if f.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidFreeze)
+	}
+	f.StartsOn = civilDate(f.StartsOn.Year(), f.StartsOn.Month(), f.StartsOn.Day())
+	f.EndsOn = civilDate(f.EndsOn.Year(), f.EndsOn.Month(), f.EndsOn.Day())
+	if f.EndsOn.Before(f.StartsOn) {
+		return nil, fmt.Errorf("%w: it ends before it starts", ErrInvalidFreeze)
+	}
+	now := time.Now()
+	if f.StartsOn.Before(businessDay(now)) {
+		return nil, fmt.Errorf("%w: it cannot start in the past", ErrInvalidFreeze)
+	}
+
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Serializes the freezes and plan assignments of the member
+	if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, f.UserID); err != nil {
+		return nil, err
+	}
+
+	startsOn, endsOn := f.StartsOn.Format("2006-01-02"), f.EndsOn.Format("2006-01-02")
+	subscription, err := scanSubscription(tx.QueryRow(`
+		SELECT `+subscriptionColumns+`
+		FROM subscriptions
+		WHERE user_id = $1 AND starts_on <= $2::date AND ends_on >= $2::date
+		ORDER BY starts_on DESC, id DESC
+		LIMIT 1
+	`, f.UserID, startsOn))
+	if err == sql.ErrNoRows {
+		return nil, ErrNoActiveSubscription
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.SubscriptionID = subscription.ID
+
+	var overlap bool
+	var used int
+	err = tx.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE starts_on <= $3::date AND ends_on >= $2::date) > 0,
+			COALESCE(SUM(ends_on - starts_on + 1) FILTER (WHERE subscription_id = $4), 0)
+		FROM subscription_freezes
+		WHERE user_id = $1
+	`, f.UserID, startsOn, endsOn, subscription.ID).Scan(&overlap, &used)
+	if err != nil {
+		return nil, err
+	}
+	if overlap {
+		return nil, ErrFreezeOverlap
+	}
+	if used+f.Days() > subscription.MaxFreezeDays {
+		return nil, fmt.Errorf("%w: %d of %d days used", ErrFreezeLimit, used, subscription.MaxFreezeDays)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO subscription_freezes (user_id, subscription_id, starts_on, ends_on, reason, created_by)
+		VALUES ($1, $2, $3::date, $4::date, $5, $6)
+		RETURNING id, created_at
+	`, f.UserID, f.SubscriptionID, startsOn, endsOn, f.Reason, f.CreatedBy).Scan(&f.ID, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE subscriptions
+		SET starts_on = CASE WHEN id = $2 THEN starts_on ELSE starts_on + $3 END,
+			ends_on = ends_on + $3
+		WHERE user_id = $1 AND (id = $2 OR starts_on > $4::date)
+	`, f.UserID, subscription.ID, f.Days(), subscription.EndsOn.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE users
+		SET expires_at = (SELECT MAX(ends_on) FROM subscriptions WHERE user_id = $1), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, f.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// Service wallets still valid when the freeze starts lose no days either
+	_, err = tx.Exec(`
+		UPDATE access_wallets
+		SET expires_on = expires_on + $3, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND expires_on >= $2::date
+	`, f.UserID, startsOn, f.Days())
+	if err != nil {
+		return nil, err
+	}
+
+	// Only what has not started yet is cancelled
+	result := &FreezeResult{Freeze: f}
+	result.Cancelled, err = cancelFrozenBookingsTx(tx, f, now)
+	if err != nil {
+		return nil, err
+	}
+	result.Classes, err = cancelFrozenEnrollmentsTx(tx, f, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// cancelFrozenBookingsTx cancels with a refund the SIMPLE bookings of the
+// member of a freeze starting after now on a frozen day of their location.
+func cancelFrozenBookingsTx(tx *sql.Tx, f *Freeze, now time.Time) ([]*BookingWithUser, error) {
+	rows, err := tx.Query(`
+		SELECT b.id, b.user_id, b.instructor_id, b.created_at, b.starts_at, b.type,
+			   u.first_name, u.last_name, u.email, u.sub_type,
+			   b.service_id, s.name, b.capacity_weight, b.duration_minutes
+		FROM bookings b
+		LEFT JOIN users u ON u.id = b.user_id
+		LEFT JOIN services s ON s.id = b.service_id
+		LEFT JOIN locations l ON l.id = b.location_id
+		WHERE b.user_id = $1
+			AND b.type = 'SIMPLE'
+			AND b.cancelled_at IS NULL
+			AND b.starts_at >= $2
+			AND (b.starts_at AT TIME ZONE COALESCE(l.time_zone, $5))::date BETWEEN $3::date AND $4::date
+		ORDER BY b.starts_at ASC
+		FOR UPDATE OF b
+	`, f.UserID, now, f.StartsOn.Format("2006-01-02"), f.EndsOn.Format("2006-01-02"), BusinessTimeZone)
+	if err != nil {
+		return nil, err
+	}
+	bookings, err := scanBookingsWithUsers(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	cancellation := Cancellation{Status: BookingStatusCancelledByAdmin, By: f.CreatedBy.String, Refunded: true}
+	for _, b := range bookings {
+		if _, err := cancelBookingTx(tx, b.ID, cancellation, now); err != nil {
+			return nil, err
+		}
+	}
+	return bookings, nil
+}
+
+// cancelFrozenEnrollmentsTx cancels the enrolments of the member of a freeze
+// in the class sessions starting after now on a frozen day of their location
+// and refunds each of them.
+func cancelFrozenEnrollmentsTx(tx *sql.Tx, f *Freeze, now time.Time) ([]time.Time, error) {
+	rows, err := tx.Query(`
+		UPDATE class_enrollments e
+		SET cancelled_at = $2, refunded = TRUE
+		FROM class_sessions s
+		JOIN instructors i ON i.id = s.instructor_id
+		JOIN locations l ON l.id = i.location_id
+		WHERE e.session_id = s.id AND e.user_id = $1 AND e.cancelled_at IS NULL AND s.starts_at >= $2
+			AND (s.starts_at AT TIME ZONE l.time_zone)::date BETWEEN $3::date AND $4::date
+		RETURNING s.starts_at
+	`, f.UserID, now, f.StartsOn.Format("2006-01-02"), f.EndsOn.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	var classes []time.Time
+	for rows.Next() {
+		var startsAt time.Time
+		if err := rows.Scan(&startsAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		classes = append(classes, startsAt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for range classes {
+		err := changeAccessesTx(tx, AccessChange{UserID: f.UserID, Delta: 1, Reason: AccessClassRefund, ActorID: f.CreatedBy.String})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return classes, nil
+}
+
+// checkNotFrozenTx returns ErrSubscriptionFrozen when a freeze of the member
+// covers the day of startsAt in loc, the time zone of the instructor.
+func checkNotFrozenTx(tx *sql.Tx, userID string, startsAt time.Time, loc *time.Location) error {
+	var frozen bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM subscription_freezes
+			WHERE user_id = $1 AND starts_on <= $2::date AND ends_on >= $2::date
+		)
+	`, userID, startsAt.In(loc).Format("2006-01-02")).Scan(&frozen)
+	if err != nil {
+		return err
+	}
+	if frozen {
+		return ErrSubscriptionFrozen
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// ItalianHolidays returns the Italian national public holidays of the given year
+// as studio-wide closures.
+func ItalianHolidays(year int) []Closure {
+	easter := easterSunday(year)
+
+	days := []struct {
+		date   time.Time
+		reason string
+	}{
+		{civilDate(year, time.January, 1), "Capodanno"},
+		{civilDate(year, time.January, 6), "Epifania"},
+		{easter, "Pasqua"},
+		{easter.AddDate(0, 0, 1), "Lunedì dell'Angelo"},
+		{civilDate(year, time.April, 25), "Festa della Liberazione"},
+		{civilDate(year, time.May, 1), "Festa del Lavoro"},
+		{civilDate(year, time.June, 2), "Festa della Repubblica"},
+		{civilDate(year, time.August, 15), "Ferragosto"},
+		{civilDate(year, time.November, 1), "Ognissanti"},
+		{civilDate(year, time.December, 8), "Immacolata Concezione"},
+		{civilDate(year, time.December, 25), "Natale"},
+		{civilDate(year, time.December, 26), "Santo Stefano"},
+	}
+
+	// San Francesco d'Assisi is a national holiday again from 2026
+	if year >= 2026 {
+		days = append(days, struct {
+			date   time.Time
+			reason string
+		}{civilDate(year, time.October, 4), "San Francesco d'Assisi"})
+	}
+
+	holidays := make([]Closure, 0, len(days))
+	for _, d := range days {
+		holidays = append(holidays, Closure{
+			StartsOn: d.date,
+			EndsOn:   d.date,
+			Reason:   d.reason,
+			Holiday:  true,
+		})
+	}
+	return holidays
+}
+
+// easterSunday computes the Gregorian Easter date (anonymous Gregorian algorithm).
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return civilDate(year, time.Month(month), day)
+}
+
+func civilDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
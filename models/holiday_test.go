@@ -0,0 +1,62 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestItalianHolidaysEasterMonday(t *testing.T) {
+	cases := map[int]time.Time{
+		2024: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC),
+		2025: time.Date(2025, time.April, 21, 0, 0, 0, 0, time.UTC),
+		2026: time.Date(2026, time.April, 6, 0, 0, 0, 0, time.UTC),
+		2027: time.Date(2027, time.March, 29, 0, 0, 0, 0, time.UTC),
+	}
+
+	for year, want := range cases {
+		found := false
+		for _, h := range models.ItalianHolidays(year) {
+			if h.Reason == "Lunedì dell'Angelo" {
+				found = true
+				if !h.StartsOn.Equal(want) {
+					t.Errorf("Easter Monday %d: expected %s, got %s", year, want.Format("2006-01-02"), h.StartsOn.Format("2006-01-02"))
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Easter Monday missing for %d", year)
+		}
+	}
+}
+
+func TestClosureCalendarIsClosed(t *testing.T) {
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calendar := models.ClosureCalendar(models.ItalianHolidays(2025))
+	calendar = append(calendar, models.Closure{
+		StartsOn: time.Date(2025, time.August, 11, 0, 0, 0, 0, time.UTC),
+		EndsOn:   time.Date(2025, time.August, 22, 0, 0, 0, 0, time.UTC),
+	})
+
+	cases := []struct {
+		local time.Time
+		want  bool
+	}{
+		{time.Date(2025, time.December, 25, 10, 0, 0, 0, loc), true},
+		{time.Date(2025, time.August, 11, 7, 0, 0, 0, loc), true},
+		{time.Date(2025, time.August, 22, 21, 0, 0, 0, loc), true},
+		{time.Date(2025, time.August, 23, 9, 0, 0, 0, loc), false},
+		{time.Date(2025, time.March, 3, 9, 0, 0, 0, loc), false},
+	}
+
+	for _, c := range cases {
+		if got := calendar.IsClosed(c.local); got != c.want {
+			t.Errorf("IsClosed(%v) = %v, want %v", c.local, got, c.want)
+		}
+	}
+}
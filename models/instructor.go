@@ -1,18 +1,37 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"time"
 )
 
+var (
+	ErrInstructorHasBookings = errors.New("instructor has upcoming bookings")
+	ErrInstructorHasClasses  = errors.New("instructor has upcoming class sessions")
+	ErrInstructorArchived    = errors.New("instructor is archived")
+)
+
 type Instructor struct {
-	ID        int64
-	FirstName string
-	LastName  string
-	MaxSlots  int
-	Enabled   bool
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID         int64
+	FirstName  string
+	LastName   string
+	MaxSlots   int
+	Enabled    bool
+	LocationID int64
+	// LocationName and TimeZone are those of the instructor's location
+	LocationName string
+	TimeZone     string
+	// ArchivedAt is set once the instructor leaves; their bookings stay
+	ArchivedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Location returns the time zone the instructor's schedule is expressed in.
+func (i *Instructor) Location() *time.Location {
+	return LoadTimeZone(i.TimeZone)
 }
 
 type InstructorRepository struct {
@@ -23,27 +42,57 @@ func NewInstructorRepository(db *sql.DB) *InstructorRepository {
 	return &InstructorRepository{db: db}
 }
 
+const instructorColumns = `
+	i.id, i.first_name, i.last_name, i.max_slots, i.enabled, i.location_id, l.name, l.time_zone, i.archived_at, i.created_at, i.updated_at
+	FROM instructors i
+	JOIN locations l ON l.id = i.location_id
+`
+
+// instructorLastName is the last name of an instructor joined as i, marked
+// when they are archived so history still names who held each booking.
+const instructorLastName = `CASE WHEN i.archived_at IS NULL THEN i.last_name ELSE TRIM(i.last_name || ' (archiviato)') END`
+
+func scanInstructor(row rowScanner) (*Instructor, error) {
+	var instructor Instructor
+	err := row.Scan(
+		&instructor.ID,
+		&instructor.FirstName,
+		&instructor.LastName,
+		&instructor.MaxSlots,
+		&instructor.Enabled,
+		&instructor.LocationID,
+		&instructor.LocationName,
+		&instructor.TimeZone,
+		&instructor.ArchivedAt,
+		&instructor.CreatedAt,
+		&instructor.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &instructor, nil
+}
+
+// GetAll returns every instructor, archived ones last.
 func (r *InstructorRepository) GetAll() ([]*Instructor, error) {
-	query := `
-		SELECT id, first_name, last_name, max_slots, enabled, created_at, updated_at
-		FROM instructors
-		ORDER BY first_name, last_name
-	`
+	query := `SELECT ` + instructorColumns + ` ORDER BY i.archived_at IS NOT NULL, i.first_name, i.last_name`
 
 	return r.queryMany(query)
 }
 
 func (r *InstructorRepository) GetEnabled() ([]*Instructor, error) {
-	query := `
-		SELECT id, first_name, last_name, max_slots, enabled, created_at, updated_at
-		FROM instructors
-		WHERE enabled = TRUE
-		ORDER BY first_name, last_name
-	`
+	query := `SELECT ` + instructorColumns + ` WHERE i.enabled = TRUE ORDER BY i.first_name, i.last_name`
 
 	return r.queryMany(query)
 }
 
+// GetEnabledByLocation returns the enabled instructors working at a location.
+func (r *InstructorRepository) GetEnabledByLocation(locationID int64) ([]*Instructor, error) {
+	query := `SELECT ` + instructorColumns + ` WHERE i.enabled = TRUE AND i.location_id = $1 ORDER BY i.first_name, i.last_name`
+
+	return r.queryMany(query, locationID)
+}
+
 func (r *InstructorRepository) queryMany(query string, args ...interface{}) ([]*Instructor, error) {
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
@@ -53,78 +102,35 @@ func (r *InstructorRepository) queryMany(query string, args ...interface{}) ([]*
 
 	var instructors []*Instructor
 	for rows.Next() {
-		var instructor Instructor
-		err := rows.Scan(
-			&instructor.ID,
-			&instructor.FirstName,
-			&instructor.LastName,
-			&instructor.MaxSlots,
-			&instructor.Enabled,
-			&instructor.CreatedAt,
-			&instructor.UpdatedAt,
-		)
+		instructor, err := scanInstructor(rows)
 		if err != nil {
 			return nil, err
 		}
-		instructors = append(instructors, &instructor)
+		instructors = append(instructors, instructor)
 	}
 
 	return instructors, rows.Err()
 }
 
 func (r *InstructorRepository) GetByID(id int64) (*Instructor, error) {
-	query := `
-		SELECT id, first_name, last_name, max_slots, enabled, created_at, updated_at
-		FROM instructors
-		WHERE id = $1
-	`
-
-	var instructor Instructor
-	err := r.db.QueryRow(query, id).Scan(
-		&instructor.ID,
-		&instructor.FirstName,
-		&instructor.LastName,
-		&instructor.MaxSlots,
-		&instructor.Enabled,
-		&instructor.CreatedAt,
-		&instructor.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &instructor, nil
+	return scanInstructor(r.db.QueryRow(`SELECT `+instructorColumns+` WHERE i.id = $1`, id))
 }
 
 func (r *InstructorRepository) GetEnabledByID(id int64) (*Instructor, error) {
-	query := `
-		SELECT id, first_name, last_name, max_slots, enabled, created_at, updated_at
-		FROM instructors
-		WHERE id = $1 AND enabled = TRUE
-	`
-
-	var instructor Instructor
-	err := r.db.QueryRow(query, id).Scan(
-		&instructor.ID,
-		&instructor.FirstName,
-		&instructor.LastName,
-		&instructor.MaxSlots,
-		&instructor.Enabled,
-		&instructor.CreatedAt,
-		&instructor.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &instructor, nil
+	return scanInstructor(r.db.QueryRow(`SELECT `+instructorColumns+` WHERE i.id = $1 AND i.enabled = TRUE`, id))
 }
 
+// Create inserts an instructor; without a location they join the first one.
 func (r *InstructorRepository) Create(instructor *Instructor) error {
 	query := `
-		INSERT INTO instructors (first_name, last_name, max_slots, enabled)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, updated_at
+		WITH inserted AS (
+			INSERT INTO instructors (first_name, last_name, max_slots, enabled, location_id)
+			VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, 0), (SELECT MIN(id) FROM locations)))
+			RETURNING id, location_id, created_at, updated_at
+		)
+		SELECT inserted.id, inserted.location_id, l.name, l.time_zone, inserted.created_at, inserted.updated_at
+		FROM inserted
+		JOIN locations l ON l.id = inserted.location_id
 	`
 
 	err := r.db.QueryRow(query,
@@ -132,7 +138,8 @@ func (r *InstructorRepository) Create(instructor *Instructor) error {
 		instructor.LastName,
 		instructor.MaxSlots,
 		instructor.Enabled,
-	).Scan(&instructor.ID, &instructor.CreatedAt, &instructor.UpdatedAt)
+		instructor.LocationID,
+	).Scan(&instructor.ID, &instructor.LocationID, &instructor.LocationName, &instructor.TimeZone, &instructor.CreatedAt, &instructor.UpdatedAt)
 
 	return err
 }
@@ -140,24 +147,118 @@ func (r *InstructorRepository) Create(instructor *Instructor) error {
 func (r *InstructorRepository) Update(instructor *Instructor) error {
 	query := `
 		UPDATE instructors
-		SET first_name = $2, last_name = $3, max_slots = $4, enabled = $5, updated_at = $6
-		WHERE id = $1
+		SET first_name = $2, last_name = $3, max_slots = $4, enabled = $5, updated_at = $6,
+			location_id = COALESCE(NULLIF($7, 0), location_id)
+		WHERE id = $1 AND archived_at IS NULL
 	`
 
-	_, err := r.db.Exec(query,
+	result, err := r.db.Exec(query,
 		instructor.ID,
 		instructor.FirstName,
 		instructor.LastName,
 		instructor.MaxSlots,
 		instructor.Enabled,
 		time.Now().UTC(),
+		instructor.LocationID,
 	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInstructorArchived
+	}
 
-	return err
+	return nil
+}
+
+// Archive disables an instructor for good while keeping them, and every
+// booking and class session they held, in the history. Members' upcoming
+// bookings must be reassigned or cancelled first: while any remain they are
+// returned with ErrInstructorHasBookings. Upcoming blocks of the instructor
+// are cancelled and their waitlist entries dropped. Archiving twice is a no-op.
+func (r *InstructorRepository) Archive(id int64, now time.Time) ([]*BookingWithUser, error) {
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The row lock waits for bookings being written with the instructor,
+	// which hold it in share mode until they commit
+	var archived bool
+	err = tx.QueryRow(`SELECT archived_at IS NOT NULL FROM instructors WHERE id = $1 FOR UPDATE`, id).Scan(&archived)
+	if err != nil {
+		return nil, err
+	}
+	if archived {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT b.id, b.user_id, b.instructor_id, b.created_at, b.starts_at, b.type,
+			   u.first_name, u.last_name, u.email, u.sub_type,
+			   b.service_id, s.name, b.capacity_weight, b.duration_minutes
+		FROM bookings b
+		LEFT JOIN users u ON u.id = b.user_id
+		LEFT JOIN services s ON s.id = b.service_id
+		WHERE b.instructor_id = $1 AND b.type = 'SIMPLE' AND b.cancelled_at IS NULL AND b.starts_at >= $2
+		ORDER BY b.starts_at ASC
+	`, id, now)
+	if err != nil {
+		return nil, err
+	}
+	upcoming, err := scanBookingsWithUsers(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(upcoming) > 0 {
+		return upcoming, ErrInstructorHasBookings
+	}
+
+	var hasClasses bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM class_sessions WHERE instructor_id = $1 AND starts_at >= $2)
+	`, id, now).Scan(&hasClasses)
+	if err != nil {
+		return nil, err
+	}
+	if hasClasses {
+		return nil, ErrInstructorHasClasses
+	}
+
+	_, err = tx.Exec(`
+		UPDATE bookings SET status = 'cancelled_by_admin', cancelled_at = $2
+		WHERE instructor_id = $1 AND type <> 'SIMPLE' AND cancelled_at IS NULL AND starts_at >= $2
+	`, id, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM waitlist_entries WHERE instructor_id = $1`, id); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE instructors SET enabled = FALSE, archived_at = $2, updated_at = $2 WHERE id = $1
+	`, id, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, tx.Commit()
 }
 
-func (r *InstructorRepository) Delete(id int64) error {
-	query := `DELETE FROM instructors WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+// checkInstructorActiveTx holds the instructor in share mode, so they cannot
+// be archived until the transaction ends, and returns ErrSlotUnavailable when
+// they already are.
+func checkInstructorActiveTx(tx *sql.Tx, instructorID int64) error {
+	var archived bool
+	err := tx.QueryRow(`SELECT archived_at IS NOT NULL FROM instructors WHERE id = $1 FOR KEY SHARE`, instructorID).Scan(&archived)
+	if err == sql.ErrNoRows || archived {
+		return ErrSlotUnavailable
+	}
 	return err
 }
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidBookingLimit = errors.New("invalid booking limit")
+	// ErrBookingLimit is wrapped by the error of each limit a booking can hit
+	ErrBookingLimit      = errors.New("booking limit reached")
+	ErrDailyLimit        = fmt.Errorf("%w: too many bookings on this day", ErrBookingLimit)
+	ErrWeeklyLimit       = fmt.Errorf("%w: too many bookings in this week", ErrBookingLimit)
+	ErrOpenBookingsLimit = fmt.Errorf("%w: too many upcoming bookings", ErrBookingLimit)
+)
+
+// BookingLimit caps the bookings a member of a subscription type can hold.
+// A zero maximum disables that limit.
+type BookingLimit struct {
+	SubType SubType
+	// MaxPerDay counts the bookings starting on the same business day
+	MaxPerDay int
+	// MaxPerWeek counts the bookings starting in the same ISO week
+	MaxPerWeek int
+	// MaxOpen counts the bookings that have not started yet
+	MaxOpen   int
+	UpdatedAt time.Time
+}
+
+func (l *BookingLimit) Validate() error {
+	if l.SubType != SubTypeShared && l.SubType != SubTypeSingle {
+		return fmt.Errorf("%w: unknown subscription type", ErrInvalidBookingLimit)
+	}
+	if l.MaxPerDay < 0 || l.MaxPerWeek < 0 || l.MaxOpen < 0 {
+		return fmt.Errorf("%w: limits cannot be negative", ErrInvalidBookingLimit)
+	}
+	return nil
+}
+
+// Unlimited reports whether no limit is set.
+func (l *BookingLimit) Unlimited() bool {
+	return l.MaxPerDay == 0 && l.MaxPerWeek == 0 && l.MaxOpen == 0
+}
+
+// BookingCounts are the bookings and class enrollments a member already holds,
+// relative to a new one.
+type BookingCounts struct {
+	// SameDay and SameWeek start on the day and in the ISO week of the new booking
+	SameDay  int
+	SameWeek int
+	// Open have not started yet
+	Open int
+}
+
+// Check returns the error of the first limit one more booking would exceed.
+func (l *BookingLimit) Check(counts BookingCounts) error {
+	if l.MaxPerDay > 0 && counts.SameDay >= l.MaxPerDay {
+		return ErrDailyLimit
+	}
+	if l.MaxPerWeek > 0 && counts.SameWeek >= l.MaxPerWeek {
+		return ErrWeeklyLimit
+	}
+	if l.MaxOpen > 0 && counts.Open >= l.MaxOpen {
+		return ErrOpenBookingsLimit
+	}
+	return nil
+}
+
+// LimitWindows returns the day and the ISO week, Monday to Monday, of loc
+// that contain startsAt.
+func LimitWindows(startsAt time.Time, loc *time.Location) (dayStart, dayEnd, weekStart, weekEnd time.Time) {
+	local := startsAt.In(loc)
+	dayStart = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	dayEnd = dayStart.AddDate(0, 0, 1)
+	weekStart = dayStart.AddDate(0, 0, -((int(local.Weekday()) + 6) % 7))
+	weekEnd = weekStart.AddDate(0, 0, 7)
+	return dayStart, dayEnd, weekStart, weekEnd
+}
+
+type BookingLimitRepository struct {
+	db *sql.DB
+}
+
+func NewBookingLimitRepository(db *sql.DB) *BookingLimitRepository {
+	return &BookingLimitRepository{db: db}
+}
+
+// GetAll returns the limits of every subscription type, unlimited when not stored.
+func (r *BookingLimitRepository) GetAll() ([]*BookingLimit, error) {
+	rows, err := r.db.Query(`SELECT sub_type, max_per_day, max_per_week, max_open, updated_at FROM booking_limits`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := map[SubType]*BookingLimit{}
+	for rows.Next() {
+		var limit BookingLimit
+		if err := rows.Scan(&limit.SubType, &limit.MaxPerDay, &limit.MaxPerWeek, &limit.MaxOpen, &limit.UpdatedAt); err != nil {
+			return nil, err
+		}
+		stored[limit.SubType] = &limit
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var limits []*BookingLimit
+	for _, subType := range []SubType{SubTypeShared, SubTypeSingle} {
+		limit, ok := stored[subType]
+		if !ok {
+			limit = &BookingLimit{SubType: subType}
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+// Save stores the limits of a subscription type.
+func (r *BookingLimitRepository) Save(limit *BookingLimit) error {
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+		INSERT INTO booking_limits (sub_type, max_per_day, max_per_week, max_open, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (sub_type) DO UPDATE
+		SET max_per_day = EXCLUDED.max_per_day,
+			max_per_week = EXCLUDED.max_per_week,
+			max_open = EXCLUDED.max_open,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, limit.SubType, limit.MaxPerDay, limit.MaxPerWeek, limit.MaxOpen, time.Now().UTC()).Scan(&limit.UpdatedAt)
+}
+
+// checkBookingLimitsTx locks the user row, so concurrent bookings and class
+// enrollments of the same member serialize, and returns the error of the
+// limit one more of them starting at startsAt would exceed. Days and weeks
+// are those of loc, the time zone of the instructor. Class enrollments count
+// like bookings; the booking excludeID is left out.
+func checkBookingLimitsTx(tx *sql.Tx, userID string, startsAt time.Time, loc *time.Location, excludeID int64, now time.Time) error {
+	var limit BookingLimit
+	err := tx.QueryRow(`
+		SELECT u.sub_type, COALESCE(l.max_per_day, 0), COALESCE(l.max_per_week, 0), COALESCE(l.max_open, 0)
+		FROM users u
+		LEFT JOIN booking_limits l ON l.sub_type = u.sub_type
+		WHERE u.id = $1
+		FOR UPDATE OF u
+	`, userID).Scan(&limit.SubType, &limit.MaxPerDay, &limit.MaxPerWeek, &limit.MaxOpen)
+	if err != nil {
+		return err
+	}
+	if limit.Unlimited() {
+		return nil
+	}
+
+	dayStart, dayEnd, weekStart, weekEnd := LimitWindows(startsAt, loc)
+	var counts BookingCounts
+	err = tx.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE starts_at >= $2 AND starts_at < $3),
+			COUNT(*) FILTER (WHERE starts_at >= $4 AND starts_at < $5),
+			COUNT(*) FILTER (WHERE starts_at > $6)
+		FROM (
+			SELECT starts_at FROM bookings
+			WHERE user_id = $1 AND cancelled_at IS NULL AND id <> $7
+			UNION ALL
+			SELECT cs.starts_at FROM class_enrollments ce
+			JOIN class_sessions cs ON cs.id = ce.session_id
+			WHERE ce.user_id = $1 AND ce.cancelled_at IS NULL
+		) held
+	`, userID, dayStart, dayEnd, weekStart, weekEnd, now, excludeID).Scan(&counts.SameDay, &counts.SameWeek, &counts.Open)
+	if err != nil {
+		return err
+	}
+
+	return limit.Check(counts)
+}
@@ -0,0 +1,89 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestBookingLimitCheck(t *testing.T) {
+	limit := models.BookingLimit{SubType: models.SubTypeShared, MaxPerDay: 1, MaxPerWeek: 3, MaxOpen: 4}
+
+	tests := []struct {
+		name   string
+		counts models.BookingCounts
+		want   error
+	}{
+		{"Within limits", models.BookingCounts{SameDay: 0, SameWeek: 2, Open: 3}, nil},
+		{"Daily", models.BookingCounts{SameDay: 1, SameWeek: 1, Open: 1}, models.ErrDailyLimit},
+		{"Weekly", models.BookingCounts{SameDay: 0, SameWeek: 3, Open: 3}, models.ErrWeeklyLimit},
+		{"Open", models.BookingCounts{SameDay: 0, SameWeek: 0, Open: 4}, models.ErrOpenBookingsLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := limit.Check(tt.counts)
+			if err != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+			if tt.want != nil && !errors.Is(err, models.ErrBookingLimit) {
+				t.Errorf("Expected %v to wrap ErrBookingLimit", err)
+			}
+		})
+	}
+}
+
+func TestBookingLimitZeroIsUnlimited(t *testing.T) {
+	limit := models.BookingLimit{SubType: models.SubTypeSingle}
+	if !limit.Unlimited() {
+		t.Fatal("Expected a limit without maximums to be unlimited")
+	}
+	if err := limit.Check(models.BookingCounts{SameDay: 10, SameWeek: 50, Open: 100}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestBookingLimitValidate(t *testing.T) {
+	if err := (&models.BookingLimit{SubType: "GOLD"}).Validate(); !errors.Is(err, models.ErrInvalidBookingLimit) {
+		t.Errorf("Expected ErrInvalidBookingLimit for unknown subscription type, got %v", err)
+	}
+	if err := (&models.BookingLimit{SubType: models.SubTypeShared, MaxOpen: -1}).Validate(); !errors.Is(err, models.ErrInvalidBookingLimit) {
+		t.Errorf("Expected ErrInvalidBookingLimit for negative limit, got %v", err)
+	}
+}
+
+func TestLimitWindows(t *testing.T) {
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sunday 23:30 in Rome is still in the ISO week that started on Monday
+	startsAt := time.Date(2024, 3, 10, 23, 30, 0, 0, loc)
+	dayStart, dayEnd, weekStart, weekEnd := models.LimitWindows(startsAt.UTC(), loc)
+
+	if want := time.Date(2024, 3, 10, 0, 0, 0, 0, loc); !dayStart.Equal(want) {
+		t.Errorf("Expected day start %v, got %v", want, dayStart)
+	}
+	if want := time.Date(2024, 3, 11, 0, 0, 0, 0, loc); !dayEnd.Equal(want) {
+		t.Errorf("Expected day end %v, got %v", want, dayEnd)
+	}
+	if want := time.Date(2024, 3, 4, 0, 0, 0, 0, loc); !weekStart.Equal(want) {
+		t.Errorf("Expected week start %v, got %v", want, weekStart)
+	}
+	if want := time.Date(2024, 3, 11, 0, 0, 0, 0, loc); !weekEnd.Equal(want) {
+		t.Errorf("Expected week end %v, got %v", want, weekEnd)
+	}
+
+	// The same instant is already Monday morning in Tokyo, a new week
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, weekStart, _ = models.LimitWindows(startsAt.UTC(), tokyo)
+	if want := time.Date(2024, 3, 11, 0, 0, 0, 0, tokyo); !weekStart.Equal(want) {
+		t.Errorf("Expected Tokyo week start %v, got %v", want, weekStart)
+	}
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidLocation = errors.New("invalid location")
+	ErrLocationInUse   = errors.New("location has instructors")
+)
+
+// Location is a studio. Slots, opening hours and closure days of its
+// instructors are expressed in its time zone.
+type Location struct {
+	ID        int64
+	Name      string
+	Address   string
+	TimeZone  string
+	Enabled   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (l *Location) Validate() error {
+	l.Name = strings.TrimSpace(l.Name)
+	l.Address = strings.TrimSpace(l.Address)
+	l.TimeZone = strings.TrimSpace(l.TimeZone)
+	if l.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidLocation)
+	}
+	if l.TimeZone == "" {
+		l.TimeZone = BusinessTimeZone
+	}
+	if _, err := time.LoadLocation(l.TimeZone); err != nil || l.TimeZone == "Local" {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidLocation, l.TimeZone)
+	}
+	return nil
+}
+
+// LoadTimeZone returns the named time zone, falling back to the business
+// time zone when the name is empty or unknown.
+func LoadTimeZone(name string) *time.Location {
+	if name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(BusinessTimeZone)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+type LocationRepository struct {
+	db *sql.DB
+}
+
+func NewLocationRepository(db *sql.DB) *LocationRepository {
+	return &LocationRepository{db: db}
+}
+
+const locationColumns = `id, name, address, time_zone, enabled, created_at, updated_at`
+
+func scanLocation(row rowScanner) (*Location, error) {
+	var location Location
+	err := row.Scan(
+		&location.ID,
+		&location.Name,
+		&location.Address,
+		&location.TimeZone,
+		&location.Enabled,
+		&location.CreatedAt,
+		&location.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+func (r *LocationRepository) GetAll() ([]*Location, error) {
+	return r.queryMany(`SELECT ` + locationColumns + ` FROM locations ORDER BY id`)
+}
+
+func (r *LocationRepository) GetEnabled() ([]*Location, error) {
+	return r.queryMany(`SELECT ` + locationColumns + ` FROM locations WHERE enabled = TRUE ORDER BY id`)
+}
+
+func (r *LocationRepository) queryMany(query string) ([]*Location, error) {
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []*Location
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+
+	return locations, rows.Err()
+}
+
+func (r *LocationRepository) GetByID(id int64) (*Location, error) {
+	return scanLocation(r.db.QueryRow(`SELECT `+locationColumns+` FROM locations WHERE id = $1`, id))
+}
+
+func (r *LocationRepository) Create(location *Location) error {
+	if err := location.Validate(); err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+		INSERT INTO locations (name, address, time_zone, enabled)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, location.Name, location.Address, location.TimeZone, location.Enabled).
+		Scan(&location.ID, &location.CreatedAt, &location.UpdatedAt)
+}
+
+// Update changes a location. Changing the time zone moves the opening hours of
+// its instructors but not the bookings already made.
+func (r *LocationRepository) Update(location *Location) error {
+	if err := location.Validate(); err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE locations
+		SET name = $2, address = $3, time_zone = $4, enabled = $5, updated_at = $6
+		WHERE id = $1
+	`, location.ID, location.Name, location.Address, location.TimeZone, location.Enabled, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete removes a location without instructors nor bookings, together with
+// its closures. It returns ErrLocationInUse otherwise.
+func (r *LocationRepository) Delete(id int64) error {
+	var inUse bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM instructors WHERE location_id = $1)
+			OR EXISTS (SELECT 1 FROM bookings WHERE location_id = $1)
+	`, id).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrLocationInUse
+	}
+
+	result, err := r.db.Exec(`DELETE FROM locations WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestLocationValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		location models.Location
+		wantErr  bool
+	}{
+		{"Valid", models.Location{Name: "Centro", TimeZone: "Europe/Rome"}, false},
+		{"Other time zone", models.Location{Name: "Londra", TimeZone: "Europe/London"}, false},
+		{"Default time zone", models.Location{Name: "Centro"}, false},
+		{"Blank name", models.Location{Name: " ", TimeZone: "Europe/Rome"}, true},
+		{"Unknown time zone", models.Location{Name: "Centro", TimeZone: "Europe/Atlantis"}, true},
+		{"Local time zone", models.Location{Name: "Centro", TimeZone: "Local"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.location.Validate()
+			if tt.wantErr && !errors.Is(err, models.ErrInvalidLocation) {
+				t.Errorf("Expected ErrInvalidLocation, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLocationValidateDefaultsTimeZone(t *testing.T) {
+	location := models.Location{Name: "Centro"}
+	if err := location.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if location.TimeZone != models.BusinessTimeZone {
+		t.Errorf("Expected time zone %s, got %s", models.BusinessTimeZone, location.TimeZone)
+	}
+}
+
+func TestLoadTimeZone(t *testing.T) {
+	if got := models.LoadTimeZone("America/New_York").String(); got != "America/New_York" {
+		t.Errorf("Expected America/New_York, got %s", got)
+	}
+	for _, name := range []string{"", "Europe/Atlantis"} {
+		if got := models.LoadTimeZone(name).String(); got != models.BusinessTimeZone {
+			t.Errorf("LoadTimeZone(%q) = %s, want %s", name, got, models.BusinessTimeZone)
+		}
+	}
+}
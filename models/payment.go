@@ -0,0 +1,233 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrPaymentMismatch = errors.New("payment does not match the checkout")
+
+type PaymentStatus string
+
+const (
+	PaymentPending PaymentStatus = "PENDING"
+	PaymentPaid    PaymentStatus = "PAID"
+	PaymentFailed  PaymentStatus = "FAILED"
+)
+
+// Payment is a checkout a member opened to buy a plan. CheckoutID is the id
+// of the checkout at the provider, SubscriptionID the subscription a PAID
+// payment bought.
+type Payment struct {
+	ID             int64
+	UserID         string
+	PlanID         int64
+	PlanName       string
+	AmountCents    int
+	Currency       string
+	Provider       string
+	CheckoutID     sql.NullString
+	Status         PaymentStatus
+	SubscriptionID sql.NullInt64
+	PaidAt         sql.NullTime
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type PaymentRepository struct {
+	db *sql.DB
+}
+
+func NewPaymentRepository(db *sql.DB) *PaymentRepository {
+	return &PaymentRepository{db: db}
+}
+
+const paymentQuery = `
+	SELECT p.id, p.user_id, p.plan_id, pl.name, p.amount_cents, p.currency, p.provider, p.checkout_id,
+		p.status, p.subscription_id, p.paid_at, p.created_at, p.updated_at
+	FROM payments p
+	JOIN plans pl ON pl.id = p.plan_id
+`
+
+func scanPayment(row rowScanner) (*Payment, error) {
+	var p Payment
+	err := row.Scan(
+		&p.ID,
+		&p.UserID,
+		&p.PlanID,
+		&p.PlanName,
+		&p.AmountCents,
+		&p.Currency,
+		&p.Provider,
+		&p.CheckoutID,
+		&p.Status,
+		&p.SubscriptionID,
+		&p.PaidAt,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *PaymentRepository) GetByID(id int64) (*Payment, error) {
+	return scanPayment(r.db.QueryRow(paymentQuery+`WHERE p.id = $1`, id))
+}
+
+// GetByUserID returns the payments of a member, the latest first.
+func (r *PaymentRepository) GetByUserID(userID string) ([]*Payment, error) {
+	rows, err := r.db.Query(paymentQuery+`WHERE p.user_id = $1 ORDER BY p.created_at DESC, p.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []*Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+
+	return payments, rows.Err()
+}
+
+// Start opens a PENDING payment of a member for an enabled plan at its
+// current price, in currency with provider. Plans that are free or not on
+// sale return ErrPlanUnavailable.
+func (r *PaymentRepository) Start(userID string, planID int64, currency, provider string) (*Payment, error) {
+	plan, err := scanPlan(r.db.QueryRow(`SELECT `+planColumns+` FROM plans WHERE id = $1`, planID))
+	if err == sql.ErrNoRows || (err == nil && (!plan.Enabled || plan.PriceCents <= 0)) {
+		return nil, ErrPlanUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	payment := &Payment{
+		UserID:      userID,
+		PlanID:      plan.ID,
+		PlanName:    plan.Name,
+		AmountCents: plan.PriceCents,
+		Currency:    currency,
+		Provider:    provider,
+		Status:      PaymentPending,
+	}
+	err = r.db.QueryRow(`
+		INSERT INTO payments (user_id, plan_id, amount_cents, currency, provider)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, payment.UserID, payment.PlanID, payment.AmountCents, payment.Currency, payment.Provider).
+		Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// SetCheckout records the checkout the provider opened for a payment.
+func (r *PaymentRepository) SetCheckout(id int64, checkoutID string) error {
+	_, err := r.db.Exec(`
+		UPDATE payments SET checkout_id = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1
+	`, id, checkoutID)
+	return err
+}
+
+// lockPaymentTx locks a payment and checks it belongs to the checkout of an
+// event, returning ErrPaymentMismatch otherwise.
+func lockPaymentTx(tx *sql.Tx, id int64, provider, checkoutID string) (*Payment, error) {
+	payment, err := scanPayment(tx.QueryRow(paymentQuery+`WHERE p.id = $1 FOR UPDATE OF p`, id))
+	if err != nil {
+		return nil, err
+	}
+	if payment.Provider != provider || (payment.CheckoutID.Valid && payment.CheckoutID.String != checkoutID) {
+		return nil, ErrPaymentMismatch
+	}
+	return payment, nil
+}
+
+// MarkPaid grants the plan of a payment once its provider reports the
+// checkout paid for amountCents, and returns the payment. The plan is
+// granted even if it was disabled meanwhile, at the price paid. Reporting a
+// payment already PAID again changes nothing, so webhooks can be retried.
+func (r *PaymentRepository) MarkPaid(id int64, provider, checkoutID string, amountCents int) (*Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	payment, err := lockPaymentTx(tx, id, provider, checkoutID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status == PaymentPaid {
+		return payment, nil
+	}
+	if amountCents != payment.AmountCents {
+		return nil, ErrPaymentMismatch
+	}
+
+	plan, err := scanPlan(tx.QueryRow(`SELECT `+planColumns+` FROM plans WHERE id = $1 FOR SHARE`, payment.PlanID))
+	if err != nil {
+		return nil, err
+	}
+	plan.PriceCents = payment.AmountCents
+	subscription, err := subscribeTx(tx, payment.UserID, plan, time.Time{}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(`
+		UPDATE payments
+		SET status = $2, checkout_id = $3, subscription_id = $4, paid_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING status, checkout_id, subscription_id, paid_at, updated_at
+	`, payment.ID, PaymentPaid, checkoutID, subscription.ID).
+		Scan(&payment.Status, &payment.CheckoutID, &payment.SubscriptionID, &payment.PaidAt, &payment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// MarkFailed records that the checkout of a PENDING payment expired or was
+// declined. Payments already settled are left as they are.
+func (r *PaymentRepository) MarkFailed(id int64, provider, checkoutID string) (*Payment, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	payment, err := lockPaymentTx(tx, id, provider, checkoutID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != PaymentPending {
+		return payment, nil
+	}
+
+	err = tx.QueryRow(`
+		UPDATE payments SET status = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING status, updated_at
+	`, payment.ID, PaymentFailed).Scan(&payment.Status, &payment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
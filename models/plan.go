@@ -0,0 +1,177 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPlan     = errors.New("invalid plan")
+	ErrPlanUnavailable = errors.New("plan unavailable")
+	ErrPlanInUse       = errors.New("plan has subscriptions")
+)
+
+// Plan is a subscription the studio sells: DurationDays days of validity with
+// Accesses accesses. PolicyID, when set, overrides the cancellation policy of
+// the subscription type for the members on the plan. MaxFreezeDays is how many
+// days each subscription to the plan can be frozen in total.
+type Plan struct {
+	ID            int64
+	Name          string
+	DurationDays  int
+	Accesses      int
+	SubType       SubType
+	PriceCents    int
+	PolicyID      sql.NullInt64
+	MaxFreezeDays int
+	Enabled       bool
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (p *Plan) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPlan)
+	}
+	if p.DurationDays <= 0 || p.DurationDays > 1095 {
+		return fmt.Errorf("%w: duration must be between 1 and 1095 days", ErrInvalidPlan)
+	}
+	if p.Accesses < 0 {
+		return fmt.Errorf("%w: accesses cannot be negative", ErrInvalidPlan)
+	}
+	if p.SubType != SubTypeShared && p.SubType != SubTypeSingle {
+		return fmt.Errorf("%w: unknown subscription type", ErrInvalidPlan)
+	}
+	if p.PriceCents < 0 {
+		return fmt.Errorf("%w: price cannot be negative", ErrInvalidPlan)
+	}
+	if p.MaxFreezeDays < 0 || p.MaxFreezeDays > 365 {
+		return fmt.Errorf("%w: freeze days must be between 0 and 365", ErrInvalidPlan)
+	}
+	return nil
+}
+
+// LastDay returns the last valid day of a subscription to the plan starting
+// on the civil date startsOn.
+func (p *Plan) LastDay(startsOn time.Time) time.Time {
+	return startsOn.AddDate(0, 0, p.DurationDays-1)
+}
+
+type PlanRepository struct {
+	db *sql.DB
+}
+
+func NewPlanRepository(db *sql.DB) *PlanRepository {
+	return &PlanRepository{db: db}
+}
+
+const planColumns = `
+	id, name, duration_days, accesses, sub_type, price_cents, policy_id, max_freeze_days, enabled,
+	created_at, updated_at
+`
+
+func scanPlan(row rowScanner) (*Plan, error) {
+	var plan Plan
+	err := row.Scan(
+		&plan.ID,
+		&plan.Name,
+		&plan.DurationDays,
+		&plan.Accesses,
+		&plan.SubType,
+		&plan.PriceCents,
+		&plan.PolicyID,
+		&plan.MaxFreezeDays,
+		&plan.Enabled,
+		&plan.CreatedAt,
+		&plan.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// GetAll returns the catalog, plans on sale first.
+func (r *PlanRepository) GetAll() ([]*Plan, error) {
+	rows, err := r.db.Query(`SELECT ` + planColumns + ` FROM plans ORDER BY enabled DESC, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plans []*Plan
+	for rows.Next() {
+		plan, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+
+	return plans, rows.Err()
+}
+
+func (r *PlanRepository) GetByID(id int64) (*Plan, error) {
+	return scanPlan(r.db.QueryRow(`SELECT `+planColumns+` FROM plans WHERE id = $1`, id))
+}
+
+func (r *PlanRepository) Create(plan *Plan) error {
+	if err := plan.Validate(); err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+		INSERT INTO plans (name, duration_days, accesses, sub_type, price_cents, policy_id, max_freeze_days, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at
+	`, plan.Name, plan.DurationDays, plan.Accesses, plan.SubType, plan.PriceCents, plan.PolicyID, plan.MaxFreezeDays, plan.Enabled).
+		Scan(&plan.ID, &plan.CreatedAt, &plan.UpdatedAt)
+}
+
+// Update changes a plan. Subscriptions already sold keep the values they were
+// sold with.
+func (r *PlanRepository) Update(plan *Plan) error {
+	if err := plan.Validate(); err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+		UPDATE plans
+		SET name = $2, duration_days = $3, accesses = $4, sub_type = $5, price_cents = $6,
+			policy_id = $7, max_freeze_days = $8, enabled = $9, updated_at = $10
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, plan.ID, plan.Name, plan.DurationDays, plan.Accesses, plan.SubType, plan.PriceCents,
+		plan.PolicyID, plan.MaxFreezeDays, plan.Enabled, time.Now().UTC()).
+		Scan(&plan.CreatedAt, &plan.UpdatedAt)
+}
+
+// Delete removes a plan nobody subscribed to or paid for. Plans with
+// subscriptions or payments return ErrPlanInUse and can only be disabled.
+func (r *PlanRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`
+		DELETE FROM plans
+		WHERE id = $1
+			AND NOT EXISTS (SELECT 1 FROM subscriptions WHERE plan_id = $1)
+			AND NOT EXISTS (SELECT 1 FROM payments WHERE plan_id = $1)
+	`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	if _, err := r.GetByID(id); err != nil {
+		return err
+	}
+	return ErrPlanInUse
+}
@@ -0,0 +1,59 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestPlanValidate(t *testing.T) {
+	base := models.Plan{Name: "Mensile", DurationDays: 30, Accesses: 8, SubType: models.SubTypeShared, PriceCents: 8000}
+
+	tests := []struct {
+		name    string
+		edit    func(p *models.Plan)
+		wantErr bool
+	}{
+		{"Valid", func(p *models.Plan) {}, false},
+		{"Free plan without accesses", func(p *models.Plan) { p.Accesses = 0; p.PriceCents = 0 }, false},
+		{"Blank name", func(p *models.Plan) { p.Name = " " }, true},
+		{"No duration", func(p *models.Plan) { p.DurationDays = 0 }, true},
+		{"Too long", func(p *models.Plan) { p.DurationDays = 1096 }, true},
+		{"Negative accesses", func(p *models.Plan) { p.Accesses = -1 }, true},
+		{"Unknown subscription type", func(p *models.Plan) { p.SubType = "GROUP" }, true},
+		{"Negative price", func(p *models.Plan) { p.PriceCents = -1 }, true},
+		{"Negative freeze days", func(p *models.Plan) { p.MaxFreezeDays = -1 }, true},
+		{"Too many freeze days", func(p *models.Plan) { p.MaxFreezeDays = 366 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := base
+			tt.edit(&plan)
+			err := plan.Validate()
+			if tt.wantErr && !errors.Is(err, models.ErrInvalidPlan) {
+				t.Errorf("Expected ErrInvalidPlan, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPlanLastDay(t *testing.T) {
+	plan := models.Plan{DurationDays: 30}
+	startsOn := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	want := time.Date(2025, 2, 13, 0, 0, 0, 0, time.UTC)
+	if got := plan.LastDay(startsOn); !got.Equal(want) {
+		t.Errorf("Expected last day %s, got %s", want.Format("2006-01-02"), got.Format("2006-01-02"))
+	}
+
+	plan.DurationDays = 1
+	if got := plan.LastDay(startsOn); !got.Equal(startsOn) {
+		t.Errorf("Expected a one day plan to end on its first day, got %s", got.Format("2006-01-02"))
+	}
+}
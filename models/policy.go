@@ -0,0 +1,321 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LateRefund tells what happens to cancellations made after the cutoff.
+type LateRefund string
+
+const (
+	// LateRefundNone never refunds late cancellations
+	LateRefundNone LateRefund = "NONE"
+	// LateRefundPartial refunds a limited number of late cancellations per month
+	LateRefundPartial LateRefund = "PARTIAL"
+	// LateRefundFull always refunds, the cutoff only marks the cancellation as late
+	LateRefundFull LateRefund = "FULL"
+)
+
+var (
+	ErrInvalidPolicy = errors.New("invalid cancellation policy")
+	ErrPolicyTaken   = errors.New("subscription type already has a cancellation policy")
+)
+
+// CancellationPolicy decides whether a cancelled booking gives its access back
+// and how attendance is enforced. Policies with a SubType apply to the users of
+// that subscription type.
+type CancellationPolicy struct {
+	ID              int64
+	Name            string
+	SubType         sql.NullString
+	CutoffHours     int
+	LateRefund      LateRefund
+	FreeLateCancels int
+	// UnmarkedAttendance is assumed for past bookings nobody marked
+	UnmarkedAttendance Attendance
+	// MaxNoShows blocks new bookings once reached within NoShowWindowDays, zero disables it
+	MaxNoShows       int
+	NoShowWindowDays int
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// DefaultCancellationPolicy is used for subscription types without a stored
+// policy: refund only when cancelling at least three hours in advance.
+func DefaultCancellationPolicy() *CancellationPolicy {
+	return &CancellationPolicy{
+		Name:               "Standard",
+		CutoffHours:        3,
+		LateRefund:         LateRefundNone,
+		UnmarkedAttendance: AttendanceAttended,
+		NoShowWindowDays:   30,
+	}
+}
+
+func (p *CancellationPolicy) Cutoff() time.Duration {
+	return time.Duration(p.CutoffHours) * time.Hour
+}
+
+// NoShowWindowStart returns the first instant whose no-shows count towards the penalty.
+func (p *CancellationPolicy) NoShowWindowStart(now time.Time) time.Time {
+	return now.AddDate(0, 0, -p.NoShowWindowDays)
+}
+
+// BlocksBooking reports whether noShows within the window prevent new bookings.
+func (p *CancellationPolicy) BlocksBooking(noShows int) bool {
+	return p.MaxNoShows > 0 && noShows >= p.MaxNoShows
+}
+
+func (p *CancellationPolicy) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPolicy)
+	}
+	if p.CutoffHours < 0 || p.CutoffHours > 720 {
+		return fmt.Errorf("%w: cutoff must be between 0 and 720 hours", ErrInvalidPolicy)
+	}
+	switch p.LateRefund {
+	case LateRefundNone, LateRefundPartial, LateRefundFull:
+	default:
+		return fmt.Errorf("%w: late refund must be NONE, PARTIAL or FULL", ErrInvalidPolicy)
+	}
+	if p.FreeLateCancels < 0 {
+		return fmt.Errorf("%w: free late cancellations cannot be negative", ErrInvalidPolicy)
+	}
+	if p.UnmarkedAttendance != AttendanceAttended && p.UnmarkedAttendance != AttendanceNoShow {
+		return fmt.Errorf("%w: unmarked attendance must be ATTENDED or NO_SHOW", ErrInvalidPolicy)
+	}
+	if p.MaxNoShows < 0 {
+		return fmt.Errorf("%w: maximum no-shows cannot be negative", ErrInvalidPolicy)
+	}
+	if p.NoShowWindowDays <= 0 || p.NoShowWindowDays > 365 {
+		return fmt.Errorf("%w: no-show window must be between 1 and 365 days", ErrInvalidPolicy)
+	}
+	if p.SubType.Valid && p.SubType.String != string(SubTypeShared) && p.SubType.String != string(SubTypeSingle) {
+		return fmt.Errorf("%w: unknown subscription type", ErrInvalidPolicy)
+	}
+	return nil
+}
+
+// CancellationDecision is the outcome of a policy for one cancellation. Reason
+// explains it to the member and is stored on the cancellation event.
+type CancellationDecision struct {
+	PolicyID int64
+	Refund   bool
+	Late     bool
+	Reason   string
+}
+
+// Decide applies the policy to a booking starting at startsAt and cancelled at
+// cancelledAt. lateRefundsUsed is how many late cancellations of the user were
+// already refunded this month.
+func (p *CancellationPolicy) Decide(startsAt, cancelledAt time.Time, lateRefundsUsed int) CancellationDecision {
+	decision := CancellationDecision{PolicyID: p.ID}
+
+	if startsAt.Sub(cancelledAt) >= p.Cutoff() {
+		decision.Refund = true
+		decision.Reason = fmt.Sprintf("Cancellata con almeno %d ore di preavviso: accesso rimborsato", p.CutoffHours)
+		return decision
+	}
+
+	decision.Late = true
+	switch p.LateRefund {
+	case LateRefundFull:
+		decision.Refund = true
+		decision.Reason = fmt.Sprintf("Cancellata con meno di %d ore di preavviso: accesso rimborsato comunque", p.CutoffHours)
+	case LateRefundPartial:
+		if lateRefundsUsed < p.FreeLateCancels {
+			decision.Refund = true
+			decision.Reason = fmt.Sprintf("Cancellazione tardiva gratuita %d di %d del mese: accesso rimborsato", lateRefundsUsed+1, p.FreeLateCancels)
+		} else {
+			decision.Reason = fmt.Sprintf("Cancellazione tardiva oltre le %d gratuite del mese: accesso non rimborsato", p.FreeLateCancels)
+		}
+	default:
+		decision.Reason = fmt.Sprintf("Cancellata con meno di %d ore di preavviso: accesso non rimborsato", p.CutoffHours)
+	}
+	return decision
+}
+
+// Record copies the decision onto a cancellation event.
+func (d CancellationDecision) Record(event *Event) {
+	event.Refunded = sql.NullBool{Bool: d.Refund, Valid: true}
+	event.LateCancel = d.Late
+	event.PolicyID = sql.NullInt64{Int64: d.PolicyID, Valid: d.PolicyID != 0}
+	event.PolicyReason = sql.NullString{String: d.Reason, Valid: d.Reason != ""}
+}
+
+// CancelledBooking pairs a cancelled booking with the refund decision taken for it.
+type CancelledBooking struct {
+	*Booking
+	Decision CancellationDecision
+}
+
+type CancellationPolicyRepository struct {
+	db *sql.DB
+}
+
+func NewCancellationPolicyRepository(db *sql.DB) *CancellationPolicyRepository {
+	return &CancellationPolicyRepository{db: db}
+}
+
+const policyColumns = `
+	id, name, sub_type, cutoff_hours, late_refund, free_late_cancels,
+	unmarked_attendance, max_no_shows, no_show_window_days, created_at, updated_at
+`
+
+func scanPolicy(row rowScanner) (*CancellationPolicy, error) {
+	var policy CancellationPolicy
+	err := row.Scan(
+		&policy.ID,
+		&policy.Name,
+		&policy.SubType,
+		&policy.CutoffHours,
+		&policy.LateRefund,
+		&policy.FreeLateCancels,
+		&policy.UnmarkedAttendance,
+		&policy.MaxNoShows,
+		&policy.NoShowWindowDays,
+		&policy.CreatedAt,
+		&policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *CancellationPolicyRepository) GetAll() ([]*CancellationPolicy, error) {
+	rows, err := r.db.Query(`SELECT ` + policyColumns + ` FROM cancellation_policies ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*CancellationPolicy
+	for rows.Next() {
+		policy, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+func (r *CancellationPolicyRepository) GetByID(id int64) (*CancellationPolicy, error) {
+	return scanPolicy(r.db.QueryRow(`SELECT `+policyColumns+` FROM cancellation_policies WHERE id = $1`, id))
+}
+
+// GetForSubType returns the policy of a subscription type, falling back to
+// DefaultCancellationPolicy when none is stored.
+func (r *CancellationPolicyRepository) GetForSubType(subType SubType) (*CancellationPolicy, error) {
+	policy, err := scanPolicy(r.db.QueryRow(`SELECT `+policyColumns+` FROM cancellation_policies WHERE sub_type = $1`, subType))
+	if err == sql.ErrNoRows {
+		return DefaultCancellationPolicy(), nil
+	}
+	return policy, err
+}
+
+// GetForUser returns the policy applying to a member at the given time: the
+// policy of the plan of the subscription they are on, else the policy of their
+// subscription type, else DefaultCancellationPolicy.
+func (r *CancellationPolicyRepository) GetForUser(userID string, at time.Time) (*CancellationPolicy, error) {
+	policy, err := scanPolicy(r.db.QueryRow(`
+		SELECT `+policyColumns+`
+		FROM cancellation_policies
+		WHERE id = (SELECT `+memberPolicyID("$2::date")+` FROM users u WHERE u.id = $1)
+	`, userID, businessDay(at).Format("2006-01-02")))
+	if err == sql.ErrNoRows {
+		return DefaultCancellationPolicy(), nil
+	}
+	return policy, err
+}
+
+// memberPolicyID returns an SQL expression for the id of the policy applying
+// to the member u on the date day: the policy of the plan of the subscription
+// covering that day, else the policy of their subscription type.
+func memberPolicyID(day string) string {
+	return `COALESCE(
+		(SELECT pl.policy_id
+		 FROM subscriptions s
+		 LEFT JOIN plans pl ON pl.id = s.plan_id
+		 WHERE s.user_id = u.id AND s.starts_on <= ` + day + ` AND s.ends_on >= ` + day + `
+		 ORDER BY s.starts_on DESC, s.id DESC
+		 LIMIT 1),
+		(SELECT id FROM cancellation_policies WHERE sub_type = u.sub_type))`
+}
+
+func (r *CancellationPolicyRepository) Create(policy *CancellationPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	if err := r.checkSubTypeFree(policy); err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+		INSERT INTO cancellation_policies (name, sub_type, cutoff_hours, late_refund, free_late_cancels,
+			unmarked_attendance, max_no_shows, no_show_window_days)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at
+	`, policy.Name, policy.SubType, policy.CutoffHours, policy.LateRefund, policy.FreeLateCancels,
+		policy.UnmarkedAttendance, policy.MaxNoShows, policy.NoShowWindowDays).
+		Scan(&policy.ID, &policy.CreatedAt, &policy.UpdatedAt)
+}
+
+func (r *CancellationPolicyRepository) Update(policy *CancellationPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	if err := r.checkSubTypeFree(policy); err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+		UPDATE cancellation_policies
+		SET name = $2, sub_type = $3, cutoff_hours = $4, late_refund = $5, free_late_cancels = $6,
+			unmarked_attendance = $7, max_no_shows = $8, no_show_window_days = $9, updated_at = $10
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, policy.ID, policy.Name, policy.SubType, policy.CutoffHours, policy.LateRefund, policy.FreeLateCancels,
+		policy.UnmarkedAttendance, policy.MaxNoShows, policy.NoShowWindowDays, time.Now().UTC()).
+		Scan(&policy.CreatedAt, &policy.UpdatedAt)
+}
+
+// checkSubTypeFree returns ErrPolicyTaken when another policy already applies
+// to the subscription type of policy.
+func (r *CancellationPolicyRepository) checkSubTypeFree(policy *CancellationPolicy) error {
+	if !policy.SubType.Valid {
+		return nil
+	}
+	var taken bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM cancellation_policies WHERE sub_type = $1 AND id <> $2)
+	`, policy.SubType, policy.ID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrPolicyTaken
+	}
+	return nil
+}
+
+// Delete removes a policy. Past events keep their reason and lose the reference.
+func (r *CancellationPolicyRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM cancellation_policies WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestCancellationPolicyDecide(t *testing.T) {
+	startsAt := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	early := startsAt.Add(-5 * time.Hour)
+	late := startsAt.Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		policy      models.CancellationPolicy
+		cancelledAt time.Time
+		used        int
+		wantRefund  bool
+		wantLate    bool
+	}{
+		{"Before cutoff is refunded", models.CancellationPolicy{CutoffHours: 3, LateRefund: models.LateRefundNone}, early, 0, true, false},
+		{"Exactly at cutoff is refunded", models.CancellationPolicy{CutoffHours: 3, LateRefund: models.LateRefundNone}, startsAt.Add(-3 * time.Hour), 0, true, false},
+		{"Late without refund", models.CancellationPolicy{CutoffHours: 3, LateRefund: models.LateRefundNone}, late, 0, false, true},
+		{"Late with full refund", models.CancellationPolicy{CutoffHours: 3, LateRefund: models.LateRefundFull}, late, 5, true, true},
+		{"Late within free allowance", models.CancellationPolicy{CutoffHours: 3, LateRefund: models.LateRefundPartial, FreeLateCancels: 2}, late, 1, true, true},
+		{"Late beyond free allowance", models.CancellationPolicy{CutoffHours: 3, LateRefund: models.LateRefundPartial, FreeLateCancels: 2}, late, 2, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := tt.policy.Decide(startsAt, tt.cancelledAt, tt.used)
+			if decision.Refund != tt.wantRefund {
+				t.Errorf("Expected refund %v, got %v", tt.wantRefund, decision.Refund)
+			}
+			if decision.Late != tt.wantLate {
+				t.Errorf("Expected late %v, got %v", tt.wantLate, decision.Late)
+			}
+			if decision.Reason == "" {
+				t.Error("Expected a reason for the member")
+			}
+		})
+	}
+}
+
+func TestCancellationPolicyValidate(t *testing.T) {
+	valid := models.DefaultCancellationPolicy()
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Expected default policy to be valid, got %v", err)
+	}
+
+	invalid := []models.CancellationPolicy{
+		{CutoffHours: 3, LateRefund: models.LateRefundNone},
+		{Name: "Negative", CutoffHours: -1, LateRefund: models.LateRefundNone},
+		{Name: "Unknown refund", CutoffHours: 3, LateRefund: "SOME"},
+		{Name: "Negative allowance", CutoffHours: 3, LateRefund: models.LateRefundPartial, FreeLateCancels: -1},
+		{Name: "Late cancel default", CutoffHours: 3, LateRefund: models.LateRefundNone, UnmarkedAttendance: models.AttendanceLateCancel, NoShowWindowDays: 30},
+		{Name: "Negative no-shows", CutoffHours: 3, LateRefund: models.LateRefundNone, UnmarkedAttendance: models.AttendanceAttended, MaxNoShows: -1, NoShowWindowDays: 30},
+		{Name: "Empty window", CutoffHours: 3, LateRefund: models.LateRefundNone, UnmarkedAttendance: models.AttendanceAttended},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); !errors.Is(err, models.ErrInvalidPolicy) {
+			t.Errorf("Expected ErrInvalidPolicy for %q, got %v", p.Name, err)
+		}
+	}
+}
+
+func TestCancellationPolicyBlocksBooking(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxNoShows int
+		noShows    int
+		want       bool
+	}{
+		{"No penalty", 0, 10, false},
+		{"Below limit", 3, 2, false},
+		{"At limit", 3, 3, true},
+		{"Above limit", 3, 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := models.CancellationPolicy{MaxNoShows: tt.maxNoShows, NoShowWindowDays: 30}
+			if got := policy.BlocksBooking(tt.noShows); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	now := time.Date(2024, 3, 31, 10, 0, 0, 0, time.UTC)
+	policy := models.CancellationPolicy{NoShowWindowDays: 30}
+	if got, want := policy.NoShowWindowStart(now), time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected window start %v, got %v", want, got)
+	}
+}
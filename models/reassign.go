@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrSameInstructor       = errors.New("bookings must move to another instructor")
+	ErrOutsideSchedule      = errors.New("slot outside the instructor's schedule")
+	ErrInvalidReassignRange = errors.New("invalid reassignment range")
+)
+
+// Reassignment moves the SIMPLE bookings of one instructor starting in
+// [From, To) to another instructor at the same time.
+type Reassignment struct {
+	FromInstructorID int64
+	ToInstructorID   int64
+	From             time.Time
+	To               time.Time
+	// Schedule is the weekly schedule of the new instructor, read in Location,
+	// and MaxSlots their capacity
+	Schedule WeeklySchedule
+	Location *time.Location
+	MaxSlots int
+}
+
+// ReassignConflict is a booking left with its instructor and the reason why.
+type ReassignConflict struct {
+	Booking *BookingWithUser
+	Err     error
+}
+
+// ReassignResult lists the bookings moved to the new instructor and those
+// that could not be moved.
+type ReassignResult struct {
+	Moved     []*BookingWithUser
+	Conflicts []ReassignConflict
+}
+
+// Reassign moves every SIMPLE booking of the reassignment in one
+// transaction, holding the per-day locks of both instructors. A booking is
+// moved only if it fits the new instructor's schedule, closures and capacity;
+// the others stay where they are and are reported as conflicts.
+func (r *BookingRepository) Reassign(re Reassignment) (*ReassignResult, error) {
+	if re.FromInstructorID == re.ToInstructorID {
+		return nil, ErrSameInstructor
+	}
+	if !re.From.Before(re.To) {
+		return nil, ErrInvalidReassignRange
+	}
+
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock every day of the range for both instructors in a fixed order
+	keySet := make(map[int64]bool)
+	for _, instructorID := range []int64{re.FromInstructorID, re.ToInstructorID} {
+		loc, err := instructorTimeZoneTx(tx, instructorID)
+		if err != nil {
+			return nil, err
+		}
+		start := re.From.In(loc)
+		for day := time.Date(start.Year(), start.Month(), start.Day(), 12, 0, 0, 0, loc); day.Before(re.To.Add(24 * time.Hour)); day = day.AddDate(0, 0, 1) {
+			keySet[bookingLockKey(instructorID, day, loc)] = true
+		}
+	}
+	keys := make([]int64, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, key := range keys {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, key); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := tx.Query(`
+		SELECT b.id, b.user_id, b.instructor_id, b.created_at, b.starts_at, b.type,
+			   u.first_name, u.last_name, u.email, u.sub_type,
+			   b.service_id, s.name, b.capacity_weight, b.duration_minutes
+		FROM bookings b
+		LEFT JOIN users u ON u.id = b.user_id
+		LEFT JOIN services s ON s.id = b.service_id
+		WHERE b.instructor_id = $1
+			AND b.type = 'SIMPLE'
+			AND b.cancelled_at IS NULL
+			AND b.starts_at >= $2
+			AND b.starts_at < $3
+		ORDER BY b.starts_at ASC
+		FOR UPDATE OF b
+	`, re.FromInstructorID, re.From, re.To)
+	if err != nil {
+		return nil, err
+	}
+	bookings, err := scanBookingsWithUsers(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReassignResult{}
+	for _, booking := range bookings {
+		if err := reassignBookingTx(tx, booking, re); err != nil {
+			if !errors.Is(err, ErrSlotUnavailable) && !errors.Is(err, ErrClosed) && !errors.Is(err, ErrOutsideSchedule) {
+				return nil, err
+			}
+			result.Conflicts = append(result.Conflicts, ReassignConflict{Booking: booking, Err: err})
+			continue
+		}
+		result.Moved = append(result.Moved, booking)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// reassignBookingTx moves one booking to the new instructor of re if it fits.
+func reassignBookingTx(tx *sql.Tx, booking *BookingWithUser, re Reassignment) error {
+	b := Booking{StartsAt: booking.StartsAt, DurationMinutes: booking.DurationMinutes}
+	if !re.Schedule.Covers(b.StartsAt.In(re.Location), b.Duration()) {
+		return ErrOutsideSchedule
+	}
+
+	if err := checkInstructorActiveTx(tx, re.ToInstructorID); err != nil {
+		return err
+	}
+
+	closed, err := isClosedTx(tx, re.ToInstructorID, b.StartsAt)
+	if err != nil {
+		return err
+	}
+	if closed {
+		return ErrClosed
+	}
+
+	var taken bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM bookings
+			WHERE user_id = $1 AND instructor_id = $2 AND starts_at = $3 AND cancelled_at IS NULL
+		)
+	`, booking.UserID, re.ToInstructorID, b.StartsAt).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrSlotUnavailable
+	}
+
+	neededSlots := BookingWeight(SubType(booking.UserSubType.String), booking.CapacityWeight)
+	if err := checkCapacityTx(tx, re.ToInstructorID, b.StartsAt, b.EndsAt(), booking.ID, neededSlots, re.MaxSlots); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE bookings
+		SET instructor_id = $1, location_id = (SELECT location_id FROM instructors WHERE id = $1)
+		WHERE id = $2
+	`, re.ToInstructorID, booking.ID)
+	if err != nil {
+		return err
+	}
+	booking.InstructorID = re.ToInstructorID
+	return nil
+}
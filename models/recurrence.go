@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var ruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// WeeklyRule is the subset of an RFC 5545 RRULE used to repeat admin blocks:
+// FREQ=WEEKLY with optional INTERVAL, BYDAY, UNTIL and COUNT.
+type WeeklyRule struct {
+	Interval int
+	Weekdays []time.Weekday
+	// Until is the last local day included, zero when unbounded
+	Until time.Time
+	Count int
+}
+
+// ParseWeeklyRule parses rules such as "FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20250630".
+// UNTIL is read as a Europe/Rome calendar day.
+func ParseWeeklyRule(s string) (*WeeklyRule, error) {
+	loc := LoadTimeZone(BusinessTimeZone)
+
+	rule := &WeeklyRule{Interval: 1}
+	weekly := false
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			if strings.ToUpper(value) != "WEEKLY" {
+				return nil, fmt.Errorf("%w: only FREQ=WEEKLY is supported", ErrInvalidRule)
+			}
+			weekly = true
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%w: INTERVAL must be positive", ErrInvalidRule)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%w: COUNT must be positive", ErrInvalidRule)
+			}
+			rule.Count = n
+		case "UNTIL":
+			// Only the date part matters, a time suffix is ignored
+			if len(value) < 8 {
+				return nil, fmt.Errorf("%w: UNTIL must be YYYYMMDD", ErrInvalidRule)
+			}
+			until, err := time.ParseInLocation("20060102", value[:8], loc)
+			if err != nil {
+				return nil, fmt.Errorf("%w: UNTIL must be YYYYMMDD", ErrInvalidRule)
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := ruleWeekdays[strings.ToUpper(strings.TrimSpace(day))]
+				if !ok {
+					return nil, fmt.Errorf("%w: unknown day %q", ErrInvalidRule, day)
+				}
+				rule.Weekdays = append(rule.Weekdays, weekday)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %q", ErrInvalidRule, key)
+		}
+	}
+
+	if !weekly {
+		return nil, fmt.Errorf("%w: FREQ=WEEKLY is required", ErrInvalidRule)
+	}
+	return rule, nil
+}
+
+// Dates returns the local days from start to end (both inclusive) on which the
+// rule fires. Weeks start on Monday and are counted from the week of start;
+// without BYDAY the rule fires on the weekday of start.
+func (r *WeeklyRule) Dates(start, end time.Time) []time.Time {
+	loc := start.Location()
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	last := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc)
+	if !r.Until.IsZero() {
+		until := r.Until.In(loc)
+		until = time.Date(until.Year(), until.Month(), until.Day(), 0, 0, 0, 0, loc)
+		if until.Before(last) {
+			last = until
+		}
+	}
+
+	weekdays := r.Weekdays
+	if len(weekdays) == 0 {
+		weekdays = []time.Weekday{day.Weekday()}
+	}
+	interval := r.Interval
+	if interval <= 0 {
+		interval = 1
+	}
+
+	// Monday of the first week, as a civil date to count weeks across DST changes
+	offset := (int(day.Weekday()) + 6) % 7
+	firstMonday := civilDay(day) - offset
+
+	var dates []time.Time
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		week := (civilDay(day) - firstMonday) / 7
+		if week%interval != 0 || !containsWeekday(weekdays, day.Weekday()) {
+			continue
+		}
+		dates = append(dates, day)
+		if r.Count > 0 && len(dates) == r.Count {
+			break
+		}
+	}
+	return dates
+}
+
+// civilDay numbers calendar days independently of the time zone offset.
+func civilDay(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func containsWeekday(weekdays []time.Weekday, day time.Weekday) bool {
+	for _, w := range weekdays {
+		if w == day {
+			return true
+		}
+	}
+	return false
+}
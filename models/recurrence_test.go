@@ -0,0 +1,90 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestParseWeeklyRule(t *testing.T) {
+	rule, err := models.ParseWeeklyRule("RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;UNTIL=20240630T235959Z")
+	if err != nil {
+		t.Fatalf("Failed to parse rule: %v", err)
+	}
+	if rule.Interval != 2 {
+		t.Errorf("Expected interval 2, got %d", rule.Interval)
+	}
+	if len(rule.Weekdays) != 2 || rule.Weekdays[0] != time.Tuesday || rule.Weekdays[1] != time.Thursday {
+		t.Errorf("Expected Tuesday and Thursday, got %v", rule.Weekdays)
+	}
+	if rule.Until.Format("2006-01-02") != "2024-06-30" {
+		t.Errorf("Expected until 2024-06-30, got %v", rule.Until)
+	}
+
+	for _, invalid := range []string{"", "FREQ=DAILY", "FREQ=WEEKLY;BYDAY=XX", "FREQ=WEEKLY;INTERVAL=0", "FREQ=WEEKLY;BYMONTH=1"} {
+		if _, err := models.ParseWeeklyRule(invalid); !errors.Is(err, models.ErrInvalidRule) {
+			t.Errorf("Expected ErrInvalidRule for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestWeeklyRuleDates(t *testing.T) {
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wednesday 3 January 2024
+	start := time.Date(2024, 1, 3, 0, 0, 0, 0, loc)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, loc)
+
+	t.Run("BYDAY every other week", func(t *testing.T) {
+		rule := &models.WeeklyRule{Interval: 2, Weekdays: []time.Weekday{time.Monday, time.Friday}}
+		got := formatDays(rule.Dates(start, end))
+		// Monday 1 January starts week zero, before the range start
+		want := []string{"2024-01-05", "2024-01-15", "2024-01-19", "2024-01-29"}
+		if !equalStrings(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Defaults to weekday of start and honours COUNT", func(t *testing.T) {
+		rule := &models.WeeklyRule{Interval: 1, Count: 3}
+		got := formatDays(rule.Dates(start, end))
+		want := []string{"2024-01-03", "2024-01-10", "2024-01-17"}
+		if !equalStrings(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("UNTIL shortens the range", func(t *testing.T) {
+		rule := &models.WeeklyRule{Interval: 1, Until: time.Date(2024, 1, 10, 0, 0, 0, 0, loc)}
+		got := formatDays(rule.Dates(start, end))
+		want := []string{"2024-01-03", "2024-01-10"}
+		if !equalStrings(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+}
+
+func formatDays(days []time.Time) []string {
+	var result []string
+	for _, d := range days {
+		result = append(result, d.Format("2006-01-02"))
+	}
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,372 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInvalidResource     = errors.New("invalid resource")
+	ErrResourceUnavailable = errors.New("resource unavailable")
+)
+
+type ResourceKind string
+
+const (
+	ResourceKindRoom      ResourceKind = "ROOM"
+	ResourceKindEquipment ResourceKind = "EQUIPMENT"
+)
+
+// Resource is a room or a machine shared by every instructor. At most
+// Capacity bookings can use it at the same time.
+type Resource struct {
+	ID        int64
+	Name      string
+	Kind      ResourceKind
+	Capacity  int
+	Enabled   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (r *Resource) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidResource)
+	}
+	if r.Kind != ResourceKindRoom && r.Kind != ResourceKindEquipment {
+		return fmt.Errorf("%w: kind must be ROOM or EQUIPMENT", ErrInvalidResource)
+	}
+	if r.Capacity <= 0 {
+		return fmt.Errorf("%w: capacity must be positive", ErrInvalidResource)
+	}
+	return nil
+}
+
+// ResourceRequirement is the quantity of a resource a service or a booking needs.
+type ResourceRequirement struct {
+	ResourceID int64
+	Quantity   int
+}
+
+// resourceLoad is the capacity of a resource and the bookings using it.
+type resourceLoad struct {
+	capacity    int
+	enabled     bool
+	occupancies []Occupancy
+}
+
+// ResourceCalendar holds the load of a set of resources over a time range.
+type ResourceCalendar map[int64]*resourceLoad
+
+// Fits reports whether every requirement still fits in [start, end). Unknown
+// and disabled resources never fit.
+func (c ResourceCalendar) Fits(needs []ResourceRequirement, start, end time.Time) bool {
+	for _, need := range needs {
+		load, ok := c[need.ResourceID]
+		if !ok || !load.enabled {
+			return false
+		}
+		used, _ := PeakLoad(load.occupancies, start, end)
+		if used+need.Quantity > load.capacity {
+			return false
+		}
+	}
+	return true
+}
+
+type ResourceRepository struct {
+	db *sql.DB
+}
+
+func NewResourceRepository(db *sql.DB) *ResourceRepository {
+	return &ResourceRepository{db: db}
+}
+
+const resourceColumns = `id, name, kind, capacity, enabled, created_at, updated_at`
+
+func scanResource(row rowScanner) (*Resource, error) {
+	var resource Resource
+	err := row.Scan(
+		&resource.ID,
+		&resource.Name,
+		&resource.Kind,
+		&resource.Capacity,
+		&resource.Enabled,
+		&resource.CreatedAt,
+		&resource.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+func (r *ResourceRepository) GetAll() ([]*Resource, error) {
+	rows, err := r.db.Query(`SELECT ` + resourceColumns + ` FROM resources ORDER BY kind, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var resources []*Resource
+	for rows.Next() {
+		resource, err := scanResource(rows)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+
+	return resources, rows.Err()
+}
+
+func (r *ResourceRepository) GetByID(id int64) (*Resource, error) {
+	return scanResource(r.db.QueryRow(`SELECT `+resourceColumns+` FROM resources WHERE id = $1`, id))
+}
+
+func (r *ResourceRepository) Create(resource *Resource) error {
+	if err := resource.Validate(); err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+		INSERT INTO resources (name, kind, capacity, enabled)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, resource.Name, resource.Kind, resource.Capacity, resource.Enabled).
+		Scan(&resource.ID, &resource.CreatedAt, &resource.UpdatedAt)
+}
+
+// Update changes a resource. Lowering the capacity does not affect bookings
+// already made.
+func (r *ResourceRepository) Update(resource *Resource) error {
+	if err := resource.Validate(); err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE resources
+		SET name = $2, kind = $3, capacity = $4, enabled = $5, updated_at = $6
+		WHERE id = $1
+	`, resource.ID, resource.Name, resource.Kind, resource.Capacity, resource.Enabled, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete removes a resource together with the requirements of services and
+// bookings on it.
+func (r *ResourceRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM resources WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetCalendar returns the load of the required resources between from and to.
+func (r *ResourceRepository) GetCalendar(needs []ResourceRequirement, from, to time.Time) (ResourceCalendar, error) {
+	if len(needs) == 0 {
+		return ResourceCalendar{}, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return loadResourceCalendarTx(tx, needs, from, to, 0, false)
+}
+
+func requirementIDs(needs []ResourceRequirement) pq.Int64Array {
+	ids := make(pq.Int64Array, 0, len(needs))
+	for _, need := range needs {
+		ids = append(ids, need.ResourceID)
+	}
+	return ids
+}
+
+// loadResourceCalendarTx reads the resources in needs and the bookings and
+// class sessions using them in [from, to), ignoring booking excludeID. With lock the resource rows are
+// locked in id order so concurrent bookings of the same resource serialize.
+func loadResourceCalendarTx(tx *sql.Tx, needs []ResourceRequirement, from, to time.Time, excludeID int64, lock bool) (ResourceCalendar, error) {
+	calendar := ResourceCalendar{}
+	if len(needs) == 0 {
+		return calendar, nil
+	}
+	ids := requirementIDs(needs)
+
+	query := `SELECT id, capacity, enabled FROM resources WHERE id = ANY($1) ORDER BY id`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	rows, err := tx.Query(query, ids)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		load := &resourceLoad{}
+		if err := rows.Scan(&id, &load.capacity, &load.enabled); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		calendar[id] = load
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`
+		SELECT br.resource_id, b.starts_at, b.duration_minutes, br.quantity
+		FROM booking_resources br
+		JOIN bookings b ON b.id = br.booking_id
+		WHERE br.resource_id = ANY($1)
+			AND b.cancelled_at IS NULL
+			AND b.starts_at < $3
+			AND b.starts_at + b.duration_minutes * INTERVAL '1 minute' > $2
+			AND b.id <> $4
+	`, ids, from, to, excludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var booking Booking
+		var quantity int
+		if err := rows.Scan(&id, &booking.StartsAt, &booking.DurationMinutes, &quantity); err != nil {
+			return nil, err
+		}
+		if load, ok := calendar[id]; ok {
+			load.occupancies = append(load.occupancies, Occupancy{StartsAt: booking.StartsAt, EndsAt: booking.EndsAt(), Weight: quantity})
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`
+		SELECT resource_id, starts_at, duration_minutes
+		FROM class_sessions
+		WHERE resource_id = ANY($1)
+			AND starts_at < $3
+			AND starts_at + duration_minutes * INTERVAL '1 minute' > $2
+	`, ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var session ClassSession
+		if err := rows.Scan(&session.ResourceID, &session.StartsAt, &session.DurationMinutes); err != nil {
+			return nil, err
+		}
+		if load, ok := calendar[session.ResourceID.Int64]; ok {
+			load.occupancies = append(load.occupancies, Occupancy{StartsAt: session.StartsAt, EndsAt: session.EndsAt(), Weight: 1})
+		}
+	}
+
+	return calendar, rows.Err()
+}
+
+// checkResourcesTx locks the resources a booking needs and returns
+// ErrResourceUnavailable unless they are all free for [startsAt, endsAt).
+func checkResourcesTx(tx *sql.Tx, needs []ResourceRequirement, startsAt, endsAt time.Time, excludeID int64) error {
+	if len(needs) == 0 {
+		return nil
+	}
+	calendar, err := loadResourceCalendarTx(tx, needs, startsAt, endsAt, excludeID, true)
+	if err != nil {
+		return err
+	}
+	if !calendar.Fits(needs, startsAt, endsAt) {
+		return ErrResourceUnavailable
+	}
+	return nil
+}
+
+// serviceResourcesTx returns the resources a service needs.
+func serviceResourcesTx(tx *sql.Tx, serviceID int64) ([]ResourceRequirement, error) {
+	return queryRequirementsTx(tx, `SELECT resource_id, quantity FROM service_resources WHERE service_id = $1 ORDER BY resource_id`, serviceID)
+}
+
+// bookingResourcesTx returns the resources held by a booking.
+func bookingResourcesTx(tx *sql.Tx, bookingID int64) ([]ResourceRequirement, error) {
+	return queryRequirementsTx(tx, `SELECT resource_id, quantity FROM booking_resources WHERE booking_id = $1 ORDER BY resource_id`, bookingID)
+}
+
+func queryRequirementsTx(tx *sql.Tx, query string, id int64) ([]ResourceRequirement, error) {
+	rows, err := tx.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	needs := []ResourceRequirement{}
+	for rows.Next() {
+		var need ResourceRequirement
+		if err := rows.Scan(&need.ResourceID, &need.Quantity); err != nil {
+			return nil, err
+		}
+		needs = append(needs, need)
+	}
+	return needs, rows.Err()
+}
+
+func insertBookingResourcesTx(tx *sql.Tx, bookingID int64, needs []ResourceRequirement) error {
+	for _, need := range needs {
+		_, err := tx.Exec(`
+			INSERT INTO booking_resources (booking_id, resource_id, quantity)
+			VALUES ($1, $2, $3)
+		`, bookingID, need.ResourceID, need.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveBookingResourcesTx fills the resources of a new booking from its
+// service unless the booking declares its own.
+func resolveBookingResourcesTx(tx *sql.Tx, booking *Booking) error {
+	if booking.Resources != nil || !booking.ServiceID.Valid {
+		return nil
+	}
+	needs, err := serviceResourcesTx(tx, booking.ServiceID.Int64)
+	if err != nil {
+		return err
+	}
+	booking.Resources = needs
+	return nil
+}
@@ -0,0 +1,76 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestResourceValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource models.Resource
+		wantErr  bool
+	}{
+		{"Valid room", models.Resource{Name: " Sala 1 ", Kind: models.ResourceKindRoom, Capacity: 2}, false},
+		{"Valid equipment", models.Resource{Name: "Reformer", Kind: models.ResourceKindEquipment, Capacity: 1}, false},
+		{"Blank name", models.Resource{Name: " ", Kind: models.ResourceKindRoom, Capacity: 1}, true},
+		{"Unknown kind", models.Resource{Name: "Sala 1", Kind: "POOL", Capacity: 1}, true},
+		{"No capacity", models.Resource{Name: "Sala 1", Kind: models.ResourceKindRoom, Capacity: 0}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.resource.Validate()
+			if tt.wantErr && !errors.Is(err, models.ErrInvalidResource) {
+				t.Errorf("Expected ErrInvalidResource, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestServiceValidateResources(t *testing.T) {
+	base := models.Service{Name: "Reformer", DurationMinutes: 50, CapacityWeight: 1}
+
+	tests := []struct {
+		name      string
+		resources []models.ResourceRequirement
+		wantErr   bool
+	}{
+		{"No resources", nil, false},
+		{"Room and machine", []models.ResourceRequirement{{ResourceID: 1, Quantity: 1}, {ResourceID: 2, Quantity: 1}}, false},
+		{"Zero quantity", []models.ResourceRequirement{{ResourceID: 1, Quantity: 0}}, true},
+		{"Duplicate resource", []models.ResourceRequirement{{ResourceID: 1, Quantity: 1}, {ResourceID: 1, Quantity: 2}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := base
+			service.Resources = tt.resources
+			err := service.Validate()
+			if tt.wantErr && !errors.Is(err, models.ErrInvalidService) {
+				t.Errorf("Expected ErrInvalidService, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEmptyResourceCalendarFits(t *testing.T) {
+	start := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	calendar := models.ResourceCalendar{}
+
+	if !calendar.Fits(nil, start, start.Add(time.Hour)) {
+		t.Error("Expected a booking without resources to fit")
+	}
+	if calendar.Fits([]models.ResourceRequirement{{ResourceID: 1, Quantity: 1}}, start, start.Add(time.Hour)) {
+		t.Error("Expected an unknown resource not to fit")
+	}
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// BookingSeries books the same weekday and time with an instructor every week
+// from StartsAt until Until. Each occurrence is a SIMPLE booking carrying the
+// series ID.
+type BookingSeries struct {
+	ID           int64
+	UserID       string
+	InstructorID int64
+	ServiceID    sql.NullInt64
+	StartsAt     time.Time
+	Until        time.Time
+	CreatedAt    time.Time
+}
+
+type BookingSeriesWithDetails struct {
+	BookingSeries
+	InstructorFirstName sql.NullString
+	InstructorLastName  sql.NullString
+	ServiceName         sql.NullString
+	UpcomingBookings    int
+}
+
+type BookingSeriesRepository struct {
+	db *sql.DB
+}
+
+func NewBookingSeriesRepository(db *sql.DB) *BookingSeriesRepository {
+	return &BookingSeriesRepository{db: db}
+}
+
+func (r *BookingSeriesRepository) Create(series *BookingSeries) error {
+	query := `
+		INSERT INTO booking_series (user_id, instructor_id, service_id, starts_at, until)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	return r.db.QueryRow(query,
+		series.UserID,
+		series.InstructorID,
+		series.ServiceID,
+		series.StartsAt,
+		series.Until,
+	).Scan(&series.ID, &series.CreatedAt)
+}
+
+// GetByUserID returns the series of a user that still have upcoming bookings.
+func (r *BookingSeriesRepository) GetByUserID(userID string) ([]*BookingSeriesWithDetails, error) {
+	query := `
+		SELECT bs.id, bs.user_id, bs.instructor_id, bs.service_id, bs.starts_at, bs.until, bs.created_at,
+			   i.first_name, i.last_name, s.name, COUNT(b.id)
+		FROM booking_series bs
+		JOIN bookings b ON b.series_id = bs.id AND b.starts_at > CURRENT_TIMESTAMP AND b.cancelled_at IS NULL
+		LEFT JOIN instructors i ON i.id = bs.instructor_id
+		LEFT JOIN services s ON s.id = bs.service_id
+		WHERE bs.user_id = $1
+		GROUP BY bs.id, i.first_name, i.last_name, s.name
+		ORDER BY bs.starts_at ASC
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*BookingSeriesWithDetails
+	for rows.Next() {
+		var series BookingSeriesWithDetails
+		err := rows.Scan(
+			&series.ID,
+			&series.UserID,
+			&series.InstructorID,
+			&series.ServiceID,
+			&series.StartsAt,
+			&series.Until,
+			&series.CreatedAt,
+			&series.InstructorFirstName,
+			&series.InstructorLastName,
+			&series.ServiceName,
+			&series.UpcomingBookings,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &series)
+	}
+
+	return result, rows.Err()
+}
+
+func (r *BookingSeriesRepository) Delete(id int64) error {
+	_, err := r.db.Exec(`DELETE FROM booking_series WHERE id = $1`, id)
+	return err
+}
+
+// Cancel cancels the upcoming bookings of a series owned by userID and deletes
+// the series itself, recording a cancellation event for each. Each booking is
+// refunded according to policy, counting the late cancellations already
+// refunded to the user this month under the lock of their user row.
+// It returns the cancelled bookings with their decision and how many of them
+// were refunded.
+func (r *BookingSeriesRepository) Cancel(id int64, userID string, policy *CancellationPolicy) ([]*CancelledBooking, int, error) {
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, 0, err
+	}
+
+	var seriesID int64
+	err = tx.QueryRow(`SELECT id FROM booking_series WHERE id = $1 AND user_id = $2 FOR UPDATE`, id, userID).Scan(&seriesID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, starts_at
+		FROM bookings
+		WHERE series_id = $1 AND starts_at > CURRENT_TIMESTAMP AND cancelled_at IS NULL
+		ORDER BY starts_at ASC
+		FOR UPDATE
+	`, seriesID)
+	if err != nil {
+		return nil, 0, err
+	}
+	var upcoming []*Booking
+	for rows.Next() {
+		var booking Booking
+		if err := rows.Scan(&booking.ID, &booking.StartsAt); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		upcoming = append(upcoming, &booking)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, 0, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	lateRefundsUsed, err := countLateRefundsTx(tx, userID, BusinessMonthStart(now))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var cancelled []*CancelledBooking
+	refunded := 0
+	for _, b := range upcoming {
+		decision := policy.Decide(b.StartsAt, now, lateRefundsUsed)
+		if decision.Refund {
+			refunded++
+			if decision.Late {
+				lateRefundsUsed++
+			}
+		}
+		booking, err := cancelBookingTx(tx, b.ID, Cancellation{Status: BookingStatusCancelledByUser, By: userID, Refunded: decision.Refund, Late: decision.Late}, now)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		event := &Event{
+			UserID:     userID,
+			StartsAt:   booking.StartsAt,
+			Type:       EventTypeDeleted,
+			OccurredAt: now.UTC(),
+		}
+		decision.Record(event)
+		if err := createEventTx(tx, event); err != nil {
+			return nil, 0, err
+		}
+		cancelled = append(cancelled, &CancelledBooking{Booking: booking, Decision: decision})
+	}
+
+	if _, err := tx.Exec(`DELETE FROM booking_series WHERE id = $1`, seriesID); err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, err
+	}
+	return cancelled, refunded, nil
+}
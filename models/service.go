@@ -0,0 +1,280 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultServiceDuration is the length of a booking made without a service.
+const DefaultServiceDuration = time.Hour
+
+var ErrInvalidService = errors.New("invalid service")
+
+// Service is a bookable activity with its own length and capacity weight.
+// An empty InstructorIDs means that every instructor offers the service.
+// Resources are the rooms and machines every booking of the service needs.
+type Service struct {
+	ID              int64
+	Name            string
+	DurationMinutes int
+	CapacityWeight  int
+	Enabled         bool
+	InstructorIDs   []int64
+	Resources       []ResourceRequirement
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (s *Service) Duration() time.Duration {
+	return time.Duration(s.DurationMinutes) * time.Minute
+}
+
+// OffersInstructor reports whether the service can be booked with the instructor.
+func (s *Service) OffersInstructor(instructorID int64) bool {
+	if len(s.InstructorIDs) == 0 {
+		return true
+	}
+	for _, id := range s.InstructorIDs {
+		if id == instructorID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidService)
+	}
+	if s.DurationMinutes <= 0 || s.DurationMinutes > 24*60 {
+		return fmt.Errorf("%w: duration must be between 1 and 1440 minutes", ErrInvalidService)
+	}
+	if s.CapacityWeight <= 0 {
+		return fmt.Errorf("%w: capacity weight must be positive", ErrInvalidService)
+	}
+	seen := make(map[int64]bool)
+	for _, need := range s.Resources {
+		if need.Quantity <= 0 {
+			return fmt.Errorf("%w: resource quantity must be positive", ErrInvalidService)
+		}
+		if seen[need.ResourceID] {
+			return fmt.Errorf("%w: resource %d listed twice", ErrInvalidService, need.ResourceID)
+		}
+		seen[need.ResourceID] = true
+	}
+	return nil
+}
+
+type ServiceRepository struct {
+	db *sql.DB
+}
+
+func NewServiceRepository(db *sql.DB) *ServiceRepository {
+	return &ServiceRepository{db: db}
+}
+
+const serviceColumns = `
+	s.id, s.name, s.duration_minutes, s.capacity_weight, s.enabled, s.created_at, s.updated_at,
+	COALESCE(ARRAY(SELECT si.instructor_id FROM service_instructors si WHERE si.service_id = s.id ORDER BY si.instructor_id), '{}'),
+	COALESCE(ARRAY(SELECT sr.resource_id FROM service_resources sr WHERE sr.service_id = s.id ORDER BY sr.resource_id), '{}'),
+	COALESCE(ARRAY(SELECT sr.quantity FROM service_resources sr WHERE sr.service_id = s.id ORDER BY sr.resource_id), '{}')
+`
+
+func (r *ServiceRepository) GetAll() ([]*Service, error) {
+	query := `SELECT ` + serviceColumns + ` FROM services s ORDER BY s.name`
+	return r.queryMany(query)
+}
+
+func (r *ServiceRepository) GetEnabled() ([]*Service, error) {
+	query := `SELECT ` + serviceColumns + ` FROM services s WHERE s.enabled = TRUE ORDER BY s.name`
+	return r.queryMany(query)
+}
+
+func (r *ServiceRepository) queryMany(query string, args ...interface{}) ([]*Service, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var services []*Service
+	for rows.Next() {
+		service, err := scanService(rows)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+
+	return services, rows.Err()
+}
+
+func (r *ServiceRepository) GetByID(id int64) (*Service, error) {
+	query := `SELECT ` + serviceColumns + ` FROM services s WHERE s.id = $1`
+	return scanService(r.db.QueryRow(query, id))
+}
+
+func (r *ServiceRepository) GetEnabledByID(id int64) (*Service, error) {
+	query := `SELECT ` + serviceColumns + ` FROM services s WHERE s.id = $1 AND s.enabled = TRUE`
+	return scanService(r.db.QueryRow(query, id))
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanService(row rowScanner) (*Service, error) {
+	var service Service
+	var instructorIDs, resourceIDs, quantities pq.Int64Array
+	err := row.Scan(
+		&service.ID,
+		&service.Name,
+		&service.DurationMinutes,
+		&service.CapacityWeight,
+		&service.Enabled,
+		&service.CreatedAt,
+		&service.UpdatedAt,
+		&instructorIDs,
+		&resourceIDs,
+		&quantities,
+	)
+	if err != nil {
+		return nil, err
+	}
+	service.InstructorIDs = []int64(instructorIDs)
+	for i, id := range resourceIDs {
+		service.Resources = append(service.Resources, ResourceRequirement{ResourceID: id, Quantity: int(quantities[i])})
+	}
+	return &service, nil
+}
+
+func (r *ServiceRepository) Create(service *Service) error {
+	if err := service.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO services (name, duration_minutes, capacity_weight, enabled)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, service.Name, service.DurationMinutes, service.CapacityWeight, service.Enabled).
+		Scan(&service.ID, &service.CreatedAt, &service.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceServiceInstructors(tx, service); err != nil {
+		return err
+	}
+	if err := replaceServiceResources(tx, service); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ServiceRepository) Update(service *Service) error {
+	if err := service.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE services
+		SET name = $2, duration_minutes = $3, capacity_weight = $4, enabled = $5, updated_at = $6
+		WHERE id = $1
+	`, service.ID, service.Name, service.DurationMinutes, service.CapacityWeight, service.Enabled, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := replaceServiceInstructors(tx, service); err != nil {
+		return err
+	}
+	if err := replaceServiceResources(tx, service); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceServiceInstructors(tx *sql.Tx, service *Service) error {
+	if _, err := tx.Exec(`DELETE FROM service_instructors WHERE service_id = $1`, service.ID); err != nil {
+		return err
+	}
+	for _, instructorID := range service.InstructorIDs {
+		_, err := tx.Exec(`
+			INSERT INTO service_instructors (service_id, instructor_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, service.ID, instructorID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replaceServiceResources(tx *sql.Tx, service *Service) error {
+	if _, err := tx.Exec(`DELETE FROM service_resources WHERE service_id = $1`, service.ID); err != nil {
+		return err
+	}
+	for _, need := range service.Resources {
+		_, err := tx.Exec(`
+			INSERT INTO service_resources (service_id, resource_id, quantity)
+			VALUES ($1, $2, $3)
+		`, service.ID, need.ResourceID, need.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes a service. Existing bookings keep their duration and lose
+// the reference. Services members hold wallets for return ErrServiceInUse
+// and can only be disabled.
+func (r *ServiceRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`
+		DELETE FROM services
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM access_wallets WHERE service_id = $1)
+	`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	if _, err := r.GetByID(id); err != nil {
+		return err
+	}
+	return ErrServiceInUse
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidBookingSettings = errors.New("invalid booking settings")
+
+// maxHorizonDays caps how far ahead members can be allowed to book
+const maxHorizonDays = 366
+
+// BookingSettings decide which slots members can book.
+type BookingSettings struct {
+	// HorizonDays is how many days ahead slots can be booked
+	HorizonDays int
+	// LeadTimeMinutes is how long before its start a slot stops being bookable
+	LeadTimeMinutes int
+	// ReleaseEnabled opens the slots of each ISO week only at ReleaseWeekday
+	// and ReleaseTime, business time zone, of the week before
+	ReleaseEnabled bool
+	ReleaseWeekday time.Weekday
+	ReleaseTime    ClockTime
+	UpdatedAt      time.Time
+}
+
+// DefaultBookingSettings is used until an admin saves the settings: one month
+// ahead, at least four hours in advance, every slot released.
+func DefaultBookingSettings() *BookingSettings {
+	return &BookingSettings{
+		HorizonDays:     30,
+		LeadTimeMinutes: 240,
+		ReleaseWeekday:  time.Sunday,
+		ReleaseTime:     18 * 60,
+	}
+}
+
+func (s *BookingSettings) Validate() error {
+	if s.HorizonDays < 1 || s.HorizonDays > maxHorizonDays {
+		return fmt.Errorf("%w: horizon must be between 1 and %d days", ErrInvalidBookingSettings, maxHorizonDays)
+	}
+	if s.LeadTimeMinutes < 0 || s.LeadTimeMinutes > 7*24*60 {
+		return fmt.Errorf("%w: lead time must be between 0 and 7 days", ErrInvalidBookingSettings)
+	}
+	if s.ReleaseWeekday < time.Sunday || s.ReleaseWeekday > time.Saturday {
+		return fmt.Errorf("%w: unknown release weekday", ErrInvalidBookingSettings)
+	}
+	if s.ReleaseTime < 0 || s.ReleaseTime >= 24*60 {
+		return fmt.Errorf("%w: release time must be within the day", ErrInvalidBookingSettings)
+	}
+	return nil
+}
+
+func (s *BookingSettings) LeadTime() time.Duration {
+	return time.Duration(s.LeadTimeMinutes) * time.Minute
+}
+
+// BookableFrom returns the first start time members can book at now.
+func (s *BookingSettings) BookableFrom(now time.Time) time.Time {
+	return now.Add(s.LeadTime())
+}
+
+// Horizon returns the last start time members can book at now.
+func (s *BookingSettings) Horizon(now time.Time) time.Time {
+	return now.AddDate(0, 0, s.HorizonDays)
+}
+
+// ReleaseAt returns when a slot starting at startsAt opens for booking, in
+// the week of loc, the time zone of the instructor; the zero time when no
+// release schedule is set.
+func (s *BookingSettings) ReleaseAt(startsAt time.Time, loc *time.Location) time.Time {
+	if !s.ReleaseEnabled {
+		return time.Time{}
+	}
+	_, _, weekStart, _ := LimitWindows(startsAt, loc)
+	previous := weekStart.AddDate(0, 0, -7)
+	offset := (int(s.ReleaseWeekday) + 6) % 7
+	return time.Date(previous.Year(), previous.Month(), previous.Day()+offset, 0, int(s.ReleaseTime), 0, 0, previous.Location())
+}
+
+// Released reports whether a slot starting at startsAt is open for booking at now.
+func (s *BookingSettings) Released(startsAt, now time.Time, loc *time.Location) bool {
+	return !now.Before(s.ReleaseAt(startsAt, loc))
+}
+
+type BookingSettingsRepository struct {
+	db *sql.DB
+}
+
+func NewBookingSettingsRepository(db *sql.DB) *BookingSettingsRepository {
+	return &BookingSettingsRepository{db: db}
+}
+
+// Get returns the stored settings, the defaults when none are saved.
+func (r *BookingSettingsRepository) Get() (*BookingSettings, error) {
+	var s BookingSettings
+	err := r.db.QueryRow(`
+		SELECT horizon_days, lead_time_minutes, release_enabled, release_weekday, release_time, updated_at
+		FROM booking_settings
+		WHERE id = 1
+	`).Scan(&s.HorizonDays, &s.LeadTimeMinutes, &s.ReleaseEnabled, &s.ReleaseWeekday, &s.ReleaseTime, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return DefaultBookingSettings(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Save stores the settings, replacing the previous ones.
+func (r *BookingSettingsRepository) Save(s *BookingSettings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(`
+		INSERT INTO booking_settings (id, horizon_days, lead_time_minutes, release_enabled, release_weekday, release_time, updated_at)
+		VALUES (1, $1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE
+		SET horizon_days = EXCLUDED.horizon_days,
+			lead_time_minutes = EXCLUDED.lead_time_minutes,
+			release_enabled = EXCLUDED.release_enabled,
+			release_weekday = EXCLUDED.release_weekday,
+			release_time = EXCLUDED.release_time,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, s.HorizonDays, s.LeadTimeMinutes, s.ReleaseEnabled, s.ReleaseWeekday, s.ReleaseTime, time.Now().UTC()).Scan(&s.UpdatedAt)
+}
@@ -0,0 +1,92 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/wellness-nutrition/app/models"
+)
+
+func TestBookingSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(s *models.BookingSettings)
+		wantErr bool
+	}{
+		{"Defaults", func(s *models.BookingSettings) {}, false},
+		{"No lead time", func(s *models.BookingSettings) { s.LeadTimeMinutes = 0 }, false},
+		{"Zero horizon", func(s *models.BookingSettings) { s.HorizonDays = 0 }, true},
+		{"Horizon over a year", func(s *models.BookingSettings) { s.HorizonDays = 400 }, true},
+		{"Negative lead time", func(s *models.BookingSettings) { s.LeadTimeMinutes = -1 }, true},
+		{"Unknown weekday", func(s *models.BookingSettings) { s.ReleaseWeekday = 7 }, true},
+		{"Release time past midnight", func(s *models.BookingSettings) { s.ReleaseTime = 24 * 60 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := models.DefaultBookingSettings()
+			tt.modify(settings)
+			err := settings.Validate()
+			if tt.wantErr && !errors.Is(err, models.ErrInvalidBookingSettings) {
+				t.Errorf("Expected ErrInvalidBookingSettings, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBookingSettingsReleaseAt(t *testing.T) {
+	loc, err := time.LoadLocation(models.BusinessTimeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := models.DefaultBookingSettings()
+	// Wednesday 10:00 of the week starting Monday 2024-03-11
+	slot := time.Date(2024, 3, 13, 10, 0, 0, 0, loc)
+
+	if got := settings.ReleaseAt(slot, loc); !got.IsZero() {
+		t.Errorf("Expected no release time without a schedule, got %v", got)
+	}
+	if !settings.Released(slot, slot.AddDate(0, 0, -30), loc) {
+		t.Error("Expected every slot released without a schedule")
+	}
+
+	settings.ReleaseEnabled = true
+	want := time.Date(2024, 3, 10, 18, 0, 0, 0, loc)
+	if got := settings.ReleaseAt(slot, loc); !got.Equal(want) {
+		t.Errorf("Expected release at %v, got %v", want, got)
+	}
+	// Slots on the Sunday itself belong to the week being released before
+	if got := settings.ReleaseAt(time.Date(2024, 3, 17, 20, 0, 0, 0, loc), loc); !got.Equal(want) {
+		t.Errorf("Expected Sunday slot released at %v, got %v", want, got)
+	}
+	if settings.Released(slot, want.Add(-time.Minute), loc) {
+		t.Error("Expected slot not released before the release time")
+	}
+	if !settings.Released(slot, want, loc) {
+		t.Error("Expected slot released at the release time")
+	}
+
+	settings.ReleaseWeekday = time.Friday
+	settings.ReleaseTime = 9 * 60
+	want = time.Date(2024, 3, 8, 9, 0, 0, 0, loc)
+	if got := settings.ReleaseAt(slot, loc); !got.Equal(want) {
+		t.Errorf("Expected release at %v, got %v", want, got)
+	}
+}
+
+func TestBookingSettingsWindow(t *testing.T) {
+	settings := models.DefaultBookingSettings()
+	now := time.Date(2024, 3, 13, 10, 0, 0, 0, time.UTC)
+
+	if got := settings.BookableFrom(now); !got.Equal(now.Add(4 * time.Hour)) {
+		t.Errorf("Expected bookable from %v, got %v", now.Add(4*time.Hour), got)
+	}
+	if got := settings.Horizon(now); !got.Equal(now.AddDate(0, 0, 30)) {
+		t.Errorf("Expected horizon %v, got %v", now.AddDate(0, 0, 30), got)
+	}
+}
@@ -0,0 +1,266 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrNoActiveSubscription = errors.New("no active subscription")
+
+type SubscriptionKind string
+
+const (
+	// SubscriptionPurchase is the first subscription of a member
+	SubscriptionPurchase SubscriptionKind = "PURCHASE"
+	// SubscriptionRenewal follows an earlier subscription of the same member
+	SubscriptionRenewal SubscriptionKind = "RENEWAL"
+)
+
+// Subscription is one purchase or renewal of a plan by a member. PlanName,
+// SubType, PriceCents and MaxFreezeDays are copied from the plan when it is
+// sold.
+type Subscription struct {
+	ID       int64
+	UserID   string
+	PlanID   sql.NullInt64
+	PlanName string
+	SubType  SubType
+	Kind     SubscriptionKind
+	// StartsOn and EndsOn are the first and last valid day, as civil dates
+	StartsOn      time.Time
+	EndsOn        time.Time
+	Accesses      int
+	PriceCents    int
+	MaxFreezeDays int
+	// CreatedBy is the admin who assigned the plan
+	CreatedBy sql.NullString
+	CreatedAt time.Time
+}
+
+// ActiveSubscription is the subscription a member is on today. CoveredUntil
+// is the last day covered without interruption by it and the renewals
+// already queued after it.
+type ActiveSubscription struct {
+	*Subscription
+	CoveredUntil time.Time
+}
+
+// businessDay returns the civil date of t in the business time zone.
+func businessDay(t time.Time) time.Time {
+	local := t.In(LoadTimeZone(BusinessTimeZone))
+	return civilDate(local.Year(), local.Month(), local.Day())
+}
+
+// BusinessMonthStart returns the first instant of the month of t in the
+// business time zone.
+func BusinessMonthStart(t time.Time) time.Time {
+	loc := LoadTimeZone(BusinessTimeZone)
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+type SubscriptionRepository struct {
+	db *sql.DB
+}
+
+func NewSubscriptionRepository(db *sql.DB) *SubscriptionRepository {
+	return &SubscriptionRepository{db: db}
+}
+
+const subscriptionColumns = `
+	id, user_id, plan_id, plan_name, sub_type, kind, starts_on, ends_on,
+	accesses, price_cents, max_freeze_days, created_by, created_at
+`
+
+func scanSubscription(row rowScanner) (*Subscription, error) {
+	var s Subscription
+	err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.PlanID,
+		&s.PlanName,
+		&s.SubType,
+		&s.Kind,
+		&s.StartsOn,
+		&s.EndsOn,
+		&s.Accesses,
+		&s.PriceCents,
+		&s.MaxFreezeDays,
+		&s.CreatedBy,
+		&s.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SubscriptionRepository) querySubscriptions(query string, args ...any) ([]*Subscription, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []*Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// GetByUserID returns every subscription of a member, the latest first.
+func (r *SubscriptionRepository) GetByUserID(userID string) ([]*Subscription, error) {
+	return r.querySubscriptions(`
+		SELECT `+subscriptionColumns+`
+		FROM subscriptions
+		WHERE user_id = $1
+		ORDER BY starts_on DESC, id DESC
+	`, userID)
+}
+
+// GetActive returns the subscription a member is on at the given time, or
+// ErrNoActiveSubscription when none covers that day.
+func (r *SubscriptionRepository) GetActive(userID string, at time.Time) (*ActiveSubscription, error) {
+	day := businessDay(at)
+	subscriptions, err := r.querySubscriptions(`
+		SELECT `+subscriptionColumns+`
+		FROM subscriptions
+		WHERE user_id = $1 AND ends_on >= $2::date
+		ORDER BY starts_on, id
+	`, userID, day.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	var active *ActiveSubscription
+	for _, s := range subscriptions {
+		if !s.StartsOn.After(day) {
+			active = &ActiveSubscription{Subscription: s, CoveredUntil: s.EndsOn}
+			continue
+		}
+		if active == nil || s.StartsOn.After(active.CoveredUntil.AddDate(0, 0, 1)) {
+			break
+		}
+		if s.EndsOn.After(active.CoveredUntil) {
+			active.CoveredUntil = s.EndsOn
+		}
+	}
+	if active == nil {
+		return nil, ErrNoActiveSubscription
+	}
+	return active, nil
+}
+
+// Assign sells a plan to an existing member from startsOn, today when zero.
+// See assignPlanTx for how it follows the member's current subscription.
+func (r *SubscriptionRepository) Assign(userID string, planID int64, startsOn time.Time, by string) (*Subscription, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	subscription, err := assignPlanTx(tx, userID, planID, startsOn, by)
+	if err != nil {
+		return nil, err
+	}
+	return subscription, tx.Commit()
+}
+
+// assignPlanTx records a subscription to an enabled plan with subscribeTx.
+func assignPlanTx(tx *sql.Tx, userID string, planID int64, startsOn time.Time, by string) (*Subscription, error) {
+	plan, err := scanPlan(tx.QueryRow(`SELECT `+planColumns+` FROM plans WHERE id = $1 FOR SHARE`, planID))
+	if err == sql.ErrNoRows || (err == nil && !plan.Enabled) {
+		return nil, ErrPlanUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return subscribeTx(tx, userID, plan, startsOn, by)
+}
+
+// subscribeTx records a subscription to plan and refreshes the subscription
+// type, expiry and accesses cached on the member. A member still covered the
+// day before startsOn renews: the new period starts after the current one and
+// its accesses add to the remaining ones. Otherwise the period starts on
+// startsOn and replaces the accesses left. Both changes to the accesses go
+// through the ledger.
+func subscribeTx(tx *sql.Tx, userID string, plan *Plan, startsOn time.Time, by string) (*Subscription, error) {
+	var current int
+	if err := tx.QueryRow(`SELECT remaining_accesses FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&current); err != nil {
+		return nil, err
+	}
+
+	var lastDay sql.NullTime
+	if err := tx.QueryRow(`SELECT MAX(ends_on) FROM subscriptions WHERE user_id = $1`, userID).Scan(&lastDay); err != nil {
+		return nil, err
+	}
+
+	if startsOn.IsZero() {
+		startsOn = businessDay(time.Now())
+	}
+	startsOn = civilDate(startsOn.Year(), startsOn.Month(), startsOn.Day())
+
+	subscription := &Subscription{
+		UserID:        userID,
+		PlanID:        sql.NullInt64{Int64: plan.ID, Valid: true},
+		PlanName:      plan.Name,
+		SubType:       plan.SubType,
+		Kind:          SubscriptionPurchase,
+		StartsOn:      startsOn,
+		Accesses:      plan.Accesses,
+		PriceCents:    plan.PriceCents,
+		MaxFreezeDays: plan.MaxFreezeDays,
+		CreatedBy:     sql.NullString{String: by, Valid: by != ""},
+	}
+	// dropped are the accesses the new plan replaces: all of them for a
+	// lapsed member, only a negative balance for a renewing one
+	dropped := current
+	if lastDay.Valid {
+		subscription.Kind = SubscriptionRenewal
+		last := civilDate(lastDay.Time.Year(), lastDay.Time.Month(), lastDay.Time.Day())
+		if !last.Before(startsOn.AddDate(0, 0, -1)) {
+			dropped = min(current, 0)
+			if !last.Before(startsOn) {
+				subscription.StartsOn = last.AddDate(0, 0, 1)
+			}
+		}
+	}
+	subscription.EndsOn = plan.LastDay(subscription.StartsOn)
+
+	err := tx.QueryRow(`
+		INSERT INTO subscriptions (user_id, plan_id, plan_name, sub_type, kind, starts_on, ends_on,
+			accesses, price_cents, max_freeze_days, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6::date, $7::date, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`, subscription.UserID, subscription.PlanID, subscription.PlanName, subscription.SubType, subscription.Kind,
+		subscription.StartsOn.Format("2006-01-02"), subscription.EndsOn.Format("2006-01-02"), subscription.Accesses, subscription.PriceCents,
+		subscription.MaxFreezeDays, subscription.CreatedBy).Scan(&subscription.ID, &subscription.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := changeAccessesTx(tx, AccessChange{UserID: userID, Delta: -dropped, Reason: AccessExpiry, ActorID: by}); err != nil {
+		return nil, err
+	}
+	if err := changeAccessesTx(tx, AccessChange{UserID: userID, Delta: plan.Accesses, Reason: AccessPlan, ActorID: by}); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users
+		SET sub_type = $2, expires_at = $3::date, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, userID, plan.SubType, subscription.EndsOn.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
@@ -199,6 +199,47 @@ func (r *UserRepository) GetAll() ([]*User, error) {
 }
 
 func (r *UserRepository) Create(user *User) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(tx, user); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateWithPlan creates a member already subscribed to a plan from startsOn,
+// today when zero. Nothing is created when the plan cannot be sold.
+func (r *UserRepository) CreateWithPlan(user *User, planID int64, startsOn time.Time, by string) (*Subscription, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(tx, user); err != nil {
+		return nil, err
+	}
+	subscription, err := assignPlanTx(tx, user.ID, planID, startsOn, by)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	user.SubType = subscription.SubType
+	user.ExpiresAt = subscription.EndsOn
+	user.RemainingAccesses = subscription.Accesses
+	return subscription, nil
+}
+
+// insertUser inserts a member and records their starting balance as the
+// opening entry of their access ledger.
+func insertUser(tx *sql.Tx, user *User) error {
 	query := `
 		INSERT INTO users
 			(id, first_name, last_name, address, password, role, med_ok,
@@ -207,7 +248,7 @@ func (r *UserRepository) Create(user *User) error {
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	`
 
-	_, err := r.db.Exec(query,
+	_, err := tx.Exec(query,
 		user.ID,
 		user.FirstName,
 		user.LastName,
@@ -225,18 +266,22 @@ func (r *UserRepository) Create(user *User) error {
 		user.VerificationTokenExpiresIn,
 		user.Goals,
 	)
+	if err != nil || user.RemainingAccesses == 0 {
+		return err
+	}
 
-	return err
+	return insertAccessEntry(tx, AccessChange{UserID: user.ID, Delta: user.RemainingAccesses, Reason: AccessOpening})
 }
 
+// Update saves the profile of a member. The remaining accesses are left
+// untouched: they only change through the access ledger.
 func (r *UserRepository) Update(user *User) error {
 	query := `
 		UPDATE users
 		SET first_name = $2, last_name = $3, address = $4, password = $5,
 			role = $6, med_ok = $7, cellphone = $8, sub_type = $9,
 			email = $10, email_verified = $11, expires_at = $12,
-			remaining_accesses = $13, verification_token = $14,
-			verification_token_expires_in = $15, goals = $16
+			verification_token = $13, verification_token_expires_in = $14, goals = $15
 		WHERE id = $1
 	`
 
@@ -253,7 +298,6 @@ func (r *UserRepository) Update(user *User) error {
 		user.Email,
 		user.EmailVerified,
 		user.ExpiresAt,
-		user.RemainingAccesses,
 		user.VerificationToken,
 		user.VerificationTokenExpiresIn,
 		user.Goals,
@@ -262,16 +306,54 @@ func (r *UserRepository) Update(user *User) error {
 	return err
 }
 
+// DecrementAccesses takes one access from a member as a manual adjustment.
+// It does nothing when none are left.
 func (r *UserRepository) DecrementAccesses(userID string) error {
-	query := `UPDATE users SET remaining_accesses = remaining_accesses - 1 WHERE id = $1 AND remaining_accesses > 0`
-	_, err := r.db.Exec(query, userID)
+	err := r.AdjustAccesses(userID, -1, "")
+	if err == ErrNoAccesses {
+		return nil
+	}
 	return err
 }
 
+// IncrementAccesses gives one access to a member as a manual adjustment.
 func (r *UserRepository) IncrementAccesses(userID string) error {
-	query := `UPDATE users SET remaining_accesses = remaining_accesses + 1 WHERE id = $1`
-	_, err := r.db.Exec(query, userID)
-	return err
+	return r.AdjustAccesses(userID, 1, "")
+}
+
+// AdjustAccesses changes the remaining accesses of a member by delta and
+// records the adjustment in the ledger. It returns ErrNoAccesses when the
+// member does not exist or the balance would drop below zero.
+func (r *UserRepository) AdjustAccesses(userID string, delta int, actorID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := changeAccessesTx(tx, AccessChange{UserID: userID, Delta: delta, Reason: AccessAdjustment, ActorID: actorID}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetAccesses sets the remaining accesses of a member, recording the
+// difference with the current balance as an adjustment.
+func (r *UserRepository) SetAccesses(userID string, value int, actorID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current int
+	if err := tx.QueryRow(`SELECT remaining_accesses FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&current); err != nil {
+		return err
+	}
+	if err := changeAccessesTx(tx, AccessChange{UserID: userID, Delta: value - current, Reason: AccessAdjustment, ActorID: actorID}); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *UserRepository) Delete(ids []string) error {
@@ -2,6 +2,7 @@ package models_test
 
 import (
 	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -82,7 +83,7 @@ func TestUserRepository_Integration(t *testing.T) {
 			t.Fatalf("Failed to create user: %v", err)
 		}
 
-		// Update user
+		// Update user: the balance only changes through the access ledger
 		user.FirstName = "Janet"
 		user.RemainingAccesses = 10
 
@@ -101,11 +102,90 @@ func TestUserRepository_Integration(t *testing.T) {
 			t.Errorf("Expected first name Janet, got %s", retrieved.FirstName)
 		}
 
+		if retrieved.RemainingAccesses != 5 {
+			t.Errorf("Expected 5 remaining accesses, got %d", retrieved.RemainingAccesses)
+		}
+
+		if err := repo.SetAccesses(user.ID, 10, ""); err != nil {
+			t.Fatalf("Failed to set accesses: %v", err)
+		}
+		retrieved, err = repo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user by ID: %v", err)
+		}
 		if retrieved.RemainingAccesses != 10 {
 			t.Errorf("Expected 10 remaining accesses, got %d", retrieved.RemainingAccesses)
 		}
 	})
 
+	t.Run("Access Ledger", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "access_ledger", "users")
+
+		ledgerRepo := models.NewAccessLedgerRepository(db)
+		user := &models.User{
+			ID:                uuid.New().String(),
+			FirstName:         "Ledger",
+			LastName:          "Member",
+			Email:             "ledger@example.com",
+			Role:              models.RoleUser,
+			SubType:           models.SubTypeShared,
+			ExpiresAt:         time.Now().Add(30 * 24 * time.Hour),
+			RemainingAccesses: 5,
+		}
+		if err := repo.Create(user); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+
+		if err := repo.DecrementAccesses(user.ID); err != nil {
+			t.Fatalf("Failed to decrement accesses: %v", err)
+		}
+		if err := repo.SetAccesses(user.ID, 2, ""); err != nil {
+			t.Fatalf("Failed to set accesses: %v", err)
+		}
+		if err := repo.AdjustAccesses(user.ID, -3, ""); !errors.Is(err, models.ErrNoAccesses) {
+			t.Errorf("Expected ErrNoAccesses below zero, got %v", err)
+		}
+
+		entries, err := ledgerRepo.GetByUserID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get ledger: %v", err)
+		}
+		wantReasons := []models.AccessReason{models.AccessAdjustment, models.AccessAdjustment, models.AccessOpening}
+		if len(entries) != len(wantReasons) {
+			t.Fatalf("Expected %d ledger entries, got %d", len(wantReasons), len(entries))
+		}
+		sum := 0
+		for i, e := range entries {
+			if e.Reason != wantReasons[i] {
+				t.Errorf("Expected entry %d to be %s, got %s", i, wantReasons[i], e.Reason)
+			}
+			sum += e.Delta
+		}
+		if sum != 2 {
+			t.Errorf("Expected the ledger to sum to 2, got %d", sum)
+		}
+
+		mismatches, err := ledgerRepo.Reconcile()
+		if err != nil {
+			t.Fatalf("Failed to reconcile: %v", err)
+		}
+		if len(mismatches) != 0 {
+			t.Errorf("Expected no mismatches, got %+v", mismatches)
+		}
+
+		// A change made behind the ledger shows up in the reconciliation
+		if _, err := db.Exec(`UPDATE users SET remaining_accesses = 7 WHERE id = $1`, user.ID); err != nil {
+			t.Fatalf("Failed to change balance: %v", err)
+		}
+		mismatches, err = ledgerRepo.Reconcile()
+		if err != nil {
+			t.Fatalf("Failed to reconcile: %v", err)
+		}
+		if len(mismatches) != 1 || mismatches[0].Cached != 7 || mismatches[0].LedgerSum != 2 {
+			t.Errorf("Expected one mismatch of 7 against 2, got %+v", mismatches)
+		}
+	})
+
 	t.Run("Increment and Decrement Accesses", func(t *testing.T) {
 		testutil.TruncateTables(t, db, "users")
 
@@ -235,4 +315,256 @@ func TestUserRepository_Integration(t *testing.T) {
 			t.Errorf("Expected 3 users, got %d", len(users))
 		}
 	})
+
+	t.Run("Subscribe And Renew Plan", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "access_ledger", "subscriptions", "plans", "users")
+
+		planRepo := models.NewPlanRepository(db)
+		subscriptionRepo := models.NewSubscriptionRepository(db)
+
+		plan := &models.Plan{Name: "Mensile", DurationDays: 30, Accesses: 8, SubType: models.SubTypeSingle, Enabled: true}
+		if err := planRepo.Create(plan); err != nil {
+			t.Fatalf("Failed to create plan: %v", err)
+		}
+
+		user := &models.User{
+			ID:        uuid.New().String(),
+			FirstName: "Plan",
+			LastName:  "Member",
+			Email:     "plan@example.com",
+			Role:      models.RoleUser,
+		}
+		today := time.Now()
+		first, err := repo.CreateWithPlan(user, plan.ID, time.Time{}, "")
+		if err != nil {
+			t.Fatalf("Failed to create user with plan: %v", err)
+		}
+		if first.Kind != models.SubscriptionPurchase {
+			t.Errorf("Expected a purchase, got %s", first.Kind)
+		}
+
+		// Renewing while covered queues the new period and adds its accesses
+		second, err := subscriptionRepo.Assign(user.ID, plan.ID, time.Time{}, "")
+		if err != nil {
+			t.Fatalf("Failed to renew: %v", err)
+		}
+		if second.Kind != models.SubscriptionRenewal {
+			t.Errorf("Expected a renewal, got %s", second.Kind)
+		}
+		if !second.StartsOn.Equal(first.EndsOn.AddDate(0, 0, 1)) {
+			t.Errorf("Expected renewal to start on %s, got %s", first.EndsOn.AddDate(0, 0, 1), second.StartsOn)
+		}
+
+		retrieved, err := repo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		if retrieved.RemainingAccesses != 16 || retrieved.SubType != models.SubTypeSingle {
+			t.Errorf("Expected 16 SINGLE accesses, got %d %s", retrieved.RemainingAccesses, retrieved.SubType)
+		}
+
+		active, err := subscriptionRepo.GetActive(user.ID, today)
+		if err != nil {
+			t.Fatalf("Failed to get active subscription: %v", err)
+		}
+		if active.ID != first.ID || !active.CoveredUntil.Equal(second.EndsOn) {
+			t.Errorf("Expected first subscription covered until %s, got %d until %s", second.EndsOn, active.ID, active.CoveredUntil)
+		}
+		if _, err := subscriptionRepo.GetActive(user.ID, today.AddDate(0, 0, 61)); !errors.Is(err, models.ErrNoActiveSubscription) {
+			t.Errorf("Expected ErrNoActiveSubscription after both periods, got %v", err)
+		}
+
+		// Plans with subscriptions cannot be deleted nor sold once disabled
+		if err := planRepo.Delete(plan.ID); !errors.Is(err, models.ErrPlanInUse) {
+			t.Errorf("Expected ErrPlanInUse, got %v", err)
+		}
+		plan.Enabled = false
+		if err := planRepo.Update(plan); err != nil {
+			t.Fatalf("Failed to disable plan: %v", err)
+		}
+		if _, err := subscriptionRepo.Assign(user.ID, plan.ID, time.Time{}, ""); !errors.Is(err, models.ErrPlanUnavailable) {
+			t.Errorf("Expected ErrPlanUnavailable, got %v", err)
+		}
+	})
+
+	t.Run("Freeze Subscription", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "access_ledger", "access_wallets", "subscription_freezes", "subscriptions", "plans", "services", "users")
+
+		planRepo := models.NewPlanRepository(db)
+		subscriptionRepo := models.NewSubscriptionRepository(db)
+		walletRepo := models.NewWalletRepository(db)
+
+		plan := &models.Plan{Name: "Mensile", DurationDays: 30, Accesses: 8, SubType: models.SubTypeShared, MaxFreezeDays: 7, Enabled: true}
+		if err := planRepo.Create(plan); err != nil {
+			t.Fatalf("Failed to create plan: %v", err)
+		}
+
+		user := &models.User{
+			ID:        uuid.New().String(),
+			FirstName: "Frozen",
+			LastName:  "Member",
+			Email:     "frozen@example.com",
+			Role:      models.RoleUser,
+		}
+		first, err := repo.CreateWithPlan(user, plan.ID, time.Time{}, "")
+		if err != nil {
+			t.Fatalf("Failed to create user with plan: %v", err)
+		}
+		second, err := subscriptionRepo.Assign(user.ID, plan.ID, time.Time{}, "")
+		if err != nil {
+			t.Fatalf("Failed to renew: %v", err)
+		}
+
+		massage := &models.Service{Name: "Massaggio", DurationMinutes: 60, CapacityWeight: 1, Enabled: true}
+		if err := models.NewServiceRepository(db).Create(massage); err != nil {
+			t.Fatalf("Failed to create service: %v", err)
+		}
+		wallet, err := walletRepo.TopUp(user.ID, massage.ID, 2, first.EndsOn, "")
+		if err != nil {
+			t.Fatalf("Failed to top up wallet: %v", err)
+		}
+
+		startsOn := first.StartsOn.AddDate(0, 0, 1)
+		result, err := subscriptionRepo.Freeze(&models.Freeze{
+			UserID:   user.ID,
+			StartsOn: startsOn,
+			EndsOn:   startsOn.AddDate(0, 0, 4),
+			Reason:   "Infortunio",
+		})
+		if err != nil {
+			t.Fatalf("Failed to freeze: %v", err)
+		}
+		if result.Freeze.SubscriptionID != first.ID || result.Freeze.Days() != 5 {
+			t.Errorf("Expected 5 days on subscription %d, got %d on %d", first.ID, result.Freeze.Days(), result.Freeze.SubscriptionID)
+		}
+
+		// The frozen subscription and the renewal after it move forward
+		subscriptions, err := subscriptionRepo.GetByUserID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get subscriptions: %v", err)
+		}
+		if len(subscriptions) != 2 ||
+			!subscriptions[1].EndsOn.Equal(first.EndsOn.AddDate(0, 0, 5)) ||
+			!subscriptions[0].StartsOn.Equal(second.StartsOn.AddDate(0, 0, 5)) ||
+			!subscriptions[0].EndsOn.Equal(second.EndsOn.AddDate(0, 0, 5)) {
+			t.Errorf("Expected both subscriptions pushed forward by 5 days, got %+v", subscriptions)
+		}
+		retrieved, err := repo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		if !retrieved.ExpiresAt.Equal(second.EndsOn.AddDate(0, 0, 5)) {
+			t.Errorf("Expected expiry %s, got %s", second.EndsOn.AddDate(0, 0, 5), retrieved.ExpiresAt)
+		}
+		extended, err := walletRepo.GetForService(user.ID, massage.ID)
+		if err != nil {
+			t.Fatalf("Failed to get wallet: %v", err)
+		}
+		if !extended.ExpiresOn.Equal(wallet.ExpiresOn.AddDate(0, 0, 5)) {
+			t.Errorf("Expected wallet expiry %s, got %s", wallet.ExpiresOn.AddDate(0, 0, 5), extended.ExpiresOn)
+		}
+
+		_, err = subscriptionRepo.Freeze(&models.Freeze{UserID: user.ID, StartsOn: startsOn.AddDate(0, 0, 4), EndsOn: startsOn.AddDate(0, 0, 5), Reason: "Ferie"})
+		if !errors.Is(err, models.ErrFreezeOverlap) {
+			t.Errorf("Expected ErrFreezeOverlap, got %v", err)
+		}
+		_, err = subscriptionRepo.Freeze(&models.Freeze{UserID: user.ID, StartsOn: startsOn.AddDate(0, 0, 10), EndsOn: startsOn.AddDate(0, 0, 12), Reason: "Ferie"})
+		if !errors.Is(err, models.ErrFreezeLimit) {
+			t.Errorf("Expected ErrFreezeLimit with 2 of 7 days left, got %v", err)
+		}
+		_, err = subscriptionRepo.Freeze(&models.Freeze{UserID: user.ID, StartsOn: startsOn.AddDate(0, 0, 10), EndsOn: startsOn.AddDate(0, 0, 11)})
+		if !errors.Is(err, models.ErrInvalidFreeze) {
+			t.Errorf("Expected ErrInvalidFreeze without a reason, got %v", err)
+		}
+
+		freezes, err := subscriptionRepo.GetFreezes(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get freezes: %v", err)
+		}
+		if len(freezes) != 1 || freezes[0].Reason != "Infortunio" {
+			t.Errorf("Expected the injury freeze only, got %+v", freezes)
+		}
+	})
+
+	t.Run("Pay For Plan", func(t *testing.T) {
+		testutil.TruncateTables(t, db, "access_ledger", "payments", "subscriptions", "plans", "users")
+
+		planRepo := models.NewPlanRepository(db)
+		paymentRepo := models.NewPaymentRepository(db)
+
+		plan := &models.Plan{Name: "Mensile", DurationDays: 30, Accesses: 8, SubType: models.SubTypeShared, PriceCents: 8000, Enabled: true}
+		if err := planRepo.Create(plan); err != nil {
+			t.Fatalf("Failed to create plan: %v", err)
+		}
+		user := &models.User{
+			ID:        uuid.New().String(),
+			FirstName: "Paying",
+			LastName:  "Member",
+			Email:     "paying@example.com",
+			Role:      models.RoleUser,
+			SubType:   models.SubTypeShared,
+			ExpiresAt: time.Now().AddDate(0, 0, -1),
+		}
+		if err := repo.Create(user); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+
+		payment, err := paymentRepo.Start(user.ID, plan.ID, "eur", "fake")
+		if err != nil {
+			t.Fatalf("Failed to start payment: %v", err)
+		}
+		if err := paymentRepo.SetCheckout(payment.ID, "fake_cs_1"); err != nil {
+			t.Fatalf("Failed to set checkout: %v", err)
+		}
+
+		// A price change after checkout does not change what was paid
+		plan.PriceCents = 9000
+		plan.Enabled = false
+		if err := planRepo.Update(plan); err != nil {
+			t.Fatalf("Failed to update plan: %v", err)
+		}
+
+		if _, err := paymentRepo.MarkPaid(payment.ID, "fake", "fake_cs_2", 8000); !errors.Is(err, models.ErrPaymentMismatch) {
+			t.Errorf("Expected ErrPaymentMismatch for another checkout, got %v", err)
+		}
+		if _, err := paymentRepo.MarkPaid(payment.ID, "fake", "fake_cs_1", 100); !errors.Is(err, models.ErrPaymentMismatch) {
+			t.Errorf("Expected ErrPaymentMismatch for another amount, got %v", err)
+		}
+
+		// Webhooks are retried, the plan is granted once
+		for i := 0; i < 2; i++ {
+			paid, err := paymentRepo.MarkPaid(payment.ID, "fake", "fake_cs_1", 8000)
+			if err != nil {
+				t.Fatalf("Failed to mark payment paid: %v", err)
+			}
+			if paid.Status != models.PaymentPaid || !paid.SubscriptionID.Valid {
+				t.Errorf("Expected a PAID payment with a subscription, got %+v", paid)
+			}
+		}
+		if failed, err := paymentRepo.MarkFailed(payment.ID, "fake", "fake_cs_1"); err != nil || failed.Status != models.PaymentPaid {
+			t.Errorf("Expected a late failure to leave the payment PAID, got %v", err)
+		}
+
+		subscriptions, err := models.NewSubscriptionRepository(db).GetByUserID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get subscriptions: %v", err)
+		}
+		if len(subscriptions) != 1 || subscriptions[0].PriceCents != 8000 {
+			t.Errorf("Expected one subscription at the price paid, got %+v", subscriptions)
+		}
+		retrieved, err := repo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		if retrieved.RemainingAccesses != 8 {
+			t.Errorf("Expected 8 accesses, got %d", retrieved.RemainingAccesses)
+		}
+
+		if _, err := paymentRepo.Start(user.ID, plan.ID, "eur", "fake"); !errors.Is(err, models.ErrPlanUnavailable) {
+			t.Errorf("Expected ErrPlanUnavailable for a disabled plan, got %v", err)
+		}
+		if err := planRepo.Delete(plan.ID); !errors.Is(err, models.ErrPlanInUse) {
+			t.Errorf("Expected ErrPlanInUse, got %v", err)
+		}
+	})
 }
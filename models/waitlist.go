@@ -0,0 +1,183 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrAlreadyWaitlisted = errors.New("already on the waitlist")
+	ErrWaitlistEntryGone = errors.New("waitlist entry no longer exists")
+)
+
+// WaitlistEntry is a user waiting for capacity on an instructor's slot.
+// Entries are served in creation order and expire when the slot starts.
+type WaitlistEntry struct {
+	ID              int64
+	UserID          string
+	InstructorID    int64
+	ServiceID       sql.NullInt64
+	DurationMinutes int
+	StartsAt        time.Time
+	CreatedAt       time.Time
+}
+
+type WaitlistEntryWithDetails struct {
+	WaitlistEntry
+	UserSubType         SubType
+	CapacityWeight      int
+	ServiceName         sql.NullString
+	InstructorFirstName sql.NullString
+	InstructorLastName  sql.NullString
+}
+
+type WaitlistRepository struct {
+	db *sql.DB
+}
+
+func NewWaitlistRepository(db *sql.DB) *WaitlistRepository {
+	return &WaitlistRepository{db: db}
+}
+
+func (r *WaitlistRepository) Create(entry *WaitlistEntry) error {
+	query := `
+		INSERT INTO waitlist_entries (user_id, instructor_id, service_id, duration_minutes, starts_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, instructor_id, starts_at) DO NOTHING
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(query,
+		entry.UserID,
+		entry.InstructorID,
+		entry.ServiceID,
+		entry.DurationMinutes,
+		entry.StartsAt,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrAlreadyWaitlisted
+	}
+	return err
+}
+
+const waitlistDetailsQuery = `
+	SELECT w.id, w.user_id, w.instructor_id, w.service_id, w.duration_minutes, w.starts_at, w.created_at,
+		   COALESCE(u.sub_type, ''), COALESCE(s.capacity_weight, 1), s.name,
+		   i.first_name, i.last_name
+	FROM waitlist_entries w
+	JOIN users u ON u.id = w.user_id
+	LEFT JOIN services s ON s.id = w.service_id
+	LEFT JOIN instructors i ON i.id = w.instructor_id
+`
+
+// GetByUserID returns the entries of a user that have not expired yet.
+func (r *WaitlistRepository) GetByUserID(userID string) ([]*WaitlistEntryWithDetails, error) {
+	query := waitlistDetailsQuery + `
+		WHERE w.user_id = $1 AND w.starts_at > CURRENT_TIMESTAMP
+		ORDER BY w.starts_at ASC
+	`
+	return r.queryWithDetails(query, userID)
+}
+
+// GetCandidates returns the unexpired entries for an instructor whose interval
+// overlaps [from, to), in waitlist order.
+func (r *WaitlistRepository) GetCandidates(instructorID int64, from, to time.Time) ([]*WaitlistEntryWithDetails, error) {
+	query := waitlistDetailsQuery + `
+		WHERE w.instructor_id = $1
+			AND w.starts_at > CURRENT_TIMESTAMP
+			AND w.starts_at < $3
+			AND w.starts_at + w.duration_minutes * INTERVAL '1 minute' > $2
+		ORDER BY w.created_at ASC, w.id ASC
+	`
+	return r.queryWithDetails(query, instructorID, from, to)
+}
+
+func (r *WaitlistRepository) queryWithDetails(query string, args ...interface{}) ([]*WaitlistEntryWithDetails, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*WaitlistEntryWithDetails
+	for rows.Next() {
+		var entry WaitlistEntryWithDetails
+		err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.InstructorID,
+			&entry.ServiceID,
+			&entry.DurationMinutes,
+			&entry.StartsAt,
+			&entry.CreatedAt,
+			&entry.UserSubType,
+			&entry.CapacityWeight,
+			&entry.ServiceName,
+			&entry.InstructorFirstName,
+			&entry.InstructorLastName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// DeleteForUser removes an entry owned by the user.
+func (r *WaitlistRepository) DeleteForUser(id int64, userID string) error {
+	result, err := r.db.Exec(`DELETE FROM waitlist_entries WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Promote books the slot of a waitlist entry through the same locked path as
+// CreateUserBooking and removes the entry in the same transaction.
+func (r *WaitlistRepository) Promote(entry *WaitlistEntry, neededSlots, maxSlots int) (*Booking, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM waitlist_entries WHERE id = $1 AND starts_at > CURRENT_TIMESTAMP`, entry.ID)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrWaitlistEntryGone
+	}
+
+	booking := &Booking{
+		UserID:          sql.NullString{String: entry.UserID, Valid: true},
+		InstructorID:    entry.InstructorID,
+		StartsAt:        entry.StartsAt,
+		Type:            BookingTypeSimple,
+		ServiceID:       entry.ServiceID,
+		DurationMinutes: entry.DurationMinutes,
+	}
+	if err := createUserBookingTx(tx, booking, neededSlots, maxSlots); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidTopUp = errors.New("invalid top-up")
+	ErrServiceInUse = errors.New("service has wallets")
+)
+
+// Wallet is a balance of accesses a member can only spend on one service.
+// ExpiresOn is the last day, as a civil date, a booking can fall on.
+type Wallet struct {
+	ID          int64
+	UserID      string
+	ServiceID   int64
+	ServiceName string
+	Remaining   int
+	ExpiresOn   time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Covers reports whether the wallet can pay for a booking starting at t.
+func (w *Wallet) Covers(t time.Time) bool {
+	return !w.ExpiresOn.Before(businessDay(t))
+}
+
+type WalletRepository struct {
+	db *sql.DB
+}
+
+func NewWalletRepository(db *sql.DB) *WalletRepository {
+	return &WalletRepository{db: db}
+}
+
+const walletQuery = `
+	SELECT w.id, w.user_id, w.service_id, s.name, w.remaining, w.expires_on, w.created_at, w.updated_at
+	FROM access_wallets w
+	JOIN services s ON s.id = w.service_id
+`
+
+func scanWallet(row rowScanner) (*Wallet, error) {
+	var w Wallet
+	err := row.Scan(
+		&w.ID,
+		&w.UserID,
+		&w.ServiceID,
+		&w.ServiceName,
+		&w.Remaining,
+		&w.ExpiresOn,
+		&w.CreatedAt,
+		&w.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// GetByUserID returns the wallets of a member ordered by service name.
+func (r *WalletRepository) GetByUserID(userID string) ([]*Wallet, error) {
+	rows, err := r.db.Query(walletQuery+`WHERE w.user_id = $1 ORDER BY s.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wallets []*Wallet
+	for rows.Next() {
+		w, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
+	}
+
+	return wallets, rows.Err()
+}
+
+// GetForService returns the wallet of a member for a service, or
+// sql.ErrNoRows when the member has none for it.
+func (r *WalletRepository) GetForService(userID string, serviceID int64) (*Wallet, error) {
+	return scanWallet(r.db.QueryRow(walletQuery+`WHERE w.user_id = $1 AND w.service_id = $2`, userID, serviceID))
+}
+
+// TopUp adds accesses to the wallet of a member for a service, opening it
+// when missing, and moves its expiry to expiresOn. A negative amount takes
+// accesses away and returns ErrNoAccesses when fewer are left. It returns
+// sql.ErrNoRows when the service does not exist.
+func (r *WalletRepository) TopUp(userID string, serviceID int64, accesses int, expiresOn time.Time, by string) (*Wallet, error) {
+	if expiresOn.IsZero() {
+		return nil, fmt.Errorf("%w: expiry is required", ErrInvalidTopUp)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(`SELECT id FROM services WHERE id = $1`, serviceID).Scan(&serviceID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO access_wallets (user_id, service_id, expires_on)
+		VALUES ($1, $2, $3::date)
+		ON CONFLICT (user_id, service_id)
+		DO UPDATE SET expires_on = EXCLUDED.expires_on, updated_at = CURRENT_TIMESTAMP
+	`, userID, serviceID, expiresOn.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	reason := AccessTopUp
+	if accesses < 0 {
+		reason = AccessAdjustment
+	}
+	if err := changeAccessesTx(tx, AccessChange{UserID: userID, Delta: accesses, Reason: reason, ServiceID: serviceID, ActorID: by}); err != nil {
+		return nil, err
+	}
+
+	wallet, err := scanWallet(tx.QueryRow(walletQuery+`WHERE w.user_id = $1 AND w.service_id = $2`, userID, serviceID))
+	if err != nil {
+		return nil, err
+	}
+	return wallet, tx.Commit()
+}
+
+// bookingWalletTx returns the service whose wallet pays for a booking of
+// the member, zero when the general balance does. A wallet that expires
+// before the booking or has no accesses left leaves it to the general
+// balance.
+func bookingWalletTx(tx *sql.Tx, userID string, serviceID sql.NullInt64, startsAt time.Time) (int64, error) {
+	if !serviceID.Valid {
+		return 0, nil
+	}
+
+	var wallet Wallet
+	err := tx.QueryRow(`
+		SELECT remaining, expires_on FROM access_wallets WHERE user_id = $1 AND service_id = $2 FOR UPDATE
+	`, userID, serviceID.Int64).Scan(&wallet.Remaining, &wallet.ExpiresOn)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if !wallet.Covers(startsAt) || wallet.Remaining <= 0 {
+		return 0, nil
+	}
+	return serviceID.Int64, nil
+}
+
+// refundWalletTx returns the service whose wallet paid for a booking, zero
+// for the general balance or bookings made before the ledger.
+func refundWalletTx(tx *sql.Tx, bookingID int64) (int64, error) {
+	var serviceID sql.NullInt64
+	err := tx.QueryRow(`
+		SELECT service_id FROM access_ledger
+		WHERE booking_id = $1 AND reason = $2
+		ORDER BY id DESC
+		LIMIT 1
+	`, bookingID, AccessBooking).Scan(&serviceID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	return serviceID.Int64, nil
+}
@@ -0,0 +1,117 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FakeCheckoutPath is the page of the member dashboard standing in for the
+// checkout of the fake provider
+const FakeCheckoutPath = "/user/checkout"
+
+var ErrUnknownCheckout = errors.New("unknown checkout")
+
+// FakeProvider takes payments without any payment service, for tests and
+// local development. Its checkout page lets the member pay or cancel, and
+// the outcome is delivered as a webhook signed like Stripe's.
+type FakeProvider struct {
+	secret string
+
+	mu        sync.Mutex
+	checkouts map[string]CheckoutRequest
+	next      int
+}
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{secret: secret, checkouts: make(map[string]CheckoutRequest)}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// CreateCheckout remembers the request and points to the fake checkout page.
+func (p *FakeProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.next++
+	id := "fake_cs_" + strconv.Itoa(p.next)
+	p.checkouts[id] = req
+	return &Checkout{ID: id, URL: FakeCheckoutPath + "?session=" + url.QueryEscape(id)}, nil
+}
+
+// Request returns what a checkout was opened for.
+func (p *FakeProvider) Request(checkoutID string) (CheckoutRequest, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	req, ok := p.checkouts[checkoutID]
+	if !ok {
+		return CheckoutRequest{}, ErrUnknownCheckout
+	}
+	return req, nil
+}
+
+type fakeEvent struct {
+	ID          string    `json:"id"`
+	Type        EventType `json:"type"`
+	CheckoutID  string    `json:"checkoutId"`
+	Reference   string    `json:"reference"`
+	AmountCents int       `json:"amountCents"`
+}
+
+// Complete ends a checkout as paid, or as failed when paid is false, and
+// returns the signed webhook request the provider would send.
+func (p *FakeProvider) Complete(checkoutID string, paid bool) ([]byte, http.Header, error) {
+	req, err := p.Request(checkoutID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	event := fakeEvent{
+		ID:          "fake_evt_" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		Type:        EventFailed,
+		CheckoutID:  checkoutID,
+		Reference:   req.Reference,
+		AmountCents: req.AmountCents,
+	}
+	if paid {
+		event.Type = EventPaid
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := http.Header{}
+	header.Set("Fake-Signature", sign(p.secret, payload, time.Now()))
+	return payload, header, nil
+}
+
+func (p *FakeProvider) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := verify(p.secret, payload, header.Get("Fake-Signature"), time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event fakeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	if event.Type != EventPaid && event.Type != EventFailed {
+		return nil, ErrUnhandledEvent
+	}
+	return &Event{
+		ID:          event.ID,
+		Type:        event.Type,
+		CheckoutID:  event.CheckoutID,
+		Reference:   event.Reference,
+		AmountCents: event.AmountCents,
+	}, nil
+}
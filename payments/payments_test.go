@@ -0,0 +1,167 @@
+package payments
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	payload := []byte(`{"id":"evt_1"}`)
+	now := time.Now()
+	header := sign("whsec_test", payload, now)
+
+	if err := verify("whsec_test", payload, header, now); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+	if err := verify("whsec_other", payload, header, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature with another secret, got %v", err)
+	}
+	if err := verify("whsec_test", []byte(`{"id":"evt_2"}`), header, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a tampered payload, got %v", err)
+	}
+	if err := verify("whsec_test", payload, header, now.Add(10*time.Minute)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a replayed event, got %v", err)
+	}
+	if err := verify("whsec_test", payload, "garbage", now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a malformed header, got %v", err)
+	}
+
+	// A rolled secret signs with both keys
+	rolled := header + ",v1=" + hex.EncodeToString(signature("whsec_new", strconv.FormatInt(now.Unix(), 10), payload))
+	if err := verify("whsec_new", payload, rolled, now); err != nil {
+		t.Errorf("Expected the second signature to match, got %v", err)
+	}
+}
+
+func TestStripeCreateCheckout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/checkout/sessions" || r.Header.Get("Authorization") != "Bearer sk_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"Invalid API Key provided"}}`))
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("Failed to parse form: %v", err)
+		}
+		if r.Form.Get("mode") != "payment" ||
+			r.Form.Get("client_reference_id") != "42" ||
+			r.Form.Get("line_items[0][price_data][unit_amount]") != "8000" ||
+			r.Form.Get("line_items[0][price_data][currency]") != "eur" {
+			t.Errorf("Unexpected checkout form: %v", r.Form)
+		}
+		if r.Header.Get("Idempotency-Key") != "checkout-42" {
+			t.Errorf("Expected the reference as idempotency key, got %q", r.Header.Get("Idempotency-Key"))
+		}
+		w.Write([]byte(`{"id":"cs_test_1","url":"https://checkout.stripe.com/c/pay/cs_test_1"}`))
+	}))
+	defer server.Close()
+
+	provider := NewStripeProvider("sk_test", "whsec_test")
+	provider.apiURL = server.URL
+
+	req := CheckoutRequest{Reference: "42", Description: "Mensile", AmountCents: 8000, Currency: "eur"}
+	checkout, err := provider.CreateCheckout(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to create checkout: %v", err)
+	}
+	if checkout.ID != "cs_test_1" || checkout.URL != "https://checkout.stripe.com/c/pay/cs_test_1" {
+		t.Errorf("Unexpected checkout %+v", checkout)
+	}
+
+	provider.secretKey = "sk_wrong"
+	if _, err := provider.CreateCheckout(context.Background(), req); err == nil || err.Error() != "stripe: Invalid API Key provided" {
+		t.Errorf("Expected the Stripe error message, got %v", err)
+	}
+}
+
+func TestStripeParseWebhook(t *testing.T) {
+	provider := NewStripeProvider("sk_test", "whsec_test")
+
+	tests := []struct {
+		name    string
+		payload string
+		want    EventType
+		wantErr error
+	}{
+		{
+			"Paid",
+			`{"id":"evt_1","type":"checkout.session.completed","data":{"object":{"id":"cs_1","client_reference_id":"42","amount_total":8000,"payment_status":"paid"}}}`,
+			EventPaid, nil,
+		},
+		{
+			"Payment pending",
+			`{"id":"evt_2","type":"checkout.session.completed","data":{"object":{"id":"cs_1","client_reference_id":"42","amount_total":8000,"payment_status":"unpaid"}}}`,
+			"", ErrUnhandledEvent,
+		},
+		{
+			"Late payment",
+			`{"id":"evt_3","type":"checkout.session.async_payment_succeeded","data":{"object":{"id":"cs_1","client_reference_id":"42","amount_total":8000,"payment_status":"paid"}}}`,
+			EventPaid, nil,
+		},
+		{
+			"Expired",
+			`{"id":"evt_4","type":"checkout.session.expired","data":{"object":{"id":"cs_1","client_reference_id":"42"}}}`,
+			EventFailed, nil,
+		},
+		{
+			"Other event",
+			`{"id":"evt_5","type":"customer.created","data":{"object":{"id":"cus_1"}}}`,
+			"", ErrUnhandledEvent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Stripe-Signature", sign("whsec_test", []byte(tt.payload), time.Now()))
+
+			event, err := provider.ParseWebhook([]byte(tt.payload), header)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse webhook: %v", err)
+			}
+			if event.Type != tt.want || event.CheckoutID != "cs_1" || event.Reference != "42" {
+				t.Errorf("Unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+func TestFakeProvider(t *testing.T) {
+	provider := NewFakeProvider("fake_secret")
+
+	checkout, err := provider.CreateCheckout(context.Background(), CheckoutRequest{Reference: "7", AmountCents: 5000, Currency: "eur"})
+	if err != nil {
+		t.Fatalf("Failed to create checkout: %v", err)
+	}
+
+	payload, header, err := provider.Complete(checkout.ID, true)
+	if err != nil {
+		t.Fatalf("Failed to complete checkout: %v", err)
+	}
+	event, err := provider.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("Failed to parse webhook: %v", err)
+	}
+	if event.Type != EventPaid || event.CheckoutID != checkout.ID || event.Reference != "7" || event.AmountCents != 5000 {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	if _, err := NewFakeProvider("other").ParseWebhook(payload, header); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature with another secret, got %v", err)
+	}
+	if _, _, err := provider.Complete("fake_cs_missing", true); !errors.Is(err, ErrUnknownCheckout) {
+		t.Errorf("Expected ErrUnknownCheckout, got %v", err)
+	}
+}
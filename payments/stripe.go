@@ -0,0 +1,147 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stripeAPIURL = "https://api.stripe.com"
+
+// StripeProvider takes payments with Stripe Checkout through its REST API.
+type StripeProvider struct {
+	secretKey     string
+	webhookSecret string
+	// apiURL is the Stripe API, replaced in tests
+	apiURL string
+	client *http.Client
+}
+
+// NewStripeProvider returns a provider authenticating with the secret API key
+// of the account and verifying webhooks with the signing secret of the
+// endpoint.
+func NewStripeProvider(secretKey, webhookSecret string) *StripeProvider {
+	return &StripeProvider{
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		apiURL:        stripeAPIURL,
+		client:        &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+type stripeSession struct {
+	ID                string `json:"id"`
+	URL               string `json:"url"`
+	ClientReferenceID string `json:"client_reference_id"`
+	AmountTotal       int    `json:"amount_total"`
+	PaymentStatus     string `json:"payment_status"`
+}
+
+type stripeError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// CreateCheckout opens a Checkout Session in payment mode with one line item.
+// The reference is the idempotency key, so retrying a request cannot open a
+// second session for the same payment.
+func (p *StripeProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", req.SuccessURL)
+	form.Set("cancel_url", req.CancelURL)
+	form.Set("client_reference_id", req.Reference)
+	form.Set("metadata[reference]", req.Reference)
+	if req.CustomerEmail != "" {
+		form.Set("customer_email", req.CustomerEmail)
+	}
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", req.Currency)
+	form.Set("line_items[0][price_data][unit_amount]", strconv.Itoa(req.AmountCents))
+	form.Set("line_items[0][price_data][product_data][name]", req.Description)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL+"/v1/checkout/sessions", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.secretKey)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Idempotency-Key", "checkout-"+req.Reference)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr stripeError
+		if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("stripe: %s", apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("stripe: unexpected status %d", resp.StatusCode)
+	}
+
+	var session stripeSession
+	if err := json.Unmarshal(body, &session); err != nil {
+		return nil, err
+	}
+	return &Checkout{ID: session.ID, URL: session.URL}, nil
+}
+
+// ParseWebhook verifies the Stripe-Signature header and maps the events of
+// Checkout Sessions. Sessions completed with a payment still pending, such as
+// a bank transfer, are ignored until the asynchronous outcome arrives.
+func (p *StripeProvider) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := verify(p.webhookSecret, payload, header.Get("Stripe-Signature"), time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object stripeSession `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	session := event.Data.Object
+
+	result := &Event{
+		ID:          event.ID,
+		CheckoutID:  session.ID,
+		Reference:   session.ClientReferenceID,
+		AmountCents: session.AmountTotal,
+	}
+	switch event.Type {
+	case "checkout.session.completed":
+		if session.PaymentStatus != "paid" && session.PaymentStatus != "no_payment_required" {
+			return nil, ErrUnhandledEvent
+		}
+		result.Type = EventPaid
+	case "checkout.session.async_payment_succeeded":
+		result.Type = EventPaid
+	case "checkout.session.async_payment_failed", "checkout.session.expired":
+		result.Type = EventFailed
+	default:
+		return nil, ErrUnhandledEvent
+	}
+	return result, nil
+}
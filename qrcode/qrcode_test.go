@@ -0,0 +1,87 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" at version 1-M, from the worked example of the standard
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := reedSolomon(data, 10); !bytes.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	formats := map[int]int{
+		0: 0b101010000010010,
+		3: 0b101101101001011,
+		7: 0b100101010100000,
+	}
+	for mask, want := range formats {
+		if got := formatBits(mask); got != want {
+			t.Errorf("Expected format bits %015b for mask %d, got %015b", want, mask, got)
+		}
+	}
+
+	if got, want := versionBits(7), 0x07C94; got != want {
+		t.Errorf("Expected version bits %#x, got %#x", want, got)
+	}
+	if got, want := versionBits(10), 0x0A4D3; got != want {
+		t.Errorf("Expected version bits %#x, got %#x", want, got)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		size int
+	}{
+		{"Short", "hello", 21},
+		{"Check-in token", "checkin:123456|1700000000." + strings.Repeat("a", 64), 41},
+		{"Largest", strings.Repeat("x", 213), 57},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode(tt.data)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if code.Size() != tt.size {
+				t.Errorf("Expected size %d, got %d", tt.size, code.Size())
+			}
+
+			// Finder pattern corners and the always dark module
+			for _, p := range [][2]int{{0, 0}, {6, 6}, {code.Size() - 1, 0}, {0, code.Size() - 1}, {8, code.Size() - 8}} {
+				if !code.Dark(p[0], p[1]) {
+					t.Errorf("Expected module %v to be dark", p)
+				}
+			}
+			if code.Dark(7, 7) {
+				t.Error("Expected the finder separator to be light")
+			}
+		})
+	}
+
+	if _, err := Encode(strings.Repeat("x", 214)); err != ErrTooLong {
+		t.Errorf("Expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	code, err := Encode("hello")
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	svg := code.SVG()
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `viewBox="0 0 29 29"`) {
+		t.Errorf("Unexpected SVG: %s", svg)
+	}
+}
@@ -51,6 +51,29 @@ func TruncateTables(t *testing.T, db *sql.DB, tables ...string) {
 		"events":      true,
 		"instructors": true,
 		"questions":   true,
+
+		"instructor_availability": true,
+		"closures":                true,
+		"services":                true,
+		"service_instructors":     true,
+		"waitlist_entries":        true,
+		"booking_series":          true,
+		"cancellation_policies":   true,
+		"class_templates":         true,
+		"class_sessions":          true,
+		"class_enrollments":       true,
+		"resources":               true,
+		"service_resources":       true,
+		"booking_resources":       true,
+		"locations":               true,
+		"booking_limits":          true,
+		"booking_settings":        true,
+		"plans":                   true,
+		"subscriptions":           true,
+		"subscription_freezes":    true,
+		"access_ledger":           true,
+		"access_wallets":          true,
+		"payments":                true,
 	}
 
 	for _, table := range tables {
@@ -92,24 +115,169 @@ func CreateTestSchema(t *testing.T, db *sql.DB) {
 			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 		);
 
+		CREATE TABLE IF NOT EXISTS locations (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			address VARCHAR(255) NOT NULL DEFAULT '',
+			time_zone VARCHAR(64) NOT NULL DEFAULT 'Europe/Rome',
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		INSERT INTO locations (id, name) VALUES (1, 'Sede principale') ON CONFLICT (id) DO NOTHING;
+		SELECT setval('locations_id_seq', (SELECT MAX(id) FROM locations));
+
 		CREATE TABLE IF NOT EXISTS instructors (
 			id SERIAL PRIMARY KEY,
 			first_name VARCHAR(255) NOT NULL,
 			last_name VARCHAR(255) NOT NULL,
 			max_slots INTEGER NOT NULL DEFAULT 2,
 			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			location_id INTEGER NOT NULL DEFAULT 1 REFERENCES locations(id),
+			archived_at TIMESTAMPTZ,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 		);
 
+		CREATE TABLE IF NOT EXISTS instructor_availability (
+			id SERIAL PRIMARY KEY,
+			instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+			weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+			start_time TIME NOT NULL,
+			end_time TIME NOT NULL,
+			CHECK (end_time > start_time)
+		);
+
+		CREATE TABLE IF NOT EXISTS closures (
+			id SERIAL PRIMARY KEY,
+			instructor_id INTEGER REFERENCES instructors(id) ON DELETE CASCADE,
+			location_id INTEGER REFERENCES locations(id) ON DELETE CASCADE,
+			starts_on DATE NOT NULL,
+			ends_on DATE NOT NULL,
+			reason VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CHECK (ends_on >= starts_on)
+		);
+
+		CREATE TABLE IF NOT EXISTS services (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0 AND duration_minutes <= 1440),
+			capacity_weight INTEGER NOT NULL DEFAULT 1 CHECK (capacity_weight > 0),
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS service_instructors (
+			service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+			instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+			PRIMARY KEY (service_id, instructor_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS booking_series (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+			service_id INTEGER REFERENCES services(id) ON DELETE SET NULL,
+			starts_at TIMESTAMPTZ NOT NULL,
+			until TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CHECK (until >= starts_at)
+		);
+
 		CREATE TABLE IF NOT EXISTS bookings (
 			id BIGSERIAL PRIMARY KEY,
 			user_id VARCHAR(255) REFERENCES users(id) ON DELETE CASCADE,
-			instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+			instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE RESTRICT,
 			starts_at TIMESTAMPTZ NOT NULL,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			type VARCHAR(20) NOT NULL DEFAULT 'SIMPLE',
-			CONSTRAINT unique_user_instructor_time UNIQUE (user_id, instructor_id, starts_at)
+			service_id INTEGER REFERENCES services(id) ON DELETE SET NULL,
+			duration_minutes INTEGER NOT NULL DEFAULT 60,
+			capacity_weight INTEGER NOT NULL DEFAULT 1 CHECK (capacity_weight > 0),
+			series_id BIGINT REFERENCES booking_series(id) ON DELETE SET NULL,
+			attendance VARCHAR(20),
+			attendance_marked_at TIMESTAMPTZ,
+			location_id INTEGER NOT NULL DEFAULT 1 REFERENCES locations(id),
+			status VARCHAR(20) NOT NULL DEFAULT 'confirmed',
+			cancelled_at TIMESTAMPTZ,
+			cancelled_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+			refunded BOOLEAN,
+			late_cancel BOOLEAN NOT NULL DEFAULT FALSE,
+			CONSTRAINT bookings_cancelled_status
+				CHECK ((cancelled_at IS NOT NULL) = (status IN ('cancelled_by_user', 'cancelled_by_admin')))
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_bookings_active_user_instructor_time
+			ON bookings(user_id, instructor_id, starts_at) WHERE cancelled_at IS NULL;
+
+		CREATE TABLE IF NOT EXISTS resources (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			kind VARCHAR(20) NOT NULL DEFAULT 'ROOM',
+			capacity INTEGER NOT NULL DEFAULT 1 CHECK (capacity > 0),
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS service_resources (
+			service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+			resource_id INTEGER NOT NULL REFERENCES resources(id) ON DELETE CASCADE,
+			quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+			PRIMARY KEY (service_id, resource_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS booking_resources (
+			booking_id BIGINT NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+			resource_id INTEGER NOT NULL REFERENCES resources(id) ON DELETE CASCADE,
+			quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+			PRIMARY KEY (booking_id, resource_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS waitlist_entries (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+			service_id INTEGER REFERENCES services(id) ON DELETE CASCADE,
+			duration_minutes INTEGER NOT NULL DEFAULT 60,
+			starts_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT unique_waitlist_user_instructor_time UNIQUE (user_id, instructor_id, starts_at)
+		);
+
+		CREATE TABLE IF NOT EXISTS cancellation_policies (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			sub_type VARCHAR(50) UNIQUE,
+			cutoff_hours INTEGER NOT NULL DEFAULT 3,
+			late_refund VARCHAR(10) NOT NULL DEFAULT 'NONE',
+			free_late_cancels INTEGER NOT NULL DEFAULT 0,
+			unmarked_attendance VARCHAR(20) NOT NULL DEFAULT 'ATTENDED',
+			max_no_shows INTEGER NOT NULL DEFAULT 0,
+			no_show_window_days INTEGER NOT NULL DEFAULT 30,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS booking_limits (
+			sub_type VARCHAR(50) PRIMARY KEY,
+			max_per_day INTEGER NOT NULL DEFAULT 0,
+			max_per_week INTEGER NOT NULL DEFAULT 0,
+			max_open INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS booking_settings (
+			id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+			horizon_days INTEGER NOT NULL DEFAULT 30,
+			lead_time_minutes INTEGER NOT NULL DEFAULT 240,
+			release_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			release_weekday SMALLINT NOT NULL DEFAULT 0,
+			release_time INTEGER NOT NULL DEFAULT 1080,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 		);
 
 		CREATE TABLE IF NOT EXISTS events (
@@ -117,9 +285,133 @@ func CreateTestSchema(t *testing.T, db *sql.DB) {
 			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
 			starts_at TIMESTAMPTZ NOT NULL,
 			type VARCHAR(50) NOT NULL,
-			occurred_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			occurred_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			refunded BOOLEAN,
+			late_cancel BOOLEAN NOT NULL DEFAULT FALSE,
+			policy_id INTEGER REFERENCES cancellation_policies(id) ON DELETE SET NULL,
+			policy_reason TEXT
+		);
+
+		CREATE TABLE IF NOT EXISTS plans (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			duration_days INTEGER NOT NULL CHECK (duration_days > 0 AND duration_days <= 1095),
+			accesses INTEGER NOT NULL CHECK (accesses >= 0),
+			sub_type VARCHAR(50) NOT NULL DEFAULT 'SHARED' CHECK (sub_type IN ('SHARED', 'SINGLE')),
+			price_cents INTEGER NOT NULL DEFAULT 0 CHECK (price_cents >= 0),
+			policy_id INTEGER REFERENCES cancellation_policies(id) ON DELETE SET NULL,
+			max_freeze_days INTEGER NOT NULL DEFAULT 0 CHECK (max_freeze_days >= 0 AND max_freeze_days <= 365),
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 		);
 
+		CREATE TABLE IF NOT EXISTS subscriptions (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			plan_id INTEGER REFERENCES plans(id) ON DELETE RESTRICT,
+			plan_name VARCHAR(255) NOT NULL,
+			sub_type VARCHAR(50) NOT NULL,
+			kind VARCHAR(20) NOT NULL CHECK (kind IN ('PURCHASE', 'RENEWAL')),
+			starts_on DATE NOT NULL,
+			ends_on DATE NOT NULL,
+			accesses INTEGER NOT NULL CHECK (accesses >= 0),
+			price_cents INTEGER NOT NULL DEFAULT 0,
+			max_freeze_days INTEGER NOT NULL DEFAULT 0,
+			created_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CHECK (ends_on >= starts_on)
+		);
+
+		CREATE TABLE IF NOT EXISTS subscription_freezes (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+			starts_on DATE NOT NULL,
+			ends_on DATE NOT NULL,
+			reason TEXT NOT NULL,
+			created_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CHECK (ends_on >= starts_on)
+		);
+
+		CREATE TABLE IF NOT EXISTS payments (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			plan_id INTEGER NOT NULL REFERENCES plans(id) ON DELETE RESTRICT,
+			amount_cents INTEGER NOT NULL CHECK (amount_cents > 0),
+			currency VARCHAR(3) NOT NULL,
+			provider VARCHAR(50) NOT NULL,
+			checkout_id VARCHAR(255),
+			status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'PAID', 'FAILED')),
+			subscription_id INTEGER REFERENCES subscriptions(id) ON DELETE SET NULL,
+			paid_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (provider, checkout_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS access_ledger (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			delta INTEGER NOT NULL CHECK (delta <> 0),
+			reason VARCHAR(20) NOT NULL
+				CHECK (reason IN ('OPENING', 'PLAN', 'EXPIRY', 'BOOKING', 'REFUND', 'CLASS', 'CLASS_REFUND', 'ADJUSTMENT', 'TOP_UP')),
+			service_id INTEGER REFERENCES services(id),
+			booking_id BIGINT REFERENCES bookings(id) ON DELETE SET NULL,
+			actor_id VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS access_wallets (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			service_id INTEGER NOT NULL REFERENCES services(id),
+			remaining INTEGER NOT NULL DEFAULT 0 CHECK (remaining >= 0),
+			expires_on DATE NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (user_id, service_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS class_templates (
+			id SERIAL PRIMARY KEY,
+			title VARCHAR(255) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			room VARCHAR(255) NOT NULL DEFAULT '',
+			resource_id INTEGER REFERENCES resources(id) ON DELETE SET NULL,
+			instructor_id INTEGER REFERENCES instructors(id) ON DELETE SET NULL,
+			capacity INTEGER NOT NULL,
+			duration_minutes INTEGER NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS class_sessions (
+			id BIGSERIAL PRIMARY KEY,
+			template_id INTEGER NOT NULL REFERENCES class_templates(id) ON DELETE CASCADE,
+			instructor_id INTEGER NOT NULL REFERENCES instructors(id) ON DELETE RESTRICT,
+			room VARCHAR(255) NOT NULL DEFAULT '',
+			resource_id INTEGER REFERENCES resources(id) ON DELETE SET NULL,
+			capacity INTEGER NOT NULL,
+			starts_at TIMESTAMPTZ NOT NULL,
+			duration_minutes INTEGER NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS class_enrollments (
+			id BIGSERIAL PRIMARY KEY,
+			session_id BIGINT NOT NULL REFERENCES class_sessions(id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			cancelled_at TIMESTAMPTZ,
+			refunded BOOLEAN,
+			late_cancel BOOLEAN NOT NULL DEFAULT FALSE
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_class_enrollments_active ON class_enrollments(session_id, user_id)
+			WHERE cancelled_at IS NULL;
+
 		CREATE TABLE IF NOT EXISTS sessions (
 			token VARCHAR(255) PRIMARY KEY,
 			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -149,7 +441,7 @@ func CreateTestSchema(t *testing.T, db *sql.DB) {
 
 // DropTestSchema drops all test tables
 func DropTestSchema(t *testing.T, db *sql.DB) {
-	tables := []string{"questions", "sessions", "bookings", "events", "instructors", "users"}
+	tables := []string{"questions", "sessions", "access_wallets", "access_ledger", "subscription_freezes", "payments", "subscriptions", "plans", "class_enrollments", "class_sessions", "class_templates", "waitlist_entries", "booking_resources", "service_resources", "resources", "bookings", "booking_series", "events", "cancellation_policies", "booking_limits", "booking_settings", "service_instructors", "services", "closures", "instructor_availability", "instructors", "locations", "users"}
 
 	for _, table := range tables {
 		_, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", table))
@@ -19,7 +19,7 @@ type SentEmail struct {
 	To      string
 	Subject string
 	Data    mail.EmailData
-	Type    string // "generic", "welcome", "reset", "new_booking", "delete_booking", "reminder"
+	Type    string // "generic", "welcome", "reset", "new_booking", "delete_booking", "reminder", "waitlist_promotion"
 }
 
 // NewMockMailer creates a new mock mailer
@@ -93,7 +93,7 @@ func (m *MockMailer) SendResetEmail(email, firstName, verificationURL string) er
 }
 
 // SendNewBookingNotification records a new booking notification
-func (m *MockMailer) SendNewBookingNotification(firstName, lastName string, startsAt time.Time) error {
+func (m *MockMailer) SendNewBookingNotification(firstName, lastName string, startsAt time.Time, timeZone string) error {
 	if m.Error != nil {
 		return m.Error
 	}
@@ -114,7 +114,7 @@ func (m *MockMailer) SendNewBookingNotification(firstName, lastName string, star
 }
 
 // SendDeleteBookingNotification records a booking deletion notification
-func (m *MockMailer) SendDeleteBookingNotification(firstName, lastName string, startsAt time.Time) error {
+func (m *MockMailer) SendDeleteBookingNotification(firstName, lastName string, startsAt time.Time, timeZone string) error {
 	if m.Error != nil {
 		return m.Error
 	}
@@ -135,7 +135,7 @@ func (m *MockMailer) SendDeleteBookingNotification(firstName, lastName string, s
 }
 
 // SendReminderEmail records a reminder email
-func (m *MockMailer) SendReminderEmail(email, firstName string, startsAt time.Time) error {
+func (m *MockMailer) SendReminderEmail(email, firstName string, startsAt time.Time, timeZone string) error {
 	if m.Error != nil {
 		return m.Error
 	}
@@ -155,6 +155,50 @@ func (m *MockMailer) SendReminderEmail(email, firstName string, startsAt time.Ti
 	return nil
 }
 
+// SendWaitlistPromotionEmail records a waitlist promotion email
+func (m *MockMailer) SendWaitlistPromotionEmail(email, firstName string, startsAt time.Time, timeZone, dashboardURL string) error {
+	if m.Error != nil {
+		return m.Error
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Emails = append(m.Emails, SentEmail{
+		To:      email,
+		Subject: "Prenotazione confermata dalla lista d'attesa",
+		Data: mail.EmailData{
+			Name:       firstName,
+			ButtonLink: dashboardURL,
+		},
+		Type: "waitlist_promotion",
+	})
+
+	return nil
+}
+
+// SendSlotCancelledEmail records the email sent when a blocked slot cancels a booking
+func (m *MockMailer) SendSlotCancelledEmail(email, firstName string, startsAt time.Time, timeZone, rebookURL string) error {
+	if m.Error != nil {
+		return m.Error
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Emails = append(m.Emails, SentEmail{
+		To:      email,
+		Subject: "Prenotazione cancellata",
+		Data: mail.EmailData{
+			Name:       firstName,
+			ButtonLink: rebookURL,
+		},
+		Type: "slot_cancelled",
+	})
+
+	return nil
+}
+
 // Reset clears all recorded emails
 func (m *MockMailer) Reset() {
 	m.mu.Lock()
@@ -30,6 +30,9 @@ type Client struct {
 
 	// Buffered channel of outbound messages
 	send chan []byte
+
+	// The member the connection belongs to, empty for admins
+	userID string
 }
 
 // readPump pumps messages from the websocket connection to the hub
@@ -28,6 +28,16 @@ var upgrader = websocket.Upgrader{
 
 // ServeWs handles websocket requests from the peer
 func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
+	serveWs(hub, "", w, r)
+}
+
+// ServeUserWs handles websocket requests of a member, who only receives the
+// notifications sent to them
+func ServeUserWs(hub *Hub, userID string, w http.ResponseWriter, r *http.Request) {
+	serveWs(hub, userID, w, r)
+}
+
+func serveWs(hub *Hub, userID string, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("websocket upgrade error: %v", err)
@@ -35,9 +45,10 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &Client{
-		hub:  hub,
-		conn: conn,
-		send: make(chan []byte, 256),
+		hub:    hub,
+		conn:   conn,
+		send:   make(chan []byte, 256),
+		userID: userID,
 	}
 
 	client.hub.register <- client
@@ -14,6 +14,10 @@ type NotificationType string
 const (
 	NotificationBookingCreated NotificationType = "booking_created"
 	NotificationBookingDeleted NotificationType = "booking_deleted"
+
+	NotificationWaitlistPromoted   NotificationType = "waitlist_promoted"
+	NotificationBookingRescheduled NotificationType = "booking_rescheduled"
+	NotificationCheckedIn          NotificationType = "checked_in"
 )
 
 // Notification represents a WebSocket notification message
@@ -23,6 +27,9 @@ type Notification struct {
 	UserName  string           `json:"userName"`
 	SlotTime  string           `json:"slotTime"`
 	Timestamp time.Time        `json:"timestamp"`
+
+	// UserID is the member the notification is for, empty for the admins
+	UserID string `json:"-"`
 }
 
 // Hub maintains active WebSocket connections and broadcasts messages
@@ -79,6 +86,9 @@ func (h *Hub) Run(ctx context.Context) {
 
 			h.mu.Lock()
 			for client := range h.clients {
+				if client.userID != notification.UserID {
+					continue
+				}
 				select {
 				case client.send <- data:
 				default:
@@ -104,7 +114,8 @@ func (h *Hub) Unregister(client *Client) {
 	h.unregister <- client
 }
 
-// Broadcast sends a notification to all connected clients
+// Broadcast sends a notification to the connected admins, or to the member
+// it is for when UserID is set
 func (h *Hub) Broadcast(notification *Notification) {
 	notification.Timestamp = time.Now()
 	select {
@@ -114,7 +125,7 @@ func (h *Hub) Broadcast(notification *Notification) {
 	}
 }
 
-// BroadcastJSON sends a JSON notification to all connected clients
+// BroadcastJSON sends a JSON notification to the connected admins
 func (h *Hub) BroadcastJSON(notificationType NotificationType, message, userName, slotTime string) {
 	notification := &Notification{
 		Type:     notificationType,
@@ -125,6 +136,18 @@ func (h *Hub) BroadcastJSON(notificationType NotificationType, message, userName
 	h.Broadcast(notification)
 }
 
+// NotifyUser sends a JSON notification to the connections of one member
+func (h *Hub) NotifyUser(userID string, notificationType NotificationType, message, userName, slotTime string) {
+	notification := &Notification{
+		Type:     notificationType,
+		Message:  message,
+		UserName: userName,
+		SlotTime: slotTime,
+		UserID:   userID,
+	}
+	h.Broadcast(notification)
+}
+
 // GetClientCount returns the number of connected clients
 func (h *Hub) GetClientCount() int {
 	h.mu.RLock()